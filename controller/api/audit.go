@@ -0,0 +1,110 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/amalgam8/amalgam8/controller/audit"
+	"github.com/amalgam8/amalgam8/controller/metrics"
+	"github.com/amalgam8/amalgam8/controller/util/i18n"
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+// AuditList is used to output the results of audit log queries.
+type AuditList struct {
+	Entries []audit.Entry `json:"entries"`
+}
+
+// Audit API.
+type Audit struct {
+	store    audit.Store
+	reporter metrics.Reporter
+}
+
+// NewAudit constructs a new Audit API.
+func NewAudit(s audit.Store, r metrics.Reporter) *Audit {
+	return &Audit{
+		store:    s,
+		reporter: r,
+	}
+}
+
+// Routes returns this API's routes wrapped by the middlewares.
+func (a *Audit) Routes(middlewares ...rest.Middleware) []*rest.Route {
+
+	routes := []*rest.Route{
+		rest.Get("/v1/audit", reportMetric(a.reporter, a.list, "get_audit")),
+	}
+
+	for _, route := range routes {
+		route.Func = rest.WrapMiddlewares(middlewares, route.Func)
+	}
+
+	return routes
+}
+
+func (a *Audit) list(w rest.ResponseWriter, req *rest.Request) error {
+	namespace := GetNamespace(req)
+	query := req.URL.Query()
+
+	since, err := parseTime(query.Get("since"))
+	if err != nil {
+		i18n.RestError(w, req, http.StatusBadRequest, i18n.ErrorInvalidTimeRange)
+		return err
+	}
+
+	until, err := parseTime(query.Get("until"))
+	if err != nil {
+		i18n.RestError(w, req, http.StatusBadRequest, i18n.ErrorInvalidTimeRange)
+		return err
+	}
+
+	if !since.IsZero() && !until.IsZero() && !since.Before(until) {
+		i18n.RestError(w, req, http.StatusBadRequest, i18n.ErrorInvalidTimeRange)
+		return errors.New("invalid_time_range")
+	}
+
+	filter := audit.Filter{
+		Since:        since,
+		Until:        until,
+		Destinations: getQueries("destination", req),
+	}
+
+	entries, err := a.store.Read(namespace, filter)
+	if err != nil {
+		handleManagerError(w, req, err)
+		return err
+	}
+
+	resp := AuditList{
+		Entries: entries,
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.WriteJson(&resp)
+	return nil
+}
+
+// parseTime parses an optional RFC 3339 timestamp, returning the zero time when none is provided.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,293 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/amalgam8/amalgam8/controller/audit"
+	"github.com/amalgam8/amalgam8/controller/middleware"
+	"github.com/amalgam8/amalgam8/controller/rules"
+	"github.com/amalgam8/amalgam8/controller/util/i18n"
+	"github.com/amalgam8/amalgam8/pkg/auth"
+	"github.com/ant0ine/go-json-rest/rest"
+	"gopkg.in/yaml.v2"
+)
+
+// ExportVersion is the version of the document format of exported rules.
+const ExportVersion = "v1"
+
+// RuleExport is a document of exported rules, which can be imported into another namespace or controller.
+type RuleExport struct {
+	Version  string       `json:"version"`
+	Revision int64        `json:"revision"`
+	Exported time.Time    `json:"exported"`
+	Rules    []rules.Rule `json:"rules"`
+}
+
+// ImportResult is used to output the results of rule imports.
+type ImportResult struct {
+	rules.ImportPlan
+	DryRun   bool              `json:"dry_run"`
+	IDs      []string          `json:"ids,omitempty"`
+	Warnings []rules.RuleError `json:"warnings,omitempty"`
+}
+
+func (r *Rule) export(w rest.ResponseWriter, req *rest.Request) error {
+	ns := GetNamespace(req)
+
+	f := rules.Filter{
+		IDs:             getQueries("id", req),
+		Tags:            getQueries("tag", req),
+		Destinations:    getQueries("destination", req),
+		IncludeInactive: true,
+	}
+
+	res, err := r.manager.GetRules(ns, f)
+	if err != nil {
+		handleManagerError(w, req, err)
+		return err
+	}
+
+	doc := RuleExport{
+		Version:  ExportVersion,
+		Revision: res.Revision,
+		Exported: time.Now().UTC(),
+		Rules:    res.Rules,
+	}
+
+	if !wantsYAML(req) {
+		w.WriteHeader(http.StatusOK)
+		w.WriteJson(&doc)
+		return nil
+	}
+
+	data, err := toYAML(doc)
+	if err != nil {
+		handleManagerError(w, req, err)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/x-yaml")
+	w.WriteHeader(http.StatusOK)
+	w.(http.ResponseWriter).Write(data)
+	return nil
+}
+
+// importRules imports a rule export document in the mode provided in the request. The scope of the replace mode is
+// selected by the destination and tag parameters. With dry_run=true the changes are reported without being applied.
+func (r *Rule) importRules(w rest.ResponseWriter, req *rest.Request) error {
+	ns := GetNamespace(req)
+	query := req.URL.Query()
+
+	mode := query.Get("mode")
+	if mode == "" {
+		mode = rules.ImportFailOnConflict
+	}
+
+	doc, err := decodeExport(req)
+	if err != nil {
+		id := i18n.ErrorInvalidJSON
+		if isYAML(req) {
+			id = i18n.ErrorInvalidYAML
+		}
+		i18n.RestError(w, req, http.StatusBadRequest, id)
+		return err
+	}
+
+	if doc.Version != ExportVersion {
+		i18n.RestError(w, req, http.StatusBadRequest, i18n.ErrorUnsupportedVersion)
+		return fmt.Errorf("unsupported_version: %v", doc.Version)
+	}
+
+	if len(doc.Rules) == 0 {
+		i18n.RestError(w, req, http.StatusBadRequest, i18n.ErrorNoRulesProvided)
+		return errors.New("no_rules_provided")
+	}
+
+	for i := range doc.Rules {
+		if doc.Rules[i].Tags == nil {
+			doc.Rules[i].Tags = []string{}
+		}
+	}
+
+	scope := rules.Filter{
+		Tags:         getQueries("tag", req),
+		Destinations: getQueries("destination", req),
+	}
+
+	resp := ImportResult{
+		DryRun: query.Get("dry_run") == "true",
+	}
+
+	if resp.DryRun {
+		existing, err := r.current(ns, rules.Filter{})
+		if err != nil {
+			handleManagerError(w, req, err)
+			return err
+		}
+
+		resp.ImportPlan, err = rules.PlanImport(mode, existing, doc.Rules, scope)
+		if err != nil {
+			handleManagerError(w, req, err)
+			return err
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.WriteJson(&resp)
+		return nil
+	}
+
+	// Replacing all the rules of the namespace is as destructive as deleting them, which only admins can do
+	unscoped := len(scope.Tags) == 0 && len(scope.Destinations) == 0
+	if mode == rules.ImportReplace && unscoped && !GetRoles(req).Grants(auth.RoleAdmin) {
+		i18n.RestError(w, req, http.StatusForbidden, i18n.ErrorAuthorizationForbidden, map[string]interface{}{
+			"Role":   auth.RoleAdmin,
+			"Method": req.Method,
+			"Path":   req.URL.Path,
+		})
+		return errors.New("forbidden: unscoped replace")
+	}
+
+	plan, newRules, err := rules.Import(r.manager, ns, mode, doc.Rules, scope)
+	if err != nil {
+		handleManagerError(w, req, err)
+		return err
+	}
+
+	plan.Added = withIDs(plan.Added, newRules.IDs)
+	r.record(req, ns, audit.OperationImport, plan.Removed, plan.Added)
+
+	resp.ImportPlan = plan
+	resp.IDs = newRules.IDs
+	resp.Warnings = newRules.Warnings
+
+	w.WriteHeader(http.StatusCreated)
+	w.WriteJson(&resp)
+	return nil
+}
+
+// wantsYAML returns whether the request asks for a YAML response, by the format parameter or the Accept header.
+func wantsYAML(req *rest.Request) bool {
+	if format := req.URL.Query().Get("format"); format != "" {
+		return format == "yaml"
+	}
+
+	for _, accept := range strings.Split(req.Header.Get("Accept"), ",") {
+		if mediatype, _, err := mime.ParseMediaType(accept); err == nil && middleware.IsYAML(mediatype) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isYAML returns whether the body of the request is YAML.
+func isYAML(req *rest.Request) bool {
+	mediatype, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	return middleware.IsYAML(mediatype)
+}
+
+// decodeExport decodes a rule export document from a JSON or YAML request body.
+func decodeExport(req *rest.Request) (RuleExport, error) {
+	doc := RuleExport{}
+
+	if !isYAML(req) {
+		err := req.DecodeJsonPayload(&doc)
+		return doc, err
+	}
+
+	data, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return doc, err
+	}
+
+	data, err = fromYAML(data)
+	if err != nil {
+		return doc, err
+	}
+
+	err = json.Unmarshal(data, &doc)
+	return doc, err
+}
+
+// toYAML encodes the value as YAML, using the names of the fields in its JSON encoding.
+func toYAML(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, &rules.JSONMarshalError{Message: err.Error()}
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil, &rules.JSONMarshalError{Message: err.Error()}
+	}
+
+	return yaml.Marshal(generic)
+}
+
+// fromYAML converts a YAML document to JSON.
+func fromYAML(data []byte) ([]byte, error) {
+	var generic interface{}
+	if err := yaml.Unmarshal(data, &generic); err != nil {
+		return nil, err
+	}
+
+	generic, err := jsonValue(generic)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(generic)
+}
+
+// jsonValue converts the maps of a decoded YAML value, which may have keys of any type, to maps with string keys.
+func jsonValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			s, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid key %v", key)
+			}
+
+			value, err := jsonValue(value)
+			if err != nil {
+				return nil, err
+			}
+			m[s] = value
+		}
+		return m, nil
+	case []interface{}:
+		for i := range v {
+			value, err := jsonValue(v[i])
+			if err != nil {
+				return nil, err
+			}
+			v[i] = value
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+
+	"github.com/amalgam8/amalgam8/controller/rules"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Rule export", func() {
+
+	It("round trips through YAML", func() {
+		doc := RuleExport{
+			Version:  ExportVersion,
+			Revision: 3,
+			Rules: []rules.Rule{
+				{
+					ID:          "abc",
+					Priority:    2,
+					Tags:        []string{"canary"},
+					Destination: "reviews",
+					Match:       []byte(`{"headers":{"Cookie":".*user=jason"}}`),
+					Route:       []byte(`{"backends":[{"tags":["v2"],"weight":0.25},{"tags":["v1"]}]}`),
+				},
+			},
+		}
+
+		data, err := toYAML(doc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring("destination: reviews"))
+
+		data, err = fromYAML(data)
+		Expect(err).ToNot(HaveOccurred())
+
+		decoded := RuleExport{}
+		Expect(json.Unmarshal(data, &decoded)).To(Succeed())
+		Expect(decoded.Version).To(Equal(ExportVersion))
+		Expect(decoded.Revision).To(Equal(int64(3)))
+		Expect(decoded.Rules).To(HaveLen(1))
+		Expect(decoded.Rules[0].Tags).To(Equal([]string{"canary"}))
+		Expect(decoded.Rules[0].Match).To(MatchJSON(doc.Rules[0].Match))
+		Expect(decoded.Rules[0].Route).To(MatchJSON(doc.Rules[0].Route))
+	})
+
+	It("rejects YAML with non-string keys", func() {
+		_, err := fromYAML([]byte("rules:\n- 1: reviews\n"))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,126 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/amalgam8/amalgam8/controller/audit"
+	"github.com/amalgam8/amalgam8/controller/metrics"
+	"github.com/amalgam8/amalgam8/controller/middleware"
+	"github.com/amalgam8/amalgam8/controller/rules"
+	"github.com/amalgam8/amalgam8/pkg/auth"
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/dgrijalva/jwt-go"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type acceptingValidator struct{}
+
+func (acceptingValidator) Validate(rules.Rule) error {
+	return nil
+}
+
+var _ = Describe("Rule import", func() {
+
+	var (
+		key     = []byte("secret")
+		manager rules.Manager
+		handler http.Handler
+	)
+
+	token := func(roles ...string) string {
+		t := jwt.New(jwt.GetSigningMethod(auth.SigningAlgorithm))
+		t.Claims[auth.NamespaceClaim] = "namespace1"
+		t.Claims[auth.RolesClaim] = roles
+		signed, err := t.SignedString(key)
+		Expect(err).ToNot(HaveOccurred())
+		return signed
+	}
+
+	importRules := func(query, token string) *httptest.ResponseRecorder {
+		doc := RuleExport{
+			Version: ExportVersion,
+			Rules: []rules.Rule{
+				{Destination: "reviews", Route: []byte(`{"backends":[{"tags":["v1"]}]}`)},
+			},
+		}
+		body, err := json.Marshal(doc)
+		Expect(err).ToNot(HaveOccurred())
+
+		req, err := http.NewRequest("POST", "http://localhost/v1/rules/import?"+query, bytes.NewReader(body))
+		Expect(err).ToNot(HaveOccurred())
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	BeforeEach(func() {
+		authenticator, err := auth.NewJWTAuthenticator(key)
+		Expect(err).ToNot(HaveOccurred())
+
+		manager = rules.NewMemoryManager(acceptingValidator{})
+		_, err = manager.AddRules("namespace1", []rules.Rule{
+			{Destination: "ratings", Route: []byte(`{"backends":[{"tags":["v1"]}]}`)},
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		authMw := &middleware.AuthMiddleware{Authenticator: authenticator, Policy: middleware.DefaultPolicy}
+		router, err := rest.MakeRouter(NewRule(manager, audit.NewMemoryStore(), metrics.NewReporter()).Routes(authMw)...)
+		Expect(err).ToNot(HaveOccurred())
+
+		a := rest.NewApi()
+		a.Use(&middleware.ContextMiddleware{})
+		a.SetApp(router)
+		handler = a.MakeHandler()
+	})
+
+	It("forbids rule editors from replacing all the rules", func() {
+		w := importRules("mode=replace", token(string(auth.RoleRuleEditor)))
+		Expect(w.Code).To(Equal(http.StatusForbidden))
+
+		res, err := manager.GetRules("namespace1", rules.Filter{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Rules).To(HaveLen(1))
+		Expect(res.Rules[0].Destination).To(Equal("ratings"))
+	})
+
+	It("allows rule editors to replace the rules of a destination", func() {
+		w := importRules("mode=replace&destination=reviews", token(string(auth.RoleRuleEditor)))
+		Expect(w.Code).To(Equal(http.StatusCreated))
+
+		res, err := manager.GetRules("namespace1", rules.Filter{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Rules).To(HaveLen(2))
+	})
+
+	It("allows admins to replace all the rules", func() {
+		w := importRules("mode=replace", token(string(auth.RoleAdmin)))
+		Expect(w.Code).To(Equal(http.StatusCreated))
+
+		res, err := manager.GetRules("namespace1", rules.Filter{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Rules).To(HaveLen(1))
+		Expect(res.Rules[0].Destination).To(Equal("reviews"))
+	})
+})
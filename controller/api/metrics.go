@@ -0,0 +1,52 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/amalgam8/amalgam8/controller/metrics"
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+// Metrics exposes the metrics of the controller to Prometheus
+type Metrics struct {
+	registry *metrics.Registry
+}
+
+// NewMetrics creates struct
+func NewMetrics(registry *metrics.Registry) *Metrics {
+	return &Metrics{
+		registry: registry,
+	}
+}
+
+// Routes for metrics API
+func (m *Metrics) Routes(middlewares ...rest.Middleware) []*rest.Route {
+	routes := []*rest.Route{
+		rest.Get("/metrics", m.GetMetrics),
+	}
+
+	for _, route := range routes {
+		route.Func = rest.WrapMiddlewares(middlewares, route.Func)
+	}
+	return routes
+}
+
+// GetMetrics writes the metrics in the Prometheus text format. Scrapes are not themselves reported, so that they do
+// not skew the request metrics.
+func (m *Metrics) GetMetrics(w rest.ResponseWriter, req *rest.Request) {
+	m.registry.ServeHTTP(w.(http.ResponseWriter), req.Request)
+}
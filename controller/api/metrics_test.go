@@ -0,0 +1,82 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/amalgam8/amalgam8/controller/metrics"
+	"github.com/amalgam8/amalgam8/controller/middleware"
+	"github.com/amalgam8/amalgam8/pkg/auth"
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/dgrijalva/jwt-go"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Metrics", func() {
+
+	var (
+		key     = []byte("secret")
+		handler http.Handler
+	)
+
+	scrape := func(roles ...string) int {
+		t := jwt.New(jwt.GetSigningMethod(auth.SigningAlgorithm))
+		t.Claims[auth.NamespaceClaim] = "namespace1"
+		t.Claims[auth.RolesClaim] = roles
+		token, err := t.SignedString(key)
+		Expect(err).ToNot(HaveOccurred())
+
+		req, err := http.NewRequest("GET", "http://localhost/metrics", nil)
+		Expect(err).ToNot(HaveOccurred())
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	BeforeEach(func() {
+		authenticator, err := auth.NewJWTAuthenticator(key)
+		Expect(err).ToNot(HaveOccurred())
+
+		authMw := &middleware.AuthMiddleware{Authenticator: authenticator, Policy: middleware.DefaultPolicy}
+		router, err := rest.MakeRouter(NewMetrics(metrics.NewRegistry()).Routes(authMw)...)
+		Expect(err).ToNot(HaveOccurred())
+
+		a := rest.NewApi()
+		a.Use(&middleware.ContextMiddleware{})
+		a.SetApp(router)
+		handler = a.MakeHandler()
+	})
+
+	It("requires authentication", func() {
+		req, err := http.NewRequest("GET", "http://localhost/metrics", nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		Expect(w.Code).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("is only served to admins", func() {
+		Expect(scrape(string(auth.RoleReader))).To(Equal(http.StatusForbidden))
+		Expect(scrape(string(auth.RoleRuleEditor))).To(Equal(http.StatusForbidden))
+		Expect(scrape(string(auth.RoleAdmin))).To(Equal(http.StatusOK))
+	})
+})
@@ -0,0 +1,180 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/amalgam8/amalgam8/controller/metrics"
+	"github.com/amalgam8/amalgam8/controller/rollouts"
+	"github.com/amalgam8/amalgam8/controller/rules"
+	"github.com/amalgam8/amalgam8/controller/util/i18n"
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+// RolloutList is used to output the rollouts of a namespace.
+type RolloutList struct {
+	Rollouts []rollouts.Rollout `json:"rollouts"`
+}
+
+// Rollout API.
+type Rollout struct {
+	manager  rollouts.Manager
+	reporter metrics.Reporter
+}
+
+// NewRollout constructs a new Rollout API.
+func NewRollout(m rollouts.Manager, r metrics.Reporter) *Rollout {
+	return &Rollout{
+		manager:  m,
+		reporter: r,
+	}
+}
+
+// Routes returns this API's routes wrapped by the middlewares.
+func (r *Rollout) Routes(middlewares ...rest.Middleware) []*rest.Route {
+
+	routes := []*rest.Route{
+		rest.Post("/v1/rollouts", reportMetric(r.reporter, r.create, "add_rollout")),
+		rest.Get("/v1/rollouts", reportMetric(r.reporter, r.list, "get_rollouts")),
+		rest.Get("/v1/rollouts/#id", reportMetric(r.reporter, r.get, "get_rollout")),
+		rest.Delete("/v1/rollouts/#id", reportMetric(r.reporter, r.remove, "delete_rollout")),
+
+		rest.Post("/v1/rollouts/#id/pause", reportMetric(r.reporter, r.pause, "pause_rollout")),
+		rest.Post("/v1/rollouts/#id/resume", reportMetric(r.reporter, r.resume, "resume_rollout")),
+		rest.Post("/v1/rollouts/#id/promote", reportMetric(r.reporter, r.promote, "promote_rollout")),
+		rest.Post("/v1/rollouts/#id/abort", reportMetric(r.reporter, r.abort, "abort_rollout")),
+	}
+
+	for _, route := range routes {
+		route.Func = rest.WrapMiddlewares(middlewares, route.Func)
+	}
+
+	return routes
+}
+
+func (r *Rollout) create(w rest.ResponseWriter, req *rest.Request) error {
+	namespace := GetNamespace(req)
+
+	rollout := rollouts.Rollout{}
+	if err := req.DecodeJsonPayload(&rollout); err != nil {
+		i18n.RestError(w, req, http.StatusBadRequest, i18n.ErrorInvalidJSON)
+		return err
+	}
+
+	rollout, err := r.manager.Create(namespace, rollout)
+	if err != nil {
+		handleRolloutError(w, req, err)
+		return err
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.WriteJson(&rollout)
+	return nil
+}
+
+func (r *Rollout) list(w rest.ResponseWriter, req *rest.Request) error {
+	namespace := GetNamespace(req)
+
+	list, err := r.manager.List(namespace)
+	if err != nil {
+		handleRolloutError(w, req, err)
+		return err
+	}
+
+	resp := RolloutList{
+		Rollouts: list,
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.WriteJson(&resp)
+	return nil
+}
+
+func (r *Rollout) get(w rest.ResponseWriter, req *rest.Request) error {
+	namespace := GetNamespace(req)
+
+	rollout, err := r.manager.Get(namespace, req.PathParam("id"))
+	if err != nil {
+		handleRolloutError(w, req, err)
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.WriteJson(&rollout)
+	return nil
+}
+
+func (r *Rollout) remove(w rest.ResponseWriter, req *rest.Request) error {
+	namespace := GetNamespace(req)
+
+	if err := r.manager.Delete(namespace, req.PathParam("id")); err != nil {
+		handleRolloutError(w, req, err)
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (r *Rollout) pause(w rest.ResponseWriter, req *rest.Request) error {
+	return r.change(r.manager.Pause, w, req)
+}
+
+func (r *Rollout) resume(w rest.ResponseWriter, req *rest.Request) error {
+	return r.change(r.manager.Resume, w, req)
+}
+
+func (r *Rollout) promote(w rest.ResponseWriter, req *rest.Request) error {
+	return r.change(r.manager.Promote, w, req)
+}
+
+func (r *Rollout) abort(w rest.ResponseWriter, req *rest.Request) error {
+	return r.change(r.manager.Abort, w, req)
+}
+
+// change applies a change to the rollout identified by the request, and outputs the changed rollout.
+func (r *Rollout) change(f func(namespace, id string) (rollouts.Rollout, error), w rest.ResponseWriter,
+	req *rest.Request) error {
+	namespace := GetNamespace(req)
+
+	rollout, err := f(namespace, req.PathParam("id"))
+	if err != nil {
+		handleRolloutError(w, req, err)
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.WriteJson(&rollout)
+	return nil
+}
+
+// handleRolloutError interprets errors from the rollout manager and outputs REST error messages.
+func handleRolloutError(w rest.ResponseWriter, req *rest.Request, err error) {
+	switch e := err.(type) {
+	case *rollouts.InvalidRolloutError:
+		i18n.RestErrorDetails(w, req, http.StatusBadRequest, i18n.ErrorInvalidRollout, e.Errors)
+	case *rollouts.NotFoundError:
+		i18n.RestError(w, req, http.StatusNotFound, i18n.ErrorRolloutNotFound)
+	case *rollouts.ConflictError:
+		i18n.RestErrorDetails(w, req, http.StatusConflict, i18n.ErrorRolloutConflict, e.Message)
+	case *rules.InvalidRuleError, *rules.JSONMarshalError:
+		handleManagerError(w, req, err)
+	default:
+		logrus.WithError(e).Warn("Unknown error")
+		i18n.RestError(w, req, http.StatusInternalServerError, i18n.ErrorInternalServer)
+	}
+}
@@ -15,33 +15,65 @@
 package api
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"errors"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/amalgam8/amalgam8/controller/audit"
 	"github.com/amalgam8/amalgam8/controller/metrics"
 	"github.com/amalgam8/amalgam8/controller/rules"
+	"github.com/amalgam8/amalgam8/controller/util"
 	"github.com/amalgam8/amalgam8/controller/util/i18n"
 	"github.com/ant0ine/go-json-rest/rest"
 )
 
+const (
+	// defaultWatchTimeout is how long a watch blocks when no timeout is provided. It is kept below the default
+	// timeout of the controller client.
+	defaultWatchTimeout = 20 * time.Second
+
+	// maxWatchTimeout is the longest a watch may block.
+	maxWatchTimeout = 5 * time.Minute
+)
+
 // RuleList is used to output the results of rule queries.
 type RuleList struct {
 	Rules    []rules.Rule `json:"rules"`
 	Revision int64        `json:"revision"`
+	Continue string       `json:"continue,omitempty"`
+}
+
+// ruleFields are the fields of rules that can be selected by the fields parameter of rule queries.
+var ruleFields = map[string]bool{
+	"id":          true,
+	"priority":    true,
+	"tags":        true,
+	"destination": true,
+	"match":       true,
+	"route":       true,
+	"actions":     true,
+	"not_before":  true,
+	"not_after":   true,
 }
 
 // Rule API.
 type Rule struct {
 	manager  rules.Manager
+	audit    audit.Store
 	reporter metrics.Reporter
 }
 
-// NewRule constructs a new Rule API.
-func NewRule(m rules.Manager, r metrics.Reporter) *Rule {
+// NewRule constructs a new Rule API. Changes to rules are recorded in the audit store.
+func NewRule(m rules.Manager, a audit.Store, r metrics.Reporter) *Rule {
 	return &Rule{
 		manager:  m,
+		audit:    a,
 		reporter: r,
 	}
 }
@@ -64,6 +96,15 @@ func (r *Rule) Routes(middlewares ...rest.Middleware) []*rest.Route {
 		rest.Get("/v1/rules/actions/#destination", reportMetric(r.reporter, r.getActionDestination, "get_rule_action_destination")),
 		rest.Delete("/v1/rules/routes/#destination", reportMetric(r.reporter, r.deleteRouteDestination, "delete_rule_route_destination")),
 		rest.Delete("/v1/rules/actions/#destination", reportMetric(r.reporter, r.deleteActionDestination, "delete_rule_action_destination")),
+
+		rest.Get("/v1/rules/export", reportMetric(r.reporter, r.export, "export_rules")),
+		rest.Post("/v1/rules/import", reportMetric(r.reporter, r.importRules, "import_rules")),
+
+		rest.Get("/v1/rules/history", reportMetric(r.reporter, r.getHistory, "get_rules_history")),
+		rest.Get("/v1/rules/history/#revision", reportMetric(r.reporter, r.getSnapshot, "get_rules_history_revision")),
+		rest.Post("/v1/rules/rollback", reportMetric(r.reporter, r.rollback, "rollback_rules")),
+
+		rest.Post("/v1/rules/simulate", reportMetric(r.reporter, r.simulate, "simulate_rules")),
 	}
 
 	for _, route := range routes {
@@ -99,10 +140,14 @@ func (r *Rule) add(w rest.ResponseWriter, req *rest.Request) error {
 		return err
 	}
 
+	r.record(req, namespace, audit.OperationAdd, []rules.Rule{}, withIDs(ruleList.Rules, newRules.IDs))
+
 	resp := struct {
-		IDs []string `json:"ids"`
+		IDs      []string          `json:"ids"`
+		Warnings []rules.RuleError `json:"warnings,omitempty"`
 	}{
-		IDs: newRules.IDs,
+		IDs:      newRules.IDs,
+		Warnings: newRules.Warnings,
 	}
 
 	w.WriteHeader(http.StatusCreated)
@@ -123,21 +168,84 @@ func (r *Rule) list(w rest.ResponseWriter, req *rest.Request) error {
 		RuleType:     rules.RuleAny,
 	}
 
+	if req.URL.Query().Get("wait") == "true" {
+		if err := r.watch(namespace, w, req); err != nil {
+			return err
+		}
+	}
+
 	return r.get(namespace, filter, w, req)
 }
 
+// watch blocks until the namespace revision moves past the revision provided in the request, or the timeout
+// expires.
+func (r *Rule) watch(ns string, w rest.ResponseWriter, req *rest.Request) error {
+	query := req.URL.Query()
+
+	revision, err := parseRevision(query.Get("revision"))
+	if err != nil {
+		i18n.RestError(w, req, http.StatusBadRequest, i18n.ErrorInvalidRevision)
+		return err
+	}
+
+	timeout := defaultWatchTimeout
+	if t := query.Get("timeout"); t != "" {
+		timeout, err = time.ParseDuration(t)
+		if err != nil || timeout <= 0 {
+			i18n.RestError(w, req, http.StatusBadRequest, i18n.ErrorInvalidTimeout)
+			return errors.New("invalid_timeout")
+		}
+
+		if timeout > maxWatchTimeout {
+			timeout = maxWatchTimeout
+		}
+	}
+
+	if err := r.manager.WatchRules(ns, revision, timeout); err != nil {
+		handleManagerError(w, req, err)
+		return err
+	}
+
+	return nil
+}
+
 func (r *Rule) get(ns string, f rules.Filter, w rest.ResponseWriter, req *rest.Request) error {
+	f.IncludeInactive = includeInactive(req)
+
+	if err := pageFilter(req, &f); err != nil {
+		i18n.RestError(w, req, http.StatusBadRequest, i18n.ErrorInvalidPage)
+		return err
+	}
+
+	fields, err := getFields(req)
+	if err != nil {
+		i18n.RestError(w, req, http.StatusBadRequest, i18n.ErrorInvalidFields)
+		return err
+	}
+
 	res, err := r.manager.GetRules(ns, f)
 	if err != nil {
 		handleManagerError(w, req, err)
 		return err
 	}
 
-	resp := RuleList{
-		Rules:    res.Rules,
+	projected, err := projectRules(res.Rules, fields)
+	if err != nil {
+		handleManagerError(w, req, err)
+		return err
+	}
+
+	resp := struct {
+		Rules    interface{} `json:"rules"`
+		Revision int64       `json:"revision"`
+		Continue string      `json:"continue,omitempty"`
+	}{
+		Rules:    projected,
 		Revision: res.Revision,
+		Continue: res.Continue,
 	}
 
+	w.Header().Set("ETag", etag(res.Revision))
 	w.WriteHeader(http.StatusOK)
 	w.WriteJson(&resp)
 	return nil
@@ -164,11 +272,30 @@ func (r *Rule) update(w rest.ResponseWriter, req *rest.Request) error {
 		}
 	}
 
-	if err := r.manager.UpdateRules(namespace, ruleList.Rules); err != nil {
+	revision, err := ifMatch(req)
+	if err != nil {
+		i18n.RestError(w, req, http.StatusBadRequest, i18n.ErrorInvalidRevision)
+		return err
+	}
+
+	ids := make([]string, len(ruleList.Rules))
+	for i, rule := range ruleList.Rules {
+		ids[i] = rule.ID
+	}
+
+	before, err := r.current(namespace, rules.Filter{IDs: ids})
+	if err != nil {
 		handleManagerError(w, req, err)
 		return err
 	}
 
+	if err := r.manager.UpdateRules(namespace, ruleList.Rules, revision); err != nil {
+		handleManagerError(w, req, err)
+		return err
+	}
+
+	r.record(req, namespace, audit.OperationUpdate, before, ruleList.Rules)
+
 	w.WriteHeader(http.StatusOK)
 	return nil
 }
@@ -188,10 +315,22 @@ func (r *Rule) getByRuleType(ruleType int, w rest.ResponseWriter, req *rest.Requ
 	destinations := getQueries("destination", req)
 
 	filter := rules.Filter{
-		IDs:          ruleIDs,
-		Tags:         tags,
-		Destinations: destinations,
-		RuleType:     ruleType,
+		IDs:             ruleIDs,
+		Tags:            tags,
+		Destinations:    destinations,
+		RuleType:        ruleType,
+		IncludeInactive: includeInactive(req),
+	}
+
+	if err := pageFilter(req, &filter); err != nil {
+		i18n.RestError(w, req, http.StatusBadRequest, i18n.ErrorInvalidPage)
+		return err
+	}
+
+	fields, err := getFields(req)
+	if err != nil {
+		i18n.RestError(w, req, http.StatusBadRequest, i18n.ErrorInvalidFields)
+		return err
 	}
 
 	retrievedRules, err := r.manager.GetRules(namespace, filter)
@@ -201,9 +340,11 @@ func (r *Rule) getByRuleType(ruleType int, w rest.ResponseWriter, req *rest.Requ
 	}
 
 	respJSON := struct {
-		Services map[string][]rules.Rule `json:"services"`
+		Services map[string]interface{} `json:"services"`
+		Continue string                 `json:"continue,omitempty"`
 	}{
-		Services: make(map[string][]rules.Rule),
+		Services: make(map[string]interface{}),
+		Continue: retrievedRules.Continue,
 	}
 
 	services := make(map[string][]rules.Rule)
@@ -218,8 +359,16 @@ func (r *Rule) getByRuleType(ruleType int, w rest.ResponseWriter, req *rest.Requ
 		}
 	}
 
-	respJSON.Services = services
+	for destination, rs := range services {
+		projected, err := projectRules(rs, fields)
+		if err != nil {
+			handleManagerError(w, req, err)
+			return err
+		}
+		respJSON.Services[destination] = projected
+	}
 
+	w.Header().Set("ETag", etag(retrievedRules.Revision))
 	w.WriteHeader(http.StatusOK)
 	w.WriteJson(&respJSON)
 
@@ -227,11 +376,25 @@ func (r *Rule) getByRuleType(ruleType int, w rest.ResponseWriter, req *rest.Requ
 }
 
 func (r *Rule) delete(ns string, f rules.Filter, w rest.ResponseWriter, req *rest.Request) error {
-	if err := r.manager.DeleteRules(ns, f); err != nil {
+	revision, err := ifMatch(req)
+	if err != nil {
+		i18n.RestError(w, req, http.StatusBadRequest, i18n.ErrorInvalidRevision)
+		return err
+	}
+
+	before, err := r.current(ns, f)
+	if err != nil {
+		handleManagerError(w, req, err)
+		return err
+	}
+
+	if err := r.manager.DeleteRules(ns, f, revision); err != nil {
 		handleManagerError(w, req, err)
 		return err
 	}
 
+	r.record(req, ns, audit.OperationDelete, before, []rules.Rule{})
+
 	w.WriteHeader(http.StatusOK)
 	return nil
 }
@@ -264,16 +427,32 @@ func (r *Rule) set(ns string, f rules.Filter, w rest.ResponseWriter, req *rest.R
 		}
 	}
 
-	newRules, err := r.manager.SetRules(ns, f, ruleList.Rules)
+	revision, err := ifMatch(req)
+	if err != nil {
+		i18n.RestError(w, req, http.StatusBadRequest, i18n.ErrorInvalidRevision)
+		return err
+	}
+
+	before, err := r.current(ns, f)
 	if err != nil {
 		handleManagerError(w, req, err)
 		return err
 	}
 
+	newRules, err := r.manager.SetRules(ns, f, ruleList.Rules, revision)
+	if err != nil {
+		handleManagerError(w, req, err)
+		return err
+	}
+
+	r.record(req, ns, audit.OperationSet, before, withIDs(ruleList.Rules, newRules.IDs))
+
 	resp := struct {
-		IDs []string `json:"ids"`
+		IDs      []string          `json:"ids"`
+		Warnings []rules.RuleError `json:"warnings,omitempty"`
 	}{
-		IDs: newRules.IDs,
+		IDs:      newRules.IDs,
+		Warnings: newRules.Warnings,
 	}
 
 	w.WriteHeader(http.StatusCreated)
@@ -353,6 +532,264 @@ func (r *Rule) deleteActionDestination(w rest.ResponseWriter, req *rest.Request)
 	return r.delete(ns, f, w, req)
 }
 
+func (r *Rule) getHistory(w rest.ResponseWriter, req *rest.Request) error {
+	ns := GetNamespace(req)
+
+	summaries, err := r.manager.GetHistory(ns)
+	if err != nil {
+		handleManagerError(w, req, err)
+		return err
+	}
+
+	resp := struct {
+		Revisions []rules.RevisionSummary `json:"revisions"`
+	}{
+		Revisions: summaries,
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.WriteJson(&resp)
+	return nil
+}
+
+func (r *Rule) getSnapshot(w rest.ResponseWriter, req *rest.Request) error {
+	ns := GetNamespace(req)
+
+	revision, err := parseRevision(req.PathParam("revision"))
+	if err != nil {
+		i18n.RestError(w, req, http.StatusBadRequest, i18n.ErrorInvalidRevision)
+		return err
+	}
+
+	snapshot, err := r.manager.GetSnapshot(ns, revision)
+	if err != nil {
+		handleManagerError(w, req, err)
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.WriteJson(&snapshot)
+	return nil
+}
+
+func (r *Rule) rollback(w rest.ResponseWriter, req *rest.Request) error {
+	ns := GetNamespace(req)
+
+	revision, err := parseRevision(req.URL.Query().Get("revision"))
+	if err != nil {
+		i18n.RestError(w, req, http.StatusBadRequest, i18n.ErrorInvalidRevision)
+		return err
+	}
+
+	before, err := r.current(ns, rules.Filter{})
+	if err != nil {
+		handleManagerError(w, req, err)
+		return err
+	}
+
+	if err := r.manager.Rollback(ns, revision); err != nil {
+		handleManagerError(w, req, err)
+		return err
+	}
+
+	after, err := r.current(ns, rules.Filter{})
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"namespace": ns,
+		}).Error("Could not read the rules after rollback")
+	}
+
+	r.record(req, ns, audit.OperationRollback, before, after)
+
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (r *Rule) simulate(w rest.ResponseWriter, req *rest.Request) error {
+	ns := GetNamespace(req)
+
+	simReq := rules.SimulationRequest{}
+	if err := req.DecodeJsonPayload(&simReq); err != nil {
+		i18n.RestError(w, req, http.StatusBadRequest, i18n.ErrorInvalidJSON)
+		return err
+	}
+
+	if simReq.Destination == "" {
+		i18n.RestError(w, req, http.StatusBadRequest, i18n.ErrorNoDestinationProvided)
+		return errors.New("no_destination_provided")
+	}
+
+	f := rules.Filter{
+		Destinations: []string{simReq.Destination},
+	}
+
+	retrievedRules, err := r.manager.GetRules(ns, f)
+	if err != nil {
+		handleManagerError(w, req, err)
+		return err
+	}
+
+	res, err := rules.Simulate(retrievedRules.Rules, simReq)
+	if err != nil {
+		handleManagerError(w, req, err)
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.WriteJson(&res)
+	return nil
+}
+
+// current returns the rules of the namespace that pass the filter, including rules outside of their activation
+// window, to record the state of rules before they are changed.
+func (r *Rule) current(ns string, f rules.Filter) ([]rules.Rule, error) {
+	f.IncludeInactive = true
+
+	res, err := r.manager.GetRules(ns, f)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Rules, nil
+}
+
+// record adds a change to the rules of the namespace to the audit log. The change has already been applied, so
+// failures are only logged.
+func (r *Rule) record(req *rest.Request, ns, operation string, before, after []rules.Rule) {
+	entry := audit.Entry{
+		Timestamp: time.Now(),
+		Namespace: ns,
+		Principal: GetPrincipal(req),
+		RequestID: req.Header.Get(util.RequestIDHeader),
+		Operation: operation,
+		Before:    before,
+		After:     after,
+	}
+
+	if err := r.audit.Append(ns, entry); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"namespace":  ns,
+			"operation":  operation,
+			"request_id": entry.RequestID,
+		}).Error("Could not record the change of rules in the audit log")
+	}
+}
+
+// withIDs returns the rules with the IDs assigned to them by the manager.
+func withIDs(rs []rules.Rule, ids []string) []rules.Rule {
+	res := make([]rules.Rule, len(rs))
+	for i, rule := range rs {
+		if i < len(ids) {
+			rule.ID = ids[i]
+		}
+		res[i] = rule
+	}
+	return res
+}
+
+// includeInactive returns whether the request asks for rules outside of their activation window.
+func includeInactive(req *rest.Request) bool {
+	return req.URL.Query().Get("include_inactive") == "true"
+}
+
+// pageFilter sets the sort order, limit and continue cursor in the query of the request on the filter.
+func pageFilter(req *rest.Request, f *rules.Filter) error {
+	query := req.URL.Query()
+
+	f.SortBy = query.Get("sort")
+	if f.SortBy != "" && f.SortBy != rules.SortID && f.SortBy != rules.SortPriority {
+		return errors.New("invalid_sort")
+	}
+
+	f.Continue = query.Get("continue")
+
+	if l := query.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit <= 0 {
+			return errors.New("invalid_limit")
+		}
+		f.Limit = limit
+	}
+
+	return nil
+}
+
+// getFields returns the fields of rules selected by the fields parameters of the request, which are comma separated
+// lists of field names. Returns nil if no fields are selected.
+func getFields(req *rest.Request) ([]string, error) {
+	var fields []string
+	for _, value := range getQueries("fields", req) {
+		for _, field := range strings.Split(value, ",") {
+			field = strings.TrimSpace(field)
+			if !ruleFields[field] {
+				return nil, fmt.Errorf("invalid_field: %v", field)
+			}
+			fields = append(fields, field)
+		}
+	}
+
+	return fields, nil
+}
+
+// projectRules returns the rules with only the selected fields, or the rules unchanged when no fields are selected.
+func projectRules(rs []rules.Rule, fields []string) (interface{}, error) {
+	if len(fields) == 0 {
+		return rs, nil
+	}
+
+	projected := make([]map[string]json.RawMessage, len(rs))
+	for i, rule := range rs {
+		data, err := json.Marshal(&rule)
+		if err != nil {
+			return nil, &rules.JSONMarshalError{Message: err.Error()}
+		}
+
+		all := make(map[string]json.RawMessage)
+		if err := json.Unmarshal(data, &all); err != nil {
+			return nil, &rules.JSONMarshalError{Message: err.Error()}
+		}
+
+		projected[i] = make(map[string]json.RawMessage, len(fields))
+		for _, field := range fields {
+			if value, ok := all[field]; ok {
+				projected[i][field] = value
+			}
+		}
+	}
+
+	return projected, nil
+}
+
+// ifMatch returns the revision in the If-Match header of the request, which changes to the rules are conditional on,
+// or AnyRevision if the header is missing or "*".
+func ifMatch(req *rest.Request) (int64, error) {
+	value := strings.TrimSpace(req.Header.Get("If-Match"))
+	if value == "" || value == "*" {
+		return rules.AnyRevision, nil
+	}
+
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return 0, errors.New("invalid_revision")
+	}
+
+	return parseRevision(value[1 : len(value)-1])
+}
+
+// etag returns the entity tag of the rules of a namespace at a revision.
+func etag(revision int64) string {
+	return fmt.Sprintf(`"%v"`, revision)
+}
+
+// parseRevision parses a non-negative rule revision.
+func parseRevision(s string) (int64, error) {
+	revision, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || revision < 0 {
+		return 0, errors.New("invalid_revision")
+	}
+
+	return revision, nil
+}
+
 func getQueries(key string, req *rest.Request) []string {
 	queries := req.URL.Query()
 	values, ok := queries[key]
@@ -366,7 +803,17 @@ func getQueries(key string, req *rest.Request) []string {
 func handleManagerError(w rest.ResponseWriter, req *rest.Request, err error, args ...interface{}) {
 	switch e := err.(type) {
 	case *rules.InvalidRuleError:
-		i18n.RestError(w, req, http.StatusBadRequest, i18n.ErrorInvalidRule, args)
+		i18n.RestErrorDetails(w, req, http.StatusBadRequest, i18n.ErrorInvalidRule, e.Errors, args)
+	case *rules.RevisionNotFoundError:
+		i18n.RestError(w, req, http.StatusNotFound, i18n.ErrorRevisionNotFound, args)
+	case *rules.InvalidPageError:
+		i18n.RestError(w, req, http.StatusBadRequest, i18n.ErrorInvalidPage, args)
+	case *rules.RevisionMismatchError:
+		i18n.RestError(w, req, http.StatusPreconditionFailed, i18n.ErrorRevisionMismatch, args)
+	case *rules.ImportConflictError:
+		i18n.RestErrorDetails(w, req, http.StatusConflict, i18n.ErrorImportConflict, e.Conflicts, args)
+	case *rules.InvalidImportModeError:
+		i18n.RestError(w, req, http.StatusBadRequest, i18n.ErrorInvalidImportMode, args)
 	case *rules.JSONMarshalError:
 		i18n.RestError(w, req, http.StatusInternalServerError, i18n.ErrorInternalServer, args)
 	default:
@@ -0,0 +1,265 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/amalgam8/amalgam8/controller/metrics"
+	"github.com/amalgam8/amalgam8/controller/rules"
+	"github.com/amalgam8/amalgam8/controller/templates"
+	"github.com/amalgam8/amalgam8/controller/util/i18n"
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+// TemplateList is used to output the templates of a namespace.
+type TemplateList struct {
+	Templates []templates.Template `json:"templates"`
+}
+
+// BindingList is used to output the bindings of a namespace.
+type BindingList struct {
+	Bindings []templates.Binding `json:"bindings"`
+}
+
+// Template API.
+type Template struct {
+	manager  templates.Manager
+	reporter metrics.Reporter
+}
+
+// NewTemplate constructs a new Template API.
+func NewTemplate(m templates.Manager, r metrics.Reporter) *Template {
+	return &Template{
+		manager:  m,
+		reporter: r,
+	}
+}
+
+// Routes returns this API's routes wrapped by the middlewares.
+func (t *Template) Routes(middlewares ...rest.Middleware) []*rest.Route {
+
+	routes := []*rest.Route{
+		rest.Post("/v1/templates", reportMetric(t.reporter, t.createTemplate, "add_template")),
+		rest.Get("/v1/templates", reportMetric(t.reporter, t.listTemplates, "get_templates")),
+		rest.Get("/v1/templates/#id", reportMetric(t.reporter, t.getTemplate, "get_template")),
+		rest.Put("/v1/templates/#id", reportMetric(t.reporter, t.updateTemplate, "update_template")),
+		rest.Delete("/v1/templates/#id", reportMetric(t.reporter, t.removeTemplate, "delete_template")),
+
+		rest.Post("/v1/bindings", reportMetric(t.reporter, t.createBinding, "add_binding")),
+		rest.Get("/v1/bindings", reportMetric(t.reporter, t.listBindings, "get_bindings")),
+		rest.Get("/v1/bindings/#id", reportMetric(t.reporter, t.getBinding, "get_binding")),
+		rest.Put("/v1/bindings/#id", reportMetric(t.reporter, t.updateBinding, "update_binding")),
+		rest.Delete("/v1/bindings/#id", reportMetric(t.reporter, t.removeBinding, "delete_binding")),
+	}
+
+	for _, route := range routes {
+		route.Func = rest.WrapMiddlewares(middlewares, route.Func)
+	}
+
+	return routes
+}
+
+func (t *Template) createTemplate(w rest.ResponseWriter, req *rest.Request) error {
+	namespace := GetNamespace(req)
+
+	template := templates.Template{}
+	if err := req.DecodeJsonPayload(&template); err != nil {
+		i18n.RestError(w, req, http.StatusBadRequest, i18n.ErrorInvalidJSON)
+		return err
+	}
+
+	template, err := t.manager.CreateTemplate(namespace, template)
+	if err != nil {
+		handleTemplateError(w, req, err)
+		return err
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.WriteJson(&template)
+	return nil
+}
+
+func (t *Template) listTemplates(w rest.ResponseWriter, req *rest.Request) error {
+	namespace := GetNamespace(req)
+
+	list, err := t.manager.ListTemplates(namespace)
+	if err != nil {
+		handleTemplateError(w, req, err)
+		return err
+	}
+
+	resp := TemplateList{
+		Templates: list,
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.WriteJson(&resp)
+	return nil
+}
+
+func (t *Template) getTemplate(w rest.ResponseWriter, req *rest.Request) error {
+	namespace := GetNamespace(req)
+
+	template, err := t.manager.GetTemplate(namespace, req.PathParam("id"))
+	if err != nil {
+		handleTemplateError(w, req, err)
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.WriteJson(&template)
+	return nil
+}
+
+func (t *Template) updateTemplate(w rest.ResponseWriter, req *rest.Request) error {
+	namespace := GetNamespace(req)
+
+	template := templates.Template{}
+	if err := req.DecodeJsonPayload(&template); err != nil {
+		i18n.RestError(w, req, http.StatusBadRequest, i18n.ErrorInvalidJSON)
+		return err
+	}
+	template.ID = req.PathParam("id")
+
+	template, err := t.manager.UpdateTemplate(namespace, template)
+	if err != nil {
+		handleTemplateError(w, req, err)
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.WriteJson(&template)
+	return nil
+}
+
+func (t *Template) removeTemplate(w rest.ResponseWriter, req *rest.Request) error {
+	namespace := GetNamespace(req)
+
+	if err := t.manager.DeleteTemplate(namespace, req.PathParam("id")); err != nil {
+		handleTemplateError(w, req, err)
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (t *Template) createBinding(w rest.ResponseWriter, req *rest.Request) error {
+	namespace := GetNamespace(req)
+
+	binding := templates.Binding{}
+	if err := req.DecodeJsonPayload(&binding); err != nil {
+		i18n.RestError(w, req, http.StatusBadRequest, i18n.ErrorInvalidJSON)
+		return err
+	}
+
+	binding, err := t.manager.CreateBinding(namespace, binding)
+	if err != nil {
+		handleTemplateError(w, req, err)
+		return err
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.WriteJson(&binding)
+	return nil
+}
+
+func (t *Template) listBindings(w rest.ResponseWriter, req *rest.Request) error {
+	namespace := GetNamespace(req)
+
+	list, err := t.manager.ListBindings(namespace)
+	if err != nil {
+		handleTemplateError(w, req, err)
+		return err
+	}
+
+	resp := BindingList{
+		Bindings: list,
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.WriteJson(&resp)
+	return nil
+}
+
+func (t *Template) getBinding(w rest.ResponseWriter, req *rest.Request) error {
+	namespace := GetNamespace(req)
+
+	binding, err := t.manager.GetBinding(namespace, req.PathParam("id"))
+	if err != nil {
+		handleTemplateError(w, req, err)
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.WriteJson(&binding)
+	return nil
+}
+
+func (t *Template) updateBinding(w rest.ResponseWriter, req *rest.Request) error {
+	namespace := GetNamespace(req)
+
+	binding := templates.Binding{}
+	if err := req.DecodeJsonPayload(&binding); err != nil {
+		i18n.RestError(w, req, http.StatusBadRequest, i18n.ErrorInvalidJSON)
+		return err
+	}
+	binding.ID = req.PathParam("id")
+
+	binding, err := t.manager.UpdateBinding(namespace, binding)
+	if err != nil {
+		handleTemplateError(w, req, err)
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.WriteJson(&binding)
+	return nil
+}
+
+func (t *Template) removeBinding(w rest.ResponseWriter, req *rest.Request) error {
+	namespace := GetNamespace(req)
+
+	if err := t.manager.DeleteBinding(namespace, req.PathParam("id")); err != nil {
+		handleTemplateError(w, req, err)
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// handleTemplateError interprets errors from the template manager and outputs REST error messages.
+func handleTemplateError(w rest.ResponseWriter, req *rest.Request, err error) {
+	switch e := err.(type) {
+	case *templates.InvalidTemplateError:
+		i18n.RestErrorDetails(w, req, http.StatusBadRequest, i18n.ErrorInvalidTemplate, e.Errors)
+	case *templates.InvalidBindingError:
+		i18n.RestErrorDetails(w, req, http.StatusBadRequest, i18n.ErrorInvalidBinding, e.Errors)
+	case *templates.TemplateNotFoundError:
+		i18n.RestError(w, req, http.StatusNotFound, i18n.ErrorTemplateNotFound)
+	case *templates.BindingNotFoundError:
+		i18n.RestError(w, req, http.StatusNotFound, i18n.ErrorBindingNotFound)
+	case *templates.ConflictError:
+		i18n.RestErrorDetails(w, req, http.StatusConflict, i18n.ErrorTemplateConflict, e.Message)
+	case *rules.InvalidRuleError, *rules.JSONMarshalError:
+		handleManagerError(w, req, err)
+	default:
+		logrus.WithError(e).Warn("Unknown error")
+		i18n.RestError(w, req, http.StatusInternalServerError, i18n.ErrorInternalServer)
+	}
+}
@@ -33,6 +33,24 @@ func GetNamespace(req *rest.Request) string {
 	return ""
 }
 
+// GetPrincipal from a request
+func GetPrincipal(req *rest.Request) string {
+	if principal, ok := req.Env[util.Principal].(string); ok {
+		return principal
+	}
+
+	return ""
+}
+
+// GetRoles granted to the principal of a request
+func GetRoles(req *rest.Request) auth.Roles {
+	if roles, ok := req.Env[util.Roles].(auth.Roles); ok {
+		return roles
+	}
+
+	return auth.Roles{}
+}
+
 func reportMetric(reporter metrics.Reporter, f func(rest.ResponseWriter, *rest.Request) error, name string) rest.HandlerFunc {
 	return func(w rest.ResponseWriter, req *rest.Request) {
 		startTime := time.Now()
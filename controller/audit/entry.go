@@ -0,0 +1,98 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package audit
+
+import (
+	"time"
+
+	"github.com/amalgam8/amalgam8/controller/rules"
+)
+
+// Operations on rules recorded in the audit log.
+const (
+	OperationAdd      = "add"
+	OperationUpdate   = "update"
+	OperationDelete   = "delete"
+	OperationSet      = "set"
+	OperationRollback = "rollback"
+	OperationImport   = "import"
+)
+
+// Entry records a change to the rules of a namespace.
+type Entry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Namespace string    `json:"namespace"`
+	Principal string    `json:"principal"`
+	RequestID string    `json:"request_id"`
+	Operation string    `json:"operation"`
+
+	// Before are the changed rules before the change, and After are the changed rules after the change.
+	Before []rules.Rule `json:"before"`
+	After  []rules.Rule `json:"after"`
+}
+
+// Filter selects audit entries.
+type Filter struct {
+	// Since excludes entries before the time. This field is ignored when zero.
+	Since time.Time
+
+	// Until excludes entries at or after the time. This field is ignored when zero.
+	Until time.Time
+
+	// Destinations is the set of destinations of which at least one rule must have changed. This field is ignored
+	// when len(Destinations) <= 0.
+	Destinations []string
+}
+
+// matches returns whether the entry passes the filter.
+func (f Filter) matches(e Entry) bool {
+	if !f.Since.IsZero() && e.Timestamp.Before(f.Since) {
+		return false
+	}
+
+	if !f.Until.IsZero() && !e.Timestamp.Before(f.Until) {
+		return false
+	}
+
+	if len(f.Destinations) == 0 {
+		return true
+	}
+
+	for _, destination := range f.Destinations {
+		for _, rule := range e.Before {
+			if rule.Destination == destination {
+				return true
+			}
+		}
+		for _, rule := range e.After {
+			if rule.Destination == destination {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// FilterEntries returns the entries that pass the filter.
+func FilterEntries(f Filter, entries []Entry) []Entry {
+	res := make([]Entry, 0, len(entries))
+	for _, entry := range entries {
+		if f.matches(entry) {
+			res = append(res, entry)
+		}
+	}
+	return res
+}
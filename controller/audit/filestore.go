@@ -0,0 +1,171 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/amalgam8/amalgam8/controller/rules"
+	"github.com/amalgam8/amalgam8/controller/util/encryption"
+)
+
+// fileExtension is the extension of the files in which audit logs are stored, one JSON entry per line.
+const fileExtension = ".log"
+
+// NewFileStore creates a store that appends the entries of each namespace to a file in the directory. Entries are
+// encrypted unless the encryption is nil.
+func NewFileStore(dir string, enc encryption.Encryption) (Store, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	return &fileStore{
+		dir:        dir,
+		encryption: enc,
+	}, nil
+}
+
+type fileStore struct {
+	dir        string
+	encryption encryption.Encryption
+	mutex      sync.Mutex
+}
+
+func (f *fileStore) Append(namespace string, entry Entry) error {
+	data, err := json.Marshal(&entry)
+	if err != nil {
+		return &rules.JSONMarshalError{Message: err.Error()}
+	}
+
+	data, err = f.encrypt(data)
+	if err != nil {
+		return err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	file, err := os.OpenFile(f.path(namespace), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err = file.Write(append(data, '\n')); err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+func (f *fileStore) Read(namespace string, filter Filter) ([]Entry, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	file, err := os.Open(f.path(namespace))
+	if os.IsNotExist(err) {
+		return []Entry{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := []Entry{}
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			data, decryptErr := f.decrypt(line)
+			if decryptErr != nil {
+				return nil, decryptErr
+			}
+
+			entry := Entry{}
+			if err := json.Unmarshal(data, &entry); err != nil {
+				return nil, &rules.JSONMarshalError{Message: err.Error()}
+			}
+
+			if filter.matches(entry) {
+				entries = append(entries, entry)
+			}
+		}
+
+		if err == io.EOF {
+			return entries, nil
+		}
+	}
+}
+
+func (f *fileStore) path(namespace string) string {
+	return filepath.Join(f.dir, url.QueryEscape(namespace)+fileExtension)
+}
+
+func (f *fileStore) encrypt(data []byte) ([]byte, error) {
+	// Short-circuit without encryption
+	if f.encryption == nil {
+		return data, nil
+	}
+
+	iv := f.encryption.NewIV()
+	payload, err := f.encryption.Encrypt(iv, data)
+	if err != nil {
+		return nil, err
+	}
+
+	e := rules.Entry{
+		IV:      base64.StdEncoding.EncodeToString(iv),
+		Payload: base64.StdEncoding.EncodeToString(payload),
+	}
+
+	return json.Marshal(&e)
+}
+
+func (f *fileStore) decrypt(data []byte) ([]byte, error) {
+	// Short-circuit without encryption
+	if f.encryption == nil {
+		return data, nil
+	}
+
+	e := rules.Entry{}
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+
+	iv, err := base64.StdEncoding.DecodeString(e.IV)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(e.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.encryption.Decrypt(iv, payload)
+}
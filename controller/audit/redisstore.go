@@ -0,0 +1,77 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package audit
+
+import (
+	"fmt"
+
+	"encoding/json"
+
+	"github.com/amalgam8/amalgam8/controller/rules"
+	"github.com/garyburd/redigo/redis"
+)
+
+// NewRedisStore creates a store that appends the entries of each namespace to a Redis list, using connections from
+// the pool.
+func NewRedisStore(pool *redis.Pool) Store {
+	return &redisStore{
+		pool: pool,
+	}
+}
+
+type redisStore struct {
+	pool *redis.Pool
+}
+
+func (r *redisStore) Append(namespace string, entry Entry) error {
+	data, err := json.Marshal(&entry)
+	if err != nil {
+		return &rules.JSONMarshalError{Message: err.Error()}
+	}
+
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	_, err = conn.Do("RPUSH", buildAuditKey(namespace), data)
+	return err
+}
+
+func (r *redisStore) Read(namespace string, filter Filter) ([]Entry, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	values, err := redis.ByteSlices(conn.Do("LRANGE", buildAuditKey(namespace), 0, -1))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(values))
+	for _, value := range values {
+		entry := Entry{}
+		if err := json.Unmarshal(value, &entry); err != nil {
+			return nil, &rules.JSONMarshalError{Message: err.Error()}
+		}
+
+		if filter.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
+
+func buildAuditKey(namespace string) string {
+	return fmt.Sprintf("controller:%v:audit", namespace)
+}
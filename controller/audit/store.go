@@ -0,0 +1,61 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package audit
+
+import "sync"
+
+// memoryLimit is the number of most recent entries retained for each namespace by the memory store.
+const memoryLimit = 1000
+
+// Store persists the audit log of each namespace.
+type Store interface {
+	// Append records an entry in the audit log of the namespace.
+	Append(namespace string, entry Entry) error
+
+	// Read returns the entries of the audit log of the namespace that pass the filter, oldest first.
+	Read(namespace string, filter Filter) ([]Entry, error)
+}
+
+// NewMemoryStore creates a store that keeps the most recent entries of each namespace in memory.
+func NewMemoryStore() Store {
+	return &memoryStore{
+		entries: make(map[string][]Entry),
+	}
+}
+
+type memoryStore struct {
+	entries map[string][]Entry
+	mutex   sync.RWMutex
+}
+
+func (m *memoryStore) Append(namespace string, entry Entry) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	entries := append(m.entries[namespace], entry)
+	if len(entries) > memoryLimit {
+		entries = entries[len(entries)-memoryLimit:]
+	}
+	m.entries[namespace] = entries
+
+	return nil
+}
+
+func (m *memoryStore) Read(namespace string, filter Filter) ([]Entry, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return FilterEntries(filter, m.entries[namespace]), nil
+}
@@ -0,0 +1,133 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package audit
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/amalgam8/amalgam8/controller/rules"
+	"github.com/amalgam8/amalgam8/controller/util/encryption"
+)
+
+func TestStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	enc, err := encryption.NewAES([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fileStore, err := NewFileStore(dir, enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"file":   fileStore,
+	}
+
+	start := time.Date(2016, 11, 1, 0, 0, 0, 0, time.UTC)
+	reviews := rules.Rule{ID: "id1", Destination: "reviews"}
+	ratings := rules.Rule{ID: "id2", Destination: "ratings"}
+
+	entries := []Entry{
+		{
+			Timestamp: start,
+			Namespace: "test/namespace",
+			Principal: "admin",
+			RequestID: "request1",
+			Operation: OperationAdd,
+			Before:    []rules.Rule{},
+			After:     []rules.Rule{reviews, ratings},
+		},
+		{
+			Timestamp: start.Add(time.Hour),
+			Namespace: "test/namespace",
+			Principal: "admin",
+			RequestID: "request2",
+			Operation: OperationDelete,
+			Before:    []rules.Rule{ratings},
+			After:     []rules.Rule{},
+		},
+	}
+
+	cases := []struct {
+		Filter  Filter
+		Entries []Entry
+	}{
+		{ // Empty filter returns all entries
+			Filter:  Filter{},
+			Entries: entries,
+		},
+		{ // Filter by time range
+			Filter: Filter{
+				Since: start.Add(time.Minute),
+				Until: start.Add(2 * time.Hour),
+			},
+			Entries: entries[1:],
+		},
+		{ // Until is exclusive
+			Filter: Filter{
+				Until: start.Add(time.Hour),
+			},
+			Entries: entries[:1],
+		},
+		{ // Filter by destinations of rules before or after the change
+			Filter: Filter{
+				Destinations: []string{"ratings"},
+			},
+			Entries: entries,
+		},
+		{
+			Filter: Filter{
+				Destinations: []string{"reviews"},
+			},
+			Entries: entries[:1],
+		},
+	}
+
+	for name, store := range stores {
+		for _, entry := range entries {
+			if err := store.Append("test/namespace", entry); err != nil {
+				t.Fatalf("%v: Append: %v", name, err)
+			}
+		}
+
+		for _, c := range cases {
+			actual, err := store.Read("test/namespace", c.Filter)
+			if err != nil {
+				t.Errorf("%v: Read(%v): %v", name, c.Filter, err)
+				continue
+			}
+
+			if !reflect.DeepEqual(actual, c.Entries) {
+				t.Errorf("%v: Read(%v): expected %v, got %v", name, c.Filter, c.Entries, actual)
+			}
+		}
+
+		if actual, err := store.Read("other", Filter{}); err != nil || len(actual) != 0 {
+			t.Errorf("%v: Read of another namespace: expected no entries, got %v, %v", name, actual, err)
+		}
+	}
+}
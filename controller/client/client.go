@@ -1,13 +1,18 @@
 package client
 
 import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
-
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Sirupsen/logrus"
@@ -15,7 +20,16 @@ import (
 	"github.com/amalgam8/amalgam8/registry/server/env"
 )
 
-const defaultTimeout = 30 * time.Second
+const (
+	defaultTimeout = 30 * time.Second
+
+	defaultRetryAttempts   = 3
+	defaultRetryBackoff    = 500 * time.Millisecond
+	defaultRetryMaxBackoff = 10 * time.Second
+
+	// defaultWatchTimeout matches the default timeout of watches on the controller.
+	defaultWatchTimeout = 20 * time.Second
+)
 
 // RuleResponse is the information returned from a rule query.
 type RuleResponse struct {
@@ -24,6 +38,18 @@ type RuleResponse struct {
 
 	// Revision of the rules for this namespace.
 	Revision int64 `json:"revision"`
+
+	// Continue is the cursor from which the next page of rules continues, or empty if this is the last page.
+	Continue string `json:"continue,omitempty"`
+}
+
+// NewRules is the information returned when rules are added.
+type NewRules struct {
+	// IDs of the added rules.
+	IDs []string `json:"ids"`
+
+	// Warnings about added rules that are valid but are likely mistakes.
+	Warnings []rules.RuleError `json:"warnings,omitempty"`
 }
 
 // Config stores the configurable attributes of the client.
@@ -40,12 +66,92 @@ type Config struct {
 	// such as enabling TLS, setting timeouts, etc.
 	// If left nil, a default HTTP client will be used.
 	HTTPClient *http.Client
+
+	// TLS configures the default HTTP client for a controller served over HTTPS.
+	// Ignored if HTTPClient is set.
+	TLS *TLSConfig
+
+	// Retry configures how failed requests are retried.
+	Retry RetryConfig
+
+	// WatchTimeout is the longest a watch waits for the rules to change before checking again.
+	// If left zero, the default timeout of the controller is used.
+	WatchTimeout time.Duration
+}
+
+// TLSConfig stores the TLS options of the client.
+type TLSConfig struct {
+	// CACertFile is a PEM file of the certificate authorities trusted to sign the certificate of the controller.
+	// If left empty, the certificate authorities of the host are trusted.
+	CACertFile string
+
+	// CertFile and KeyFile are PEM files of the certificate and key presented to the controller.
+	// If left empty, no client certificate is presented.
+	CertFile string
+	KeyFile  string
+
+	// InsecureSkipVerify disables verification of the certificate of the controller.
+	InsecureSkipVerify bool
+}
+
+// RetryConfig stores the retry policy of the client. Requests that fail because the controller could not be reached
+// or is unavailable are retried with exponential backoff. Requests that add rules are only retried if the controller
+// is unavailable, since other failures may happen after the rules were added.
+type RetryConfig struct {
+	// Attempts is the maximum number of attempts of each request. If left zero, a default is used.
+	// Set to 1 to disable retries.
+	Attempts int
+
+	// Backoff is the delay before the first retry, which doubles for every following retry.
+	// If left zero, a default is used.
+	Backoff time.Duration
+
+	// MaxBackoff is the longest delay between retries. If left zero, a default is used.
+	MaxBackoff time.Duration
 }
 
 // Client for the controller.
 type Client interface {
 	// GetRules returns the rules for this namespace that match the filter.
 	GetRules(f rules.Filter) (RuleResponse, error)
+
+	// AddRules adds the rules to this namespace.
+	AddRules(rs []rules.Rule) (NewRules, error)
+
+	// UpdateRules updates rules by ID in this namespace. Unless the revision is rules.AnyRevision, the rules are
+	// only updated if the namespace is at the revision.
+	UpdateRules(rs []rules.Rule, revision int64) error
+
+	// DeleteRules deletes the rules that match the filter in this namespace. Unless the revision is
+	// rules.AnyRevision, the rules are only deleted if the namespace is at the revision.
+	DeleteRules(f rules.Filter, revision int64) error
+
+	// GetRoutes returns the route rules of the destination.
+	GetRoutes(destination string) (RuleResponse, error)
+
+	// SetRoutes replaces the route rules of the destination with the rules. Unless the revision is
+	// rules.AnyRevision, the rules are only set if the namespace is at the revision.
+	SetRoutes(destination string, rs []rules.Rule, revision int64) (NewRules, error)
+
+	// DeleteRoutes deletes the route rules of the destination. Unless the revision is rules.AnyRevision, the rules
+	// are only deleted if the namespace is at the revision.
+	DeleteRoutes(destination string, revision int64) error
+
+	// GetActions returns the action rules of the destination.
+	GetActions(destination string) (RuleResponse, error)
+
+	// SetActions replaces the action rules of the destination with the rules. Unless the revision is
+	// rules.AnyRevision, the rules are only set if the namespace is at the revision.
+	SetActions(destination string, rs []rules.Rule, revision int64) (NewRules, error)
+
+	// DeleteActions deletes the action rules of the destination. Unless the revision is rules.AnyRevision, the
+	// rules are only deleted if the namespace is at the revision.
+	DeleteActions(destination string, revision int64) error
+
+	// Watch returns a channel of the rules that match the filter, which receives the current rules and then the
+	// rules at each later revision of this namespace. Failed requests are logged and retried. The channel is closed
+	// once the stop channel is closed. The limit and continue cursor of the filter are ignored.
+	Watch(f rules.Filter, stop <-chan struct{}) <-chan RuleResponse
 }
 
 // New constructs a new controller client.
@@ -54,69 +160,444 @@ func New(conf Config) (Client, error) {
 		return nil, err
 	}
 
+	// Watches block on the controller, so they must not be cut short by the timeout of the HTTP client
+	watchClient := *conf.HTTPClient
+	if watchClient.Timeout > 0 {
+		watchClient.Timeout += conf.WatchTimeout
+	}
+
 	return &client{
-		url:        conf.URL,
-		authToken:  conf.AuthToken,
-		httpClient: conf.HTTPClient,
+		url:          conf.URL,
+		authToken:    conf.AuthToken,
+		httpClient:   conf.HTTPClient,
+		watchClient:  &watchClient,
+		retry:        conf.Retry,
+		watchTimeout: conf.WatchTimeout,
 	}, nil
 }
 
 // normalizeConfig validates and sets defaults for the client configuration.
 func normalizeConfig(conf *Config) error {
+	// Normalize server URL to not end with a "/"
+	conf.URL = strings.TrimRight(conf.URL, "/")
+
 	u, err := url.Parse(conf.URL)
 	if err != nil {
-		return err
+		return newError(ErrorCodeInvalidConfiguration, "cannot parse server URL", err, "")
 	}
 
 	if u.Scheme != "http" && u.Scheme != "https" {
-		// TODO: custom error type
-		return fmt.Errorf("client: Unsupported protocol scheme %v", u.Scheme)
+		return newError(ErrorCodeInvalidConfiguration, fmt.Sprintf("unsupported scheme %v", u.Scheme), nil, "")
 	}
 
 	if conf.HTTPClient == nil {
 		conf.HTTPClient = &http.Client{
 			Timeout: defaultTimeout,
 		}
+
+		if conf.TLS != nil {
+			tlsConfig, err := newTLSConfig(*conf.TLS)
+			if err != nil {
+				return err
+			}
+			conf.HTTPClient.Transport = &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: tlsConfig,
+			}
+		}
+	}
+
+	if conf.Retry.Attempts <= 0 {
+		conf.Retry.Attempts = defaultRetryAttempts
+	}
+	if conf.Retry.Backoff <= 0 {
+		conf.Retry.Backoff = defaultRetryBackoff
+	}
+	if conf.Retry.MaxBackoff <= 0 {
+		conf.Retry.MaxBackoff = defaultRetryMaxBackoff
+	}
+
+	if conf.WatchTimeout <= 0 {
+		conf.WatchTimeout = defaultWatchTimeout
 	}
 
 	return nil
 }
 
+// newTLSConfig loads the certificates of the TLS options.
+func newTLSConfig(conf TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: conf.InsecureSkipVerify,
+	}
+
+	if conf.CACertFile != "" {
+		pem, err := ioutil.ReadFile(conf.CACertFile)
+		if err != nil {
+			return nil, newError(ErrorCodeInvalidConfiguration, "cannot read CA certificate file", err, "")
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, newError(ErrorCodeInvalidConfiguration, "no certificates found in CA certificate file", nil, "")
+		}
+	}
+
+	if conf.CertFile != "" || conf.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+		if err != nil {
+			return nil, newError(ErrorCodeInvalidConfiguration, "cannot load client certificate", err, "")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
 type client struct {
-	url        string
-	authToken  string
-	httpClient *http.Client
+	url          string
+	authToken    string
+	httpClient   *http.Client
+	watchClient  *http.Client
+	retry        RetryConfig
+	watchTimeout time.Duration
+}
+
+// request to the controller.
+type request struct {
+	method string
+	path   string
+	query  url.Values
+
+	// revision the request is conditional on, unless it is rules.AnyRevision.
+	revision int64
+
+	body interface{}
+
+	// status expected in the response.
+	status int
+
+	// wait marks requests that block until the rules change.
+	wait bool
 }
 
 func (c *client) GetRules(filter rules.Filter) (RuleResponse, error) {
+	switch filter.RuleType {
+	case rules.RuleRoute:
+		return c.getByRuleType("/v1/rules/routes", filter)
+	case rules.RuleAction:
+		return c.getByRuleType("/v1/rules/actions", filter)
+	}
+
 	var ruleResponse RuleResponse
+	_, err := c.doRequest(request{
+		method:   "GET",
+		path:     "/v1/rules",
+		query:    filterQuery(filter),
+		revision: rules.AnyRevision,
+		status:   http.StatusOK,
+	}, &ruleResponse)
 
-	u, err := url.Parse(c.url + "/v1/rules")
+	return ruleResponse, err
+}
+
+// getByRuleType returns the rules of a single rule type, which the controller groups by destination.
+func (c *client) getByRuleType(path string, filter rules.Filter) (RuleResponse, error) {
+	var ruleResponse RuleResponse
+
+	resp := struct {
+		Services map[string][]rules.Rule `json:"services"`
+		Continue string                  `json:"continue"`
+	}{}
+	header, err := c.doRequest(request{
+		method:   "GET",
+		path:     path,
+		query:    filterQuery(filter),
+		revision: rules.AnyRevision,
+		status:   http.StatusOK,
+	}, &resp)
 	if err != nil {
 		return ruleResponse, err
 	}
 
-	query := u.Query()
-	for _, id := range filter.IDs {
-		query.Add("id", id)
+	// The revision is only returned in the entity tag
+	revision, err := strconv.ParseInt(strings.Trim(header.Get("ETag"), `"`), 10, 64)
+	if err != nil {
+		return ruleResponse, newError(ErrorCodeInternalClientError, "error parsing revision of rules", err,
+			header.Get(env.RequestID))
 	}
 
-	for _, tag := range filter.Tags {
-		query.Add("tag", tag)
+	destinations := make([]string, 0, len(resp.Services))
+	for destination := range resp.Services {
+		destinations = append(destinations, destination)
 	}
-	u.RawQuery = query.Encode()
+	sort.Strings(destinations)
+
+	ruleResponse.Rules = []rules.Rule{}
+	for _, destination := range destinations {
+		ruleResponse.Rules = append(ruleResponse.Rules, resp.Services[destination]...)
+	}
+	ruleResponse.Revision = revision
+	ruleResponse.Continue = resp.Continue
+
+	return ruleResponse, nil
+}
+
+func (c *client) AddRules(rs []rules.Rule) (NewRules, error) {
+	var newRules NewRules
+	_, err := c.doRequest(request{
+		method:   "POST",
+		path:     "/v1/rules",
+		revision: rules.AnyRevision,
+		body:     ruleList(rs),
+		status:   http.StatusCreated,
+	}, &newRules)
+
+	return newRules, err
+}
+
+func (c *client) UpdateRules(rs []rules.Rule, revision int64) error {
+	_, err := c.doRequest(request{
+		method:   "PUT",
+		path:     "/v1/rules",
+		revision: revision,
+		body:     ruleList(rs),
+		status:   http.StatusOK,
+	}, nil)
+
+	return err
+}
+
+func (c *client) DeleteRules(filter rules.Filter, revision int64) error {
+	_, err := c.doRequest(request{
+		method:   "DELETE",
+		path:     "/v1/rules",
+		query:    filterQuery(filter),
+		revision: revision,
+		status:   http.StatusOK,
+	}, nil)
+
+	return err
+}
+
+func (c *client) GetRoutes(destination string) (RuleResponse, error) {
+	return c.getDestination("/v1/rules/routes/", destination)
+}
+
+func (c *client) SetRoutes(destination string, rs []rules.Rule, revision int64) (NewRules, error) {
+	return c.setDestination("/v1/rules/routes/", destination, rs, revision)
+}
+
+func (c *client) DeleteRoutes(destination string, revision int64) error {
+	return c.deleteDestination("/v1/rules/routes/", destination, revision)
+}
+
+func (c *client) GetActions(destination string) (RuleResponse, error) {
+	return c.getDestination("/v1/rules/actions/", destination)
+}
+
+func (c *client) SetActions(destination string, rs []rules.Rule, revision int64) (NewRules, error) {
+	return c.setDestination("/v1/rules/actions/", destination, rs, revision)
+}
 
-	req, err := http.NewRequest("GET", u.String(), nil)
+func (c *client) DeleteActions(destination string, revision int64) error {
+	return c.deleteDestination("/v1/rules/actions/", destination, revision)
+}
+
+func (c *client) getDestination(path, destination string) (RuleResponse, error) {
+	var ruleResponse RuleResponse
+	_, err := c.doRequest(request{
+		method:   "GET",
+		path:     path + url.PathEscape(destination),
+		revision: rules.AnyRevision,
+		status:   http.StatusOK,
+	}, &ruleResponse)
+
+	return ruleResponse, err
+}
+
+func (c *client) setDestination(path, destination string, rs []rules.Rule, revision int64) (NewRules, error) {
+	var newRules NewRules
+	_, err := c.doRequest(request{
+		method:   "PUT",
+		path:     path + url.PathEscape(destination),
+		revision: revision,
+		body:     ruleList(rs),
+		status:   http.StatusCreated,
+	}, &newRules)
+
+	return newRules, err
+}
+
+func (c *client) deleteDestination(path, destination string, revision int64) error {
+	_, err := c.doRequest(request{
+		method:   "DELETE",
+		path:     path + url.PathEscape(destination),
+		revision: revision,
+		status:   http.StatusOK,
+	}, nil)
+
+	return err
+}
+
+func (c *client) Watch(filter rules.Filter, stop <-chan struct{}) <-chan RuleResponse {
+	filter.Limit = 0
+	filter.Continue = ""
+
+	changes := make(chan RuleResponse)
+	go c.watch(filter, stop, changes)
+
+	return changes
+}
+
+// watch sends the rules that match the filter to the channel each time the revision of the namespace changes,
+// until the stop channel is closed.
+func (c *client) watch(filter rules.Filter, stop <-chan struct{}, changes chan<- RuleResponse) {
+	defer close(changes)
+
+	revision := rules.AnyRevision
+	backoff := c.retry.Backoff
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		if revision != rules.AnyRevision {
+			start := time.Now()
+			current, err := c.waitRevision(revision)
+			if err != nil {
+				logrus.WithError(err).Warn("Failed to watch rules on controller")
+				if !sleep(backoff, stop) {
+					return
+				}
+				backoff = c.nextBackoff(backoff)
+				continue
+			}
+
+			// The watch timed out without a change. Controllers that do not support watching return immediately, and
+			// are polled no faster than retries.
+			if current <= revision {
+				if time.Since(start) < c.watchTimeout && !sleep(c.retry.Backoff, stop) {
+					return
+				}
+				continue
+			}
+		}
+
+		resp, err := c.GetRules(filter)
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to retrieve watched rules from controller")
+			if !sleep(backoff, stop) {
+				return
+			}
+			backoff = c.nextBackoff(backoff)
+			continue
+		}
+		backoff = c.retry.Backoff
+
+		if resp.Revision <= revision {
+			continue
+		}
+		revision = resp.Revision
+
+		select {
+		case changes <- resp:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// waitRevision blocks until the revision of the namespace is greater than the revision or the watch timeout
+// expires, and returns the current revision.
+func (c *client) waitRevision(revision int64) (int64, error) {
+	// Only the revision is needed, so as little as possible of the rules is returned
+	query := url.Values{}
+	query.Set("wait", "true")
+	query.Set("revision", strconv.FormatInt(revision, 10))
+	query.Set("timeout", c.watchTimeout.String())
+	query.Set("limit", "1")
+	query.Set("fields", "id")
+
+	var ruleResponse RuleResponse
+	_, err := c.doRequest(request{
+		method:   "GET",
+		path:     "/v1/rules",
+		query:    query,
+		revision: rules.AnyRevision,
+		status:   http.StatusOK,
+		wait:     true,
+	}, &ruleResponse)
+
+	return ruleResponse.Revision, err
+}
+
+// doRequest performs the request, retrying failed attempts according to the retry policy, and decodes the JSON
+// response into the result unless it is nil. Returns the headers of the response.
+func (c *client) doRequest(r request, result interface{}) (http.Header, error) {
+	var body []byte
+	if r.body != nil {
+		var err error
+		body, err = json.Marshal(r.body)
+		if err != nil {
+			return nil, newError(ErrorCodeInternalClientError, "error marshaling HTTP request body", err, "")
+		}
+	}
+
+	backoff := c.retry.Backoff
+	for attempt := 1; ; attempt++ {
+		header, err := c.doAttempt(r, body, result)
+		if err == nil || attempt >= c.retry.Attempts || !retryable(r.method, err) {
+			return header, err
+		}
+
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"method":  r.method,
+			"path":    r.path,
+			"attempt": attempt,
+			"backoff": backoff,
+		}).Debug("Retrying failed request to controller")
+
+		sleep(backoff, nil)
+		backoff = c.nextBackoff(backoff)
+	}
+}
+
+// doAttempt performs a single attempt of the request.
+func (c *client) doAttempt(r request, body []byte, result interface{}) (http.Header, error) {
+	u := c.url + r.path
+	if len(r.query) > 0 {
+		u += "?" + r.query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequest(r.method, u, reader)
 	if err != nil {
-		logrus.WithError(err).Warn("Error building request to get rules from controller")
-		return ruleResponse, err
+		return nil, newError(ErrorCodeInternalClientError, "error creating HTTP request", err, "")
 	}
 	c.setAuthHeader(req)
 
-	resp, err := c.httpClient.Do(req)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if r.revision != rules.AnyRevision {
+		req.Header.Set("If-Match", fmt.Sprintf(`"%v"`, r.revision))
+	}
+
+	httpClient := c.httpClient
+	if r.wait {
+		httpClient = c.watchClient
+	}
+
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		logrus.WithError(err).Warn("Failed to retrieve rules from controller")
-		return ruleResponse, err
+		return nil, newError(ErrorCodeConnectionFailure, "error performing HTTP request", err, "")
 	}
 	defer resp.Body.Close()
 
@@ -124,29 +605,35 @@ func (c *client) GetRules(filter rules.Filter) (RuleResponse, error) {
 
 	data, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		logrus.WithError(err).WithFields(logrus.Fields{
-			"request_id": requestID,
-		}).Warn("Error reading response from controller")
-		return ruleResponse, err
+		return nil, newError(ErrorCodeConnectionFailure, "error reading HTTP response body", err, requestID)
 	}
 
-	if resp.StatusCode != http.StatusOK {
+	if resp.StatusCode != r.status {
 		logrus.WithFields(logrus.Fields{
 			"status_code": resp.StatusCode,
 			"request_id":  requestID,
 			"body":        string(data),
-		}).Warn("Controller returned unexpected response code")
-		return ruleResponse, errors.New("client: received unexpected response code") // FIXME: custom error?
+		}).Debug("Controller returned unexpected response code")
+		return nil, responseError(resp.StatusCode, data, requestID)
 	}
 
-	if err = json.Unmarshal(data, &ruleResponse); err != nil {
-		logrus.WithError(err).WithFields(logrus.Fields{
-			"request_id": requestID,
-		}).Warn("Error reading rules JSON from controller")
-		return ruleResponse, err
+	if result != nil {
+		if err = json.Unmarshal(data, result); err != nil {
+			return nil, newError(ErrorCodeInternalClientError, "error unmarshaling HTTP response body", err,
+				requestID)
+		}
 	}
 
-	return ruleResponse, nil
+	return resp.Header, nil
+}
+
+// nextBackoff returns the delay before the retry following a retry delayed by the backoff.
+func (c *client) nextBackoff(backoff time.Duration) time.Duration {
+	backoff *= 2
+	if backoff > c.retry.MaxBackoff {
+		backoff = c.retry.MaxBackoff
+	}
+	return backoff
 }
 
 // setAuthHeader optionally sets an authorization header. If the token is empty we assume no authentication is enabled
@@ -156,3 +643,77 @@ func (c *client) setAuthHeader(req *http.Request) {
 		req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", c.authToken))
 	}
 }
+
+// retryable returns whether a request that failed with the error may be retried. Only unavailable controllers are
+// retried for requests that add rules, since other failures may happen after the rules were added.
+func retryable(method string, err error) bool {
+	e, ok := err.(Error)
+	if !ok {
+		return false
+	}
+
+	switch e.Code {
+	case ErrorCodeServiceUnavailable:
+		return true
+	case ErrorCodeConnectionFailure, ErrorCodeInternalServerError:
+		return method != "POST"
+	default:
+		return false
+	}
+}
+
+// sleep waits for the duration, and returns false if the stop channel is closed first.
+func sleep(d time.Duration, stop <-chan struct{}) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+// filterQuery returns the query parameters that select the rules that match the filter.
+func filterQuery(filter rules.Filter) url.Values {
+	query := url.Values{}
+	for _, id := range filter.IDs {
+		query.Add("id", id)
+	}
+
+	for _, tag := range filter.Tags {
+		query.Add("tag", tag)
+	}
+
+	for _, destination := range filter.Destinations {
+		query.Add("destination", destination)
+	}
+
+	if filter.IncludeInactive {
+		query.Set("include_inactive", "true")
+	}
+
+	if filter.SortBy != "" {
+		query.Set("sort", filter.SortBy)
+	}
+
+	if filter.Limit > 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+
+	if filter.Continue != "" {
+		query.Set("continue", filter.Continue)
+	}
+
+	return query
+}
+
+// ruleList returns the request body of a list of rules.
+func ruleList(rs []rules.Rule) interface{} {
+	return struct {
+		Rules []rules.Rule `json:"rules"`
+	}{
+		Rules: rs,
+	}
+}
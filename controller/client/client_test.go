@@ -0,0 +1,328 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/amalgam8/amalgam8/controller/rules"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) (Client, *httptest.Server) {
+	server := httptest.NewServer(handler)
+
+	c, err := New(Config{
+		URL: server.URL,
+		Retry: RetryConfig{
+			Attempts: 3,
+			Backoff:  time.Millisecond,
+		},
+		WatchTimeout: time.Second,
+	})
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+
+	return c, server
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func TestGetRulesQuery(t *testing.T) {
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if r.URL.Path != "/v1/rules" || query.Get("destination") != "reviews" || query.Get("limit") != "2" ||
+			query.Get("include_inactive") != "true" {
+			t.Errorf("unexpected request %v", r.URL)
+		}
+
+		writeJSON(w, http.StatusOK, RuleResponse{
+			Rules:    []rules.Rule{{ID: "a"}},
+			Revision: 4,
+			Continue: "next",
+		})
+	})
+	defer server.Close()
+
+	resp, err := c.GetRules(rules.Filter{
+		Destinations:    []string{"reviews"},
+		IncludeInactive: true,
+		Limit:           2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.Rules) != 1 || resp.Revision != 4 || resp.Continue != "next" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
+
+func TestGetRulesByRuleType(t *testing.T) {
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/rules/routes" {
+			t.Errorf("unexpected path %v", r.URL.Path)
+		}
+
+		w.Header().Set("ETag", `"7"`)
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"services": map[string][]rules.Rule{
+				"reviews": {{ID: "b", Destination: "reviews"}},
+				"details": {{ID: "a", Destination: "details"}},
+			},
+		})
+	})
+	defer server.Close()
+
+	resp, err := c.GetRules(rules.Filter{RuleType: rules.RuleRoute})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Revision != 7 || len(resp.Rules) != 2 || resp.Rules[0].ID != "a" || resp.Rules[1].ID != "b" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
+
+func TestRevisionPrecondition(t *testing.T) {
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-Match") != `"3"` {
+			writeJSON(w, http.StatusPreconditionFailed, map[string]string{
+				"error":       "error_revision_mismatch",
+				"description": "revision mismatch",
+			})
+			return
+		}
+		writeJSON(w, http.StatusCreated, NewRules{IDs: []string{"a"}})
+	})
+	defer server.Close()
+
+	newRules, err := c.SetRoutes("reviews", []rules.Rule{{Destination: "reviews"}}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(newRules.IDs) != 1 {
+		t.Errorf("unexpected IDs %v", newRules.IDs)
+	}
+
+	_, err = c.SetRoutes("reviews", []rules.Rule{{Destination: "reviews"}}, 2)
+	if !IsErrorCode(err, ErrorCodeRevisionMismatch) {
+		t.Errorf("expected revision mismatch, got %v", err)
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+	cases := []struct {
+		Status int
+		Body   interface{}
+		Code   ErrorCode
+	}{
+		{
+			Status: http.StatusBadRequest,
+			Body: map[string]interface{}{
+				"error":   "error_invalid_rule",
+				"details": []rules.RuleError{{Field: "destination"}},
+			},
+			Code: ErrorCodeInvalidRule,
+		},
+		{
+			Status: http.StatusForbidden,
+			Body:   map[string]string{"error": "error_auth_forbidden"},
+			Code:   ErrorCodeForbidden,
+		},
+		{
+			Status: http.StatusConflict,
+			Body:   map[string]string{"error": "error_import_conflict"},
+			Code:   ErrorCodeConflict,
+		},
+		{
+			Status: http.StatusNotFound,
+			Body:   "not found",
+			Code:   ErrorCodeNotFound,
+		},
+	}
+
+	for _, c := range cases {
+		client, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, c.Status, c.Body)
+		})
+
+		_, err := client.AddRules([]rules.Rule{{}})
+		server.Close()
+
+		if !IsErrorCode(err, c.Code) {
+			t.Errorf("expected %v for status %v, got %v", c.Code, c.Status, err)
+		}
+	}
+}
+
+func TestInvalidRuleDetails(t *testing.T) {
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"error":   "error_invalid_rule",
+			"details": []rules.RuleError{{Field: "destination"}},
+		})
+	})
+	defer server.Close()
+
+	err := c.UpdateRules([]rules.Rule{{ID: "a"}}, rules.AnyRevision)
+	e, ok := err.(Error)
+	if !ok || e.ID != "error_invalid_rule" {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	var details []rules.RuleError
+	if err := json.Unmarshal(e.Details, &details); err != nil || len(details) != 1 {
+		t.Errorf("unexpected details %s", e.Details)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var mutex sync.Mutex
+	attempts := map[string]int{}
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		attempts[r.Method]++
+		n := attempts[r.Method]
+		mutex.Unlock()
+
+		if r.Method == "DELETE" && n == 3 {
+			writeJSON(w, http.StatusOK, struct{}{})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "error_internal"})
+	})
+	defer server.Close()
+
+	if err := c.DeleteRules(rules.Filter{IDs: []string{"a"}}, rules.AnyRevision); err != nil {
+		t.Errorf("expected delete to succeed after retries, got %v", err)
+	}
+
+	// Rules might have been added by a failed request, so it is not retried
+	if _, err := c.AddRules([]rules.Rule{{}}); !IsErrorCode(err, ErrorCodeInternalServerError) {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	if attempts["DELETE"] != 3 || attempts["POST"] != 1 {
+		t.Errorf("unexpected attempts %v", attempts)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	var mutex sync.Mutex
+	revision := int64(1)
+
+	c, server := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		// Each watch sees a new revision
+		if r.URL.Query().Get("wait") == "true" {
+			revision++
+		}
+		writeJSON(w, http.StatusOK, RuleResponse{
+			Rules:    []rules.Rule{},
+			Revision: revision,
+		})
+	})
+	defer server.Close()
+
+	stop := make(chan struct{})
+	changes := c.Watch(rules.Filter{}, stop)
+
+	for _, expected := range []int64{1, 2, 3} {
+		select {
+		case resp := <-changes:
+			if resp.Revision != expected {
+				t.Errorf("expected revision %v, got %v", expected, resp.Revision)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for change")
+		}
+	}
+
+	close(stop)
+	for range changes {
+	}
+}
+
+func TestWatchWithoutWaitSupport(t *testing.T) {
+	var requests int32
+
+	// The controller ignores the wait parameter, and the revision never changes
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		writeJSON(w, http.StatusOK, RuleResponse{
+			Rules:    []rules.Rule{},
+			Revision: 1,
+		})
+	}))
+	defer server.Close()
+
+	c, err := New(Config{
+		URL: server.URL,
+		Retry: RetryConfig{
+			Backoff: 50 * time.Millisecond,
+		},
+		WatchTimeout: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	changes := c.Watch(rules.Filter{}, stop)
+
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for rules")
+	}
+
+	time.Sleep(500 * time.Millisecond)
+	close(stop)
+	for range changes {
+	}
+
+	if n := atomic.LoadInt32(&requests); n > 15 {
+		t.Errorf("expected the watch to back off, got %v requests", n)
+	}
+}
+
+func TestInvalidConfiguration(t *testing.T) {
+	if _, err := New(Config{URL: "ftp://controller"}); !IsErrorCode(err, ErrorCodeInvalidConfiguration) {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	_, err := New(Config{
+		URL: "https://controller",
+		TLS: &TLSConfig{CACertFile: "/nonexistent"},
+	})
+	if !IsErrorCode(err, ErrorCodeInvalidConfiguration) {
+		t.Errorf("unexpected error %v", err)
+	}
+}
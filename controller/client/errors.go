@@ -0,0 +1,215 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/amalgam8/amalgam8/controller/util/i18n"
+)
+
+// ErrorCode represents an error condition which might occur when using the client.
+type ErrorCode int
+
+// Enumerate valid ErrorCode values.
+const (
+	ErrorCodeUndefined ErrorCode = iota
+
+	ErrorCodeInvalidRequest
+	ErrorCodeInvalidRule
+	ErrorCodeNotFound
+	ErrorCodeConflict
+	ErrorCodeRevisionMismatch
+
+	ErrorCodeConnectionFailure
+
+	ErrorCodeServiceUnavailable
+	ErrorCodeInternalServerError
+
+	ErrorCodeUnauthorized
+	ErrorCodeForbidden
+	ErrorCodeInvalidConfiguration
+	ErrorCodeInternalClientError
+)
+
+func (code ErrorCode) String() string {
+	switch code {
+
+	case ErrorCodeInvalidRequest:
+		return "ErrorCodeInvalidRequest"
+	case ErrorCodeInvalidRule:
+		return "ErrorCodeInvalidRule"
+	case ErrorCodeNotFound:
+		return "ErrorCodeNotFound"
+	case ErrorCodeConflict:
+		return "ErrorCodeConflict"
+	case ErrorCodeRevisionMismatch:
+		return "ErrorCodeRevisionMismatch"
+	case ErrorCodeConnectionFailure:
+		return "ErrorCodeConnectionFailure"
+	case ErrorCodeServiceUnavailable:
+		return "ErrorCodeServiceUnavailable"
+	case ErrorCodeInternalServerError:
+		return "ErrorCodeInternalServerError"
+	case ErrorCodeUnauthorized:
+		return "ErrorCodeUnauthorized"
+	case ErrorCodeForbidden:
+		return "ErrorCodeForbidden"
+	case ErrorCodeInvalidConfiguration:
+		return "ErrorCodeInvalidConfiguration"
+	case ErrorCodeInternalClientError:
+		return "ErrorCodeInternalClientError"
+
+	default:
+		return "ErrorCodeUndefined"
+	}
+}
+
+// Error represents an actual error occurred while using the client.
+type Error struct {
+	Code      ErrorCode
+	Message   string
+	Cause     error
+	RequestID string
+
+	// ID is the untranslated error ID returned by the controller, such as "error_invalid_rule".
+	ID string
+
+	// Details returned by the controller, such as the problems found in invalid rules.
+	Details json.RawMessage
+}
+
+func (err Error) Error() string {
+	var buf bytes.Buffer
+	buf.WriteString(err.Code.String())
+	buf.WriteString(": ")
+	buf.WriteString(err.Message)
+
+	if err.Cause != nil {
+		buf.WriteString(" (")
+		buf.WriteString(err.Cause.Error())
+		buf.WriteString(")")
+	}
+
+	if err.RequestID != "" {
+		buf.WriteString(" (")
+		buf.WriteString(err.RequestID)
+		buf.WriteString(")")
+	}
+
+	return buf.String()
+}
+
+// IsErrorCode returns whether the error is a client error with the code.
+func IsErrorCode(err error, code ErrorCode) bool {
+	e, ok := err.(Error)
+	return ok && e.Code == code
+}
+
+func newError(code ErrorCode, message string, cause error, requestID string) Error {
+	return Error{
+		Code:      code,
+		Message:   message,
+		Cause:     cause,
+		RequestID: requestID,
+	}
+}
+
+// errorCodes maps the error IDs returned by the controller to error codes.
+var errorCodes = map[string]ErrorCode{
+	i18n.ErrorInvalidJSON:           ErrorCodeInvalidRequest,
+	i18n.ErrorInvalidRule:           ErrorCodeInvalidRule,
+	i18n.ErrorNoRulesProvided:       ErrorCodeInvalidRequest,
+	i18n.ErrorNoDestinationProvided: ErrorCodeInvalidRequest,
+	i18n.ErrorInvalidRevision:       ErrorCodeInvalidRequest,
+	i18n.ErrorInvalidTimeout:        ErrorCodeInvalidRequest,
+	i18n.ErrorInvalidTimeRange:      ErrorCodeInvalidRequest,
+	i18n.ErrorInvalidYAML:           ErrorCodeInvalidRequest,
+	i18n.ErrorInvalidImportMode:     ErrorCodeInvalidRequest,
+	i18n.ErrorUnsupportedVersion:    ErrorCodeInvalidRequest,
+	i18n.ErrorInvalidPage:           ErrorCodeInvalidRequest,
+	i18n.ErrorInvalidFields:         ErrorCodeInvalidRequest,
+
+	i18n.ErrorRevisionNotFound: ErrorCodeNotFound,
+	i18n.ErrorImportConflict:   ErrorCodeConflict,
+	i18n.ErrorRevisionMismatch: ErrorCodeRevisionMismatch,
+
+	i18n.ErrorInvalidRollout:  ErrorCodeInvalidRequest,
+	i18n.ErrorRolloutNotFound: ErrorCodeNotFound,
+	i18n.ErrorRolloutConflict: ErrorCodeConflict,
+
+	i18n.ErrorInvalidTemplate:  ErrorCodeInvalidRequest,
+	i18n.ErrorInvalidBinding:   ErrorCodeInvalidRequest,
+	i18n.ErrorTemplateNotFound: ErrorCodeNotFound,
+	i18n.ErrorBindingNotFound:  ErrorCodeNotFound,
+	i18n.ErrorTemplateConflict: ErrorCodeConflict,
+
+	i18n.ErrorAuthorizationMissingHeader:         ErrorCodeUnauthorized,
+	i18n.ErrorAuthorizationMalformedHeader:       ErrorCodeUnauthorized,
+	i18n.ErrorAuthorizationTokenValidationFailed: ErrorCodeUnauthorized,
+	i18n.ErrorAuthorizationNotAuthorized:         ErrorCodeUnauthorized,
+	i18n.ErrorAuthorizationForbidden:             ErrorCodeForbidden,
+
+	i18n.ErrorInternalServer: ErrorCodeInternalServerError,
+}
+
+// responseError returns the error for an unexpected response from the controller. The code is taken from the error
+// ID in the body of the response if the controller returned one, and from the status code otherwise.
+func responseError(status int, body []byte, requestID string) Error {
+	var resp struct {
+		Error       string          `json:"error"`
+		Description string          `json:"description"`
+		Details     json.RawMessage `json:"details"`
+	}
+	if json.Unmarshal(body, &resp) != nil || resp.Error == "" {
+		return newError(statusErrorCode(status), string(body), nil, requestID)
+	}
+
+	code, ok := errorCodes[resp.Error]
+	if !ok {
+		code = statusErrorCode(status)
+	}
+
+	err := newError(code, resp.Description, nil, requestID)
+	err.ID = resp.Error
+	err.Details = resp.Details
+	return err
+}
+
+// statusErrorCode returns the error code for an unexpected HTTP status code.
+func statusErrorCode(status int) ErrorCode {
+	switch status {
+	case http.StatusBadRequest:
+		return ErrorCodeInvalidRequest
+	case http.StatusNotFound:
+		return ErrorCodeNotFound
+	case http.StatusConflict:
+		return ErrorCodeConflict
+	case http.StatusPreconditionFailed:
+		return ErrorCodeRevisionMismatch
+	case http.StatusUnauthorized:
+		return ErrorCodeUnauthorized
+	case http.StatusForbidden:
+		return ErrorCodeForbidden
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return ErrorCodeServiceUnavailable
+	case http.StatusInternalServerError:
+		return ErrorCodeInternalServerError
+	default:
+		return ErrorCodeInternalClientError
+	}
+}
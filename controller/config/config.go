@@ -31,12 +31,20 @@ type Database struct {
 	Username string
 	Password string
 	Host     string
+	Path     string
 	//URL string
 }
 
+// Registry config
+type Registry struct {
+	URL   string
+	Token string
+}
+
 // Config for the controller
 type Config struct {
 	Database     Database
+	Registry     Registry
 	APIPort      int
 	SecretKey    string
 	LogLevel     logrus.Level
@@ -64,6 +72,11 @@ func New(context *cli.Context) *Config {
 			Username: context.String(dbUserFlag),
 			Password: context.String(dbPasswordFlag),
 			Host:     context.String(dbHostFlag),
+			Path:     context.String(dbPathFlag),
+		},
+		Registry: Registry{
+			URL:   context.String(registryURLFlag),
+			Token: context.String(registryTokenFlag),
 		},
 		APIPort:      context.Int(apiPortFlag),
 		SecretKey:    context.String(secretKeyFlag),
@@ -107,10 +120,16 @@ func (c *Config) Validate() error {
 			},
 		)
 		// TODO: redis logic
+	} else if c.Database.Type == "file" {
+		validators = append(validators, util.IsNotEmpty("Database path", c.Database.Path))
 	} else if c.Database.Type != "memory" {
 		return fmt.Errorf("Invalid database type %v", c.Database.Type)
 	}
 
+	if c.Registry.URL != "" {
+		validators = append(validators, util.IsValidURL("Registry URL", c.Registry.URL))
+	}
+
 	if len(c.SecretKey) != 16 {
 		return fmt.Errorf("Secret must have a length of 16 characters")
 	}
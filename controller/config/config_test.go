@@ -116,6 +116,17 @@ var _ = Describe("Config", func() {
 				c.Database.Username = "username"
 				Expect(c.Validate()).To(HaveOccurred())
 			})
+
+			It("does not accept empty path if file type provided", func() {
+				c.Database.Type = "file"
+				Expect(c.Validate()).To(HaveOccurred())
+			})
+
+			It("accepts a path if file type provided", func() {
+				c.Database.Type = "file"
+				c.Database.Path = "/var/lib/a8controller"
+				Expect(c.Validate()).ToNot(HaveOccurred())
+			})
 		})
 	})
 
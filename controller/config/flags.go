@@ -21,16 +21,19 @@ import (
 )
 
 const (
-	apiPortFlag      = "api_port"
-	dbTypeFlag       = "database_type"
-	dbUserFlag       = "database_username"
-	dbPasswordFlag   = "database_password"
-	dbHostFlag       = "database_host"
-	secretKeyFlag    = "encryption_key"
-	logLevelFlag     = "log_level"
-	authModeFlag     = "auth_mode"
-	jwtSecretFlag    = "jwt_secret"
-	requireHTTPSFlag = "require_https"
+	apiPortFlag       = "api_port"
+	dbTypeFlag        = "database_type"
+	dbUserFlag        = "database_username"
+	dbPasswordFlag    = "database_password"
+	dbHostFlag        = "database_host"
+	dbPathFlag        = "database_path"
+	secretKeyFlag     = "encryption_key"
+	logLevelFlag      = "log_level"
+	authModeFlag      = "auth_mode"
+	jwtSecretFlag     = "jwt_secret"
+	requireHTTPSFlag  = "require_https"
+	registryURLFlag   = "registry_url"
+	registryTokenFlag = "registry_token"
 )
 
 const apiPort = 8080
@@ -75,7 +78,7 @@ var Flags = []cli.Flag{
 		Name:   dbTypeFlag,
 		EnvVar: envVar(dbTypeFlag),
 		Value:  "memory",
-		Usage:  "database type",
+		Usage:  "database type (memory, redis, file)",
 	},
 	cli.StringFlag{
 		Name:   dbUserFlag,
@@ -92,6 +95,22 @@ var Flags = []cli.Flag{
 		EnvVar: envVar(dbHostFlag),
 		Usage:  "database host",
 	},
+	cli.StringFlag{
+		Name:   dbPathFlag,
+		EnvVar: envVar(dbPathFlag),
+		Usage:  "directory in which the file database stores rules",
+	},
+
+	cli.StringFlag{
+		Name:   registryURLFlag,
+		EnvVar: envVar(registryURLFlag),
+		Usage:  "URL of the registry through which template bindings select destinations by tags",
+	},
+	cli.StringFlag{
+		Name:   registryTokenFlag,
+		EnvVar: envVar(registryTokenFlag),
+		Usage:  "registry auth token",
+	},
 
 	cli.StringFlag{
 		Name:   logLevelFlag,
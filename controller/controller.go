@@ -18,19 +18,26 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/ant0ine/go-json-rest/rest"
 	"github.com/urfave/cli"
 
 	"github.com/amalgam8/amalgam8/controller/api"
+	"github.com/amalgam8/amalgam8/controller/audit"
 	"github.com/amalgam8/amalgam8/controller/config"
 	"github.com/amalgam8/amalgam8/controller/metrics"
 	"github.com/amalgam8/amalgam8/controller/middleware"
+	"github.com/amalgam8/amalgam8/controller/rollouts"
 	"github.com/amalgam8/amalgam8/controller/rules"
+	"github.com/amalgam8/amalgam8/controller/storage"
+	"github.com/amalgam8/amalgam8/controller/templates"
+	"github.com/amalgam8/amalgam8/controller/util/encryption"
 	"github.com/amalgam8/amalgam8/controller/util/i18n"
 	"github.com/amalgam8/amalgam8/pkg/auth"
 	"github.com/amalgam8/amalgam8/pkg/version"
+	registryclient "github.com/amalgam8/amalgam8/registry/client"
 )
 
 // Main is the entrypoint for the controller when running as an executable
@@ -79,9 +86,10 @@ func Run(conf *config.Config) error {
 		return validationErr
 	}
 
-	reporter := metrics.NewReporter()
+	reporter := metrics.NewPrometheusReporter()
 
 	healthAPI := api.NewHealth(reporter)
+	metricsAPI := api.NewMetrics(metrics.DefaultRegistry)
 
 	validator, err := rules.NewValidator()
 	if err != nil {
@@ -91,16 +99,91 @@ func Run(conf *config.Config) error {
 	}
 
 	var ruleManager rules.Manager
+	var rolloutStore storage.Store
+	var templateStore storage.Store
+	var auditStore audit.Store
 	if conf.Database.Type == "redis" {
 		ruleManager = rules.NewRedisManager(
 			conf.Database.Host,
 			conf.Database.Password,
 			validator,
 		)
+		pool := storage.NewRedisPool(conf.Database.Host, conf.Database.Password)
+		rolloutStore = storage.NewRedisStore(pool, "rollouts")
+		templateStore = storage.NewRedisStore(pool, "templates")
+		auditStore = audit.NewRedisStore(pool)
+	} else if conf.Database.Type == "file" {
+		enc, err := encryption.NewAES([]byte(conf.SecretKey))
+		if err != nil {
+			logrus.WithError(err).Error("Encryption creation failed")
+			setupHandler.SetError(err)
+			return err
+		}
+
+		ruleManager, err = rules.NewFileManager(conf.Database.Path, enc, validator)
+		if err != nil {
+			logrus.WithError(err).Error("File database creation failed")
+			setupHandler.SetError(err)
+			return err
+		}
+
+		rolloutStore, err = storage.NewFileStore(filepath.Join(conf.Database.Path, "rollouts"), enc)
+		if err != nil {
+			logrus.WithError(err).Error("File database creation failed")
+			setupHandler.SetError(err)
+			return err
+		}
+
+		templateStore, err = storage.NewFileStore(filepath.Join(conf.Database.Path, "templates"), enc)
+		if err != nil {
+			logrus.WithError(err).Error("File database creation failed")
+			setupHandler.SetError(err)
+			return err
+		}
+
+		auditStore, err = audit.NewFileStore(filepath.Join(conf.Database.Path, "audit"), enc)
+		if err != nil {
+			logrus.WithError(err).Error("File database creation failed")
+			setupHandler.SetError(err)
+			return err
+		}
 	} else {
 		ruleManager = rules.NewMemoryManager(validator)
+		rolloutStore = storage.NewMemoryStore()
+		templateStore = storage.NewMemoryStore()
+		auditStore = audit.NewMemoryStore()
+	}
+	rulesAPI := api.NewRule(ruleManager, auditStore, reporter)
+	auditAPI := api.NewAudit(auditStore, reporter)
+
+	rolloutManager := rollouts.NewManager(rollouts.Config{
+		Store: rolloutStore,
+		Rules: ruleManager,
+	})
+	rolloutManager.Start()
+	rolloutsAPI := api.NewRollout(rolloutManager, reporter)
+
+	var catalog templates.Catalog
+	if conf.Registry.URL != "" {
+		registry, err := registryclient.New(registryclient.Config{
+			URL:       conf.Registry.URL,
+			AuthToken: conf.Registry.Token,
+		})
+		if err != nil {
+			logrus.WithError(err).Error("Registry client creation failed")
+			setupHandler.SetError(err)
+			return err
+		}
+		catalog = templates.NewRegistryCatalog(registry)
 	}
-	rulesAPI := api.NewRule(ruleManager, reporter)
+
+	templateManager := templates.NewManager(templates.Config{
+		Store:   templateStore,
+		Rules:   ruleManager,
+		Catalog: catalog,
+	})
+	templateManager.Start()
+	templatesAPI := api.NewTemplate(templateManager, reporter)
 
 	a := rest.NewApi()
 	a.Use(
@@ -109,7 +192,7 @@ func Run(conf *config.Config) error {
 		&rest.RecoverMiddleware{
 			EnableResponseStackTrace: false,
 		},
-		&rest.ContentTypeCheckerMiddleware{},
+		&middleware.ContentTypeChecker{},
 		&middleware.ContextMiddleware{},
 		&middleware.RequestIDMiddleware{},
 		&middleware.LoggingMiddleware{},
@@ -125,10 +208,14 @@ func Run(conf *config.Config) error {
 		return err
 	}
 
-	authMw := &middleware.AuthMiddleware{Authenticator: authenticator}
+	authMw := &middleware.AuthMiddleware{Authenticator: authenticator, Policy: middleware.DefaultPolicy}
 
 	routes := rulesAPI.Routes(authMw)
+	routes = append(routes, rolloutsAPI.Routes(authMw)...)
+	routes = append(routes, templatesAPI.Routes(authMw)...)
+	routes = append(routes, auditAPI.Routes(authMw)...)
 	routes = append(routes, healthAPI.Routes()...)
+	routes = append(routes, metricsAPI.Routes(authMw)...)
 	router, err := rest.MakeRouter(
 		routes...,
 	)
@@ -0,0 +1,42 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package metrics
+
+// Metrics of the controller, exposed through the DefaultRegistry.
+var (
+	// Requests counts the requests handled by each route of the API, by result.
+	Requests = NewCounterVec("amalgam8_controller_requests_total",
+		"Requests handled by the controller API, by route and result.", "route", "result")
+
+	// RequestDuration measures the latency of each route of the API.
+	RequestDuration = NewHistogramVec("amalgam8_controller_request_duration_seconds",
+		"Latency of requests handled by the controller API, by route.", DefaultBuckets, "route")
+
+	// Rules is the number of rules in each namespace.
+	Rules = NewGaugeVec("amalgam8_controller_rules",
+		"Number of rules in each namespace.", "namespace")
+
+	// StorageDuration measures the latency of storage backend operations.
+	StorageDuration = NewHistogramVec("amalgam8_controller_storage_duration_seconds",
+		"Latency of storage backend operations, by operation.", DefaultBuckets, "operation")
+
+	// AuthFailures counts the requests rejected by authentication or authorization, by reason.
+	AuthFailures = NewCounterVec("amalgam8_controller_auth_failures_total",
+		"Requests rejected by authentication or authorization, by reason.", "reason")
+)
+
+func init() {
+	DefaultRegistry.Register(Requests, RequestDuration, Rules, StorageDuration, AuthFailures)
+}
@@ -0,0 +1,345 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ContentType is the media type of the Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the upper bounds, in seconds, of the buckets of latency histograms.
+var DefaultBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Collector is a metric family that can be exposed in the Prometheus text format.
+type Collector interface {
+	// Write writes the samples of the metric family in the Prometheus text format.
+	Write(w io.Writer) error
+}
+
+// Registry is a set of collectors exposed together.
+type Registry struct {
+	collectors []Collector
+	mutex      sync.Mutex
+}
+
+// DefaultRegistry holds the metrics of the controller.
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds the collectors to the registry.
+func (r *Registry) Register(collectors ...Collector) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.collectors = append(r.collectors, collectors...)
+}
+
+// Write writes the samples of all the collectors in the Prometheus text format.
+func (r *Registry) Write(w io.Writer) error {
+	r.mutex.Lock()
+	collectors := append([]Collector(nil), r.collectors...)
+	r.mutex.Unlock()
+
+	buf := bufio.NewWriter(w)
+	for _, c := range collectors {
+		if err := c.Write(buf); err != nil {
+			return err
+		}
+	}
+	return buf.Flush()
+}
+
+// ServeHTTP exposes the metrics of the registry to Prometheus.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(http.StatusOK)
+	r.Write(w)
+}
+
+// family is the common part of labeled metric families.
+type family struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+	mutex  sync.Mutex
+}
+
+// key joins label values into a map key.
+func (f *family) key(values []string) string {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %v expects %v label values, got %v", f.name, len(f.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// header writes the HELP and TYPE lines of the family.
+func (f *family) header(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v %v\n", f.name, escapeHelp(f.help), f.name, f.kind)
+	return err
+}
+
+// sample writes a sample of the family with the label values, and an optional extra label.
+func (f *family) sample(w io.Writer, suffix string, values []string, extra string, value float64) error {
+	pairs := make([]string, 0, len(values)+1)
+	for i, v := range values {
+		pairs = append(pairs, fmt.Sprintf("%v=\"%v\"", f.labels[i], escapeLabel(v)))
+	}
+	if extra != "" {
+		pairs = append(pairs, extra)
+	}
+
+	labels := ""
+	if len(pairs) > 0 {
+		labels = "{" + strings.Join(pairs, ",") + "}"
+	}
+
+	_, err := fmt.Fprintf(w, "%v%v%v %v\n", f.name, suffix, labels, formatFloat(value))
+	return err
+}
+
+// sortedKeys returns the keys of the samples of a family in a stable order.
+func sortedKeys(keys []string) []string {
+	sort.Strings(keys)
+	return keys
+}
+
+// CounterVec is a family of counters partitioned by label values.
+type CounterVec struct {
+	family
+	values map[string]float64
+}
+
+// NewCounterVec creates a counter family with the labels.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{
+		family: family{name: name, help: help, kind: "counter", labels: labels},
+		values: make(map[string]float64),
+	}
+}
+
+// Inc increments the counter with the label values.
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds a non-negative amount to the counter with the label values.
+func (c *CounterVec) Add(v float64, values ...string) {
+	key := c.key(values)
+
+	c.mutex.Lock()
+	c.values[key] += v
+	c.mutex.Unlock()
+}
+
+// Write implements the Collector interface.
+func (c *CounterVec) Write(w io.Writer) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return writeScalars(w, &c.family, c.values)
+}
+
+// GaugeVec is a family of gauges partitioned by label values.
+type GaugeVec struct {
+	family
+	values map[string]float64
+}
+
+// NewGaugeVec creates a gauge family with the labels.
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{
+		family: family{name: name, help: help, kind: "gauge", labels: labels},
+		values: make(map[string]float64),
+	}
+}
+
+// Set sets the gauge with the label values.
+func (g *GaugeVec) Set(v float64, values ...string) {
+	key := g.key(values)
+
+	g.mutex.Lock()
+	g.values[key] = v
+	g.mutex.Unlock()
+}
+
+// Delete removes the gauge with the label values.
+func (g *GaugeVec) Delete(values ...string) {
+	key := g.key(values)
+
+	g.mutex.Lock()
+	delete(g.values, key)
+	g.mutex.Unlock()
+}
+
+// Write implements the Collector interface.
+func (g *GaugeVec) Write(w io.Writer) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	return writeScalars(w, &g.family, g.values)
+}
+
+func writeScalars(w io.Writer, f *family, values map[string]float64) error {
+	if err := f.header(w); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	for _, key := range sortedKeys(keys) {
+		if err := f.sample(w, "", splitKey(key, len(f.labels)), "", values[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HistogramVec is a family of histograms partitioned by label values.
+type HistogramVec struct {
+	family
+	buckets    []float64
+	histograms map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64 // Count of observations in each bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec creates a histogram family with the bucket upper bounds, in increasing order, and the labels.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return &HistogramVec{
+		family:     family{name: name, help: help, kind: "histogram", labels: labels},
+		buckets:    buckets,
+		histograms: make(map[string]*histogram),
+	}
+}
+
+// Observe records a value in the histogram with the label values.
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	key := h.key(values)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	hist, ok := h.histograms[key]
+	if !ok {
+		hist = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.histograms[key] = hist
+	}
+
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		hist.counts[i]++
+	}
+	hist.count++
+	hist.sum += v
+}
+
+// Since records the time elapsed since the start, in seconds, in the histogram with the label values.
+func (h *HistogramVec) Since(start time.Time, values ...string) {
+	h.Observe(time.Since(start).Seconds(), values...)
+}
+
+// Write implements the Collector interface.
+func (h *HistogramVec) Write(w io.Writer) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if err := h.header(w); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(h.histograms))
+	for key := range h.histograms {
+		keys = append(keys, key)
+	}
+
+	for _, key := range sortedKeys(keys) {
+		hist := h.histograms[key]
+		values := splitKey(key, len(h.labels))
+
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += hist.counts[i]
+			le := fmt.Sprintf("le=\"%v\"", formatFloat(bound))
+			if err := h.sample(w, "_bucket", values, le, float64(cumulative)); err != nil {
+				return err
+			}
+		}
+		if err := h.sample(w, "_bucket", values, "le=\"+Inf\"", float64(hist.count)); err != nil {
+			return err
+		}
+		if err := h.sample(w, "_sum", values, "", hist.sum); err != nil {
+			return err
+		}
+		if err := h.sample(w, "_count", values, "", float64(hist.count)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func splitKey(key string, n int) []string {
+	if n == 0 {
+		return nil
+	}
+	return strings.Split(key, "\xff")
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
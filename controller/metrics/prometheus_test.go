@@ -0,0 +1,76 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	requests := NewCounterVec("requests_total", "Requests.", "route", "result")
+	rules := NewGaugeVec("rules", "Rules.", "namespace")
+	latency := NewHistogramVec("latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+
+	registry := NewRegistry()
+	registry.Register(requests, rules, latency)
+
+	requests.Inc("get_rules", "success")
+	requests.Inc("get_rules", "success")
+	requests.Inc("add_rules", "failure")
+	rules.Set(3, `a"b`)
+	latency.Observe(0.05, "get_rules")
+	latency.Observe(0.1, "get_rules")
+	latency.Observe(2, "get_rules")
+
+	var buf bytes.Buffer
+	if err := registry.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total{route="add_rules",result="failure"} 1
+requests_total{route="get_rules",result="success"} 2
+# HELP rules Rules.
+# TYPE rules gauge
+rules{namespace="a\"b"} 3
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="get_rules",le="0.1"} 2
+latency_seconds_bucket{route="get_rules",le="1"} 2
+latency_seconds_bucket{route="get_rules",le="+Inf"} 3
+latency_seconds_sum{route="get_rules"} 2.15
+latency_seconds_count{route="get_rules"} 3
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected exposition:\n%v\nexpected:\n%v", buf.String(), expected)
+	}
+}
+
+func TestGaugeDelete(t *testing.T) {
+	rules := NewGaugeVec("rules", "Rules.", "namespace")
+	rules.Set(1, "a")
+	rules.Delete("a")
+
+	var buf bytes.Buffer
+	if err := rules.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), `namespace="a"`) {
+		t.Errorf("expected deleted gauge to be omitted, got:\n%v", buf.String())
+	}
+}
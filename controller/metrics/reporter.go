@@ -54,3 +54,25 @@ func (l *logger) Success(id string, time time.Duration) error {
 	}).Debug("Metric recorded success")
 	return nil
 }
+
+type prometheus struct {
+	logger
+}
+
+// NewPrometheusReporter Reporter implementation that counts requests and measures their latency by metric ID, in the
+// metrics of the DefaultRegistry, and logs success and failure
+func NewPrometheusReporter() Reporter {
+	return &prometheus{}
+}
+
+func (p *prometheus) Failure(id string, time time.Duration, err error) error {
+	Requests.Inc(id, "failure")
+	RequestDuration.Observe(time.Seconds(), id)
+	return p.logger.Failure(id, time, err)
+}
+
+func (p *prometheus) Success(id string, time time.Duration) error {
+	Requests.Inc(id, "success")
+	RequestDuration.Observe(time.Seconds(), id)
+	return p.logger.Success(id, time)
+}
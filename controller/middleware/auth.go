@@ -20,6 +20,7 @@ import (
 
 	"strings"
 
+	"github.com/amalgam8/amalgam8/controller/metrics"
 	"github.com/amalgam8/amalgam8/controller/util"
 	"github.com/amalgam8/amalgam8/controller/util/i18n"
 	"github.com/amalgam8/amalgam8/pkg/auth"
@@ -28,10 +29,30 @@ import (
 
 const adminNamespace = "admin"
 
+// DefaultPolicy specifies the roles required for the controller API. Any role can read rules. Rules, rollouts, rule
+// templates and their bindings can be changed by rule editors, and only admins can delete, replace or roll back all
+// the rules of a namespace. Imports that replace all the rules are checked by the rule API, since the policy cannot
+// tell them from scoped imports. Metrics span all namespaces, so only admins can read them.
+var DefaultPolicy = auth.Policy{
+	{Method: "GET", Path: "/metrics", Role: auth.RoleAdmin},
+	{Method: "GET", Path: "*", Role: auth.RoleReader},
+	{Method: "POST", Path: "/v1/rules/simulate", Role: auth.RoleReader},
+	{Method: "DELETE", Path: "/v1/rules", Role: auth.RoleAdmin},
+	{Method: "POST", Path: "/v1/rules/rollback", Role: auth.RoleAdmin},
+	{Path: "/v1/rules*", Role: auth.RoleRuleEditor},
+	{Path: "/v1/rollouts*", Role: auth.RoleRuleEditor},
+	{Path: "/v1/templates*", Role: auth.RoleRuleEditor},
+	{Path: "/v1/bindings*", Role: auth.RoleRuleEditor},
+}
+
 // AuthMiddleware provides a generic authentication middleware
-// On failure, a 401 HTTP response is returned. On success, the wrapped middleware is called.
+// On failure, a 401 HTTP response is returned. If the token does not grant the role required by the policy, a 403
+// HTTP response is returned. On success, the wrapped middleware is called.
 type AuthMiddleware struct {
 	Authenticator auth.Authenticator
+
+	// Policy enforced on authenticated requests. No roles are required when the policy is nil.
+	Policy auth.Policy
 }
 
 // MiddlewareFunc returns a go-json-rest HTTP Handler function, wrapping calls to the provided HandlerFunc
@@ -50,6 +71,7 @@ func (mw *AuthMiddleware) handler(writer rest.ResponseWriter, request *rest.Requ
 	if authHeader != "" {
 		parts := strings.SplitN(authHeader, " ", 2)
 		if len(parts) != 2 || (parts[0] != "Bearer" && parts[0] != "bearer") {
+			metrics.AuthFailures.Inc("malformed_header")
 			i18n.RestError(writer, request, http.StatusUnauthorized, i18n.ErrorAuthorizationMalformedHeader)
 			return
 		}
@@ -58,21 +80,41 @@ func (mw *AuthMiddleware) handler(writer rest.ResponseWriter, request *rest.Requ
 
 	ctx := request.Env[util.Context].(context.Context)
 
-	nsPtr, err := mw.Authenticator.Authenticate(ctx, token)
+	nsPtr, roles, err := auth.AuthenticateRoles(ctx, mw.Authenticator, token)
 	if err != nil {
 		switch err {
 		case auth.ErrEmptyToken:
+			metrics.AuthFailures.Inc("missing_token")
 			i18n.RestError(writer, request, http.StatusUnauthorized, i18n.ErrorAuthorizationMissingHeader)
 		case auth.ErrUnauthorized, auth.ErrUnrecognizedToken:
+			metrics.AuthFailures.Inc("unauthorized")
 			i18n.RestError(writer, request, http.StatusUnauthorized, i18n.ErrorAuthorizationNotAuthorized)
 		case auth.ErrCommunicationError:
+			metrics.AuthFailures.Inc("validation_failed")
 			i18n.RestError(writer, request, http.StatusServiceUnavailable, i18n.ErrorAuthorizationTokenValidationFailed)
 		default:
+			metrics.AuthFailures.Inc("error")
 			i18n.RestError(writer, request, http.StatusInternalServerError, i18n.ErrorInternalServer)
 		}
 		return
 	}
 
+	if mw.Policy != nil {
+		if role := mw.Policy.Role(request.Method, request.URL.Path); !roles.Grants(role) {
+			metrics.AuthFailures.Inc("forbidden")
+			i18n.RestError(writer, request, http.StatusForbidden, i18n.ErrorAuthorizationForbidden, map[string]interface{}{
+				"Role":   role,
+				"Method": request.Method,
+				"Path":   request.URL.Path,
+			})
+			return
+		}
+	}
+
+	// The principal is the namespace the token was issued for, even when acting on behalf of another namespace
+	request.Env[util.Principal] = nsPtr.String()
+	request.Env[util.Roles] = roles
+
 	// Recognize admin namespace and get the namespace from the header
 	if nsPtr.String() == adminNamespace {
 		nsStr := request.Header.Get(util.NamespaceHeader)
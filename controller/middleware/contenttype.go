@@ -0,0 +1,63 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package middleware
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+// YAMLMediaTypes are the media types accepted for YAML request bodies.
+var YAMLMediaTypes = []string{"application/x-yaml", "application/yaml", "text/yaml"}
+
+// ContentTypeChecker verifies the Content-Type header of requests with a body, and responds with a 415 error when
+// it is neither JSON nor YAML. The charset, when provided, must be UTF-8. Handlers that do not accept YAML decode
+// bodies as JSON, and so reject YAML bodies as invalid JSON.
+type ContentTypeChecker struct{}
+
+// MiddlewareFunc makes ContentTypeChecker implement the Middleware interface.
+func (mw *ContentTypeChecker) MiddlewareFunc(handler rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		mediatype, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		charset, ok := params["charset"]
+		if !ok {
+			charset = "UTF-8"
+		}
+
+		if r.ContentLength > 0 &&
+			!((mediatype == "application/json" || IsYAML(mediatype)) && strings.ToUpper(charset) == "UTF-8") {
+			rest.Error(w,
+				"Bad Content-Type or charset, expected 'application/json' or 'application/x-yaml'",
+				http.StatusUnsupportedMediaType,
+			)
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+// IsYAML returns whether the media type is a YAML media type.
+func IsYAML(mediatype string) bool {
+	for _, t := range YAMLMediaTypes {
+		if mediatype == t {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package rollouts
+
+import "fmt"
+
+// InvalidRolloutError occurs when a rollout is not valid
+type InvalidRolloutError struct {
+	// Errors describe each of the problems found with the rollout.
+	Errors []FieldError
+}
+
+// Error description
+func (e *InvalidRolloutError) Error() string {
+	if len(e.Errors) == 0 {
+		return "Invalid Rollout Error"
+	}
+	return fmt.Sprintf("Invalid Rollout Error: %v", e.Errors[0])
+}
+
+// FieldError describes a problem with a field of a rollout.
+type FieldError struct {
+	// Field of the rollout the problem was found in, such as "steps.0.weight".
+	Field string `json:"field"`
+
+	// Description of the problem.
+	Description string `json:"description"`
+}
+
+// String representation of the field error
+func (e FieldError) String() string {
+	return fmt.Sprintf("%v: %v", e.Field, e.Description)
+}
+
+// NotFoundError occurs when a rollout does not exist in a namespace
+type NotFoundError struct {
+	ID string
+}
+
+// Error description
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("Rollout %v not found", e.ID)
+}
+
+// ConflictError occurs when a rollout cannot be created or changed in its current state
+type ConflictError struct {
+	Message string
+}
+
+// Error description
+func (e *ConflictError) Error() string {
+	return e.Message
+}
@@ -0,0 +1,37 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package rollouts
+
+// Decision of a gate about a rollout.
+type Decision int
+
+// Decisions of gates.
+const (
+	// Proceed allows the rollout to advance when its step ends.
+	Proceed Decision = iota
+
+	// Hold keeps the rollout at its current step, even when the step ends.
+	Hold
+
+	// Abort routes all traffic back to the stable backend and ends the rollout.
+	Abort
+)
+
+// Gate is consulted on each running rollout before it advances, such as to hold or abort rollouts based on the
+// metrics of the canary backend.
+type Gate interface {
+	// Check decides whether the rollout in the namespace may advance. A rollout is held when checking fails.
+	Check(namespace string, rollout Rollout) (Decision, error)
+}
@@ -0,0 +1,478 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package rollouts
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/amalgam8/amalgam8/controller/rules"
+	"github.com/amalgam8/amalgam8/controller/storage"
+	"github.com/pborman/uuid"
+)
+
+// defaultInterval is how often running rollouts are checked when no interval is configured.
+const defaultInterval = 5 * time.Second
+
+// writeAttempts is how many times a change of the rollouts of a namespace is attempted, when the rollouts keep being
+// changed concurrently by other requests or controllers.
+const writeAttempts = 5
+
+// Manager manages the rollouts of each namespace, and advances running rollouts in the background.
+type Manager interface {
+	// Create validates the rollout, routes its destination according to its first step, and starts it.
+	Create(namespace string, rollout Rollout) (Rollout, error)
+
+	// Get returns a rollout of the namespace.
+	Get(namespace, id string) (Rollout, error)
+
+	// List returns the rollouts of the namespace.
+	List(namespace string) ([]Rollout, error)
+
+	// Delete removes a rollout from the namespace, aborting it first if it is still active. The route rule generated
+	// for the rollout is kept, so that the destination remains routed to the backend it was left at.
+	Delete(namespace, id string) error
+
+	// Pause keeps a running rollout at its current step.
+	Pause(namespace, id string) (Rollout, error)
+
+	// Resume restarts the current step of a paused rollout.
+	Resume(namespace, id string) (Rollout, error)
+
+	// Promote advances a running rollout to its next step without waiting for the current step to end.
+	Promote(namespace, id string) (Rollout, error)
+
+	// Abort routes all traffic back to the stable backend and ends an active rollout.
+	Abort(namespace, id string) (Rollout, error)
+
+	// Start advances running rollouts in the background until the manager is stopped.
+	Start()
+
+	// Stop the manager.
+	Stop()
+}
+
+// Config for the rollout manager.
+type Config struct {
+	// Store in which the rollouts of each namespace are persisted.
+	Store storage.Store
+
+	// Rules manager through which the route rules of rollouts are set.
+	Rules rules.Manager
+
+	// Gates consulted before running rollouts advance.
+	Gates []Gate
+
+	// Interval at which running rollouts are checked.
+	Interval time.Duration
+}
+
+// NewManager creates a rollout manager.
+func NewManager(conf Config) Manager {
+	interval := conf.Interval
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+
+	return &manager{
+		store:    conf.Store,
+		rules:    conf.Rules,
+		gates:    conf.Gates,
+		interval: interval,
+		stop:     make(chan struct{}),
+	}
+}
+
+type manager struct {
+	store    storage.Store
+	rules    rules.Manager
+	gates    []Gate
+	interval time.Duration
+	stop     chan struct{}
+}
+
+func (m *manager) Create(namespace string, rollout Rollout) (Rollout, error) {
+	if errs := rollout.validate(); len(errs) > 0 {
+		return Rollout{}, &InvalidRolloutError{Errors: errs}
+	}
+
+	rollout.ID = uuid.New()
+	rollout.Status = StatusRunning
+	rollout.Step = 0
+	rollout.StepStarted = time.Now()
+	rollout.Message = ""
+
+	routed := false
+	for attempt := 1; ; attempt++ {
+		rollouts, revision, err := m.read(namespace)
+		if err != nil {
+			return Rollout{}, err
+		}
+
+		for _, existing := range rollouts {
+			if existing.Active() && existing.Destination == rollout.Destination {
+				// The rollout that was created concurrently owns the route rule of the destination
+				if routed {
+					m.restore(namespace, existing)
+				}
+
+				return Rollout{}, &ConflictError{
+					Message: fmt.Sprintf("Rollout %v of destination %v is already in progress", existing.ID, existing.Destination),
+				}
+			}
+		}
+
+		if !routed {
+			if err := m.route(namespace, rollout); err != nil {
+				return Rollout{}, err
+			}
+			routed = true
+		}
+
+		err = m.write(namespace, revision, append(rollouts, rollout))
+		if isRevisionMismatch(err) && attempt < writeAttempts {
+			continue
+		} else if err != nil {
+			return Rollout{}, writeError(namespace, err)
+		}
+
+		return rollout, nil
+	}
+}
+
+func (m *manager) Get(namespace, id string) (Rollout, error) {
+	rollouts, _, err := m.read(namespace)
+	if err != nil {
+		return Rollout{}, err
+	}
+
+	i := indexOf(rollouts, id)
+	if i < 0 {
+		return Rollout{}, &NotFoundError{ID: id}
+	}
+
+	return rollouts[i], nil
+}
+
+func (m *manager) List(namespace string) ([]Rollout, error) {
+	rollouts, _, err := m.read(namespace)
+	return rollouts, err
+}
+
+func (m *manager) Delete(namespace, id string) error {
+	if _, err := m.Abort(namespace, id); err != nil {
+		if _, ok := err.(*ConflictError); !ok {
+			return err
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		rollouts, revision, err := m.read(namespace)
+		if err != nil {
+			return err
+		}
+
+		i := indexOf(rollouts, id)
+		if i < 0 {
+			return &NotFoundError{ID: id}
+		}
+
+		err = m.write(namespace, revision, append(rollouts[:i], rollouts[i+1:]...))
+		if isRevisionMismatch(err) && attempt < writeAttempts {
+			continue
+		}
+
+		return writeError(namespace, err)
+	}
+}
+
+func (m *manager) Pause(namespace, id string) (Rollout, error) {
+	return m.update(namespace, id, func(rollout *Rollout) error {
+		if rollout.Status != StatusRunning {
+			return statusConflict(*rollout)
+		}
+
+		rollout.Status = StatusPaused
+		return nil
+	})
+}
+
+func (m *manager) Resume(namespace, id string) (Rollout, error) {
+	return m.update(namespace, id, func(rollout *Rollout) error {
+		if rollout.Status != StatusPaused {
+			return statusConflict(*rollout)
+		}
+
+		rollout.Status = StatusRunning
+		rollout.StepStarted = time.Now()
+		return nil
+	})
+}
+
+func (m *manager) Promote(namespace, id string) (Rollout, error) {
+	return m.update(namespace, id, func(rollout *Rollout) error {
+		if rollout.Status != StatusRunning {
+			return statusConflict(*rollout)
+		}
+
+		rollout.next(time.Now())
+		return nil
+	})
+}
+
+func (m *manager) Abort(namespace, id string) (Rollout, error) {
+	return m.abort(namespace, id, "Aborted manually")
+}
+
+func (m *manager) abort(namespace, id, message string) (Rollout, error) {
+	return m.update(namespace, id, func(rollout *Rollout) error {
+		if !rollout.Active() {
+			return statusConflict(*rollout)
+		}
+
+		rollout.Status = StatusAborted
+		rollout.Message = message
+		return nil
+	})
+}
+
+func (m *manager) Start() {
+	go func() {
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				m.advance(now)
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (m *manager) Stop() {
+	close(m.stop)
+}
+
+// advance consults the gates of each running rollout, and advances the rollouts whose current step has ended.
+func (m *manager) advance(now time.Time) {
+	namespaces, err := m.store.Namespaces()
+	if err != nil {
+		logrus.WithError(err).Error("Could not read the namespaces of rollouts")
+		return
+	}
+
+	for _, namespace := range namespaces {
+		rollouts, _, err := m.read(namespace)
+		if err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{
+				"namespace": namespace,
+			}).Error("Could not read rollouts")
+			continue
+		}
+
+		for _, rollout := range rollouts {
+			if rollout.Status != StatusRunning {
+				continue
+			}
+
+			// The rollout may be changed by another request or controller while gates are consulted, in which case
+			// the update finds that it is no longer at the step it was checked at
+			switch m.check(namespace, rollout) {
+			case Abort:
+				_, err = m.abort(namespace, rollout.ID, "Aborted by a gate")
+			case Proceed:
+				if !rollout.stepEnded(now) {
+					continue
+				}
+
+				_, err = m.update(namespace, rollout.ID, func(current *Rollout) error {
+					if current.Status != StatusRunning || current.Step != rollout.Step {
+						return statusConflict(*current)
+					}
+
+					current.next(now)
+					return nil
+				})
+			default:
+				continue
+			}
+
+			if err != nil {
+				if _, ok := err.(*ConflictError); ok {
+					continue
+				}
+
+				logrus.WithError(err).WithFields(logrus.Fields{
+					"namespace": namespace,
+					"id":        rollout.ID,
+				}).Error("Could not advance rollout")
+			}
+		}
+	}
+}
+
+// check returns the most restrictive decision of the gates about the rollout.
+func (m *manager) check(namespace string, rollout Rollout) Decision {
+	decision := Proceed
+	for _, gate := range m.gates {
+		d, err := gate.Check(namespace, rollout)
+		if err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{
+				"namespace": namespace,
+				"id":        rollout.ID,
+			}).Warn("Rollout gate check failed")
+			d = Hold
+		}
+
+		if d > decision {
+			decision = d
+		}
+	}
+
+	return decision
+}
+
+// update applies the change to a rollout, and updates the route rule of the rollout if the weight of the canary
+// backend changed. The rollouts of the namespace are only written if they were not changed since they were read, so
+// that concurrent changes, such as those of controllers advancing the same rollout, apply one at a time. A change that
+// lost the race is applied again to the rollout written by the winner.
+func (m *manager) update(namespace, id string, change func(*Rollout) error) (Rollout, error) {
+	routed := false
+	for attempt := 1; ; attempt++ {
+		rollouts, revision, err := m.read(namespace)
+		if err != nil {
+			return Rollout{}, err
+		}
+
+		i := indexOf(rollouts, id)
+		if i < 0 {
+			return Rollout{}, &NotFoundError{ID: id}
+		}
+
+		current := rollouts[i]
+		rollout := current
+		if err := change(&rollout); err != nil {
+			// The route rule was set for a change that is not applied anymore
+			if routed {
+				m.restore(namespace, current)
+			}
+			return Rollout{}, err
+		}
+
+		if routed || rollout.weight() != current.weight() {
+			if err := m.route(namespace, rollout); err != nil {
+				return Rollout{}, err
+			}
+			routed = true
+		}
+
+		rollouts[i] = rollout
+		err = m.write(namespace, revision, rollouts)
+		if isRevisionMismatch(err) && attempt < writeAttempts {
+			continue
+		} else if err != nil {
+			if routed {
+				m.restore(namespace, current)
+			}
+			return Rollout{}, writeError(namespace, err)
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"namespace": namespace,
+			"id":        id,
+			"status":    rollout.Status,
+			"step":      rollout.Step,
+			"weight":    rollout.weight(),
+		}).Info("Rollout updated")
+
+		return rollout, nil
+	}
+}
+
+// read returns the rollouts of the namespace and their revision.
+func (m *manager) read(namespace string) ([]Rollout, int64, error) {
+	rollouts := []Rollout{}
+	revision, err := m.store.Read(namespace, &rollouts)
+	if err != nil {
+		return nil, 0, err
+	}
+	return rollouts, revision, nil
+}
+
+// write replaces the rollouts of the namespace if they are still at the revision, removing the namespace from the
+// store when it has no rollouts.
+func (m *manager) write(namespace string, revision int64, rollouts []Rollout) error {
+	if len(rollouts) == 0 {
+		return m.store.Delete(namespace, revision)
+	}
+	return m.store.Write(namespace, revision, rollouts)
+}
+
+// restore sets the route rule of the rollout as last written, after the rule was set for a change that was not
+// written.
+func (m *manager) restore(namespace string, rollout Rollout) {
+	if err := m.route(namespace, rollout); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"namespace": namespace,
+			"id":        rollout.ID,
+		}).Error("Could not restore the route rule of rollout")
+	}
+}
+
+// route replaces the route rule of the rollout according to its current state.
+func (m *manager) route(namespace string, rollout Rollout) error {
+	rule, err := rollout.rule()
+	if err != nil {
+		return err
+	}
+
+	_, err = m.rules.SetRules(namespace, rollout.filter(), []rules.Rule{rule}, rules.AnyRevision)
+	return err
+}
+
+// statusConflict describes why a rollout cannot be changed in its current status.
+func statusConflict(rollout Rollout) error {
+	return &ConflictError{
+		Message: fmt.Sprintf("Rollout %v is %v", rollout.ID, rollout.Status),
+	}
+}
+
+// writeError returns a conflict for changes that kept losing races with concurrent changes of the rollouts.
+func writeError(namespace string, err error) error {
+	if isRevisionMismatch(err) {
+		return &ConflictError{
+			Message: fmt.Sprintf("Rollouts of namespace %v are being changed concurrently", namespace),
+		}
+	}
+	return err
+}
+
+func isRevisionMismatch(err error) bool {
+	_, ok := err.(*rules.RevisionMismatchError)
+	return ok
+}
+
+func indexOf(rollouts []Rollout, id string) int {
+	for i, rollout := range rollouts {
+		if rollout.ID == id {
+			return i
+		}
+	}
+	return -1
+}
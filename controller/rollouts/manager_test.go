@@ -0,0 +1,306 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package rollouts
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/amalgam8/amalgam8/controller/rules"
+	"github.com/amalgam8/amalgam8/controller/storage"
+	"github.com/amalgam8/amalgam8/controller/util/encryption"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type MockValidator struct{}
+
+func (m *MockValidator) Validate(r rules.Rule) error {
+	return nil
+}
+
+type MockGate struct {
+	Decision Decision
+	Error    error
+}
+
+func (m *MockGate) Check(namespace string, rollout Rollout) (Decision, error) {
+	return m.Decision, m.Error
+}
+
+// racingStore changes the rollouts before the first write, as if another request or controller changed them after
+// they were read.
+type racingStore struct {
+	storage.Store
+	race func()
+}
+
+func (r *racingStore) Write(namespace string, revision int64, v interface{}) error {
+	if race := r.race; race != nil {
+		r.race = nil
+		race()
+	}
+	return r.Store.Write(namespace, revision, v)
+}
+
+var _ = Describe("Manager", func() {
+
+	Describe("with a memory store", func() {
+		testManager(func() storage.Store {
+			return storage.NewMemoryStore()
+		})
+	})
+
+	Describe("with a file store", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "rollouts")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		testManager(func() storage.Store {
+			enc, err := encryption.NewAES([]byte("0123456789abcdef0123456789abcdef"))
+			Expect(err).ToNot(HaveOccurred())
+
+			store, err := storage.NewFileStore(dir, enc)
+			Expect(err).ToNot(HaveOccurred())
+			return store
+		})
+	})
+})
+
+func testManager(newStore func() storage.Store) {
+	var (
+		ruleManager rules.Manager
+		gate        *MockGate
+		store       storage.Store
+		m           *manager
+		rollout     Rollout
+	)
+
+	// canaryWeight returns the weight of the canary backend in the route rule of the destination.
+	canaryWeight := func() float64 {
+		res, err := ruleManager.GetRules("namespace", rules.Filter{Destinations: []string{"reviews"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Rules).To(HaveLen(1))
+
+		route := rules.Route{}
+		Expect(json.Unmarshal(res.Rules[0].Route, &route)).To(Succeed())
+		for _, backend := range route.Backends {
+			if backend.Tags[0] == "v2" {
+				if backend.Weight == 0 {
+					return 1
+				}
+				return backend.Weight
+			}
+		}
+		return 0
+	}
+
+	BeforeEach(func() {
+		ruleManager = rules.NewMemoryManager(&MockValidator{})
+		gate = &MockGate{}
+		store = newStore()
+		m = NewManager(Config{
+			Store: store,
+			Rules: ruleManager,
+			Gates: []Gate{gate},
+		}).(*manager)
+
+		var err error
+		rollout, err = m.Create("namespace", Rollout{
+			Destination: "reviews",
+			StableTags:  []string{"v1"},
+			CanaryTags:  []string{"v2"},
+			Steps: []Step{
+				{Weight: 0.1, Duration: 60},
+				{Weight: 0.5},
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("routes the destination according to the first step", func() {
+		Expect(rollout.ID).ToNot(BeEmpty())
+		Expect(rollout.Status).To(Equal(StatusRunning))
+		Expect(rollout.Step).To(Equal(0))
+		Expect(canaryWeight()).To(Equal(0.1))
+
+		res, err := m.Get("namespace", rollout.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.ID).To(Equal(rollout.ID))
+
+		list, err := m.List("namespace")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(list).To(HaveLen(1))
+	})
+
+	It("rejects invalid rollouts", func() {
+		_, err := m.Create("namespace", Rollout{
+			Destination: "ratings",
+			StableTags:  []string{"v1"},
+			CanaryTags:  []string{"v1"},
+			Steps: []Step{
+				{Weight: 1.5},
+			},
+		})
+		Expect(err).To(BeAssignableToTypeOf(&InvalidRolloutError{}))
+		Expect(err.(*InvalidRolloutError).Errors).To(ConsistOf(
+			FieldError{Field: "canary_tags", Description: "Canary tags must differ from the stable tags"},
+			FieldError{Field: "steps.0.weight", Description: "Weight 1.5 is not between 0 and 1"},
+		))
+	})
+
+	It("rejects a second rollout of the destination", func() {
+		_, err := m.Create("namespace", Rollout{
+			Destination: "reviews",
+			StableTags:  []string{"v1"},
+			CanaryTags:  []string{"v3"},
+			Steps: []Step{
+				{Weight: 0.1},
+			},
+		})
+		Expect(err).To(BeAssignableToTypeOf(&ConflictError{}))
+	})
+
+	It("advances when the step ends", func() {
+		m.advance(time.Now().Add(30 * time.Second))
+		Expect(canaryWeight()).To(Equal(0.1))
+
+		m.advance(time.Now().Add(61 * time.Second))
+		Expect(canaryWeight()).To(Equal(0.5))
+
+		// The last step is gated manually
+		m.advance(time.Now().Add(time.Hour))
+		res, err := m.Get("namespace", rollout.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Step).To(Equal(1))
+		Expect(res.Status).To(Equal(StatusRunning))
+	})
+
+	It("completes when promoted past the last step", func() {
+		res, err := m.Promote("namespace", rollout.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Step).To(Equal(1))
+		Expect(canaryWeight()).To(Equal(0.5))
+
+		res, err = m.Promote("namespace", rollout.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Status).To(Equal(StatusCompleted))
+		Expect(canaryWeight()).To(Equal(1.0))
+
+		_, err = m.Promote("namespace", rollout.ID)
+		Expect(err).To(BeAssignableToTypeOf(&ConflictError{}))
+	})
+
+	It("does not advance while paused", func() {
+		res, err := m.Pause("namespace", rollout.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Status).To(Equal(StatusPaused))
+
+		m.advance(time.Now().Add(time.Hour))
+		Expect(canaryWeight()).To(Equal(0.1))
+
+		_, err = m.Promote("namespace", rollout.ID)
+		Expect(err).To(BeAssignableToTypeOf(&ConflictError{}))
+
+		res, err = m.Resume("namespace", rollout.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Status).To(Equal(StatusRunning))
+
+		m.advance(time.Now().Add(time.Hour))
+		Expect(canaryWeight()).To(Equal(0.5))
+	})
+
+	It("routes all traffic to the stable backend when aborted", func() {
+		res, err := m.Abort("namespace", rollout.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Status).To(Equal(StatusAborted))
+		Expect(canaryWeight()).To(Equal(0.0))
+
+		_, err = m.Resume("namespace", rollout.ID)
+		Expect(err).To(BeAssignableToTypeOf(&ConflictError{}))
+	})
+
+	It("is held by gates", func() {
+		gate.Decision = Hold
+		m.advance(time.Now().Add(time.Hour))
+		Expect(canaryWeight()).To(Equal(0.1))
+
+		gate.Decision = Proceed
+		gate.Error = errors.New("metrics unavailable")
+		m.advance(time.Now().Add(time.Hour))
+		Expect(canaryWeight()).To(Equal(0.1))
+	})
+
+	It("is aborted by gates", func() {
+		gate.Decision = Abort
+		m.advance(time.Now())
+
+		res, err := m.Get("namespace", rollout.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Status).To(Equal(StatusAborted))
+		Expect(canaryWeight()).To(Equal(0.0))
+	})
+
+	It("aborts the rollout when deleted", func() {
+		Expect(m.Delete("namespace", rollout.ID)).To(Succeed())
+		Expect(canaryWeight()).To(Equal(0.0))
+
+		_, err := m.Get("namespace", rollout.ID)
+		Expect(err).To(BeAssignableToTypeOf(&NotFoundError{}))
+
+		Expect(m.Delete("namespace", rollout.ID)).To(BeAssignableToTypeOf(&NotFoundError{}))
+	})
+
+	It("does not advance a rollout paused concurrently", func() {
+		other := NewManager(Config{Store: store, Rules: ruleManager}).(*manager)
+		m.store = &racingStore{Store: store, race: func() {
+			_, err := other.Pause("namespace", rollout.ID)
+			Expect(err).ToNot(HaveOccurred())
+		}}
+
+		m.advance(time.Now().Add(61 * time.Second))
+
+		res, err := m.Get("namespace", rollout.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Status).To(Equal(StatusPaused))
+		Expect(res.Step).To(Equal(0))
+		Expect(canaryWeight()).To(Equal(0.1))
+	})
+
+	It("applies a change again to a rollout promoted concurrently", func() {
+		other := NewManager(Config{Store: store, Rules: ruleManager}).(*manager)
+		m.store = &racingStore{Store: store, race: func() {
+			_, err := other.Promote("namespace", rollout.ID)
+			Expect(err).ToNot(HaveOccurred())
+		}}
+
+		res, err := m.Abort("namespace", rollout.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Status).To(Equal(StatusAborted))
+		Expect(res.Step).To(Equal(1))
+		Expect(canaryWeight()).To(Equal(0.0))
+	})
+}
@@ -0,0 +1,205 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package rollouts
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/amalgam8/amalgam8/controller/rules"
+)
+
+// Status of a rollout.
+const (
+	// StatusRunning rollouts advance through their steps as their gates allow.
+	StatusRunning = "running"
+
+	// StatusPaused rollouts stay at their current step until they are resumed.
+	StatusPaused = "paused"
+
+	// StatusCompleted rollouts have routed all traffic to the canary backend.
+	StatusCompleted = "completed"
+
+	// StatusAborted rollouts have routed all traffic back to the stable backend.
+	StatusAborted = "aborted"
+)
+
+// tagPrefix prefixes the ID of a rollout in the tags of the route rule generated for it.
+const tagPrefix = "a8_rollout="
+
+// Rollout progressively shifts the traffic of a destination from a stable backend to a canary backend.
+type Rollout struct {
+	ID          string   `json:"id"`
+	Destination string   `json:"destination"`
+	Priority    int      `json:"priority,omitempty"`
+	StableTags  []string `json:"stable_tags"`
+	CanaryTags  []string `json:"canary_tags"`
+	Steps       []Step   `json:"steps"`
+
+	Status      string    `json:"status"`
+	Step        int       `json:"step"`
+	StepStarted time.Time `json:"step_started"`
+	Message     string    `json:"message,omitempty"`
+}
+
+// Step is a stage of a rollout.
+type Step struct {
+	// Weight of the canary backend, between 0 and 1.
+	Weight float64 `json:"weight"`
+
+	// Duration in seconds after which the rollout advances to the next step. Steps without a duration are gated
+	// manually, and only advance when the rollout is promoted.
+	Duration float64 `json:"duration,omitempty"`
+}
+
+// Active returns whether the rollout still controls the route of its destination.
+func (r Rollout) Active() bool {
+	return r.Status == StatusRunning || r.Status == StatusPaused
+}
+
+// stepEnded returns whether the duration of the current step has elapsed. Steps without a duration never end on
+// their own.
+func (r Rollout) stepEnded(now time.Time) bool {
+	duration := r.Steps[r.Step].Duration
+	return duration > 0 && !now.Before(r.StepStarted.Add(time.Duration(duration*float64(time.Second))))
+}
+
+// next advances the rollout to its next step, completing the rollout after its last step.
+func (r *Rollout) next(now time.Time) {
+	if r.Step+1 < len(r.Steps) {
+		r.Step++
+		r.StepStarted = now
+	} else {
+		r.Status = StatusCompleted
+	}
+}
+
+// tag identifies the route rule generated for the rollout.
+func (r Rollout) tag() string {
+	return tagPrefix + r.ID
+}
+
+// filter selects the route rule generated for the rollout.
+func (r Rollout) filter() rules.Filter {
+	return rules.Filter{
+		Tags:            []string{r.tag()},
+		Destinations:    []string{r.Destination},
+		RuleType:        rules.RuleRoute,
+		IncludeInactive: true,
+	}
+}
+
+// weight returns the weight of the canary backend in the current state of the rollout.
+func (r Rollout) weight() float64 {
+	switch r.Status {
+	case StatusCompleted:
+		return 1
+	case StatusAborted:
+		return 0
+	default:
+		return r.Steps[r.Step].Weight
+	}
+}
+
+// rule generates the route rule for the current state of the rollout. The stable backend is left unweighted so that
+// it receives the remaining traffic, and backends that would receive no traffic are omitted.
+func (r Rollout) rule() (rules.Rule, error) {
+	weight := r.weight()
+
+	backends := make([]rules.Backend, 0, 2)
+	if weight > 0 {
+		backend := rules.Backend{Tags: r.CanaryTags}
+		if weight < 1 {
+			backend.Weight = weight
+		}
+		backends = append(backends, backend)
+	}
+	if weight < 1 {
+		backends = append(backends, rules.Backend{Tags: r.StableTags})
+	}
+
+	route, err := json.Marshal(&rules.Route{Backends: backends})
+	if err != nil {
+		return rules.Rule{}, &rules.JSONMarshalError{Message: err.Error()}
+	}
+
+	return rules.Rule{
+		Priority:    r.Priority,
+		Tags:        []string{r.tag()},
+		Destination: r.Destination,
+		Route:       route,
+	}, nil
+}
+
+// validate returns the problems with the definition of the rollout.
+func (r Rollout) validate() []FieldError {
+	errs := []FieldError{}
+	addError := func(field, format string, args ...interface{}) {
+		errs = append(errs, FieldError{
+			Field:       field,
+			Description: fmt.Sprintf(format, args...),
+		})
+	}
+
+	if r.Destination == "" {
+		addError("destination", "Destination is required")
+	}
+	if len(r.StableTags) == 0 {
+		addError("stable_tags", "Stable tags are required")
+	}
+	if len(r.CanaryTags) == 0 {
+		addError("canary_tags", "Canary tags are required")
+	} else if sameTags(r.StableTags, r.CanaryTags) {
+		addError("canary_tags", "Canary tags must differ from the stable tags")
+	}
+	if len(r.Steps) == 0 {
+		addError("steps", "At least one step is required")
+	}
+	for i, step := range r.Steps {
+		if step.Weight < 0 || step.Weight > 1 {
+			addError(fmt.Sprintf("steps.%v.weight", i), "Weight %v is not between 0 and 1", step.Weight)
+		}
+		if step.Duration < 0 {
+			addError(fmt.Sprintf("steps.%v.duration", i), "Duration %v is negative", step.Duration)
+		}
+	}
+
+	return errs
+}
+
+// sameTags returns whether the sets of tags are equal.
+func sameTags(a, b []string) bool {
+	setA, setB := tagSet(a), tagSet(b)
+	if len(setA) != len(setB) {
+		return false
+	}
+
+	for tag := range setA {
+		if !setB[tag] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func tagSet(tags []string) map[string]bool {
+	set := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		set[tag] = true
+	}
+	return set
+}
@@ -17,11 +17,37 @@ package rules
 import "fmt"
 
 // InvalidRuleError occurs when a rule is not valid
-type InvalidRuleError struct{}
+type InvalidRuleError struct {
+	// Errors describe each of the problems found with the rules.
+	Errors []RuleError
+}
 
 // Error description
 func (e *InvalidRuleError) Error() string {
-	return "Invalid Rule Error"
+	if len(e.Errors) == 0 {
+		return "Invalid Rule Error"
+	}
+	return fmt.Sprintf("Invalid Rule Error: %v", e.Errors[0])
+}
+
+// RuleError describes a problem with a field of a rule.
+type RuleError struct {
+	// Index of the rule in the list of rules provided.
+	Index int `json:"index"`
+
+	// ID of the rule, if it has been assigned one.
+	ID string `json:"id,omitempty"`
+
+	// Field of the rule the problem was found in, such as "route.backends.0.weight".
+	Field string `json:"field"`
+
+	// Description of the problem.
+	Description string `json:"description"`
+}
+
+// String representation of the rule error
+func (e RuleError) String() string {
+	return fmt.Sprintf("rule %v: %v: %v", e.Index, e.Field, e.Description)
 }
 
 // RedisInsertError occurs when there is an issue writing to Redis
@@ -41,3 +67,35 @@ type JSONMarshalError struct {
 func (e *JSONMarshalError) Error() string {
 	return fmt.Sprintf("Error marshaling JSON: %v", e.Message)
 }
+
+// RevisionNotFoundError occurs when a revision is not in the rule history of a namespace
+type RevisionNotFoundError struct {
+	Revision int64
+}
+
+// Error description
+func (e *RevisionNotFoundError) Error() string {
+	return fmt.Sprintf("Revision %v not found", e.Revision)
+}
+
+// RevisionMismatchError occurs when rules are changed on the condition that the namespace is at a revision, and the
+// namespace has moved to another revision
+type RevisionMismatchError struct {
+	Expected int64
+	Actual   int64
+}
+
+// Error description
+func (e *RevisionMismatchError) Error() string {
+	return fmt.Sprintf("Revision %v does not match current revision %v", e.Expected, e.Actual)
+}
+
+// InvalidPageError occurs when the sort order or continue cursor of a page of rules is not valid
+type InvalidPageError struct {
+	Message string
+}
+
+// Error description
+func (e *InvalidPageError) Error() string {
+	return fmt.Sprintf("Invalid page: %v", e.Message)
+}
@@ -0,0 +1,273 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package rules
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/amalgam8/amalgam8/controller/metrics"
+	"github.com/amalgam8/amalgam8/controller/util/encryption"
+)
+
+// fileExtension is the extension of the files in which namespaces and snapshots are stored.
+const fileExtension = ".json"
+
+// historyExtension is the extension of the directory in which the snapshots of a namespace are stored.
+const historyExtension = ".history"
+
+// NewFileManager creates a manager that keeps rules in memory and persists the rules of each namespace to a file in
+// the directory, so that they survive restarts. The snapshot of each revision in the history is written once to its
+// own file, so that a change only writes the current rules and the new snapshot. Files are encrypted unless the
+// encryption is nil.
+func NewFileManager(dir string, enc encryption.Encryption, validator Validator) (Manager, error) {
+	store := &fileStore{
+		dir:        dir,
+		encryption: enc,
+	}
+
+	states, err := store.load()
+	if err != nil {
+		return nil, err
+	}
+
+	m := newMemory(validator)
+	for namespace, state := range states {
+		m.rules[namespace] = make(map[string]Rule)
+		for _, rule := range state.Rules {
+			m.rules[namespace][rule.ID] = rule
+		}
+		m.revision[namespace] = state.Revision
+		m.history[namespace] = state.History
+		metrics.Rules.Set(float64(len(state.Rules)), namespace)
+	}
+	m.persister = store
+
+	return m, nil
+}
+
+// fileStore persists the state of each namespace to a file.
+type fileStore struct {
+	dir        string
+	encryption encryption.Encryption
+}
+
+// persist writes the snapshot at the new revision, atomically replaces the file of the namespace, and removes the
+// snapshot that fell out of the history.
+func (f *fileStore) persist(namespace string, state namespaceState, snapshot Snapshot) error {
+	defer metrics.StorageDuration.Since(time.Now(), "file_persist")
+
+	// The snapshot is written first, so that the history of a namespace includes its revision. Snapshots of revisions
+	// that were never reached are ignored when loaded.
+	if err := os.MkdirAll(f.historyPath(namespace), 0700); err != nil {
+		return err
+	}
+
+	if err := f.write(f.snapshotPath(namespace, snapshot.Revision), &snapshot); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"namespace": namespace,
+			"revision":  snapshot.Revision,
+		}).Error("Could not write rule snapshot to file")
+		return err
+	}
+
+	if err := f.write(f.path(namespace), &state); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"namespace": namespace,
+		}).Error("Could not write rules to file")
+		return err
+	}
+
+	if err := os.Remove(f.snapshotPath(namespace, snapshot.Revision-historyLimit)); err != nil && !os.IsNotExist(err) {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"namespace": namespace,
+		}).Warn("Could not remove rule snapshot file")
+	}
+
+	return nil
+}
+
+// write atomically replaces the file with the encrypted JSON encoding of the value.
+func (f *fileStore) write(path string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return &JSONMarshalError{Message: err.Error()}
+	}
+
+	data, err = f.encrypt(data)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file and rename it over the existing file so that the file is never partially written
+	tmp, err := ioutil.TempFile(f.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return nil
+}
+
+// read decodes the encrypted JSON encoding of the value from the file.
+func (f *fileStore) read(path string, v interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	data, err = f.decrypt(data)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"file": path,
+		}).Error("Could not decrypt rules file")
+		return err
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return &JSONMarshalError{Message: err.Error()}
+	}
+
+	return nil
+}
+
+// load reads the state of all namespaces from the directory, creating the directory if necessary.
+func (f *fileStore) load() (map[string]namespaceState, error) {
+	if err := os.MkdirAll(f.dir, 0700); err != nil {
+		return nil, err
+	}
+
+	filenames, err := filepath.Glob(filepath.Join(f.dir, "*"+fileExtension))
+	if err != nil {
+		return nil, err
+	}
+
+	states := make(map[string]namespaceState, len(filenames))
+	for _, filename := range filenames {
+		namespace, err := url.QueryUnescape(strings.TrimSuffix(filepath.Base(filename), fileExtension))
+		if err != nil {
+			return nil, err
+		}
+
+		state := namespaceState{}
+		if err := f.read(filename, &state); err != nil {
+			return nil, err
+		}
+
+		state.History, err = f.loadHistory(namespace, state.Revision)
+		if err != nil {
+			return nil, err
+		}
+
+		states[namespace] = state
+	}
+
+	return states, nil
+}
+
+// loadHistory reads the snapshots of the namespace up to the revision, from oldest to newest.
+func (f *fileStore) loadHistory(namespace string, revision int64) ([]Snapshot, error) {
+	history := []Snapshot{}
+	for rev := revision - historyLimit + 1; rev <= revision; rev++ {
+		snapshot := Snapshot{}
+		if err := f.read(f.snapshotPath(namespace, rev), &snapshot); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		history = append(history, snapshot)
+	}
+
+	return history, nil
+}
+
+func (f *fileStore) path(namespace string) string {
+	return filepath.Join(f.dir, url.QueryEscape(namespace)+fileExtension)
+}
+
+func (f *fileStore) historyPath(namespace string) string {
+	return filepath.Join(f.dir, url.QueryEscape(namespace)+historyExtension)
+}
+
+func (f *fileStore) snapshotPath(namespace string, revision int64) string {
+	return filepath.Join(f.historyPath(namespace), strconv.FormatInt(revision, 10)+fileExtension)
+}
+
+func (f *fileStore) encrypt(data []byte) ([]byte, error) {
+	// Short-circuit without encryption
+	if f.encryption == nil {
+		return data, nil
+	}
+
+	iv := f.encryption.NewIV()
+	payload, err := f.encryption.Encrypt(iv, data)
+	if err != nil {
+		return nil, err
+	}
+
+	e := Entry{
+		IV:      base64.StdEncoding.EncodeToString(iv),
+		Payload: base64.StdEncoding.EncodeToString(payload),
+	}
+
+	return json.Marshal(&e)
+}
+
+func (f *fileStore) decrypt(data []byte) ([]byte, error) {
+	// Short-circuit without encryption
+	if f.encryption == nil {
+		return data, nil
+	}
+
+	e := Entry{}
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+
+	iv, err := base64.StdEncoding.DecodeString(e.IV)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(e.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.encryption.Decrypt(iv, payload)
+}
@@ -0,0 +1,186 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package rules
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/amalgam8/amalgam8/controller/util/encryption"
+)
+
+func TestFileManagerReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	enc, err := encryption.NewAES([]byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager, err := NewFileManager(dir, enc, &MockValidator{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules := []Rule{
+		{
+			Destination: "reviews",
+			Route:       []byte(`{"backends":[{"tags":["v1"]}]}`),
+		},
+	}
+	added, err := manager.AddRules("test/namespace", rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The rules must not be stored in plain text
+	data, err := ioutil.ReadFile(filepath.Join(dir, "test%2Fnamespace.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("reviews")) {
+		t.Error("expected rules file to be encrypted")
+	}
+
+	data, err = ioutil.ReadFile(filepath.Join(dir, "test%2Fnamespace.history", "1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("reviews")) {
+		t.Error("expected snapshot file to be encrypted")
+	}
+
+	manager, err = NewFileManager(dir, enc, &MockValidator{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	retrieved, err := manager.GetRules("test/namespace", Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retrieved.Revision != 1 {
+		t.Errorf("expected revision 1, got %v", retrieved.Revision)
+	}
+	if len(retrieved.Rules) != 1 || retrieved.Rules[0].ID != added.IDs[0] {
+		t.Errorf("expected rule %v, got %v", added.IDs[0], retrieved.Rules)
+	}
+
+	history, err := manager.GetHistory("test/namespace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 {
+		t.Errorf("expected 1 revision in history, got %v", history)
+	}
+
+	// Loading with a different key must fail
+	other, err := encryption.NewAES([]byte("fedcba9876543210"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileManager(dir, other, &MockValidator{}); err == nil {
+		t.Error("expected error loading rules with a different key")
+	}
+}
+
+func TestFileManagerHistoryFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	manager, err := NewFileManager(dir, nil, &MockValidator{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	added, err := manager.AddRules("namespace", []Rule{
+		{
+			Destination: "reviews",
+			Route:       []byte(`{"backends":[{"tags":["v1"]}]}`),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revisions := historyLimit + 5
+	for priority := 2; priority <= revisions; priority++ {
+		rule := Rule{
+			ID:          added.IDs[0],
+			Priority:    priority,
+			Destination: "reviews",
+			Route:       []byte(`{"backends":[{"tags":["v1"]}]}`),
+		}
+		if err := manager.UpdateRules("namespace", []Rule{rule}, AnyRevision); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The file of the namespace only holds the current rules
+	data, err := ioutil.ReadFile(filepath.Join(dir, "namespace.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := map[string]interface{}{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := state["history"]; exists {
+		t.Error("expected history to be stored separately from the rules")
+	}
+
+	snapshots, err := filepath.Glob(filepath.Join(dir, "namespace.history", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != historyLimit {
+		t.Errorf("expected %v snapshot files, got %v", historyLimit, len(snapshots))
+	}
+
+	manager, err = NewFileManager(dir, nil, &MockValidator{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := manager.GetHistory("namespace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != historyLimit {
+		t.Fatalf("expected %v revisions in history, got %v", historyLimit, len(history))
+	}
+	if history[0].Revision != int64(revisions-historyLimit+1) || history[len(history)-1].Revision != int64(revisions) {
+		t.Errorf("expected revisions %v to %v, got %v", revisions-historyLimit+1, revisions, history)
+	}
+
+	snapshot, err := manager.GetSnapshot("namespace", int64(revisions-1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshot.Rules) != 1 || snapshot.Rules[0].Priority != revisions-1 {
+		t.Errorf("expected rule of priority %v at revision %v, got %v", revisions-1, revisions-1, snapshot.Rules)
+	}
+}
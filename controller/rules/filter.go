@@ -15,7 +15,11 @@
 package rules
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"sort"
+	"time"
 )
 
 const (
@@ -45,12 +49,151 @@ type Filter struct {
 
 	// RuleType is the type of rule to filter by.
 	RuleType int
+
+	// IncludeInactive includes rules outside of their activation window, which are otherwise filtered out.
+	IncludeInactive bool
+
+	// SortBy orders the rules by SortID or SortPriority. Rules are not sorted when SortBy is empty, unless a page is
+	// requested, in which case they are sorted by ID. Sorting and pagination only apply when rules are retrieved.
+	SortBy string
+
+	// Limit is the maximum number of rules in a page. This field is ignored when Limit <= 0.
+	Limit int
+
+	// Continue is the cursor returned with the previous page of rules, after which the page starts. This field is
+	// ignored when empty.
+	Continue string
 }
 
+// Orders in which rules are sorted.
+const (
+	// SortID sorts rules by ID.
+	SortID = "id"
+
+	// SortPriority sorts rules by decreasing priority, and rules of equal priority by ID.
+	SortPriority = "priority"
+)
+
 // Empty returns whether the filter has any attributes that would cause rules to be filtered out. A filter is considered
-// empty if no rules would be filtered out from any set of rules.
+// empty if no rules would be filtered out from any set of rules. Rules outside of their activation window are filtered
+// by IncludeInactive, regardless of whether the filter is empty.
 func (f Filter) Empty() bool {
-	return len(f.IDs) == 0 && len(f.Tags) == 0 && len(f.Destinations) == 0 && f.RuleType == RuleAny
+	return len(f.IDs) == 0 && len(f.Tags) == 0 && len(f.Destinations) == 0 && f.RuleType == RuleAny &&
+		f.Limit <= 0 && f.Continue == ""
+}
+
+// paged returns whether the filter sorts or pages the rules.
+func (f Filter) paged() bool {
+	return f.SortBy != "" || f.Limit > 0 || f.Continue != ""
+}
+
+// order returns the order in which the filter sorts the rules.
+func (f Filter) order() string {
+	if f.SortBy == "" {
+		return SortID
+	}
+	return f.SortBy
+}
+
+// cursor is the position of the last rule of a page in the sort order.
+type cursor struct {
+	SortBy   string `json:"sort"`
+	ID       string `json:"id"`
+	Priority int    `json:"priority,omitempty"`
+}
+
+// newCursor returns the cursor positioned at the rule.
+func newCursor(sortBy string, rule Rule) cursor {
+	c := cursor{SortBy: sortBy, ID: rule.ID}
+	if sortBy == SortPriority {
+		c.Priority = rule.Priority
+	}
+	return c
+}
+
+// encode returns the opaque representation of the cursor.
+func (c cursor) encode() string {
+	data, _ := json.Marshal(&c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor decodes the continue cursor of the filter. Returns nil when the filter has no cursor.
+func decodeCursor(f Filter) (*cursor, error) {
+	sortBy := f.order()
+	if sortBy != SortID && sortBy != SortPriority {
+		return nil, &InvalidPageError{Message: fmt.Sprintf("unknown sort order %q", f.SortBy)}
+	}
+
+	if f.Continue == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(f.Continue)
+	if err != nil {
+		return nil, &InvalidPageError{Message: "malformed continue cursor"}
+	}
+
+	c := &cursor{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, &InvalidPageError{Message: "malformed continue cursor"}
+	}
+
+	if c.SortBy != sortBy {
+		return nil, &InvalidPageError{Message: "continue cursor of a different sort order"}
+	}
+
+	return c, nil
+}
+
+// before returns whether the rule comes before the cursor in its sort order.
+func (c cursor) before(rule Rule) bool {
+	if c.SortBy == SortPriority && rule.Priority != c.Priority {
+		return rule.Priority > c.Priority
+	}
+	return rule.ID <= c.ID
+}
+
+type rulesByID []Rule
+
+func (r rulesByID) Len() int           { return len(r) }
+func (r rulesByID) Less(i, j int) bool { return r[i].ID < r[j].ID }
+func (r rulesByID) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+
+// PageRules sorts the rules in the order of the filter, and returns the page of rules selected by the limit and
+// continue cursor of the filter, with the cursor of the next page. The cursor is empty on the last page. Rules are
+// returned unchanged when the filter does not sort or page them.
+func PageRules(f Filter, rules []Rule) ([]Rule, string, error) {
+	if !f.paged() {
+		return rules, "", nil
+	}
+
+	c, err := decodeCursor(f)
+	if err != nil {
+		return nil, "", err
+	}
+
+	sorted := make([]Rule, len(rules))
+	copy(sorted, rules)
+
+	sortBy := f.order()
+	if sortBy == SortPriority {
+		sortByPriority(sorted)
+	} else {
+		sort.Sort(rulesByID(sorted))
+	}
+
+	start := 0
+	if c != nil {
+		start = sort.Search(len(sorted), func(i int) bool { return !c.before(sorted[i]) })
+	}
+	sorted = sorted[start:]
+
+	if f.Limit <= 0 || len(sorted) <= f.Limit {
+		return sorted, "", nil
+	}
+
+	page := sorted[:f.Limit]
+	return page, newCursor(sortBy, page[len(page)-1]).encode(), nil
 }
 
 // String representation of the filter
@@ -89,9 +232,16 @@ func FilterRules(f Filter, rules []Rule) []Rule {
 		}
 	}
 
+	now := time.Now()
+
 	// Iterate through the rules, building a new list of rules that pass the filter
 	res := make([]Rule, 0, len(rules)) // Filtered rules
 	for _, rule := range rules {
+		// Filter out rules outside of their activation window
+		if !f.IncludeInactive && !rule.Active(now) {
+			continue
+		}
+
 		// Filter by ID. The ID must be a member of the set of acceptable IDs.
 		if ids != nil {
 			if _, exists := ids[rule.ID]; !exists {
@@ -17,6 +17,7 @@ package rules
 import (
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestFilterRules(t *testing.T) {
@@ -37,6 +38,15 @@ func TestFilterRules(t *testing.T) {
 		Route:       []byte(`{}`),
 	}
 
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	scheduled := rule1
+	scheduled.NotBefore = &future
+
+	expired := rule2
+	expired.NotAfter = &past
+
 	cases := []struct {
 		In, Out []Rule
 		Filter  Filter
@@ -98,6 +108,30 @@ func TestFilterRules(t *testing.T) {
 				RuleType: RuleRoute,
 			},
 		},
+		{ // Filter rules outside of their activation window
+			In: []Rule{
+				scheduled,
+				expired,
+				rule1,
+			},
+			Out: []Rule{
+				rule1,
+			},
+			Filter: Filter{},
+		},
+		{ // Include rules outside of their activation window
+			In: []Rule{
+				scheduled,
+				expired,
+			},
+			Out: []Rule{
+				scheduled,
+				expired,
+			},
+			Filter: Filter{
+				IncludeInactive: true,
+			},
+		},
 	}
 	for _, c := range cases {
 		actual := FilterRules(c.Filter, c.In)
@@ -106,3 +140,110 @@ func TestFilterRules(t *testing.T) {
 		}
 	}
 }
+
+func TestFilterEmpty(t *testing.T) {
+	cases := []struct {
+		Filter Filter
+		Empty  bool
+	}{
+		{Filter{}, true},
+		{Filter{IncludeInactive: true}, true},
+		{Filter{SortBy: SortPriority}, true},
+		{Filter{IDs: []string{"id1"}}, false},
+		{Filter{Tags: []string{"tag1"}}, false},
+		{Filter{Destinations: []string{"service1"}}, false},
+		{Filter{RuleType: RuleRoute}, false},
+		{Filter{Limit: 10}, false},
+	}
+
+	for i, c := range cases {
+		if c.Filter.Empty() != c.Empty {
+			t.Errorf("Case %v: expected empty %v", i, c.Empty)
+		}
+	}
+}
+
+func TestPageRules(t *testing.T) {
+	rules := []Rule{
+		{ID: "d", Priority: 1},
+		{ID: "b", Priority: 2},
+		{ID: "a", Priority: 1},
+		{ID: "c", Priority: 3},
+		{ID: "e", Priority: 2},
+	}
+
+	cases := []struct {
+		Filter Filter
+		Pages  [][]string
+	}{
+		{ // Unsorted rules are returned unchanged
+			Filter: Filter{},
+			Pages:  [][]string{{"d", "b", "a", "c", "e"}},
+		},
+		{
+			Filter: Filter{SortBy: SortID},
+			Pages:  [][]string{{"a", "b", "c", "d", "e"}},
+		},
+		{ // Pages are sorted by ID by default
+			Filter: Filter{Limit: 2},
+			Pages:  [][]string{{"a", "b"}, {"c", "d"}, {"e"}},
+		},
+		{
+			Filter: Filter{SortBy: SortPriority, Limit: 2},
+			Pages:  [][]string{{"c", "b"}, {"e", "a"}, {"d"}},
+		},
+		{ // The last page is full
+			Filter: Filter{SortBy: SortPriority, Limit: 5},
+			Pages:  [][]string{{"c", "b", "e", "a", "d"}},
+		},
+	}
+
+	for _, c := range cases {
+		f := c.Filter
+		for i, expected := range c.Pages {
+			page, next, err := PageRules(f, rules)
+			if err != nil {
+				t.Fatalf("PageRules(%v): unexpected error %v", f, err)
+			}
+
+			ids := make([]string, len(page))
+			for j, rule := range page {
+				ids[j] = rule.ID
+			}
+			if !reflect.DeepEqual(ids, expected) {
+				t.Errorf("PageRules(%v): page %v: expected %v, got %v", f, i, expected, ids)
+			}
+
+			if (next == "") != (i == len(c.Pages)-1) {
+				t.Errorf("PageRules(%v): page %v: unexpected cursor %q", f, i, next)
+			}
+			f.Continue = next
+		}
+	}
+
+	// A cursor continues from its position even if the rule at the position has been removed
+	page, next, err := PageRules(Filter{Limit: 2}, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _, err = PageRules(Filter{Limit: 2, Continue: next}, []Rule{rules[0], rules[3], rules[4]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 2 || page[0].ID != "c" || page[1].ID != "d" {
+		t.Errorf("expected page [c d] after removal, got %v", page)
+	}
+
+	invalid := []Filter{
+		{SortBy: "destination"},
+		{Limit: 2, Continue: "not a cursor"},
+		{SortBy: SortPriority, Limit: 2, Continue: next}, // Cursor of another sort order
+	}
+	for _, f := range invalid {
+		if _, _, err := PageRules(f, rules); err == nil {
+			t.Errorf("PageRules(%v): expected error", f)
+		} else if _, ok := err.(*InvalidPageError); !ok {
+			t.Errorf("PageRules(%v): expected InvalidPageError, got %v", f, err)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package rules
+
+import (
+	"sort"
+	"time"
+)
+
+// historyLimit is the number of most recent revisions retained in the history of each namespace.
+const historyLimit = 100
+
+// Snapshot is the complete collection of rules in a namespace at a revision.
+type Snapshot struct {
+	// Revision of the rules.
+	Revision int64 `json:"revision"`
+
+	// Timestamp of the change that produced the revision.
+	Timestamp time.Time `json:"timestamp"`
+
+	// Rules in the namespace at the revision.
+	Rules []Rule `json:"rules"`
+}
+
+// RevisionSummary describes a revision in the rule history of a namespace.
+type RevisionSummary struct {
+	// Revision of the rules.
+	Revision int64 `json:"revision"`
+
+	// Timestamp of the change that produced the revision.
+	Timestamp time.Time `json:"timestamp"`
+
+	// Count of the rules in the namespace at the revision.
+	Count int `json:"count"`
+}
+
+// sortSummaries sorts revision summaries from oldest to newest.
+func sortSummaries(summaries []RevisionSummary) {
+	sort.Sort(summariesByRevision(summaries))
+}
+
+type summariesByRevision []RevisionSummary
+
+func (s summariesByRevision) Len() int           { return len(s) }
+func (s summariesByRevision) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s summariesByRevision) Less(i, j int) bool { return s[i].Revision < s[j].Revision }
@@ -0,0 +1,133 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package rules
+
+import "fmt"
+
+// Modes of importing rules into a namespace.
+const (
+	// ImportMerge keeps the existing rules, except for those that conflict with imported rules, which are replaced.
+	ImportMerge = "merge"
+
+	// ImportReplace replaces all the existing rules in the scope of the import.
+	ImportReplace = "replace"
+
+	// ImportFailOnConflict adds the imported rules, and fails when any of them conflicts with an existing rule.
+	ImportFailOnConflict = "fail-on-conflict"
+)
+
+// ImportPlan describes the changes an import makes to the rules of a namespace.
+type ImportPlan struct {
+	// Added are the imported rules.
+	Added []Rule `json:"added"`
+
+	// Removed are the existing rules that are deleted by the import.
+	Removed []Rule `json:"removed"`
+
+	// Conflicts are the existing rules that conflict with imported rules.
+	Conflicts []Rule `json:"conflicts"`
+}
+
+// ImportConflictError occurs when rules imported in the fail-on-conflict mode conflict with existing rules.
+type ImportConflictError struct {
+	Conflicts []Rule
+}
+
+// Error description
+func (e *ImportConflictError) Error() string {
+	return fmt.Sprintf("Import conflicts with %v existing rules", len(e.Conflicts))
+}
+
+// InvalidImportModeError occurs when rules are imported in an unknown mode.
+type InvalidImportModeError struct {
+	Mode string
+}
+
+// Error description
+func (e *InvalidImportModeError) Error() string {
+	return fmt.Sprintf("Invalid import mode %q", e.Mode)
+}
+
+// Conflicts returns the existing rules that conflict with any of the rules. Rules conflict when they have the same
+// ID, or the same destination, type, priority and match conditions.
+func Conflicts(existing, rules []Rule) []Rule {
+	conflicts := []Rule{}
+	for _, e := range existing {
+		for _, r := range rules {
+			if (r.ID != "" && r.ID == e.ID) || conflicting(e, r) {
+				conflicts = append(conflicts, e)
+				break
+			}
+		}
+	}
+	return conflicts
+}
+
+// PlanImport returns the changes made by importing the rules into a namespace with the existing rules. The scope
+// filter selects the existing rules replaced in the replace mode.
+func PlanImport(mode string, existing, rules []Rule, scope Filter) (ImportPlan, error) {
+	plan := ImportPlan{
+		Added:     rules,
+		Conflicts: Conflicts(existing, rules),
+	}
+
+	switch mode {
+	case ImportMerge:
+		plan.Removed = plan.Conflicts
+	case ImportReplace:
+		scope.IncludeInactive = true
+		plan.Removed = FilterRules(scope, existing)
+	case ImportFailOnConflict:
+		if len(plan.Conflicts) > 0 {
+			return plan, &ImportConflictError{Conflicts: plan.Conflicts}
+		}
+		plan.Removed = []Rule{}
+	default:
+		return plan, &InvalidImportModeError{Mode: mode}
+	}
+
+	return plan, nil
+}
+
+// Import imports the rules into the namespace. The existing rules removed by the import are deleted and the rules are
+// added as a single atomic transaction. Imported rules are assigned new IDs.
+func Import(m Manager, namespace, mode string, rules []Rule, scope Filter) (ImportPlan, NewRules, error) {
+	existing, err := m.GetRules(namespace, Filter{IncludeInactive: true})
+	if err != nil {
+		return ImportPlan{}, NewRules{}, err
+	}
+
+	plan, err := PlanImport(mode, existing.Rules, rules, scope)
+	if err != nil {
+		return plan, NewRules{}, err
+	}
+
+	var newRules NewRules
+	switch {
+	case mode == ImportReplace:
+		newRules, err = m.SetRules(namespace, scope, rules, AnyRevision)
+	case len(plan.Removed) > 0:
+		ids := make([]string, len(plan.Removed))
+		for i, rule := range plan.Removed {
+			ids[i] = rule.ID
+		}
+		newRules, err = m.SetRules(namespace, Filter{IDs: ids}, rules, AnyRevision)
+	default:
+		// An empty filter matches all rules, so rules that replace none are added
+		newRules, err = m.AddRules(namespace, rules)
+	}
+
+	return plan, newRules, err
+}
@@ -0,0 +1,147 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package rules
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlanImport(t *testing.T) {
+	existing := []Rule{
+		{ID: "a", Destination: "reviews", Priority: 1, Route: []byte(`{"backends":[{"tags":["v1"]}]}`)},
+		{ID: "b", Destination: "reviews", Priority: 2, Match: []byte(`{"headers":{"x":"1"}}`), Route: []byte(`{"backends":[{"tags":["v2"]}]}`)},
+		{ID: "c", Destination: "ratings", Priority: 1, Actions: []byte(`[{"action":"trace"}]`)},
+	}
+
+	imported := []Rule{
+		// Conflicts with rule "b", whose match is equal by value
+		{Destination: "reviews", Priority: 2, Match: []byte(`{ "headers": {"x": "1"} }`), Route: []byte(`{"backends":[{"tags":["v3"]}]}`)},
+		// Conflicts with rule "c" by ID
+		{ID: "c", Destination: "details", Priority: 1, Actions: []byte(`[{"action":"trace"}]`)},
+		// Same destination and priority as rule "a", but a different rule type
+		{Destination: "reviews", Priority: 1, Actions: []byte(`[{"action":"trace"}]`)},
+	}
+
+	cases := []struct {
+		Name     string
+		Mode     string
+		Scope    Filter
+		Removed  []string
+		Conflict bool
+		Invalid  bool
+	}{
+		{Name: "merge", Mode: ImportMerge, Removed: []string{"b", "c"}},
+		{Name: "replace all", Mode: ImportReplace, Removed: []string{"a", "b", "c"}},
+		{Name: "replace destination", Mode: ImportReplace, Scope: Filter{Destinations: []string{"ratings"}}, Removed: []string{"c"}},
+		{Name: "fail on conflict", Mode: ImportFailOnConflict, Conflict: true},
+		{Name: "unknown mode", Mode: "overwrite", Invalid: true},
+	}
+
+	for _, c := range cases {
+		plan, err := PlanImport(c.Mode, existing, imported, c.Scope)
+
+		_, conflict := err.(*ImportConflictError)
+		_, invalid := err.(*InvalidImportModeError)
+		if conflict != c.Conflict || invalid != c.Invalid || (err != nil && !conflict && !invalid) {
+			t.Errorf("%v: unexpected error %v", c.Name, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		if ids := ruleIDs(plan.Removed); !reflect.DeepEqual(ids, c.Removed) {
+			t.Errorf("%v: removed %v, expected %v", c.Name, ids, c.Removed)
+		}
+		if ids := ruleIDs(plan.Conflicts); !reflect.DeepEqual(ids, []string{"b", "c"}) {
+			t.Errorf("%v: conflicts %v, expected [b c]", c.Name, ids)
+		}
+		if len(plan.Added) != len(imported) {
+			t.Errorf("%v: added %v rules, expected %v", c.Name, len(plan.Added), len(imported))
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	m := NewMemoryManager(&MockValidator{})
+	if _, err := m.AddRules("ns", []Rule{
+		{Destination: "reviews", Priority: 1, Route: []byte(`{"backends":[{"tags":["v1"]}]}`)},
+		{Destination: "ratings", Priority: 1, Route: []byte(`{"backends":[{"tags":["v1"]}]}`)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	imported := []Rule{
+		{Destination: "reviews", Priority: 1, Route: []byte(`{"backends":[{"tags":["v2"]}]}`)},
+	}
+
+	if _, _, err := Import(m, "ns", ImportFailOnConflict, imported, Filter{}); err == nil {
+		t.Error("expected conflict error")
+	}
+
+	_, newRules, err := Import(m, "ns", ImportMerge, imported, Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := m.GetRules("ns", Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Rules) != 2 {
+		t.Fatalf("expected 2 rules after merge, got %v", len(res.Rules))
+	}
+	for _, rule := range res.Rules {
+		if rule.Destination == "reviews" && rule.ID != newRules.IDs[0] {
+			t.Errorf("expected rule %v to replace the conflicting rule, got %v", newRules.IDs[0], rule.ID)
+		}
+	}
+
+	// Merging rules without conflicts must not remove any rules
+	if _, _, err := Import(m, "ns", ImportMerge, []Rule{
+		{Destination: "details", Priority: 1, Route: []byte(`{"backends":[{"tags":["v1"]}]}`)},
+	}, Filter{}); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err = m.GetRules("ns", Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Rules) != 3 {
+		t.Errorf("expected 3 rules after merge, got %v", len(res.Rules))
+	}
+
+	if _, _, err := Import(m, "ns", ImportReplace, imported, Filter{}); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err = m.GetRules("ns", Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Rules) != 1 {
+		t.Errorf("expected 1 rule after replace, got %v", len(res.Rules))
+	}
+}
+
+func ruleIDs(rs []Rule) []string {
+	ids := []string{}
+	for _, rule := range rs {
+		ids = append(ids, rule.ID)
+	}
+	return ids
+}
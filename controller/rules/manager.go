@@ -14,6 +14,11 @@
 
 package rules
 
+import "time"
+
+// AnyRevision is the revision precondition of changes that apply whatever the revision of the namespace is.
+const AnyRevision int64 = -1
+
 // Manager is an interface for managing collections of rules mapped by namespace.
 type Manager interface {
 	// AddRules validates the rules and adds them to the collection for the namespace.
@@ -22,21 +27,41 @@ type Manager interface {
 	// GetRules returns a collection of filtered rules from the namespace.
 	GetRules(namespace string, filter Filter) (RetrievedRules, error)
 
-	// UpdateRules updates rules by ID in the namespace.
-	UpdateRules(namespace string, rules []Rule) error
+	// UpdateRules updates rules by ID in the namespace. Unless the revision is AnyRevision, the rules are only updated
+	// if the namespace is at the revision.
+	UpdateRules(namespace string, rules []Rule, revision int64) error
 
-	// DeleteRules deletes rules that match the filter in the namespace.
-	DeleteRules(namespace string, filter Filter) error
+	// DeleteRules deletes rules that match the filter in the namespace. Unless the revision is AnyRevision, the rules
+	// are only deleted if the namespace is at the revision.
+	DeleteRules(namespace string, filter Filter, revision int64) error
 
 	// SetRules deletes the rules that match the filter and adds the new rules as a single
-	// atomic transaction.
-	SetRules(namespace string, filter Filter, rules []Rule) (NewRules, error)
+	// atomic transaction. Unless the revision is AnyRevision, the rules are only set if the namespace is at the
+	// revision.
+	SetRules(namespace string, filter Filter, rules []Rule, revision int64) (NewRules, error)
+
+	// WatchRules blocks until the revision of the rules in the namespace is greater than the provided revision,
+	// or until the timeout expires.
+	WatchRules(namespace string, revision int64, timeout time.Duration) error
+
+	// GetHistory returns summaries of the revisions retained in the rule history of the namespace, oldest first.
+	GetHistory(namespace string) ([]RevisionSummary, error)
+
+	// GetSnapshot returns the rules of the namespace at a revision in the rule history.
+	GetSnapshot(namespace string, revision int64) (Snapshot, error)
+
+	// Rollback replaces all the rules in the namespace with the rules at a revision in the rule history. The
+	// rollback is recorded in the history as a new revision.
+	Rollback(namespace string, revision int64) error
 }
 
 // NewRules provides information about newly added rules.
 type NewRules struct {
 	// IDs of the added rules.
 	IDs []string
+
+	// Warnings about added rules that are valid but are likely mistakes.
+	Warnings []RuleError
 }
 
 // RetrievedRules are the results of a read from a manager.
@@ -47,4 +72,7 @@ type RetrievedRules struct {
 	// Revision of the rules for this namespace. Each time the collection of rules for the namespace are changed
 	// the revision is incremented.
 	Revision int64
+
+	// Continue is the cursor from which the next page of rules continues, or empty if this is the last page.
+	Continue string
 }
@@ -16,6 +16,9 @@ package rules
 
 import (
 	"errors"
+	"io/ioutil"
+	"os"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -29,8 +32,34 @@ func (m *MockValidator) Validate(r Rule) error {
 	return m.Error
 }
 
-var _ = Describe("Manager", func() {
+var _ = Describe("Memory manager", func() {
+	testManager(func(validator Validator) Manager {
+		return NewMemoryManager(validator)
+	})
+})
+
+var _ = Describe("File manager", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "rules")
+		Expect(err).ToNot(HaveOccurred())
+	})
 
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	testManager(func(validator Validator) Manager {
+		manager, err := NewFileManager(dir, nil, validator)
+		Expect(err).ToNot(HaveOccurred())
+		return manager
+	})
+})
+
+// testManager runs the common manager tests against managers created by newManager.
+func testManager(newManager func(Validator) Manager) {
 	var (
 		validator Validator
 		manager   Manager
@@ -43,7 +72,7 @@ var _ = Describe("Manager", func() {
 	})
 
 	JustBeforeEach(func() {
-		manager = NewMemoryManager(validator)
+		manager = newManager(validator)
 	})
 
 	Describe("simple CRUD operations", func() {
@@ -104,7 +133,7 @@ var _ = Describe("Manager", func() {
 								Destination: NewDestination,
 							},
 						}
-						err = manager.UpdateRules(namespace, rules)
+						err = manager.UpdateRules(namespace, rules, AnyRevision)
 					})
 
 					Context("the modified rule is valid", func() {
@@ -143,7 +172,7 @@ var _ = Describe("Manager", func() {
 						filter := Filter{
 							IDs: newRules.IDs,
 						}
-						err = manager.DeleteRules(namespace, filter)
+						err = manager.DeleteRules(namespace, filter, AnyRevision)
 					})
 
 					It("should not error", func() {
@@ -209,4 +238,279 @@ var _ = Describe("Manager", func() {
 			})
 		})
 	})
-})
+
+	Describe("watching rules", func() {
+		var rules []Rule
+
+		BeforeEach(func() {
+			rules = []Rule{
+				{
+					Destination: "DestinationX",
+				},
+			}
+		})
+
+		It("returns immediately when the revision is already newer", func() {
+			_, err := manager.AddRules(namespace, rules)
+			Expect(err).ToNot(HaveOccurred())
+
+			start := time.Now()
+			Expect(manager.WatchRules(namespace, 0, time.Minute)).To(Succeed())
+			Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+		})
+
+		It("returns when the rules change", func() {
+			done := make(chan error)
+			go func() {
+				done <- manager.WatchRules(namespace, 0, time.Minute)
+			}()
+
+			Consistently(done, 100*time.Millisecond).ShouldNot(Receive())
+
+			_, err := manager.AddRules(namespace, rules)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(done).Should(Receive(BeNil()))
+		})
+
+		It("ignores changes to other namespaces", func() {
+			done := make(chan error)
+			go func() {
+				done <- manager.WatchRules(namespace, 0, time.Minute)
+			}()
+
+			_, err := manager.AddRules("other", rules)
+			Expect(err).ToNot(HaveOccurred())
+
+			Consistently(done, 100*time.Millisecond).ShouldNot(Receive())
+		})
+
+		It("returns when the timeout expires", func() {
+			Expect(manager.WatchRules(namespace, 0, 50*time.Millisecond)).To(Succeed())
+		})
+	})
+
+	Describe("paging rules", func() {
+		JustBeforeEach(func() {
+			rules := make([]Rule, 5)
+			for i := range rules {
+				rules[i] = Rule{Destination: "DestinationX", Priority: i % 2}
+			}
+			_, err := manager.AddRules(namespace, rules)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("returns all the rules in pages", func() {
+			filter := Filter{SortBy: SortPriority, Limit: 2}
+			var pages [][]Rule
+			for {
+				retrievedRules, err := manager.GetRules(namespace, filter)
+				Expect(err).ToNot(HaveOccurred())
+				pages = append(pages, retrievedRules.Rules)
+
+				if retrievedRules.Continue == "" {
+					break
+				}
+				filter.Continue = retrievedRules.Continue
+			}
+
+			Expect(pages).To(HaveLen(3))
+
+			var all []Rule
+			for _, page := range pages {
+				all = append(all, page...)
+			}
+			Expect(all).To(HaveLen(5))
+			for i := 1; i < len(all); i++ {
+				Expect(all[i-1].Priority >= all[i].Priority).To(BeTrue())
+				if all[i-1].Priority == all[i].Priority {
+					Expect(all[i-1].ID < all[i].ID).To(BeTrue())
+				}
+			}
+		})
+
+		It("fails on an invalid cursor", func() {
+			_, err := manager.GetRules(namespace, Filter{Limit: 2, Continue: "invalid"})
+			Expect(err).To(BeAssignableToTypeOf(&InvalidPageError{}))
+		})
+	})
+
+	Describe("revision preconditions", func() {
+		var (
+			ids      []string
+			revision int64
+		)
+
+		JustBeforeEach(func() {
+			newRules, err := manager.AddRules(namespace, []Rule{{Destination: "DestinationX"}})
+			Expect(err).ToNot(HaveOccurred())
+			ids = newRules.IDs
+
+			retrievedRules, err := manager.GetRules(namespace, Filter{})
+			Expect(err).ToNot(HaveOccurred())
+			revision = retrievedRules.Revision
+		})
+
+		It("changes rules at the current revision", func() {
+			Expect(manager.UpdateRules(namespace, []Rule{{ID: ids[0], Destination: "DestinationY"}}, revision)).To(Succeed())
+
+			_, err := manager.SetRules(namespace, Filter{Destinations: []string{"DestinationY"}}, []Rule{{Destination: "DestinationY"}}, revision+1)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(manager.DeleteRules(namespace, Filter{}, revision+2)).To(Succeed())
+		})
+
+		It("fails to change rules at another revision", func() {
+			err := manager.UpdateRules(namespace, []Rule{{ID: ids[0], Destination: "DestinationY"}}, revision-1)
+			Expect(err).To(Equal(&RevisionMismatchError{Expected: revision - 1, Actual: revision}))
+
+			_, err = manager.SetRules(namespace, Filter{}, []Rule{{Destination: "DestinationY"}}, revision+1)
+			Expect(err).To(BeAssignableToTypeOf(&RevisionMismatchError{}))
+
+			err = manager.DeleteRules(namespace, Filter{}, revision+1)
+			Expect(err).To(BeAssignableToTypeOf(&RevisionMismatchError{}))
+
+			retrievedRules, err := manager.GetRules(namespace, Filter{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(retrievedRules.Revision).To(Equal(revision))
+			Expect(retrievedRules.Rules).To(HaveLen(1))
+			Expect(retrievedRules.Rules[0].Destination).To(Equal("DestinationX"))
+		})
+	})
+
+	Describe("rule history", func() {
+		var (
+			ids []string
+			err error
+		)
+
+		JustBeforeEach(func() {
+			var newRules NewRules
+			newRules, err = manager.AddRules(namespace, []Rule{{Destination: "DestinationX"}})
+			Expect(err).ToNot(HaveOccurred())
+			ids = newRules.IDs
+
+			err = manager.UpdateRules(namespace, []Rule{{ID: ids[0], Destination: "DestinationY"}}, AnyRevision)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("records each revision", func() {
+			summaries, err := manager.GetHistory(namespace)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(summaries).To(HaveLen(2))
+			Expect(summaries[0].Revision).To(Equal(int64(1)))
+			Expect(summaries[0].Count).To(Equal(1))
+			Expect(summaries[1].Revision).To(Equal(int64(2)))
+		})
+
+		It("returns the rules at a revision", func() {
+			snapshot, err := manager.GetSnapshot(namespace, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(snapshot.Revision).To(Equal(int64(1)))
+			Expect(snapshot.Rules).To(HaveLen(1))
+			Expect(snapshot.Rules[0].Destination).To(Equal("DestinationX"))
+		})
+
+		It("fails to return an unknown revision", func() {
+			_, err := manager.GetSnapshot(namespace, 5)
+			Expect(err).To(BeAssignableToTypeOf(&RevisionNotFoundError{}))
+		})
+
+		Describe("rolling back", func() {
+			JustBeforeEach(func() {
+				err = manager.DeleteRules(namespace, Filter{}, AnyRevision)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = manager.Rollback(namespace, 1)
+			})
+
+			It("should not error", func() {
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("restores the rules at the revision", func() {
+				retrievedRules, err := manager.GetRules(namespace, Filter{})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(retrievedRules.Rules).To(HaveLen(1))
+				Expect(retrievedRules.Rules[0].ID).To(Equal(ids[0]))
+				Expect(retrievedRules.Rules[0].Destination).To(Equal("DestinationX"))
+			})
+
+			It("records the rollback as a new revision", func() {
+				retrievedRules, err := manager.GetRules(namespace, Filter{})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(retrievedRules.Revision).To(Equal(int64(4)))
+
+				summaries, err := manager.GetHistory(namespace)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(summaries).To(HaveLen(4))
+			})
+		})
+	})
+
+	Describe("scheduled rules", func() {
+		var (
+			ids []string
+			err error
+		)
+
+		JustBeforeEach(func() {
+			notBefore := time.Now().Add(50 * time.Millisecond)
+			notAfter := time.Now().Add(100 * time.Millisecond)
+			rules := []Rule{
+				{
+					Destination: "DestinationX",
+					Actions:     []byte(`[{"action":"abort","return_code":503}]`),
+					NotBefore:   &notBefore,
+					NotAfter:    &notAfter,
+				},
+			}
+
+			var newRules NewRules
+			newRules, err = manager.AddRules(namespace, rules)
+			ids = newRules.IDs
+		})
+
+		It("should not error", func() {
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ids).To(HaveLen(1))
+		})
+
+		It("only retrieves the rule within its activation window", func() {
+			retrievedRules, err := manager.GetRules(namespace, Filter{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(retrievedRules.Rules).To(BeEmpty())
+			Expect(retrievedRules.Revision).To(Equal(int64(1)))
+
+			retrievedRules, err = manager.GetRules(namespace, Filter{IncludeInactive: true})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(retrievedRules.Rules).To(HaveLen(1))
+
+			time.Sleep(60 * time.Millisecond)
+
+			retrievedRules, err = manager.GetRules(namespace, Filter{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(retrievedRules.Rules).To(HaveLen(1))
+			Expect(retrievedRules.Revision).To(Equal(int64(2)))
+		})
+
+		It("deletes the rule once it expires", func() {
+			time.Sleep(110 * time.Millisecond)
+
+			retrievedRules, err := manager.GetRules(namespace, Filter{IncludeInactive: true})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(retrievedRules.Rules).To(BeEmpty())
+			Expect(retrievedRules.Revision).To(Equal(int64(2)))
+		})
+
+		It("ends watches when the rule is activated", func() {
+			start := time.Now()
+			Expect(manager.WatchRules(namespace, 1, time.Second)).To(Succeed())
+			Expect(time.Since(start)).To(BeNumerically("<", 500*time.Millisecond))
+
+			retrievedRules, err := manager.GetRules(namespace, Filter{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(retrievedRules.Rules).To(HaveLen(1))
+		})
+	})
+}
@@ -16,27 +16,53 @@ package rules
 
 import (
 	"errors"
-
 	"sync"
+	"time"
 
+	"github.com/amalgam8/amalgam8/controller/metrics"
 	"github.com/pborman/uuid"
 )
 
 // NewMemoryManager constructs a new in memory manager.
 func NewMemoryManager(validator Validator) Manager {
+	return newMemory(validator)
+}
+
+func newMemory(validator Validator) *memory {
 	return &memory{
 		rules:     make(map[string]map[string]Rule),
 		revision:  make(map[string]int64),
+		history:   make(map[string][]Snapshot),
+		scheduled: make(map[string]time.Time),
 		validator: validator,
 		mutex:     &sync.Mutex{},
+		notifier:  newNotifier(),
 	}
 }
 
 type memory struct {
 	rules     map[string]map[string]Rule
 	revision  map[string]int64
+	history   map[string][]Snapshot
+	scheduled map[string]time.Time
 	validator Validator
 	mutex     *sync.Mutex
+	notifier  *notifier
+	persister persister
+}
+
+// persister durably stores the state of a namespace each time its rules change, along with the snapshot of the rules
+// at the new revision.
+type persister interface {
+	persist(namespace string, state namespaceState, snapshot Snapshot) error
+}
+
+// namespaceState is the state of the rules in a namespace. The history is stored separately from the rules, so that
+// it is not rewritten on every change.
+type namespaceState struct {
+	Revision int64      `json:"revision"`
+	Rules    []Rule     `json:"rules"`
+	History  []Snapshot `json:"-"`
 }
 
 func (m *memory) AddRules(namespace string, rules []Rule) (NewRules, error) {
@@ -44,8 +70,16 @@ func (m *memory) AddRules(namespace string, rules []Rule) (NewRules, error) {
 		return NewRules{}, errors.New("rules: no rules provided")
 	}
 
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if err := m.applySchedule(namespace); err != nil {
+		return NewRules{}, err
+	}
+
 	// Validate rules
-	if err := m.validateRules(rules); err != nil {
+	warnings, err := validateRules(m.validator, m.namespaceRules(namespace), rules)
+	if err != nil {
 		return NewRules{}, err
 	}
 
@@ -53,9 +87,9 @@ func (m *memory) AddRules(namespace string, rules []Rule) (NewRules, error) {
 	m.generateRuleIDs(rules)
 
 	// Add the rules
-	m.mutex.Lock()
-	m.addRules(namespace, rules)
-	m.mutex.Unlock()
+	if err := m.addRules(namespace, rules); err != nil {
+		return NewRules{}, err
+	}
 
 	// Get the new IDs
 	ids := make([]string, len(rules))
@@ -64,11 +98,12 @@ func (m *memory) AddRules(namespace string, rules []Rule) (NewRules, error) {
 	}
 
 	return NewRules{
-		IDs: ids,
+		IDs:      ids,
+		Warnings: warnings,
 	}, nil
 }
 
-func (m *memory) addRules(namespace string, rules []Rule) {
+func (m *memory) addRules(namespace string, rules []Rule) error {
 	_, exists := m.rules[namespace]
 	if !exists {
 		m.rules[namespace] = make(map[string]Rule)
@@ -78,17 +113,28 @@ func (m *memory) addRules(namespace string, rules []Rule) {
 		m.rules[namespace][rule.ID] = rule
 	}
 
-	m.revision[namespace]++
+	return m.updateRevision(namespace)
 }
 
 func (m *memory) GetRules(namespace string, filter Filter) (RetrievedRules, error) {
 	m.mutex.Lock()
 
+	if err := m.applySchedule(namespace); err != nil {
+		m.mutex.Unlock()
+		return RetrievedRules{}, err
+	}
+
 	revision := m.revision[namespace]
 
 	rules, exists := m.rules[namespace]
 	if !exists {
 		m.mutex.Unlock()
+
+		// The cursor is validated even though there are no rules to page
+		if _, _, err := PageRules(filter, []Rule{}); err != nil {
+			return RetrievedRules{}, err
+		}
+
 		return RetrievedRules{
 			Rules:    []Rule{},
 			Revision: revision,
@@ -119,26 +165,33 @@ func (m *memory) GetRules(namespace string, filter Filter) (RetrievedRules, erro
 
 	m.mutex.Unlock()
 
-	results = FilterRules(filter, results)
+	results, next, err := PageRules(filter, FilterRules(filter, results))
+	if err != nil {
+		return RetrievedRules{}, err
+	}
 
 	return RetrievedRules{
 		Rules:    results,
 		Revision: revision,
+		Continue: next,
 	}, nil
 }
 
-func (m *memory) UpdateRules(namespace string, rules []Rule) error {
+func (m *memory) UpdateRules(namespace string, rules []Rule, revision int64) error {
 	if len(rules) == 0 {
 		return errors.New("rules: no rules provided")
 	}
 
-	// Validate rules
-	if err := m.validateRules(rules); err != nil {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if err := m.applySchedule(namespace); err != nil {
 		return err
 	}
 
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
+	if err := m.checkRevision(namespace, revision); err != nil {
+		return err
+	}
 
 	// Make sure the IDs exist
 	_, exists := m.rules[namespace]
@@ -153,42 +206,97 @@ func (m *memory) UpdateRules(namespace string, rules []Rule) error {
 		}
 	}
 
+	// Validate rules
+	existing := excludeRules(m.namespaceRules(namespace), rules)
+	if _, err := validateRules(m.validator, existing, rules); err != nil {
+		return err
+	}
+
 	// Update the rules
 	for _, rule := range rules {
 		m.rules[namespace][rule.ID] = rule
 	}
 
 	// Update the revision
-	m.revision[namespace]++
+	return m.updateRevision(namespace)
+}
+
+func (m *memory) DeleteRules(namespace string, filter Filter, revision int64) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if err := m.applySchedule(namespace); err != nil {
+		return err
+	}
+
+	if err := m.checkRevision(namespace, revision); err != nil {
+		return err
+	}
+
+	// Rules are deleted regardless of their activation window
+	filter.IncludeInactive = true
+
+	if err := m.deleteRulesByFilter(namespace, filter); err != nil {
+		return err
+	}
+
+	return m.updateRevision(namespace)
+}
+
+func (m *memory) WatchRules(namespace string, revision int64, timeout time.Duration) error {
+	m.mutex.Lock()
+	if err := m.applySchedule(namespace); err != nil {
+		m.mutex.Unlock()
+		return err
+	}
+
+	if m.revision[namespace] > revision {
+		m.mutex.Unlock()
+		return nil
+	}
+
+	changed := m.notifier.wait(namespace)
+	timeout = scheduleTimeout(m.namespaceRules(namespace), time.Now(), timeout)
+	m.mutex.Unlock()
+
+	waitTimeout(changed, timeout)
 
 	return nil
 }
 
-func (m *memory) DeleteRules(namespace string, filter Filter) error {
+func (m *memory) SetRules(namespace string, filter Filter, rules []Rule, revision int64) (NewRules, error) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	m.revision[namespace]++
-	return m.deleteRulesByFilter(namespace, filter)
-}
+	if err := m.applySchedule(namespace); err != nil {
+		return NewRules{}, err
+	}
+
+	if err := m.checkRevision(namespace, revision); err != nil {
+		return NewRules{}, err
+	}
+
+	// Rules are replaced regardless of their activation window
+	filter.IncludeInactive = true
 
-func (m *memory) SetRules(namespace string, filter Filter, rules []Rule) (NewRules, error) {
 	// Validate rules
-	if err := m.validateRules(rules); err != nil {
+	existing := m.namespaceRules(namespace)
+	existing = excludeRules(existing, FilterRules(filter, existing))
+	warnings, err := validateRules(m.validator, existing, rules)
+	if err != nil {
 		return NewRules{}, err
 	}
 
 	m.generateRuleIDs(rules)
 
 	// Delete the existing rules that match the filter and add the new rules
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
-
 	if err := m.deleteRulesByFilter(namespace, filter); err != nil {
 		return NewRules{}, err
 	}
 
-	m.addRules(namespace, rules)
+	if err := m.addRules(namespace, rules); err != nil {
+		return NewRules{}, err
+	}
 
 	// Get the new IDs
 	ids := make([]string, len(rules))
@@ -197,7 +305,8 @@ func (m *memory) SetRules(namespace string, filter Filter, rules []Rule) (NewRul
 	}
 
 	return NewRules{
-		IDs: ids,
+		IDs:      ids,
+		Warnings: warnings,
 	}, nil
 }
 
@@ -223,17 +332,155 @@ func (m *memory) deleteRulesByFilter(namespace string, filter Filter) error {
 	return nil
 }
 
-func (m *memory) generateRuleIDs(rules []Rule) {
-	for i := range rules {
-		rules[i].ID = uuid.New() // Generate an ID for each rule
+func (m *memory) GetHistory(namespace string) ([]RevisionSummary, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	history := m.history[namespace]
+	summaries := make([]RevisionSummary, len(history))
+	for i, snapshot := range history {
+		summaries[i] = RevisionSummary{
+			Revision:  snapshot.Revision,
+			Timestamp: snapshot.Timestamp,
+			Count:     len(snapshot.Rules),
+		}
 	}
+
+	return summaries, nil
 }
 
-func (m *memory) validateRules(rules []Rule) error {
-	for _, rule := range rules {
-		if err := m.validator.Validate(rule); err != nil {
+func (m *memory) GetSnapshot(namespace string, revision int64) (Snapshot, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.getSnapshot(namespace, revision)
+}
+
+func (m *memory) Rollback(namespace string, revision int64) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	snapshot, err := m.getSnapshot(namespace, revision)
+	if err != nil {
+		return err
+	}
+
+	m.rules[namespace] = make(map[string]Rule)
+	for _, rule := range snapshot.Rules {
+		m.rules[namespace][rule.ID] = rule
+	}
+
+	return m.updateRevision(namespace)
+}
+
+func (m *memory) getSnapshot(namespace string, revision int64) (Snapshot, error) {
+	for _, snapshot := range m.history[namespace] {
+		if snapshot.Revision == revision {
+			return snapshot, nil
+		}
+	}
+
+	return Snapshot{}, &RevisionNotFoundError{Revision: revision}
+}
+
+// checkRevision returns a RevisionMismatchError unless the namespace is at the revision, or the revision is
+// AnyRevision. The caller must hold the mutex.
+func (m *memory) checkRevision(namespace string, revision int64) error {
+	if revision != AnyRevision && revision != m.revision[namespace] {
+		return &RevisionMismatchError{Expected: revision, Actual: m.revision[namespace]}
+	}
+
+	return nil
+}
+
+// updateRevision increments the revision of the namespace, records the rules at the new revision in the history,
+// persists the rules if the manager has a persister, and wakes any watchers. If the rules cannot be persisted, the
+// rules of the namespace are restored to the previous revision. The caller must hold the mutex.
+func (m *memory) updateRevision(namespace string) error {
+	previousHistory := m.history[namespace]
+
+	m.revision[namespace]++
+
+	snapshot := Snapshot{
+		Revision:  m.revision[namespace],
+		Timestamp: time.Now(),
+		Rules:     m.namespaceRules(namespace),
+	}
+
+	history := append(previousHistory, snapshot)
+	if len(history) > historyLimit {
+		history = history[len(history)-historyLimit:]
+	}
+	m.history[namespace] = history
+
+	if m.persister != nil {
+		if err := m.persister.persist(namespace, m.namespaceState(namespace), snapshot); err != nil {
+			m.revision[namespace]--
+			m.history[namespace] = previousHistory
+
+			m.rules[namespace] = make(map[string]Rule)
+			if len(previousHistory) > 0 {
+				for _, rule := range previousHistory[len(previousHistory)-1].Rules {
+					m.rules[namespace][rule.ID] = rule
+				}
+			}
+
 			return err
 		}
 	}
+
+	metrics.Rules.Set(float64(len(m.rules[namespace])), namespace)
+	m.notifier.notify(namespace)
+
 	return nil
 }
+
+// applySchedule deletes the expired rules of the namespace, and updates the revision if the activation window of any
+// rule started or ended since the schedule was last applied, as the rules that apply have changed. The caller must
+// hold the mutex.
+func (m *memory) applySchedule(namespace string) error {
+	now := time.Now()
+	rules := m.namespaceRules(namespace)
+
+	// A schedule that was never applied is checked from the zero time, so that rules loaded from storage that
+	// expired while the manager was not running are deleted
+	if !scheduleChanged(rules, m.scheduled[namespace], now) {
+		m.scheduled[namespace] = now
+		return nil
+	}
+
+	for _, rule := range expiredRules(rules, now) {
+		delete(m.rules[namespace], rule.ID)
+	}
+
+	if err := m.updateRevision(namespace); err != nil {
+		return err
+	}
+
+	m.scheduled[namespace] = now
+	return nil
+}
+
+// namespaceState returns the state of the namespace to persist. The caller must hold the mutex.
+func (m *memory) namespaceState(namespace string) namespaceState {
+	return namespaceState{
+		Revision: m.revision[namespace],
+		Rules:    m.namespaceRules(namespace),
+	}
+}
+
+func (m *memory) generateRuleIDs(rules []Rule) {
+	for i := range rules {
+		rules[i].ID = uuid.New() // Generate an ID for each rule
+	}
+}
+
+// namespaceRules returns all the rules in the namespace. The caller must hold the mutex.
+func (m *memory) namespaceRules(namespace string) []Rule {
+	rules := make([]Rule, 0, len(m.rules[namespace]))
+	for _, rule := range m.rules[namespace] {
+		rules = append(rules, rule)
+	}
+
+	return rules
+}
@@ -0,0 +1,79 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package rules
+
+import (
+	"sync"
+	"time"
+)
+
+// notifier tracks the callers waiting for the rules of a namespace to change.
+type notifier struct {
+	changes map[string]chan struct{}
+	mutex   sync.Mutex
+}
+
+func newNotifier() *notifier {
+	return &notifier{
+		changes: make(map[string]chan struct{}),
+	}
+}
+
+// wait returns a channel that is closed the next time the rules in the namespace change.
+func (n *notifier) wait(namespace string) <-chan struct{} {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	ch, exists := n.changes[namespace]
+	if !exists {
+		ch = make(chan struct{})
+		n.changes[namespace] = ch
+	}
+
+	return ch
+}
+
+// notify wakes all callers waiting for the rules in the namespace to change.
+func (n *notifier) notify(namespace string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if ch, exists := n.changes[namespace]; exists {
+		close(ch)
+		delete(n.changes, namespace)
+	}
+}
+
+// notifyAll wakes all callers waiting on any namespace.
+func (n *notifier) notifyAll() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	for namespace, ch := range n.changes {
+		close(ch)
+		delete(n.changes, namespace)
+	}
+}
+
+// waitTimeout blocks until the channel is closed or the timeout expires.
+func waitTimeout(changed <-chan struct{}, timeout time.Duration) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-changed:
+	case <-timer.C:
+	}
+}
@@ -23,11 +23,48 @@ import (
 
 	"fmt"
 
+	"strconv"
+	"time"
+
 	"github.com/Sirupsen/logrus"
+	"github.com/amalgam8/amalgam8/controller/metrics"
 	"github.com/amalgam8/amalgam8/controller/util/encryption"
 	"github.com/garyburd/redigo/redis"
 )
 
+// revisionChannel is the Redis channel on which the namespaces of changed rules are published.
+const revisionChannel = "controller:revisions"
+
+// subscribeRetryInterval is the delay between attempts to resubscribe to the revision channel.
+const subscribeRetryInterval = 5 * time.Second
+
+// historyEntry is the record of the rules of a namespace at a revision. Rules are stored as they are in the rules
+// hash, so they remain encrypted.
+type historyEntry struct {
+	Timestamp time.Time         `json:"timestamp"`
+	Rules     map[string]string `json:"rules"`
+}
+
+// updateRevisionScript atomically increments the revision of a namespace, copies the rules of the namespace into
+// the history at the new revision, trims the history, records the time of the revision as the time the schedule of
+// the rules was last applied, and publishes the namespace to the revision channel.
+//
+// KEYS: rules hash, revision, history hash, schedule time
+// ARGV: namespace, timestamp, history limit, revision channel, schedule time in microseconds
+var updateRevisionScript = redis.NewScript(4, `
+local revision = redis.call("INCR", KEYS[2])
+local entries = redis.call("HGETALL", KEYS[1])
+local rules = {}
+for i = 1, #entries, 2 do
+	rules[entries[i]] = entries[i + 1]
+end
+redis.call("HSET", KEYS[3], revision, cjson.encode({timestamp = ARGV[2], rules = rules}))
+redis.call("HDEL", KEYS[3], revision - tonumber(ARGV[3]))
+redis.call("SET", KEYS[4], ARGV[5])
+redis.call("PUBLISH", ARGV[4], ARGV[1])
+return revision
+`)
+
 // Entry is used to encapsulate a record with an IV for encryption and decryption.
 type Entry struct {
 	IV      string `json:"IV"`
@@ -72,6 +109,8 @@ func newRedisDB(address string, password string) *redisDB {
 }
 
 func (rdb *redisDB) ReadAllEntries(namespace string) ([]string, int64, error) {
+	defer metrics.StorageDuration.Since(time.Now(), "redis_read_all_entries")
+
 	conn := rdb.pool.Get()
 	defer conn.Close()
 
@@ -102,7 +141,17 @@ func (rdb *redisDB) ReadAllEntries(namespace string) ([]string, int64, error) {
 	return entries, rev, nil
 }
 
+// CountEntries returns the number of rules in the namespace.
+func (rdb *redisDB) CountEntries(namespace string) (int, error) {
+	conn := rdb.pool.Get()
+	defer conn.Close()
+
+	return redis.Int(conn.Do("HLEN", buildRulesKey(namespace)))
+}
+
 func (rdb *redisDB) ReadEntries(namespace string, ids []string) ([]string, int64, error) {
+	defer metrics.StorageDuration.Since(time.Now(), "redis_read_entries")
+
 	args := make([]interface{}, len(ids)+1)
 	args[0] = buildRulesKey(namespace)
 	for i, id := range ids {
@@ -131,7 +180,14 @@ func (rdb *redisDB) ReadEntries(namespace string, ids []string) ([]string, int64
 	return entries, rev, nil
 }
 
-func (rdb *redisDB) InsertEntries(namespace string, entries map[string]string) error {
+func (rdb *redisDB) InsertEntries(namespace string, rules []Rule) error {
+	defer metrics.StorageDuration.Since(time.Now(), "redis_insert_entries")
+
+	entries, err := marshalEntries(rules)
+	if err != nil {
+		return err
+	}
+
 	encrypted, err := rdb.encrypt(entries)
 	if err != nil {
 		return err
@@ -142,27 +198,54 @@ func (rdb *redisDB) InsertEntries(namespace string, entries map[string]string) e
 	conn := rdb.pool.Get()
 	defer conn.Close()
 
+	if err := updateRevisionScript.Load(conn); err != nil {
+		return err
+	}
+
+	conn.Send("MULTI")
+
 	logrus.Debug("HMSET ", args)
-	_, err = redis.String(conn.Do("HMSET", args...))
-	if err != nil {
+	if err := conn.Send("HMSET", args...); err != nil {
+		return err
+	}
+
+	if err := sendIndex(conn, namespace, rules); err != nil {
 		return err
 	}
 
-	_, err = conn.Do("INCR", buildNamespaceKey(namespace, "revision")) // FIXME: pipeline
+	if err := rdb.sendUpdateRevision(conn, namespace); err != nil {
+		return err
+	}
 
+	_, err = conn.Do("EXEC")
 	return err
 }
 
 // 1. Get all existing IDs
 // 2. Ensure the new rules are a subset of the existing rules
-// 3. Update the rules
-func (rdb *redisDB) UpdateEntries(namespace string, entries map[string]string) error {
+// 3. Update the rules and the revision, if the namespace is still at the expected revision
+func (rdb *redisDB) UpdateEntries(namespace string, rules []Rule, revision int64) error {
+	defer metrics.StorageDuration.Since(time.Now(), "redis_update_entries")
+
 	key := buildRulesKey(namespace)
 
+	entries, err := marshalEntries(rules)
+	if err != nil {
+		return err
+	}
+
 	conn := rdb.pool.Get()
 	defer conn.Close()
 
-	conn.Do("WATCH", key) // TODO: return codes?
+	if err := updateRevisionScript.Load(conn); err != nil {
+		return err
+	}
+
+	conn.Do("WATCH", key, buildNamespaceKey(namespace, "revision")) // TODO: return codes?
+
+	if err := rdb.checkRevision(conn, namespace, revision); err != nil {
+		return err
+	}
 
 	existingIDs, err := redis.Strings(conn.Do("HKEYS", key))
 	if err != nil {
@@ -193,6 +276,15 @@ func (rdb *redisDB) UpdateEntries(namespace string, entries map[string]string) e
 		return err
 	}
 
+	// Priorities may have changed
+	if err := sendIndex(conn, namespace, rules); err != nil {
+		return err
+	}
+
+	if err := rdb.sendUpdateRevision(conn, namespace); err != nil {
+		return err
+	}
+
 	// Execute transaction
 	_, err = redis.Values(conn.Do("EXEC"))
 
@@ -200,16 +292,17 @@ func (rdb *redisDB) UpdateEntries(namespace string, entries map[string]string) e
 	if err != nil {
 		if err == redis.ErrNil {
 			logrus.Error("Transaction failed due to conflict")
+			return rdb.conflictError(conn, namespace, revision)
 		}
 		return err
 	}
 
-	_, err = conn.Do("INCR", buildNamespaceKey(namespace, "revision")) // FIXME: pipeline
-
-	return err
+	return nil
 }
 
 func (rdb *redisDB) DeleteEntries(namespace string, ids []string) error {
+	defer metrics.StorageDuration.Since(time.Now(), "redis_delete_entries")
+
 	conn := rdb.pool.Get()
 	defer conn.Close()
 
@@ -221,44 +314,51 @@ func (rdb *redisDB) DeleteEntries(namespace string, ids []string) error {
 		i++
 	}
 
+	conn.Send("MULTI")
+
 	logrus.Debug("HDEL ", args)
-	_, err := redis.Int(conn.Do("HDEL", args...))
+	conn.Send("HDEL", args...)
+
+	if err := sendUnindex(conn, namespace, ids); err != nil {
+		return err
+	}
+
+	_, err := conn.Do("EXEC")
 	// TODO: more error checking?
 	if err != nil {
 		return err
 	}
 
-	_, err = conn.Do("INCR", buildNamespaceKey(namespace, "revision")) // FIXME: pipeline
-
-	return err
+	return rdb.updateRevision(conn, namespace)
 }
 
 func (rdb *redisDB) DeleteAllEntries(namespace string) error {
+	defer metrics.StorageDuration.Since(time.Now(), "redis_delete_all_entries")
+
 	conn := rdb.pool.Get()
 	defer conn.Close()
 
-	_, err := redis.Int(conn.Do("DEL", buildRulesKey(namespace)))
+	_, err := redis.Int(conn.Do("DEL", buildRulesKey(namespace), buildIDIndexKey(namespace), buildPriorityIndexKey(namespace),
+		buildActivationIndexKey(namespace), buildExpiryIndexKey(namespace)))
 	if err != nil {
 		return err
 	}
 
-	_, err = conn.Do("INCR", buildNamespaceKey(namespace, "revision")) // FIXME: pipeline
-
-	return err
+	return rdb.updateRevision(conn, namespace)
 }
 
-func (rdb *redisDB) SetByDestination(namespace string, filter Filter, rules []Rule) error {
+// SetByDestination deletes the rules that match the filter and adds the rules as a single transaction, if the namespace
+// is still at the expected revision.
+func (rdb *redisDB) SetByDestination(namespace string, filter Filter, rules []Rule, revision int64) error {
+	defer metrics.StorageDuration.Since(time.Now(), "redis_set_by_destination")
+
 	var err error
 
 	key := buildRulesKey(namespace)
 
-	entries := make(map[string]string)
-	for _, rule := range rules {
-		entry, err := json.Marshal(&rule)
-		if err != nil {
-			return err
-		}
-		entries[rule.ID] = string(entry)
+	entries, err := marshalEntries(rules)
+	if err != nil {
+		return err
 	}
 
 	entries, err = rdb.encrypt(entries)
@@ -269,7 +369,15 @@ func (rdb *redisDB) SetByDestination(namespace string, filter Filter, rules []Ru
 	conn := rdb.pool.Get()
 	defer conn.Close() // Automatically calls DISCARD if necessary
 
-	conn.Do("WATCH", key)
+	if err := updateRevisionScript.Load(conn); err != nil {
+		return err
+	}
+
+	conn.Do("WATCH", key, buildNamespaceKey(namespace, "revision"))
+
+	if err := rdb.checkRevision(conn, namespace, revision); err != nil {
+		return err
+	}
 
 	// Get all rules
 	existingEntryMap, err := redis.StringMap(conn.Do("HGETALL", key))
@@ -313,9 +421,11 @@ func (rdb *redisDB) SetByDestination(namespace string, filter Filter, rules []Ru
 	// Delete IDs
 	if len(rulesToDelete) > 0 {
 		args := make([]interface{}, len(rulesToDelete)+1)
+		ids := make([]string, len(rulesToDelete))
 		args[0] = key
 		for i, rule := range rulesToDelete {
 			args[i+1] = rule.ID
+			ids[i] = rule.ID
 		}
 		logrus.Debug("HDEL ", args)
 
@@ -323,6 +433,10 @@ func (rdb *redisDB) SetByDestination(namespace string, filter Filter, rules []Ru
 		if err != nil {
 			return err
 		}
+
+		if err := sendUnindex(conn, namespace, ids); err != nil {
+			return err
+		}
 	}
 
 	// Add new rules
@@ -334,6 +448,14 @@ func (rdb *redisDB) SetByDestination(namespace string, filter Filter, rules []Ru
 		if err != nil {
 			return err
 		}
+
+		if err := sendIndex(conn, namespace, rules); err != nil {
+			return err
+		}
+	}
+
+	if err := rdb.sendUpdateRevision(conn, namespace); err != nil {
+		return err
 	}
 
 	// Execute transaction
@@ -343,15 +465,568 @@ func (rdb *redisDB) SetByDestination(namespace string, filter Filter, rules []Ru
 		// Nil return indicates that the transaction failed
 		if err == redis.ErrNil {
 			logrus.Error("Transaction failed due to conflict")
+			return rdb.conflictError(conn, namespace, revision)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// ReadPage returns the decrypted entries of up to count rules of the namespace that follow the cursor in the sort
+// order, whether more rules follow them, and the revision of the namespace. The page starts with the first rule when
+// the cursor is nil.
+func (rdb *redisDB) ReadPage(namespace, sortBy string, after *cursor, count int) ([]string, bool, int64, error) {
+	defer metrics.StorageDuration.Since(time.Now(), "redis_read_page")
+
+	conn := rdb.pool.Get()
+	defer conn.Close()
+
+	if err := rdb.checkIndex(conn, namespace); err != nil {
+		return []string{}, false, 0, err
+	}
+
+	var ids []string
+	var err error
+	if sortBy == SortPriority {
+		var start int
+		start, err = rdb.priorityRank(conn, namespace, after)
+		if err != nil {
+			return []string{}, false, 0, err
+		}
+
+		ids, err = redis.Strings(conn.Do("ZRANGE", buildPriorityIndexKey(namespace), start, start+count))
+	} else {
+		min := "-"
+		if after != nil {
+			min = "(" + after.ID
+		}
+
+		ids, err = redis.Strings(conn.Do("ZRANGEBYLEX", buildIDIndexKey(namespace), min, "+", "LIMIT", 0, count+1))
+	}
+	if err != nil {
+		return []string{}, false, 0, err
+	}
+
+	more := len(ids) > count
+	if more {
+		ids = ids[:count]
+	}
+
+	entries := []string{}
+	if len(ids) > 0 {
+		args := make([]interface{}, len(ids)+1)
+		args[0] = buildRulesKey(namespace)
+		for i, id := range ids {
+			args[i+1] = id
+		}
+
+		logrus.Debug("HMGET ", args)
+		values, err := redis.Values(conn.Do("HMGET", args...))
+		if err != nil {
+			return []string{}, false, 0, err
+		}
+
+		// Rules deleted since the index was read are skipped
+		for _, value := range values {
+			if value != nil {
+				entry, err := redis.String(value, nil)
+				if err != nil {
+					return []string{}, false, 0, err
+				}
+				entries = append(entries, entry)
+			}
+		}
+	}
+
+	entries, err = rdb.decrypt(entries)
+	if err != nil {
+		return []string{}, false, 0, err
+	}
+
+	rev, err := rdb.readRevision(conn, namespace)
+	if err != nil {
+		return []string{}, false, 0, err
+	}
+
+	return entries, more, rev, nil
+}
+
+// priorityRank returns the rank in the priority index of the first rule after the cursor.
+func (rdb *redisDB) priorityRank(conn redis.Conn, namespace string, after *cursor) (int, error) {
+	if after == nil {
+		return 0, nil
+	}
+
+	key := buildPriorityIndexKey(namespace)
+	score := -after.Priority
+
+	// The rank of the rule at the cursor is only valid if its priority has not changed since
+	current, err := redis.Int(conn.Do("ZSCORE", key, after.ID))
+	if err == nil && current == score {
+		rank, err := redis.Int(conn.Do("ZRANK", key, after.ID))
+		if err == nil {
+			return rank + 1, nil
+		}
+	}
+	if err != nil && err != redis.ErrNil {
+		return 0, err
+	}
+
+	// Otherwise count the rules of higher priority, and the rules of equal priority up to the ID of the cursor
+	higher, err := redis.Int(conn.Do("ZCOUNT", key, "-inf", fmt.Sprintf("(%v", score)))
+	if err != nil {
+		return 0, err
+	}
+
+	ties, err := redis.Strings(conn.Do("ZRANGEBYSCORE", key, score, score))
+	if err != nil {
+		return 0, err
+	}
+
+	rank := higher
+	for _, id := range ties {
+		if id <= after.ID {
+			rank++
+		}
+	}
+
+	return rank, nil
+}
+
+// checkIndex rebuilds the sort and schedule indexes of the namespace if they do not index all of its rules, as for rules stored
+// before the indexes were introduced.
+func (rdb *redisDB) checkIndex(conn redis.Conn, namespace string) error {
+	key := buildRulesKey(namespace)
+
+	conn.Do("WATCH", key)
+	defer conn.Do("UNWATCH")
+
+	count, err := redis.Int(conn.Do("HLEN", key))
+	if err != nil {
+		return err
+	}
+
+	indexed, err := redis.Int(conn.Do("ZCARD", buildIDIndexKey(namespace)))
+	if err != nil {
+		return err
+	}
+
+	if count == indexed {
+		return nil
+	}
+
+	entryMap, err := redis.StringMap(conn.Do("HGETALL", key))
+	if err != nil {
+		return err
+	}
+
+	entries := make([]string, 0, len(entryMap))
+	for _, entry := range entryMap {
+		entries = append(entries, entry)
+	}
+
+	entries, err = rdb.decrypt(entries)
+	if err != nil {
+		return err
+	}
+
+	rules := make([]Rule, len(entries))
+	for i, entry := range entries {
+		if err := json.Unmarshal([]byte(entry), &rules[i]); err != nil {
+			return err
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"namespace": namespace,
+		"rules":     count,
+		"indexed":   indexed,
+	}).Info("Rebuilding rule indexes")
+
+	conn.Send("MULTI")
+	conn.Send("DEL", buildIDIndexKey(namespace), buildPriorityIndexKey(namespace), buildActivationIndexKey(namespace),
+		buildExpiryIndexKey(namespace))
+	if err := sendIndex(conn, namespace, rules); err != nil {
+		return err
+	}
+
+	// A concurrent change of the rules fails the transaction, and the indexes are checked again on the next read
+	_, err = conn.Do("EXEC")
+	if err == redis.ErrNil {
+		return nil
+	}
+	return err
+}
+
+// sendIndex queues the addition of the rules to the sort and schedule indexes of the namespace in a transaction.
+// Rules already in the indexes are moved to their current position.
+func sendIndex(conn redis.Conn, namespace string, rules []Rule) error {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	ids := make([]interface{}, 0, len(rules)*2+1)
+	priorities := make([]interface{}, 0, len(rules)*2+1)
+	ids = append(ids, buildIDIndexKey(namespace))
+	priorities = append(priorities, buildPriorityIndexKey(namespace))
+	for _, rule := range rules {
+		// Rules of equal score are ordered by ID, so rules of equal priority are ordered by ID
+		ids = append(ids, 0, rule.ID)
+		priorities = append(priorities, -rule.Priority, rule.ID)
+	}
+
+	if err := conn.Send("ZADD", ids...); err != nil {
+		return err
+	}
+	if err := conn.Send("ZADD", priorities...); err != nil {
+		return err
+	}
+
+	if err := sendScheduleIndex(conn, buildActivationIndexKey(namespace), rules, func(r Rule) *time.Time {
+		return r.NotBefore
+	}); err != nil {
+		return err
+	}
+	return sendScheduleIndex(conn, buildExpiryIndexKey(namespace), rules, func(r Rule) *time.Time {
+		return r.NotAfter
+	})
+}
+
+// sendScheduleIndex queues the indexing of the rules by the time returned for each rule, removing the rules for which
+// no time is returned from the index.
+func sendScheduleIndex(conn redis.Conn, key string, rules []Rule, at func(Rule) *time.Time) error {
+	added := []interface{}{key}
+	removed := []interface{}{key}
+	for _, rule := range rules {
+		if t := at(rule); t != nil {
+			added = append(added, micros(*t), rule.ID)
+		} else {
+			removed = append(removed, rule.ID)
+		}
+	}
+
+	if len(added) > 1 {
+		if err := conn.Send("ZADD", added...); err != nil {
+			return err
+		}
+	}
+	if len(removed) > 1 {
+		return conn.Send("ZREM", removed...)
+	}
+	return nil
+}
+
+// sendUnindex queues the removal of the rules with the IDs from the sort and schedule indexes of the namespace in a
+// transaction.
+func sendUnindex(conn redis.Conn, namespace string, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, len(ids)+1)
+	for i, id := range ids {
+		args[i+1] = id
+	}
+
+	args[0] = buildIDIndexKey(namespace)
+	if err := conn.Send("ZREM", args...); err != nil {
+		return err
+	}
+
+	for _, key := range []string{buildPriorityIndexKey(namespace), buildActivationIndexKey(namespace), buildExpiryIndexKey(namespace)} {
+		indexArgs := append([]interface{}{key}, args[1:]...)
+		if err := conn.Send("ZREM", indexArgs...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// marshalEntries encodes the rules as entries mapped by rule ID.
+func marshalEntries(rules []Rule) (map[string]string, error) {
+	entries := make(map[string]string)
+	for _, rule := range rules {
+		data, err := json.Marshal(&rule)
+		if err != nil {
+			return nil, &JSONMarshalError{Message: err.Error()}
+		}
+		entries[rule.ID] = string(data)
+	}
+	return entries, nil
+}
+
+// SubscribeRevisions listens for revision changes made by any controller sharing the database and wakes the
+// watchers registered with the notifier. The subscribed function is called each time the subscription is confirmed,
+// after which no change is missed. It resubscribes on failure and never returns.
+func (rdb *redisDB) SubscribeRevisions(n *notifier, subscribed func()) {
+	for {
+		if err := rdb.subscribeRevisions(n, subscribed); err != nil {
+			logrus.WithError(err).Error("Subscription to rule revisions failed")
 		}
+
+		// Changes may have been missed while unsubscribed, so let all watchers check their revision again
+		n.notifyAll()
+
+		time.Sleep(subscribeRetryInterval)
+	}
+}
+
+func (rdb *redisDB) subscribeRevisions(n *notifier, subscribed func()) error {
+	conn := redis.PubSubConn{Conn: rdb.pool.Get()}
+	defer conn.Close()
+
+	logrus.Debug("SUBSCRIBE ", revisionChannel)
+	if err := conn.Subscribe(revisionChannel); err != nil {
 		return err
 	}
 
-	_, err = redis.Int64(conn.Do("INCR", buildNamespaceKey(namespace, "revision"))) // FIXME: pipeline
+	for {
+		switch v := conn.Receive().(type) {
+		case redis.Message:
+			n.notify(string(v.Data))
+		case redis.Subscription:
+			if v.Kind == "subscribe" {
+				subscribed()
+			}
+		case error:
+			return v
+		}
+	}
+}
+
+// UpdateRevision increments the revision of the namespace without changing its rules.
+func (rdb *redisDB) UpdateRevision(namespace string) error {
+	defer metrics.StorageDuration.Since(time.Now(), "redis_update_revision")
+
+	conn := rdb.pool.Get()
+	defer conn.Close()
+
+	return rdb.updateRevision(conn, namespace)
+}
+
+// updateRevision increments the revision of the namespace, records the rules at the new revision in the history,
+// and publishes the change to any watchers.
+func (rdb *redisDB) updateRevision(conn redis.Conn, namespace string) error {
+	_, err := updateRevisionScript.Do(conn, updateRevisionArgs(namespace, time.Now())...)
 
 	return err
 }
 
+// sendUpdateRevision queues the update of the revision of the namespace in a transaction, so that the revision is
+// updated atomically with the rules. The script must be loaded before the transaction starts.
+func (rdb *redisDB) sendUpdateRevision(conn redis.Conn, namespace string) error {
+	return updateRevisionScript.SendHash(conn, updateRevisionArgs(namespace, time.Now())...)
+}
+
+// readRevision returns the revision of the namespace.
+func (rdb *redisDB) readRevision(conn redis.Conn, namespace string) (int64, error) {
+	rev, err := redis.Int64(conn.Do("GET", buildNamespaceKey(namespace, "revision")))
+	if err == redis.ErrNil {
+		return 0, nil
+	}
+
+	return rev, err
+}
+
+// checkRevision returns a RevisionMismatchError unless the namespace is at the revision, or the revision is
+// AnyRevision. The revision key must be watched, so that the check holds until the transaction executes.
+func (rdb *redisDB) checkRevision(conn redis.Conn, namespace string, revision int64) error {
+	if revision == AnyRevision {
+		return nil
+	}
+
+	actual, err := rdb.readRevision(conn, namespace)
+	if err != nil {
+		return err
+	}
+
+	if actual != revision {
+		return &RevisionMismatchError{Expected: revision, Actual: actual}
+	}
+
+	return nil
+}
+
+// conflictError returns the error of a transaction that failed because a watched key changed. Changes conditional on
+// a revision fail with a RevisionMismatchError, as the namespace has moved past the revision.
+func (rdb *redisDB) conflictError(conn redis.Conn, namespace string, revision int64) error {
+	if revision == AnyRevision {
+		return redis.ErrNil
+	}
+
+	actual, err := rdb.readRevision(conn, namespace)
+	if err != nil {
+		return err
+	}
+
+	return &RevisionMismatchError{Expected: revision, Actual: actual}
+}
+
+// updateRevisionArgs returns the arguments of the script updating the revision of the namespace at the time. Any
+// change of the schedule up to the time is published by the update, as sidecars read the rules after it.
+func updateRevisionArgs(namespace string, now time.Time) []interface{} {
+	return []interface{}{
+		buildRulesKey(namespace),
+		buildNamespaceKey(namespace, "revision"),
+		buildHistoryKey(namespace),
+		buildNamespaceKey(namespace, "scheduled"),
+		namespace,
+		now.UTC().Format(time.RFC3339Nano),
+		historyLimit,
+		revisionChannel,
+		micros(now),
+	}
+}
+
+// micros returns the time in microseconds since the Unix epoch, which scores of sorted sets represent exactly.
+func micros(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Microsecond)
+}
+
+// ApplySchedule deletes the expired rules of the namespace and updates its revision, if the activation window of any
+// of its rules started or ended since the revision was last updated by any controller sharing the database. Returns
+// whether the revision was updated. A concurrent update of the revision applies the schedule in place of this one.
+// The schedule indexes are read rather than the rules, so applying the schedule is cheap when nothing is due.
+func (rdb *redisDB) ApplySchedule(namespace string, now time.Time) (bool, error) {
+	defer metrics.StorageDuration.Since(time.Now(), "redis_apply_schedule")
+
+	key := buildNamespaceKey(namespace, "scheduled")
+
+	conn := rdb.pool.Get()
+	defer conn.Close()
+
+	conn.Do("WATCH", key)
+
+	since, err := redis.Int64(conn.Do("GET", key))
+	if err != nil && err != redis.ErrNil {
+		return false, err
+	}
+
+	activated, err := redis.Int(conn.Do("ZCOUNT", buildActivationIndexKey(namespace), fmt.Sprintf("(%v", since), micros(now)))
+	if err != nil {
+		return false, err
+	}
+
+	expired, err := redis.Strings(conn.Do("ZRANGEBYSCORE", buildExpiryIndexKey(namespace), "-inf", micros(now)))
+	if err != nil {
+		return false, err
+	}
+
+	if activated == 0 && len(expired) == 0 {
+		conn.Do("UNWATCH")
+		return false, nil
+	}
+
+	if err := updateRevisionScript.Load(conn); err != nil {
+		return false, err
+	}
+
+	conn.Send("MULTI")
+
+	if len(expired) > 0 {
+		args := make([]interface{}, len(expired)+1)
+		args[0] = buildRulesKey(namespace)
+		for i, id := range expired {
+			args[i+1] = id
+		}
+
+		logrus.Debug("HDEL ", args)
+		if err := conn.Send("HDEL", args...); err != nil {
+			return false, err
+		}
+
+		if err := sendUnindex(conn, namespace, expired); err != nil {
+			return false, err
+		}
+	}
+
+	if err := updateRevisionScript.SendHash(conn, updateRevisionArgs(namespace, now)...); err != nil {
+		return false, err
+	}
+
+	// Nil return indicates that the revision was updated concurrently, which published the schedule
+	_, err = redis.Values(conn.Do("EXEC"))
+	if err == redis.ErrNil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ReadHistory returns summaries of the revisions in the history of the namespace.
+func (rdb *redisDB) ReadHistory(namespace string) ([]RevisionSummary, error) {
+	defer metrics.StorageDuration.Since(time.Now(), "redis_read_history")
+
+	conn := rdb.pool.Get()
+	defer conn.Close()
+
+	logrus.Debug("HGETALL ", buildHistoryKey(namespace))
+	entryMap, err := redis.StringMap(conn.Do("HGETALL", buildHistoryKey(namespace)))
+	if err != nil {
+		return []RevisionSummary{}, err
+	}
+
+	summaries := make([]RevisionSummary, 0, len(entryMap))
+	for field, entry := range entryMap {
+		revision, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return []RevisionSummary{}, err
+		}
+
+		h := historyEntry{}
+		if err := json.Unmarshal([]byte(entry), &h); err != nil {
+			return []RevisionSummary{}, err
+		}
+
+		summaries = append(summaries, RevisionSummary{
+			Revision:  revision,
+			Timestamp: h.Timestamp,
+			Count:     len(h.Rules),
+		})
+	}
+
+	sortSummaries(summaries)
+
+	return summaries, nil
+}
+
+// ReadSnapshot returns the decrypted entries of the namespace at a revision in the history.
+func (rdb *redisDB) ReadSnapshot(namespace string, revision int64) ([]string, time.Time, error) {
+	defer metrics.StorageDuration.Since(time.Now(), "redis_read_snapshot")
+
+	conn := rdb.pool.Get()
+	defer conn.Close()
+
+	logrus.Debug("HGET ", buildHistoryKey(namespace), " ", revision)
+	entry, err := redis.String(conn.Do("HGET", buildHistoryKey(namespace), revision))
+	if err == redis.ErrNil {
+		return []string{}, time.Time{}, &RevisionNotFoundError{Revision: revision}
+	} else if err != nil {
+		return []string{}, time.Time{}, err
+	}
+
+	h := historyEntry{}
+	if err := json.Unmarshal([]byte(entry), &h); err != nil {
+		return []string{}, time.Time{}, err
+	}
+
+	entries := make([]string, 0, len(h.Rules))
+	for _, ruleEntry := range h.Rules {
+		entries = append(entries, ruleEntry)
+	}
+
+	entries, err = rdb.decrypt(entries)
+	if err != nil {
+		return []string{}, time.Time{}, err
+	}
+
+	return entries, h.Timestamp, nil
+}
+
 // encrypt
 func (rdb *redisDB) encrypt(entries map[string]string) (map[string]string, error) {
 	// Short-circuit without encryption
@@ -442,6 +1117,26 @@ func buildNamespaceKey(namespace, key string) string {
 	return fmt.Sprintf("controller:%v:%v", namespace, key)
 }
 
+func buildHistoryKey(namespace string) string {
+	return fmt.Sprintf("controller:%v:history", namespace)
+}
+
+func buildIDIndexKey(namespace string) string {
+	return fmt.Sprintf("controller:%v:index:id", namespace)
+}
+
+func buildPriorityIndexKey(namespace string) string {
+	return fmt.Sprintf("controller:%v:index:priority", namespace)
+}
+
+func buildActivationIndexKey(namespace string) string {
+	return fmt.Sprintf("controller:%v:index:activation", namespace)
+}
+
+func buildExpiryIndexKey(namespace string) string {
+	return fmt.Sprintf("controller:%v:index:expiry", namespace)
+}
+
 func buildRulesKey(namespace string) string {
 	return fmt.Sprintf("controller:%v:rules", namespace)
 }
@@ -16,24 +16,39 @@ package rules
 
 import (
 	"errors"
+	"sync"
+	"time"
 
 	"encoding/json"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/amalgam8/amalgam8/controller/metrics"
 	"github.com/pborman/uuid"
 )
 
 // NewRedisManager creates a Redis backed manager implementation.
 func NewRedisManager(host, pass string, v Validator) Manager {
-	return &redisManager{
-		validator: v,
-		db:        newRedisDB(host, pass),
+	r := &redisManager{
+		validator:  v,
+		db:         newRedisDB(host, pass),
+		notifier:   newNotifier(),
+		subscribed: make(chan struct{}),
 	}
+
+	// Revision changes are only received once subscribed, so the subscription starts with the manager
+	go r.db.SubscribeRevisions(r.notifier, func() {
+		r.subscribe.Do(func() { close(r.subscribed) })
+	})
+
+	return r
 }
 
 type redisManager struct {
-	validator Validator
-	db        *redisDB
+	validator  Validator
+	db         *redisDB
+	notifier   *notifier
+	subscribe  sync.Once
+	subscribed chan struct{}
 }
 
 func (r *redisManager) AddRules(namespace string, rules []Rule) (NewRules, error) {
@@ -42,30 +57,27 @@ func (r *redisManager) AddRules(namespace string, rules []Rule) (NewRules, error
 	}
 
 	// Validate rules
-	for _, rule := range rules {
-		if err := r.validator.Validate(rule); err != nil {
-			return NewRules{}, &InvalidRuleError{}
-		}
+	existing, err := r.GetRules(namespace, Filter{IncludeInactive: true})
+	if err != nil {
+		return NewRules{}, err
 	}
 
-	entries := make(map[string]string)
-	for i := range rules {
-		id := uuid.New() // Generate an ID for each rule
-		rules[i].ID = id
-		data, err := json.Marshal(&rules[i])
-		if err != nil {
-			return NewRules{}, &JSONMarshalError{Message: err.Error()}
-		}
+	warnings, err := validateRules(r.validator, existing.Rules, rules)
+	if err != nil {
+		return NewRules{}, err
+	}
 
-		entries[id] = string(data)
+	for i := range rules {
+		rules[i].ID = uuid.New() // Generate an ID for each rule
 	}
 
-	if err := r.db.InsertEntries(namespace, entries); err != nil {
+	if err := r.db.InsertEntries(namespace, rules); err != nil {
 		logrus.WithError(err).WithFields(logrus.Fields{
 			"namespace": namespace,
 		}).Error("Error inserting entries in Redis")
 		return NewRules{}, err
 	}
+	r.reportRules(namespace)
 
 	// Get the new IDs
 	ids := make([]string, len(rules))
@@ -74,11 +86,23 @@ func (r *redisManager) AddRules(namespace string, rules []Rule) (NewRules, error
 	}
 
 	return NewRules{
-		IDs: ids,
+		IDs:      ids,
+		Warnings: warnings,
 	}, nil
 }
 
 func (r *redisManager) GetRules(namespace string, filter Filter) (RetrievedRules, error) {
+	// The schedule is applied through its indexes before any read, so that the revision of the rules read reflects
+	// the rules that apply
+	if err := r.applySchedule(namespace); err != nil {
+		return RetrievedRules{}, err
+	}
+
+	// Pages of rules are read through the sort indexes, rather than reading all the rules
+	if filter.Limit > 0 && len(filter.IDs) == 0 {
+		return r.getPage(namespace, filter)
+	}
+
 	results := []Rule{}
 
 	var stringRules []string
@@ -104,42 +128,126 @@ func (r *redisManager) GetRules(namespace string, filter Filter) (RetrievedRules
 		}
 	}
 
-	results = make([]Rule, len(stringRules))
-	for index, entry := range stringRules {
-		rule := Rule{}
-		if err = json.Unmarshal([]byte(entry), &rule); err != nil {
-			logrus.WithError(err).WithFields(logrus.Fields{
-				"namespace": namespace,
-				"entry":     entry,
-			}).Error("Could not unmarshal object returned from Redis")
-			return RetrievedRules{}, &JSONMarshalError{Message: err.Error()}
-		}
-		results[index] = rule
+	results, err = decodeRules(namespace, stringRules)
+	if err != nil {
+		return RetrievedRules{}, err
 	}
 
-	results = FilterRules(filter, results)
+	if len(filter.IDs) == 0 {
+		metrics.Rules.Set(float64(len(results)), namespace)
+	}
+
+	results, next, err := PageRules(filter, FilterRules(filter, results))
+	if err != nil {
+		return RetrievedRules{}, err
+	}
 
 	return RetrievedRules{
 		Rules:    results,
 		Revision: rev,
+		Continue: next,
 	}, nil
 }
 
-func (r *redisManager) SetRules(namespace string, filter Filter, rules []Rule) (NewRules, error) {
+// getPage reads the rules of the namespace in the sort order of the filter, from the continue cursor of the filter,
+// until a page of rules passes the filter.
+func (r *redisManager) getPage(namespace string, filter Filter) (RetrievedRules, error) {
+	after, err := decodeCursor(filter)
+	if err != nil {
+		return RetrievedRules{}, err
+	}
+
+	sortBy := filter.order()
+	results := []Rule{}
+	for {
+		entries, more, rev, err := r.db.ReadPage(namespace, sortBy, after, filter.Limit)
+		if err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{
+				"namespace": namespace,
+				"filter":    filter,
+			}).Error("Could not read page of entries from Redis")
+			return RetrievedRules{}, err
+		}
+
+		batch, err := decodeRules(namespace, entries)
+		if err != nil {
+			return RetrievedRules{}, err
+		}
+
+		for _, rule := range FilterRules(filter, batch) {
+			results = append(results, rule)
+			if len(results) < filter.Limit {
+				continue
+			}
+
+			// Another page follows if any rule was read after this one
+			next := ""
+			if more || rule.ID != batch[len(batch)-1].ID {
+				next = newCursor(sortBy, rule).encode()
+			}
+
+			return RetrievedRules{
+				Rules:    results,
+				Revision: rev,
+				Continue: next,
+			}, nil
+		}
+
+		if !more {
+			return RetrievedRules{
+				Rules:    results,
+				Revision: rev,
+			}, nil
+		}
+
+		// Rules deleted since the index was read may leave the batch empty, in which case it is read again
+		if len(batch) > 0 {
+			c := newCursor(sortBy, batch[len(batch)-1])
+			after = &c
+		}
+	}
+}
+
+// decodeRules unmarshals the rules read from Redis.
+func decodeRules(namespace string, entries []string) ([]Rule, error) {
+	rules := make([]Rule, len(entries))
+	for i, entry := range entries {
+		if err := json.Unmarshal([]byte(entry), &rules[i]); err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{
+				"namespace": namespace,
+				"entry":     entry,
+			}).Error("Could not unmarshal object returned from Redis")
+			return nil, &JSONMarshalError{Message: err.Error()}
+		}
+	}
+
+	return rules, nil
+}
+
+func (r *redisManager) SetRules(namespace string, filter Filter, rules []Rule, revision int64) (NewRules, error) {
 	for i := range rules {
 		rules[i].ID = uuid.New()
 	}
 
 	// Validate rules
-	for _, rule := range rules {
-		if err := r.validator.Validate(rule); err != nil {
-			return NewRules{}, &InvalidRuleError{}
-		}
+	existing, err := r.GetRules(namespace, Filter{IncludeInactive: true})
+	if err != nil {
+		return NewRules{}, err
+	}
+
+	// Rules are replaced regardless of their activation window
+	filter.IncludeInactive = true
+
+	remaining := excludeRules(existing.Rules, FilterRules(filter, existing.Rules))
+	warnings, err := validateRules(r.validator, remaining, rules)
+	if err != nil {
+		return NewRules{}, err
 	}
 
-	if err := r.db.SetByDestination(namespace, filter, rules); err != nil {
+	if err := r.db.SetByDestination(namespace, filter, rules, revision); err != nil {
 		return NewRules{}, err
 	}
+	r.reportRules(namespace)
 
 	// Get the new IDs
 	ids := make([]string, len(rules))
@@ -148,42 +256,160 @@ func (r *redisManager) SetRules(namespace string, filter Filter, rules []Rule) (
 	}
 
 	return NewRules{
-		IDs: ids,
+		IDs:      ids,
+		Warnings: warnings,
 	}, nil
 }
 
-func (r *redisManager) UpdateRules(namespace string, rules []Rule) error {
+func (r *redisManager) UpdateRules(namespace string, rules []Rule, revision int64) error {
 	if len(rules) == 0 {
 		return errors.New("rules: no rules provided")
 	}
 
 	// Validate rules
-	for _, rule := range rules {
-		if err := r.validator.Validate(rule); err != nil {
-			return &InvalidRuleError{}
+	existing, err := r.GetRules(namespace, Filter{IncludeInactive: true})
+	if err != nil {
+		return err
+	}
+
+	if _, err := validateRules(r.validator, excludeRules(existing.Rules, rules), rules); err != nil {
+		return err
+	}
+
+	if err := r.db.UpdateEntries(namespace, rules, revision); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"namespace": namespace,
+		}).Error("Error updating entries in Redis")
+		return err
+	}
+	r.reportRules(namespace)
+
+	return nil
+}
+
+func (r *redisManager) DeleteRules(namespace string, filter Filter, revision int64) error {
+	// Rules are deleted regardless of their activation window
+	filter.IncludeInactive = true
+
+	if err := r.db.SetByDestination(namespace, filter, []Rule{}, revision); err != nil {
+		return err
+	}
+	r.reportRules(namespace)
+
+	return nil
+}
+
+func (r *redisManager) WatchRules(namespace string, revision int64, timeout time.Duration) error {
+	// Changes made before the subscription is confirmed are missed, so the watch cannot rely on them until then
+	deadline := time.Now().Add(timeout)
+	waitTimeout(r.subscribed, timeout)
+	timeout = deadline.Sub(time.Now())
+
+	// Wait for changes before reading the revision so that no change in between is missed
+	changed := r.notifier.wait(namespace)
+
+	res, err := r.GetRules(namespace, Filter{IncludeInactive: true})
+	if err != nil {
+		return err
+	}
+
+	if res.Revision > revision {
+		return nil
+	}
+
+	waitTimeout(changed, scheduleTimeout(res.Rules, time.Now(), timeout))
+
+	return nil
+}
+
+func (r *redisManager) GetHistory(namespace string) ([]RevisionSummary, error) {
+	summaries, err := r.db.ReadHistory(namespace)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"namespace": namespace,
+		}).Error("Could not read history from Redis")
+		return []RevisionSummary{}, err
+	}
+
+	return summaries, nil
+}
+
+func (r *redisManager) GetSnapshot(namespace string, revision int64) (Snapshot, error) {
+	entries, timestamp, err := r.db.ReadSnapshot(namespace, revision)
+	if err != nil {
+		if _, ok := err.(*RevisionNotFoundError); !ok {
+			logrus.WithError(err).WithFields(logrus.Fields{
+				"namespace": namespace,
+				"revision":  revision,
+			}).Error("Could not read snapshot from Redis")
 		}
+		return Snapshot{}, err
 	}
 
-	entries := make(map[string]string)
-	for _, rule := range rules {
-		data, err := json.Marshal(&rule)
-		if err != nil {
-			return err
+	rules := make([]Rule, len(entries))
+	for index, entry := range entries {
+		rule := Rule{}
+		if err = json.Unmarshal([]byte(entry), &rule); err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{
+				"namespace": namespace,
+				"entry":     entry,
+			}).Error("Could not unmarshal object returned from Redis")
+			return Snapshot{}, &JSONMarshalError{Message: err.Error()}
 		}
+		rules[index] = rule
+	}
+
+	return Snapshot{
+		Revision:  revision,
+		Timestamp: timestamp,
+		Rules:     rules,
+	}, nil
+}
+
+func (r *redisManager) Rollback(namespace string, revision int64) error {
+	snapshot, err := r.GetSnapshot(namespace, revision)
+	if err != nil {
+		return err
+	}
 
-		entries[rule.ID] = string(data)
+	// Replace all the rules, keeping the IDs they had at the revision
+	if err := r.db.SetByDestination(namespace, Filter{IncludeInactive: true}, snapshot.Rules, AnyRevision); err != nil {
+		return err
 	}
+	r.reportRules(namespace)
+
+	return nil
+}
 
-	if err := r.db.UpdateEntries(namespace, entries); err != nil {
+// applySchedule deletes the expired rules of the namespace, and updates the revision if the activation window of any
+// rule started or ended since the revision was last updated, as the rules that apply have changed. The time of the last
+// update is shared by all controllers, so the revision is updated once per change.
+func (r *redisManager) applySchedule(namespace string) error {
+	changed, err := r.db.ApplySchedule(namespace, time.Now())
+	if err != nil {
 		logrus.WithError(err).WithFields(logrus.Fields{
 			"namespace": namespace,
-		}).Error("Error updating entries in Redis")
+		}).Error("Could not apply rule schedule in Redis")
 		return err
 	}
 
+	// Expired rules may have been deleted
+	if changed {
+		r.reportRules(namespace)
+	}
+
 	return nil
 }
 
-func (r *redisManager) DeleteRules(namespace string, filter Filter) error {
-	return r.db.SetByDestination(namespace, filter, []Rule{})
+// reportRules updates the rules metric of the namespace after its rules changed.
+func (r *redisManager) reportRules(namespace string) {
+	count, err := r.db.CountEntries(namespace)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"namespace": namespace,
+		}).Warn("Could not count entries in Redis")
+		return
+	}
+
+	metrics.Rules.Set(float64(count), namespace)
 }
@@ -14,7 +14,10 @@
 
 package rules
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 // Rule represents an individual rule.
 type Rule struct {
@@ -25,4 +28,112 @@ type Rule struct {
 	Match       json.RawMessage `json:"match,omitempty"`
 	Route       json.RawMessage `json:"route,omitempty"`
 	Actions     json.RawMessage `json:"actions,omitempty"`
+	NotBefore   *time.Time      `json:"not_before,omitempty"`
+	NotAfter    *time.Time      `json:"not_after,omitempty"`
+}
+
+// Active returns whether the time is within the activation window of the rule.
+func (r Rule) Active(t time.Time) bool {
+	return (r.NotBefore == nil || !t.Before(*r.NotBefore)) && !r.Expired(t)
+}
+
+// Expired returns whether the activation window of the rule ended at or before the time.
+func (r Rule) Expired(t time.Time) bool {
+	return r.NotAfter != nil && !t.Before(*r.NotAfter)
+}
+
+// Match is the decoded form of the match conditions of a rule.
+type Match struct {
+	Source  *Source           `json:"source,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Path    *PathMatch        `json:"path,omitempty"`
+	Method  []string          `json:"method,omitempty"`
+	Query   map[string]string `json:"query,omitempty"`
+	Cookies map[string]string `json:"cookies,omitempty"`
+	All     []Match           `json:"all,omitempty"`
+	Any     []Match           `json:"any,omitempty"`
+	None    []Match           `json:"none,omitempty"`
+}
+
+// conditions returns the match without its "all", "any" and "none" blocks.
+func (m Match) conditions() Match {
+	return Match{
+		Source:  m.Source,
+		Headers: m.Headers,
+		Path:    m.Path,
+		Method:  m.Method,
+		Query:   m.Query,
+		Cookies: m.Cookies,
+	}
+}
+
+// empty returns whether the match has no conditions of its own.
+func (m Match) empty() bool {
+	return m.Source == nil && len(m.Headers) == 0 && m.Path == nil && len(m.Method) == 0 && len(m.Query) == 0 &&
+		len(m.Cookies) == 0
+}
+
+// PathMatch matches the path of a request, relative to the destination service, by prefix or regular expression.
+type PathMatch struct {
+	Prefix string `json:"prefix,omitempty"`
+	Regex  string `json:"regex,omitempty"`
+}
+
+// Source is the service from which a request originates.
+type Source struct {
+	Name string   `json:"name,omitempty"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+// Route is the decoded form of the route of a rule.
+type Route struct {
+	Backends []Backend `json:"backends"`
+}
+
+// Backend is a version of a service to which requests are routed.
+type Backend struct {
+	Name    string   `json:"name,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+	Weight  float64  `json:"weight,omitempty"`
+	Timeout float64  `json:"timeout,omitempty"`
+	Retries *float64 `json:"retries,omitempty"`
+
+	Rewrite        *Rewrite          `json:"rewrite,omitempty"`
+	Headers        *HeaderOperations `json:"headers,omitempty"`
+	CircuitBreaker *CircuitBreaker   `json:"circuit_breaker,omitempty"`
+}
+
+// CircuitBreaker limits the load on each instance of a backend and ejects failing instances from load balancing.
+type CircuitBreaker struct {
+	MaxConnections     int     `json:"max_connections,omitempty"`
+	MaxPendingRequests int     `json:"max_pending_requests,omitempty"`
+	Consecutive5xx     int     `json:"consecutive_5xx,omitempty"`
+	EjectionDuration   float64 `json:"ejection_duration,omitempty"`
+}
+
+// Rewrite replaces the URI prefix of requests sent to a backend.
+type Rewrite struct {
+	Prefix      string `json:"prefix"`
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// HeaderOperations change the headers of requests sent to a backend.
+type HeaderOperations struct {
+	Set    map[string]string `json:"set,omitempty"`
+	Add    map[string]string `json:"add,omitempty"`
+	Remove []string          `json:"remove,omitempty"`
+}
+
+// Action is the decoded form of the attributes common to all rule actions.
+type Action struct {
+	Action string   `json:"action"`
+	Tags   []string `json:"tags,omitempty"`
+
+	// Backend is the backend to which a mirror action sends copies of requests.
+	Backend *Backend `json:"backend,omitempty"`
+
+	// Key and Header select whether a rate limit action limits the requests of each source service or header value
+	// separately.
+	Key    string `json:"key,omitempty"`
+	Header string `json:"header,omitempty"`
 }
@@ -0,0 +1,74 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package rules
+
+import "time"
+
+// scheduleChanged returns whether the activation window of any of the rules started or ended in the interval
+// (since, now].
+func scheduleChanged(rules []Rule, since, now time.Time) bool {
+	within := func(t *time.Time) bool {
+		return t != nil && t.After(since) && !t.After(now)
+	}
+
+	for _, rule := range rules {
+		if within(rule.NotBefore) || within(rule.NotAfter) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// nextScheduleChange returns the earliest start or end of an activation window of the rules after now, or the zero
+// time if there is none.
+func nextScheduleChange(rules []Rule, now time.Time) time.Time {
+	var next time.Time
+	consider := func(t *time.Time) {
+		if t != nil && t.After(now) && (next.IsZero() || t.Before(next)) {
+			next = *t
+		}
+	}
+
+	for _, rule := range rules {
+		consider(rule.NotBefore)
+		consider(rule.NotAfter)
+	}
+
+	return next
+}
+
+// scheduleTimeout shortens the timeout of a watch so that it ends when the activation window of any of the rules
+// starts or ends, as the rules that apply change at that time.
+func scheduleTimeout(rules []Rule, now time.Time, timeout time.Duration) time.Duration {
+	next := nextScheduleChange(rules, now)
+	if !next.IsZero() && next.Sub(now) < timeout {
+		return next.Sub(now)
+	}
+
+	return timeout
+}
+
+// expiredRules returns the rules whose activation window ended at or before now.
+func expiredRules(rules []Rule, now time.Time) []Rule {
+	var expired []Rule
+	for _, rule := range rules {
+		if rule.Expired(now) {
+			expired = append(expired, rule)
+		}
+	}
+
+	return expired
+}
@@ -0,0 +1,442 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package rules
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SimulationRequest describes a synthetic request to evaluate rules against.
+type SimulationRequest struct {
+	// Source service of the request.
+	Source Source `json:"source"`
+
+	// Destination service of the request.
+	Destination string `json:"destination"`
+
+	// Headers of the request. Header names are case insensitive.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Path of the request, relative to the destination service. Defaults to "/".
+	Path string `json:"path,omitempty"`
+
+	// Method of the request. Defaults to GET.
+	Method string `json:"method,omitempty"`
+
+	// Query parameters of the request.
+	Query map[string]string `json:"query,omitempty"`
+
+	// Cookies of the request.
+	Cookies map[string]string `json:"cookies,omitempty"`
+}
+
+// SimulationResult describes how the sidecar of the source service would handle a request.
+type SimulationResult struct {
+	// Destination service of the request.
+	Destination string `json:"destination"`
+
+	// RouteRule is the ID of the route rule that was selected, if any.
+	RouteRule string `json:"route_rule,omitempty"`
+
+	// Rejected is true if route rules of the destination apply to the source but none of them match the request, in
+	// which case the sidecar rejects the request with 412 Precondition Failed.
+	Rejected bool `json:"rejected,omitempty"`
+
+	// ActionRule is the ID of the action rule that was selected, if any.
+	ActionRule string `json:"action_rule,omitempty"`
+
+	// Backends the request may be routed to.
+	Backends []SimulatedBackend `json:"backends,omitempty"`
+}
+
+// SimulatedBackend is a backend the request may be routed to, and the actions applied when it is selected.
+type SimulatedBackend struct {
+	Backend
+
+	// Actions applied to the request when this backend is selected.
+	Actions []json.RawMessage `json:"actions,omitempty"`
+}
+
+// Simulate evaluates the rules against a synthetic request the same way the sidecar of the source service does.
+//
+// Rules for other destinations are ignored, as are rules that don't apply to the source. A rule applies to the
+// source when either every source in its "all" block (which includes its top-level conditions) or some entry of its
+// "any" block applies, and no source in its "none" block applies. Entries of an "any" block without a source always
+// apply, and so does a rule with neither block. Only the blocks and entries that apply to the source are kept.
+//
+// A rule matches a request when all of the conditions in its "all" block hold, all of the conditions of at least
+// one entry in its "any" block hold, and the conditions of no entry in its "none" block all hold. Matching rules are
+// considered in order of decreasing priority, and the first matching route rule and the first matching action rule
+// are selected. Without route rules that apply to the source, requests are load balanced across all instances of
+// the destination. Backends without a weight share the weight not
+// assigned to other backends equally. Actions with tags only apply when the selected backend has those tags.
+func Simulate(rules []Rule, req SimulationRequest) (SimulationResult, error) {
+	res := SimulationResult{
+		Destination: req.Destination,
+	}
+
+	headers := make(map[string]string, len(req.Headers))
+	for name, value := range req.Headers {
+		headers[strings.ToLower(name)] = value
+	}
+	req.Headers = headers
+
+	if req.Path == "" {
+		req.Path = "/"
+	}
+	if req.Method == "" {
+		req.Method = "GET"
+	}
+
+	var routes, actions []Rule
+	for _, rule := range rules {
+		if rule.Destination != req.Destination {
+			continue
+		}
+
+		if len(rule.Route) > 0 {
+			routes = append(routes, rule)
+		} else if len(rule.Actions) > 0 {
+			actions = append(actions, rule)
+		}
+	}
+	sortByPriority(routes)
+	sortByPriority(actions)
+
+	// Select the route rule
+	var selectedRoute *Rule
+	applicable := false
+	for i := range routes {
+		match, err := matchSource(routes[i], req.Source)
+		if err != nil {
+			return SimulationResult{}, err
+		}
+
+		if match == nil {
+			continue
+		}
+		applicable = true
+
+		if match.matchRequest(req) {
+			selectedRoute = &routes[i]
+			break
+		}
+	}
+
+	if selectedRoute != nil {
+		route := Route{}
+		if err := json.Unmarshal(selectedRoute.Route, &route); err != nil {
+			return SimulationResult{}, &JSONMarshalError{Message: err.Error()}
+		}
+
+		res.RouteRule = selectedRoute.ID
+		res.Backends = make([]SimulatedBackend, len(route.Backends))
+		for i, backend := range distributeWeights(req.Destination, route.Backends) {
+			res.Backends[i] = SimulatedBackend{Backend: backend}
+		}
+	} else if applicable {
+		res.Rejected = true
+		return res, nil
+	} else {
+		// Without applicable route rules requests are load balanced across all instances of the destination
+		res.Backends = []SimulatedBackend{
+			{
+				Backend: Backend{
+					Name:   req.Destination,
+					Weight: 1,
+				},
+			},
+		}
+	}
+
+	// Select the action rule
+	for _, rule := range actions {
+		match, err := matchSource(rule, req.Source)
+		if err != nil {
+			return SimulationResult{}, err
+		}
+
+		if match == nil || !match.matchRequest(req) {
+			continue
+		}
+
+		var ruleActions []json.RawMessage
+		if err := json.Unmarshal(rule.Actions, &ruleActions); err != nil {
+			return SimulationResult{}, &JSONMarshalError{Message: err.Error()}
+		}
+
+		res.ActionRule = rule.ID
+		for _, raw := range ruleActions {
+			action := Action{}
+			if err := json.Unmarshal(raw, &action); err != nil {
+				return SimulationResult{}, &JSONMarshalError{Message: err.Error()}
+			}
+
+			for i := range res.Backends {
+				// Tagged actions only apply to backends selected by a route rule
+				if len(action.Tags) == 0 || (selectedRoute != nil && containsTags(res.Backends[i].Tags, action.Tags)) {
+					res.Backends[i].Actions = append(res.Backends[i].Actions, raw)
+				}
+			}
+		}
+		break
+	}
+
+	return res, nil
+}
+
+// sourceMatch holds the match blocks of a rule, reduced to the entries that apply to a source.
+type sourceMatch struct {
+	all  []Match
+	any  []Match
+	none []Match
+}
+
+// matchSource returns the match blocks of the rule that apply to the source, the way the sidecar of the source
+// preprocesses the rule, or nil if the rule doesn't apply to the source. Sources are removed from the returned
+// entries.
+func matchSource(rule Rule, source Source) (*sourceMatch, error) {
+	if len(rule.Match) == 0 {
+		return &sourceMatch{}, nil
+	}
+
+	match := Match{}
+	if err := json.Unmarshal(rule.Match, &match); err != nil {
+		return nil, &JSONMarshalError{Message: err.Error()}
+	}
+
+	// Top-level conditions must hold in addition to the "all" block
+	if !match.empty() {
+		match.All = append(match.All, match.conditions())
+	}
+
+	res := &sourceMatch{}
+
+	allApplies := true
+	for _, m := range match.All {
+		if m.Source != nil && !matchSourceService(*m.Source, source) {
+			allApplies = false
+			res.all = nil
+			break
+		}
+		m.Source = nil
+		res.all = append(res.all, m)
+	}
+
+	// Only entries with a source make the "any" block apply, while the conditions of entries without a source
+	// still hold for the requests of any source the rule applies to
+	anyApplies := false
+	for _, m := range match.Any {
+		if m.Source != nil {
+			if !matchSourceService(*m.Source, source) {
+				continue
+			}
+			anyApplies = true
+		}
+		m.Source = nil
+		res.any = append(res.any, m)
+	}
+
+	excluded := false
+	for _, m := range match.None {
+		if m.Source != nil {
+			excluded = excluded || matchSourceService(*m.Source, source)
+			continue
+		}
+
+		// The sidecar ignores entries without conditions
+		if !m.empty() {
+			res.none = append(res.none, m)
+		}
+	}
+
+	// A rule applies through its "all" or "any" block, so a rule with neither never applies
+	applies := (len(match.All) > 0 && allApplies) || (len(match.Any) > 0 && anyApplies)
+	if !applies || excluded {
+		return nil, nil
+	}
+
+	return res, nil
+}
+
+// matchSourceService returns whether the source of a match entry applies to the source service. A source with a
+// name applies to the service with that name, and a source with tags applies to services with all of those tags.
+func matchSourceService(s Source, source Source) bool {
+	if s.Name != "" && s.Name != source.Name {
+		return false
+	}
+
+	if s.Name == "" && len(s.Tags) == 0 {
+		return false
+	}
+
+	return containsTags(source.Tags, s.Tags)
+}
+
+// matchRequest returns whether the match blocks hold for a request. Header names in the request must be lower case.
+func (sm *sourceMatch) matchRequest(req SimulationRequest) bool {
+	for _, m := range sm.all {
+		if !matchConditions(m, req) {
+			return false
+		}
+	}
+
+	if len(sm.any) > 0 {
+		matched := false
+		for _, m := range sm.any {
+			if matchConditions(m, req) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	for _, m := range sm.none {
+		if matchConditions(m, req) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchConditions returns whether the request conditions of a match entry hold for a request.
+func matchConditions(m Match, req SimulationRequest) bool {
+	for name, pattern := range m.Headers {
+		if !matchValue(req.Headers, strings.ToLower(name), pattern) {
+			return false
+		}
+	}
+
+	if m.Path != nil {
+		if m.Path.Prefix != "" && !strings.HasPrefix(req.Path, m.Path.Prefix) {
+			return false
+		}
+
+		if m.Path.Regex != "" && !matchPattern(m.Path.Regex, req.Path) {
+			return false
+		}
+	}
+
+	if len(m.Method) > 0 {
+		matched := false
+		for _, method := range m.Method {
+			if method == req.Method {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	for name, pattern := range m.Query {
+		if !matchValue(req.Query, name, pattern) {
+			return false
+		}
+	}
+
+	for name, pattern := range m.Cookies {
+		if !matchValue(req.Cookies, name, pattern) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchValue returns whether the named value exists and matches the pattern.
+func matchValue(values map[string]string, name, pattern string) bool {
+	value, exists := values[name]
+	return exists && matchPattern(pattern, value)
+}
+
+// matchPattern returns whether the value matches the regular expression. The sidecar treats patterns it cannot
+// compile as not matching.
+func matchPattern(pattern, value string) bool {
+	matched, err := regexp.MatchString(pattern, value)
+	return err == nil && matched
+}
+
+// distributeWeights returns the backends with a name and weight set on each of them. Backends without a name are
+// backends of the destination, and backends without a weight share the weight not assigned to other backends.
+func distributeWeights(destination string, backends []Backend) []Backend {
+	res := make([]Backend, len(backends))
+	copy(res, backends)
+
+	sum := 0.0
+	unweighted := 0
+	for i := range res {
+		if res[i].Name == "" {
+			res[i].Name = destination
+		}
+
+		if res[i].Weight == 0 {
+			unweighted++
+		} else {
+			sum += res[i].Weight
+		}
+	}
+
+	for i := range res {
+		if res[i].Weight == 0 {
+			res[i].Weight = (1 - sum) / float64(unweighted)
+		}
+	}
+
+	return res
+}
+
+// containsTags returns whether tags contains every tag in required.
+func containsTags(tags, required []string) bool {
+	set := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		set[tag] = true
+	}
+
+	for _, tag := range required {
+		if !set[tag] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sortByPriority sorts rules by decreasing priority. Rules with equal priority are ordered by ID so that the order
+// is deterministic.
+func sortByPriority(rules []Rule) {
+	sort.Sort(rulesByPriority(rules))
+}
+
+type rulesByPriority []Rule
+
+func (r rulesByPriority) Len() int      { return len(r) }
+func (r rulesByPriority) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
+func (r rulesByPriority) Less(i, j int) bool {
+	if r[i].Priority != r[j].Priority {
+		return r[i].Priority > r[j].Priority
+	}
+	return r[i].ID < r[j].ID
+}
@@ -0,0 +1,334 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package rules
+
+import (
+	"testing"
+)
+
+func TestSimulate(t *testing.T) {
+	rules := []Rule{
+		{
+			ID:          "default",
+			Destination: "reviews",
+			Route:       []byte(`{"backends":[{"tags":["v1"]}]}`),
+		},
+		{
+			ID:          "jason",
+			Priority:    10,
+			Destination: "reviews",
+			Match:       []byte(`{"source":{"name":"productpage"},"headers":{"Cookie":"^(.*?;)?(user=jason)(;.*)?$"}}`),
+			Route:       []byte(`{"backends":[{"tags":["v2"],"weight":0.25},{"tags":["v3"]},{"name":"ratings","tags":["v1"]}]}`),
+		},
+		{
+			ID:          "fault",
+			Destination: "reviews",
+			Match:       []byte(`{"any":[{"source":{"tags":["canary"]}},{"headers":{"X-Test":"true"}}],"none":[{"source":{"name":"admin"}}]}`),
+			Actions:     []byte(`[{"action":"delay","duration":2,"tags":["v2"]},{"action":"trace","log_value":"test"}]`),
+		},
+		{
+			ID:          "other",
+			Priority:    100,
+			Destination: "ratings",
+			Route:       []byte(`{"backends":[{"tags":["v9"]}]}`),
+		},
+	}
+
+	cases := []struct {
+		Name       string
+		Request    SimulationRequest
+		RouteRule  string
+		ActionRule string
+		Backends   map[string]float64
+		Actions    map[string]int
+	}{
+		{
+			Name: "default route",
+			Request: SimulationRequest{
+				Source:      Source{Name: "productpage"},
+				Destination: "reviews",
+			},
+			RouteRule: "default",
+			Backends:  map[string]float64{"reviews-v1": 1},
+		},
+		{
+			Name: "higher priority route with distributed weights",
+			Request: SimulationRequest{
+				Source:      Source{Name: "productpage"},
+				Destination: "reviews",
+				Headers:     map[string]string{"cookie": "user=jason"},
+			},
+			RouteRule: "jason",
+			Backends:  map[string]float64{"reviews-v2": 0.25, "reviews-v3": 0.375, "ratings-v1": 0.375},
+		},
+		{
+			Name: "actions filtered by backend tags",
+			Request: SimulationRequest{
+				Source:      Source{Name: "productpage", Tags: []string{"canary"}},
+				Destination: "reviews",
+				Headers:     map[string]string{"Cookie": "user=jason"},
+			},
+			RouteRule:  "jason",
+			ActionRule: "fault",
+			Backends:   map[string]float64{"reviews-v2": 0.25, "reviews-v3": 0.375, "ratings-v1": 0.375},
+			Actions:    map[string]int{"reviews-v2": 2, "reviews-v3": 1, "ratings-v1": 1},
+		},
+		{
+			Name: "excluded by none block",
+			Request: SimulationRequest{
+				Source:      Source{Name: "admin", Tags: []string{"canary"}},
+				Destination: "reviews",
+			},
+			RouteRule: "default",
+			Backends:  map[string]float64{"reviews-v1": 1},
+		},
+		{
+			Name: "no route rules",
+			Request: SimulationRequest{
+				Source:      Source{Name: "productpage"},
+				Destination: "details",
+			},
+			Backends: map[string]float64{"details-": 1},
+		},
+	}
+
+	for _, c := range cases {
+		res, err := Simulate(rules, c.Request)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", c.Name, err)
+			continue
+		}
+
+		if res.RouteRule != c.RouteRule {
+			t.Errorf("%v: expected route rule %q, got %q", c.Name, c.RouteRule, res.RouteRule)
+		}
+
+		if res.ActionRule != c.ActionRule {
+			t.Errorf("%v: expected action rule %q, got %q", c.Name, c.ActionRule, res.ActionRule)
+		}
+
+		if len(res.Backends) != len(c.Backends) {
+			t.Errorf("%v: expected %v backends, got %v", c.Name, len(c.Backends), len(res.Backends))
+			continue
+		}
+
+		for _, b := range res.Backends {
+			key := b.Name + "-"
+			if len(b.Tags) > 0 {
+				key += b.Tags[0]
+			}
+
+			if weight, exists := c.Backends[key]; !exists || weight != b.Weight {
+				t.Errorf("%v: unexpected backend %v with weight %v", c.Name, key, b.Weight)
+			}
+
+			if len(b.Actions) != c.Actions[key] {
+				t.Errorf("%v: expected %v actions for backend %v, got %v", c.Name, c.Actions[key], key, len(b.Actions))
+			}
+		}
+	}
+}
+
+func TestSimulateRejected(t *testing.T) {
+	rules := []Rule{
+		{
+			ID:          "jason",
+			Destination: "reviews",
+			Match:       []byte(`{"headers":{"Cookie":"user=jason"}}`),
+			Route:       []byte(`{"backends":[{"tags":["v2"]}]}`),
+		},
+	}
+
+	res, err := Simulate(rules, SimulationRequest{Destination: "reviews"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !res.Rejected {
+		t.Error("expected request to be rejected")
+	}
+
+	if len(res.Backends) != 0 {
+		t.Errorf("expected no backends, got %v", res.Backends)
+	}
+}
+
+func TestSimulateSourceNotTargeted(t *testing.T) {
+	rules := []Rule{
+		{
+			ID:          "jason",
+			Destination: "reviews",
+			Match:       []byte(`{"source":{"name":"productpage"},"headers":{"Cookie":"user=jason"}}`),
+			Route:       []byte(`{"backends":[{"tags":["v2"]}]}`),
+		},
+		{
+			ID:          "canary",
+			Destination: "reviews",
+			Match:       []byte(`{"all":[{"source":{"name":"productpage"}}],"any":[{"source":{"tags":["canary"]}}]}`),
+			Actions:     []byte(`[{"action":"trace","log_value":"canary"}]`),
+		},
+	}
+
+	// The sidecar of a source that no rule targets load balances across all instances
+	res, err := Simulate(rules, SimulationRequest{Source: Source{Name: "details"}, Destination: "reviews"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if res.Rejected || res.RouteRule != "" || res.ActionRule != "" {
+		t.Errorf("expected no rules to apply, got %+v", res)
+	}
+
+	if len(res.Backends) != 1 || res.Backends[0].Name != "reviews" || res.Backends[0].Weight != 1 {
+		t.Errorf("expected all instances of the destination, got %v", res.Backends)
+	}
+
+	// The route rule applies to productpage, which is rejected without the cookie
+	res, err = Simulate(rules, SimulationRequest{Source: Source{Name: "productpage"}, Destination: "reviews"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !res.Rejected {
+		t.Error("expected request to be rejected")
+	}
+
+	// Sources in "all" and "any" blocks apply when either block applies
+	res, err = Simulate(rules, SimulationRequest{Source: Source{Name: "details", Tags: []string{"canary"}},
+		Destination: "reviews"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if res.ActionRule != "canary" {
+		t.Errorf("expected action rule %q, got %q", "canary", res.ActionRule)
+	}
+}
+
+func TestSimulateMatchConditions(t *testing.T) {
+	rules := []Rule{
+		{
+			ID:          "api",
+			Destination: "reviews",
+			Match:       []byte(`{"path":{"prefix":"/api"},"method":["GET","HEAD"],"none":[{"cookies":{"user":"^test"}}]}`),
+			Route:       []byte(`{"backends":[{"tags":["v2"]}]}`),
+		},
+		{
+			ID:          "debug",
+			Destination: "reviews",
+			Match:       []byte(`{"source":{"name":"productpage"},"any":[{"query":{"debug":"^(true|1)$"}},{"path":{"regex":"^/debug/"}}]}`),
+			Route:       []byte(`{"backends":[{"tags":["v3"]}]}`),
+		},
+		{
+			ID:          "admin",
+			Priority:    10,
+			Destination: "reviews",
+			Match: []byte(`{"source":{"name":"productpage"},` +
+				`"any":[{"path":{"prefix":"/admin"},"method":["POST"]},{"headers":{"X-Admin":"true"}}],` +
+				`"none":[{"path":{"prefix":"/admin/health"},"method":["POST"]}]}`),
+			Route: []byte(`{"backends":[{"tags":["v4"]}]}`),
+		},
+		{
+			// Entries without a source never make an "any" block apply
+			ID:          "unsourced",
+			Priority:    20,
+			Destination: "reviews",
+			Match:       []byte(`{"any":[{"headers":{"X-Unsourced":"true"}}]}`),
+			Route:       []byte(`{"backends":[{"tags":["v5"]}]}`),
+		},
+		{
+			// Rules apply through their "all" or "any" blocks, never through a "none" block alone
+			ID:          "none-only",
+			Priority:    30,
+			Destination: "reviews",
+			Match:       []byte(`{"none":[{"headers":{"X-Excluded":"true"}}]}`),
+			Route:       []byte(`{"backends":[{"tags":["v6"]}]}`),
+		},
+	}
+
+	productpage := Source{Name: "productpage"}
+
+	cases := []struct {
+		Name      string
+		Request   SimulationRequest
+		RouteRule string
+	}{
+		{
+			Name:    "unsourced any entry",
+			Request: SimulationRequest{Source: productpage, Destination: "reviews", Path: "/reviews", Headers: map[string]string{"X-Unsourced": "true"}},
+		},
+		{
+			Name:    "any block of another source",
+			Request: SimulationRequest{Source: Source{Name: "details"}, Destination: "reviews", Path: "/reviews", Query: map[string]string{"debug": "1"}},
+		},
+		{
+			Name:      "path and method of the same entry",
+			Request:   SimulationRequest{Source: productpage, Destination: "reviews", Path: "/admin/users", Method: "POST"},
+			RouteRule: "admin",
+		},
+		{
+			Name:    "path without the method of its entry",
+			Request: SimulationRequest{Source: productpage, Destination: "reviews", Path: "/admin/users"},
+		},
+		{
+			Name:    "method without the path of its entry",
+			Request: SimulationRequest{Source: productpage, Destination: "reviews", Path: "/reviews", Method: "POST"},
+		},
+		{
+			Name:    "excluded by path and method of the same entry",
+			Request: SimulationRequest{Source: productpage, Destination: "reviews", Path: "/admin/health", Method: "POST"},
+		},
+		{
+			Name:      "not excluded by path alone",
+			Request:   SimulationRequest{Source: productpage, Destination: "reviews", Path: "/admin/health", Headers: map[string]string{"X-Admin": "true"}},
+			RouteRule: "admin",
+		},
+		{
+			Name:      "path prefix and default method",
+			Request:   SimulationRequest{Source: productpage, Destination: "reviews", Path: "/api/reviews"},
+			RouteRule: "api",
+		},
+		{
+			Name:    "method not matched",
+			Request: SimulationRequest{Source: productpage, Destination: "reviews", Path: "/api/reviews", Method: "POST"},
+		},
+		{
+			Name:    "excluded cookie",
+			Request: SimulationRequest{Source: productpage, Destination: "reviews", Path: "/api", Cookies: map[string]string{"user": "tester"}},
+		},
+		{
+			Name:      "query parameter",
+			Request:   SimulationRequest{Source: productpage, Destination: "reviews", Path: "/reviews", Query: map[string]string{"debug": "1"}},
+			RouteRule: "debug",
+		},
+		{
+			Name:      "path regex",
+			Request:   SimulationRequest{Source: productpage, Destination: "reviews", Path: "/debug/reviews", Method: "POST"},
+			RouteRule: "debug",
+		},
+	}
+
+	for _, c := range cases {
+		res, err := Simulate(rules, c.Request)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", c.Name, err)
+			continue
+		}
+
+		if res.RouteRule != c.RouteRule {
+			t.Errorf("%v: expected route rule %q, got %q", c.Name, c.RouteRule, res.RouteRule)
+		}
+	}
+}
@@ -15,8 +15,13 @@
 package rules
 
 import (
-	"errors"
+	"encoding/json"
 	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/xeipuuv/gojsonschema"
@@ -45,7 +50,7 @@ func NewValidator() (Validator, error) {
 	}, nil
 }
 
-// Validate a rule
+// Validate a rule against the schema, then check the semantics of the rule.
 func (v *validator) Validate(rule Rule) error {
 	ruleLoader := gojsonschema.NewGoLoader(&rule)
 	result, err := v.schema.Validate(ruleLoader)
@@ -55,18 +60,392 @@ func (v *validator) Validate(rule Rule) error {
 	}
 
 	if !result.Valid() {
-		// TODO: better logging and better error generation
-		descriptions := make([]string, len(result.Errors()))
+		errs := make([]RuleError, len(result.Errors()))
 		for i, e := range result.Errors() {
-			descriptions[i] = fmt.Sprintf("%v: %v", e.Field(), e.Description())
+			errs[i] = RuleError{
+				ID:          rule.ID,
+				Field:       e.Field(),
+				Description: e.Description(),
+			}
 		}
 
 		logrus.WithFields(logrus.Fields{
-			"descriptions": descriptions,
+			"errors": errs,
 		}).Warn("Invalid rule")
 
-		return errors.New("invalid rule")
+		return &InvalidRuleError{Errors: errs}
+	}
+
+	if errs := checkRule(rule); len(errs) > 0 {
+		logrus.WithFields(logrus.Fields{
+			"errors": errs,
+		}).Warn("Invalid rule")
+
+		return &InvalidRuleError{Errors: errs}
 	}
 
 	return nil
 }
+
+// checkRule checks the semantics of a rule that is valid according to the schema.
+func checkRule(rule Rule) []RuleError {
+	var errs []RuleError
+	addError := func(field, format string, args ...interface{}) {
+		errs = append(errs, RuleError{
+			ID:          rule.ID,
+			Field:       field,
+			Description: fmt.Sprintf(format, args...),
+		})
+	}
+
+	seenTags := make(map[string]bool, len(rule.Tags))
+	for i, tag := range rule.Tags {
+		if seenTags[tag] {
+			addError(fmt.Sprintf("tags.%v", i), "Duplicate tag %q", tag)
+		}
+		seenTags[tag] = true
+	}
+
+	if rule.NotBefore != nil && rule.NotAfter != nil && !rule.NotAfter.After(*rule.NotBefore) {
+		addError("not_after", "Rule expires before it is activated")
+	} else if rule.Expired(time.Now()) {
+		addError("not_after", "Rule has already expired")
+	}
+
+	if len(rule.Match) > 0 {
+		match := Match{}
+		if err := json.Unmarshal(rule.Match, &match); err != nil {
+			addError("match", "%v", err)
+			return errs
+		}
+
+		checkMatch("match", match, addError)
+	}
+
+	if len(rule.Route) > 0 {
+		route := Route{}
+		if err := json.Unmarshal(rule.Route, &route); err != nil {
+			addError("route", "%v", err)
+			return errs
+		}
+
+		sum := 0.0
+		unweighted := 0
+		seenBackends := make(map[string]int, len(route.Backends))
+		for i, backend := range route.Backends {
+			if backend.Weight == 0 {
+				unweighted++
+			}
+			sum += backend.Weight
+
+			key := backendKey(rule.Destination, backend)
+			if j, exists := seenBackends[key]; exists {
+				addError(fmt.Sprintf("route.backends.%v", i), "Duplicate of backend %v", j)
+			}
+			seenBackends[key] = i
+
+			if backend.CircuitBreaker != nil {
+				field := fmt.Sprintf("route.backends.%v.circuit_breaker", i)
+				cb := backend.CircuitBreaker
+				if cb.MaxPendingRequests > 0 && cb.MaxConnections == 0 {
+					addError(field, "Pending requests can only be limited together with connections")
+				}
+				if (cb.Consecutive5xx == 0) != (cb.EjectionDuration == 0) {
+					addError(field, "Ejection requires both a number of consecutive 5xx responses and a duration")
+				}
+			}
+
+			if backend.Headers != nil {
+				for _, name := range backend.Headers.conflicts() {
+					addError(fmt.Sprintf("route.backends.%v.headers", i), "Header %q is both changed and removed", name)
+				}
+			}
+		}
+
+		// Allow for the imprecision of weights such as 0.1 + 0.2 + 0.7
+		if sum > 1+weightTolerance {
+			addError("route.backends", "Sum of backend weights %v is greater than 1", sum)
+		} else if unweighted > 0 && sum >= 1-weightTolerance {
+			addError("route.backends", "Backends without a weight receive no traffic, as the other backends have a total weight of 1")
+		}
+	}
+
+	if len(rule.Actions) > 0 {
+		var actions []Action
+		if err := json.Unmarshal(rule.Actions, &actions); err != nil {
+			addError("actions", "%v", err)
+			return errs
+		}
+
+		for i, action := range actions {
+			switch action.Action {
+			case "mirror":
+				if action.Backend == nil || len(action.Tags) == 0 {
+					continue
+				}
+
+				// A mirror action must not copy requests to the backend that already receives them
+				if backendKey(rule.Destination, *action.Backend) == backendKey(rule.Destination, Backend{Tags: action.Tags}) {
+					addError(fmt.Sprintf("actions.%v.backend", i), "Mirrored requests are sent to the backend the action applies to")
+				}
+			case "rate_limit":
+				if action.Key == "header" && action.Header == "" {
+					addError(fmt.Sprintf("actions.%v.header", i), "Header is required to limit requests by header")
+				} else if action.Key != "header" && action.Header != "" {
+					addError(fmt.Sprintf("actions.%v.header", i), "Header is only used to limit requests by header")
+				}
+			}
+		}
+	}
+
+	return errs
+}
+
+// checkMatch checks that the patterns of the match conditions and the nested match blocks are valid regular
+// expressions.
+func checkMatch(field string, m Match, addError func(field, format string, args ...interface{})) {
+	checkPatterns := func(kind string, patterns map[string]string) {
+		names := make([]string, 0, len(patterns))
+		for name := range patterns {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if _, err := regexp.Compile(patterns[name]); err != nil {
+				addError(fmt.Sprintf("%v.%v.%v", field, kind, name), "Invalid pattern: %v", err)
+			}
+		}
+	}
+
+	checkPatterns("headers", m.Headers)
+	checkPatterns("query", m.Query)
+	checkPatterns("cookies", m.Cookies)
+
+	if m.Path != nil && m.Path.Regex != "" {
+		if _, err := regexp.Compile(m.Path.Regex); err != nil {
+			addError(field+".path.regex", "Invalid pattern: %v", err)
+		}
+	}
+
+	for i, nested := range m.All {
+		checkMatch(fmt.Sprintf("%v.all.%v", field, i), nested, addError)
+	}
+	for i, nested := range m.Any {
+		checkMatch(fmt.Sprintf("%v.any.%v", field, i), nested, addError)
+	}
+	for i, nested := range m.None {
+		checkMatch(fmt.Sprintf("%v.none.%v", field, i), nested, addError)
+	}
+}
+
+// conflicts returns the names of the headers that are removed as well as set or added, in the order of removal.
+func (h *HeaderOperations) conflicts() []string {
+	changed := make(map[string]bool, len(h.Set)+len(h.Add))
+	for name := range h.Set {
+		changed[strings.ToLower(name)] = true
+	}
+	for name := range h.Add {
+		changed[strings.ToLower(name)] = true
+	}
+
+	var names []string
+	for _, name := range h.Remove {
+		if changed[strings.ToLower(name)] {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// weightTolerance is the tolerated error in the sum of backend weights.
+const weightTolerance = 1e-9
+
+// backendKey identifies a backend by its service name and its set of tags.
+func backendKey(destination string, backend Backend) string {
+	name := backend.Name
+	if name == "" {
+		name = destination
+	}
+
+	tags := make([]string, len(backend.Tags))
+	copy(tags, backend.Tags)
+	sort.Strings(tags)
+
+	return name + ":" + strings.Join(tags, ",")
+}
+
+// validateRules validates each of the rules with the validator, then checks that the rules do not conflict with each
+// other or with the existing rules of the namespace. The existing rules must not include rules that are replaced by
+// the rules. Returned warnings describe rules that are valid but are likely mistakes.
+func validateRules(v Validator, existing, rules []Rule) ([]RuleError, error) {
+	var errs []RuleError
+	for i, rule := range rules {
+		err := v.Validate(rule)
+		if err == nil {
+			continue
+		}
+
+		invalidErr, ok := err.(*InvalidRuleError)
+		if !ok {
+			return nil, err
+		}
+
+		for _, e := range invalidErr.Errors {
+			e.Index = i
+			errs = append(errs, e)
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, &InvalidRuleError{Errors: errs}
+	}
+
+	warnings, errs := checkRuleSet(existing, rules)
+	if len(errs) > 0 {
+		logrus.WithFields(logrus.Fields{
+			"errors": errs,
+		}).Warn("Conflicting rules")
+
+		return nil, &InvalidRuleError{Errors: errs}
+	}
+
+	if len(warnings) > 0 {
+		logrus.WithFields(logrus.Fields{
+			"warnings": warnings,
+		}).Warn("Suspicious rules")
+	}
+
+	return warnings, nil
+}
+
+// checkRuleSet checks the rules against each other and against the existing rules. Rules conflict when they have
+// the same destination, type, priority and match conditions, since the proxy cannot tell which of them to apply.
+// Rules are unreachable when a rule of higher priority with the same destination and type has no match conditions.
+func checkRuleSet(existing, rules []Rule) ([]RuleError, []RuleError) {
+	type checkedRule struct {
+		Rule
+		index    int // Index in the provided rules, or -1 for existing rules
+		ruleType int
+		match    interface{}
+	}
+
+	all := make([]checkedRule, 0, len(existing)+len(rules))
+	for i, rule := range existing {
+		all = append(all, checkedRule{Rule: rule, index: -1 - i})
+	}
+	for i, rule := range rules {
+		all = append(all, checkedRule{Rule: rule, index: i})
+	}
+
+	for i := range all {
+		all[i].ruleType = ruleType(all[i].Rule)
+		all[i].match = decodeMatch(all[i].Rule)
+	}
+
+	var warnings, errs []RuleError
+	for i := range all {
+		a := all[i]
+		if a.ruleType == RuleAny {
+			continue
+		}
+
+		for j := range all {
+			b := all[j]
+			if i == j || a.Destination != b.Destination || a.ruleType != b.ruleType {
+				continue
+			}
+
+			// Report conflicts between provided rules once, on the later rule
+			if a.index >= 0 && a.Priority == b.Priority && (b.index < 0 || b.index < a.index) && reflect.DeepEqual(a.match, b.match) {
+				errs = append(errs, RuleError{
+					Index:       a.index,
+					ID:          a.ID,
+					Field:       "match",
+					Description: fmt.Sprintf("Conflicts with %v, which has the same destination, priority and match", describeRule(b.Rule, b.index)),
+				})
+			}
+
+			if b.Priority <= a.Priority || b.match != nil {
+				continue
+			}
+
+			// Report unreachable rules on the provided rule, whether it is the unreachable or the shadowing rule
+			if a.index >= 0 {
+				warnings = append(warnings, RuleError{
+					Index:       a.index,
+					ID:          a.ID,
+					Field:       "priority",
+					Description: fmt.Sprintf("Unreachable, as %v has a higher priority and matches all requests", describeRule(b.Rule, b.index)),
+				})
+			} else if b.index >= 0 {
+				warnings = append(warnings, RuleError{
+					Index:       b.index,
+					ID:          b.ID,
+					Field:       "match",
+					Description: fmt.Sprintf("Makes %v unreachable, as it has a higher priority and matches all requests", describeRule(a.Rule, a.index)),
+				})
+			}
+		}
+	}
+
+	return warnings, errs
+}
+
+// ruleType returns whether the rule is a route or an action rule.
+func ruleType(rule Rule) int {
+	switch {
+	case len(rule.Route) > 0:
+		return RuleRoute
+	case len(rule.Actions) > 0:
+		return RuleAction
+	default:
+		return RuleAny
+	}
+}
+
+// decodeMatch decodes the match conditions of the rule, so that they are compared by value rather than by their JSON
+// representation. Returns nil for rules without match conditions.
+func decodeMatch(rule Rule) interface{} {
+	if len(rule.Match) == 0 {
+		return nil
+	}
+
+	var match interface{}
+	if err := json.Unmarshal(rule.Match, &match); err != nil {
+		return string(rule.Match)
+	}
+	return match
+}
+
+// conflicting returns whether the rules have the same destination, type, priority and match conditions.
+func conflicting(a, b Rule) bool {
+	return a.Destination == b.Destination && ruleType(a) == ruleType(b) && ruleType(a) != RuleAny &&
+		a.Priority == b.Priority && reflect.DeepEqual(decodeMatch(a), decodeMatch(b))
+}
+
+// describeRule refers to an existing rule by its ID, or to a provided rule by its index.
+func describeRule(rule Rule, index int) string {
+	if index < 0 {
+		return fmt.Sprintf("rule %v", rule.ID)
+	}
+	return fmt.Sprintf("rule %v", index)
+}
+
+// excludeRules returns the rules whose IDs are not among the IDs of the excluded rules.
+func excludeRules(rules, excluded []Rule) []Rule {
+	ids := make(map[string]bool, len(excluded))
+	for _, rule := range excluded {
+		ids[rule.ID] = true
+	}
+
+	res := make([]Rule, 0, len(rules))
+	for _, rule := range rules {
+		if !ids[rule.ID] {
+			res = append(res, rule)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,311 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package rules
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckRule(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	cases := []struct {
+		Name   string
+		Rule   Rule
+		Fields []string
+	}{
+		{
+			Name: "valid route",
+			Rule: Rule{
+				Destination: "reviews",
+				Tags:        []string{"a", "b"},
+				Route:       []byte(`{"backends":[{"tags":["v1"],"weight":0.1},{"tags":["v2"],"weight":0.2},{"tags":["v3"],"weight":0.7}]}`),
+			},
+		},
+		{
+			Name: "duplicate rule tags",
+			Rule: Rule{
+				Destination: "reviews",
+				Tags:        []string{"a", "b", "a"},
+				Actions:     []byte(`[{"action":"trace"}]`),
+			},
+			Fields: []string{"tags.2"},
+		},
+		{
+			Name: "weights greater than 1",
+			Rule: Rule{
+				Destination: "reviews",
+				Route:       []byte(`{"backends":[{"tags":["v1"],"weight":0.6},{"tags":["v2"],"weight":0.5}]}`),
+			},
+			Fields: []string{"route.backends"},
+		},
+		{
+			Name: "unweighted backend without remaining weight",
+			Rule: Rule{
+				Destination: "reviews",
+				Route:       []byte(`{"backends":[{"tags":["v1"],"weight":1},{"tags":["v2"]}]}`),
+			},
+			Fields: []string{"route.backends"},
+		},
+		{
+			Name: "duplicate backends",
+			Rule: Rule{
+				Destination: "reviews",
+				Route:       []byte(`{"backends":[{"tags":["v1","eu"]},{"name":"reviews","tags":["eu","v1"]},{"name":"ratings","tags":["v1","eu"]}]}`),
+			},
+			Fields: []string{"route.backends.1"},
+		},
+		{
+			Name: "valid rewrite and headers",
+			Rule: Rule{
+				Destination: "reviews",
+				Route:       []byte(`{"backends":[{"tags":["v2"],"rewrite":{"prefix":"/v2"},"headers":{"set":{"X-Version":"v2"},"remove":["Cookie"]}}]}`),
+			},
+		},
+		{
+			Name: "header changed and removed",
+			Rule: Rule{
+				Destination: "reviews",
+				Route:       []byte(`{"backends":[{"tags":["v1"]},{"tags":["v2"],"headers":{"add":{"x-version":"v2"},"remove":["Cookie","X-Version"]}}]}`),
+			},
+			Fields: []string{"route.backends.1.headers"},
+		},
+		{
+			Name: "valid match conditions",
+			Rule: Rule{
+				Destination: "reviews",
+				Match:       []byte(`{"path":{"prefix":"/api"},"any":[{"method":["GET","HEAD"]},{"query":{"debug":"^(true|1)$"}}],"none":[{"cookies":{"user":"^test"}}]}`),
+				Route:       []byte(`{"backends":[{"tags":["v2"]}]}`),
+			},
+		},
+		{
+			Name: "invalid match patterns",
+			Rule: Rule{
+				Destination: "reviews",
+				Match:       []byte(`{"headers":{"Cookie":"user=(jason"},"all":[{"path":{"regex":"^/v[0-9+$"}},{"query":{"a":"ok","b":"*"}}]}`),
+				Route:       []byte(`{"backends":[{"tags":["v2"]}]}`),
+			},
+			Fields: []string{"match.headers.Cookie", "match.all.0.path.regex", "match.all.1.query.b"},
+		},
+		{
+			Name: "valid circuit breaker",
+			Rule: Rule{
+				Destination: "reviews",
+				Route:       []byte(`{"backends":[{"tags":["v1"],"circuit_breaker":{"max_connections":10,"max_pending_requests":5,"consecutive_5xx":3,"ejection_duration":30}}]}`),
+			},
+		},
+		{
+			Name: "incomplete circuit breakers",
+			Rule: Rule{
+				Destination: "reviews",
+				Route:       []byte(`{"backends":[{"tags":["v1"],"weight":0.5,"circuit_breaker":{"max_pending_requests":5}},{"tags":["v2"],"circuit_breaker":{"consecutive_5xx":3}}]}`),
+			},
+			Fields: []string{"route.backends.0.circuit_breaker", "route.backends.1.circuit_breaker"},
+		},
+		{
+			Name: "valid activation window",
+			Rule: Rule{
+				Destination: "reviews",
+				Actions:     []byte(`[{"action":"abort","return_code":503}]`),
+				NotBefore:   &past,
+				NotAfter:    &future,
+			},
+		},
+		{
+			Name: "expires before activation",
+			Rule: Rule{
+				Destination: "reviews",
+				Actions:     []byte(`[{"action":"abort","return_code":503}]`),
+				NotBefore:   &future,
+				NotAfter:    &past,
+			},
+			Fields: []string{"not_after"},
+		},
+		{
+			Name: "already expired",
+			Rule: Rule{
+				Destination: "reviews",
+				Actions:     []byte(`[{"action":"abort","return_code":503}]`),
+				NotAfter:    &past,
+			},
+			Fields: []string{"not_after"},
+		},
+		{
+			Name: "valid mirror",
+			Rule: Rule{
+				Destination: "reviews",
+				Actions:     []byte(`[{"action":"mirror","tags":["v1"],"probability":0.5,"backend":{"tags":["v2"]}}]`),
+			},
+		},
+		{
+			Name: "mirror to the same backend",
+			Rule: Rule{
+				Destination: "reviews",
+				Actions:     []byte(`[{"action":"trace"},{"action":"mirror","tags":["v1"],"backend":{"name":"reviews","tags":["v1"]}}]`),
+			},
+			Fields: []string{"actions.1.backend"},
+		},
+		{
+			Name: "rate limit by header",
+			Rule: Rule{
+				Destination: "reviews",
+				Actions:     []byte(`[{"action":"rate_limit","rate":10,"burst":5,"key":"header","header":"X-User"}]`),
+			},
+		},
+		{
+			Name: "rate limit by header without a header",
+			Rule: Rule{
+				Destination: "reviews",
+				Actions:     []byte(`[{"action":"rate_limit","rate":10,"key":"header"}]`),
+			},
+			Fields: []string{"actions.0.header"},
+		},
+		{
+			Name: "rate limit by source with a header",
+			Rule: Rule{
+				Destination: "reviews",
+				Actions:     []byte(`[{"action":"rate_limit","rate":10,"key":"source","header":"X-User"}]`),
+			},
+			Fields: []string{"actions.0.header"},
+		},
+	}
+
+	for _, c := range cases {
+		errs := checkRule(c.Rule)
+		if len(errs) != len(c.Fields) {
+			t.Errorf("%v: expected %v errors, got %v", c.Name, len(c.Fields), errs)
+			continue
+		}
+
+		for i, e := range errs {
+			if e.Field != c.Fields[i] {
+				t.Errorf("%v: expected error in field %v, got %v", c.Name, c.Fields[i], e)
+			}
+		}
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	existing := []Rule{
+		{
+			ID:          "default",
+			Destination: "reviews",
+			Route:       []byte(`{"backends":[{"tags":["v1"]}]}`),
+		},
+		{
+			ID:          "jason",
+			Priority:    5,
+			Destination: "reviews",
+			Match:       []byte(`{"headers":{"Cookie":"user=jason"}}`),
+			Route:       []byte(`{"backends":[{"tags":["v2"]}]}`),
+		},
+	}
+
+	cases := []struct {
+		Name     string
+		Rules    []Rule
+		Errors   []int
+		Warnings []int
+	}{
+		{
+			Name: "no conflicts",
+			Rules: []Rule{
+				{
+					Priority:    5,
+					Destination: "reviews",
+					Match:       []byte(`{"headers":{"Cookie":"user=shriram"}}`),
+					Route:       []byte(`{"backends":[{"tags":["v3"]}]}`),
+				},
+				{
+					Destination: "reviews",
+					Actions:     []byte(`[{"action":"trace"}]`),
+				},
+			},
+		},
+		{
+			Name: "conflict with existing rule",
+			Rules: []Rule{
+				{
+					Priority:    5,
+					Destination: "reviews",
+					Match:       []byte(`{ "headers" : { "Cookie" : "user=jason" } }`),
+					Route:       []byte(`{"backends":[{"tags":["v3"]}]}`),
+				},
+			},
+			Errors: []int{0},
+		},
+		{
+			Name: "conflict between provided rules",
+			Rules: []Rule{
+				{
+					Destination: "ratings",
+					Route:       []byte(`{"backends":[{"tags":["v1"]}]}`),
+				},
+				{
+					Destination: "ratings",
+					Route:       []byte(`{"backends":[{"tags":["v2"]}]}`),
+				},
+			},
+			Errors: []int{1},
+		},
+		{
+			Name: "shadowed by rule matching all requests",
+			Rules: []Rule{
+				{
+					Priority:    10,
+					Destination: "reviews",
+					Route:       []byte(`{"backends":[{"tags":["v3"]}]}`),
+				},
+			},
+			Warnings: []int{0, 0},
+		},
+	}
+
+	for _, c := range cases {
+		warnings, err := validateRules(&MockValidator{}, existing, c.Rules)
+
+		var errs []RuleError
+		if err != nil {
+			invalidErr, ok := err.(*InvalidRuleError)
+			if !ok {
+				t.Errorf("%v: unexpected error: %v", c.Name, err)
+				continue
+			}
+			errs = invalidErr.Errors
+		}
+
+		if len(errs) != len(c.Errors) {
+			t.Errorf("%v: expected %v errors, got %v", c.Name, len(c.Errors), errs)
+		} else {
+			for i, e := range errs {
+				if e.Index != c.Errors[i] {
+					t.Errorf("%v: expected error for rule %v, got %v", c.Name, c.Errors[i], e)
+				}
+			}
+		}
+
+		if len(warnings) != len(c.Warnings) {
+			t.Errorf("%v: expected %v warnings, got %v", c.Name, len(c.Warnings), warnings)
+		} else {
+			for i, w := range warnings {
+				if w.Index != c.Warnings[i] {
+					t.Errorf("%v: expected warning for rule %v, got %v", c.Name, c.Warnings[i], w)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/amalgam8/amalgam8/controller/rules"
+	"github.com/amalgam8/amalgam8/controller/util/encryption"
+)
+
+// fileExtension is the extension of the files in which documents are stored.
+const fileExtension = ".json"
+
+// NewFileStore creates a store that persists the document of each namespace to a file in the directory, along with
+// its revision. Files are encrypted unless the encryption is nil.
+func NewFileStore(dir string, enc encryption.Encryption) (Store, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	return &fileStore{
+		dir:        dir,
+		encryption: enc,
+		deleted:    make(map[string]int64),
+	}, nil
+}
+
+// fileStore checks and changes the revisions of documents under a lock, as the directory is only used by one
+// controller. The revisions of deleted documents are kept in memory, so that they are not reused by the controller.
+type fileStore struct {
+	dir        string
+	encryption encryption.Encryption
+	deleted    map[string]int64
+	mutex      sync.Mutex
+}
+
+func (f *fileStore) Namespaces() ([]string, error) {
+	filenames, err := filepath.Glob(filepath.Join(f.dir, "*"+fileExtension))
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces := make([]string, len(filenames))
+	for i, filename := range filenames {
+		namespaces[i], err = url.QueryUnescape(strings.TrimSuffix(filepath.Base(filename), fileExtension))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return namespaces, nil
+}
+
+func (f *fileStore) Read(namespace string, v interface{}) (int64, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	doc, err := f.read(namespace)
+	if err != nil || doc.Data == nil {
+		return doc.Revision, err
+	}
+
+	return doc.Revision, unmarshal(doc.Data, v)
+}
+
+// Write atomically replaces the file of the namespace.
+func (f *fileStore) Write(namespace string, revision int64, v interface{}) error {
+	data, err := marshal(v)
+	if err != nil {
+		return err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	doc, err := f.read(namespace)
+	if err != nil {
+		return err
+	}
+
+	if err := checkRevision(revision, doc.Revision); err != nil {
+		return err
+	}
+
+	data, err = marshal(&document{
+		Revision: doc.Revision + 1,
+		Data:     data,
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err = f.encrypt(data)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file and rename it over the existing file so that the file is never partially written
+	tmp, err := ioutil.TempFile(f.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), f.path(namespace))
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"namespace": namespace,
+			"dir":       f.dir,
+		}).Error("Could not write file")
+		return err
+	}
+
+	delete(f.deleted, namespace)
+
+	return nil
+}
+
+func (f *fileStore) Delete(namespace string, revision int64) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	doc, err := f.read(namespace)
+	if err != nil {
+		return err
+	}
+
+	if err := checkRevision(revision, doc.Revision); err != nil {
+		return err
+	}
+
+	if err := os.Remove(f.path(namespace)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	f.deleted[namespace] = doc.Revision + 1
+
+	return nil
+}
+
+// read returns the document of the namespace, which has no data if the namespace has no file.
+func (f *fileStore) read(namespace string) (document, error) {
+	data, err := ioutil.ReadFile(f.path(namespace))
+	if os.IsNotExist(err) {
+		return document{Revision: f.deleted[namespace]}, nil
+	} else if err != nil {
+		return document{}, err
+	}
+
+	data, err = f.decrypt(data)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"namespace": namespace,
+			"dir":       f.dir,
+		}).Error("Could not decrypt file")
+		return document{}, err
+	}
+
+	doc := document{}
+	if err := unmarshal(data, &doc); err != nil {
+		return document{}, err
+	}
+
+	return doc, nil
+}
+
+func (f *fileStore) path(namespace string) string {
+	return filepath.Join(f.dir, url.QueryEscape(namespace)+fileExtension)
+}
+
+func (f *fileStore) encrypt(data []byte) ([]byte, error) {
+	// Short-circuit without encryption
+	if f.encryption == nil {
+		return data, nil
+	}
+
+	iv := f.encryption.NewIV()
+	payload, err := f.encryption.Encrypt(iv, data)
+	if err != nil {
+		return nil, err
+	}
+
+	e := rules.Entry{
+		IV:      base64.StdEncoding.EncodeToString(iv),
+		Payload: base64.StdEncoding.EncodeToString(payload),
+	}
+
+	return json.Marshal(&e)
+}
+
+func (f *fileStore) decrypt(data []byte) ([]byte, error) {
+	// Short-circuit without encryption
+	if f.encryption == nil {
+		return data, nil
+	}
+
+	e := rules.Entry{}
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+
+	iv, err := base64.StdEncoding.DecodeString(e.IV)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(e.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.encryption.Decrypt(iv, payload)
+}
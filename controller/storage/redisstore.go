@@ -0,0 +1,162 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+
+	"github.com/amalgam8/amalgam8/controller/rules"
+	"github.com/garyburd/redigo/redis"
+)
+
+// NewRedisPool creates a pool of Redis connections, which can be shared by the stores of a controller.
+func NewRedisPool(address, password string) *redis.Pool {
+	return redis.NewPool(func() (redis.Conn, error) {
+		conn, err := redis.DialURL(
+			address,
+			redis.DialPassword(password),
+		)
+		if err != nil {
+			if conn != nil {
+				conn.Close()
+			}
+			return nil, err
+		}
+		return conn, nil
+	}, 10)
+}
+
+// NewRedisStore creates a store that persists the document of each namespace to Redis, under keys of the given name,
+// such as "rollouts".
+func NewRedisStore(pool *redis.Pool, name string) Store {
+	return &redisStore{
+		pool: pool,
+		name: name,
+	}
+}
+
+type redisStore struct {
+	pool *redis.Pool
+	name string
+}
+
+func (r *redisStore) Namespaces() ([]string, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	return redis.Strings(conn.Do("SMEMBERS", r.namespacesKey()))
+}
+
+func (r *redisStore) Read(namespace string, v interface{}) (int64, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	values, err := redis.Values(conn.Do("MGET", r.documentKey(namespace), r.revisionKey(namespace)))
+	if err != nil {
+		return 0, err
+	}
+
+	var data []byte
+	var revision int64
+	if _, err := redis.Scan(values, &data, &revision); err != nil {
+		return 0, err
+	}
+
+	if data == nil {
+		return revision, nil
+	}
+
+	return revision, unmarshal(data, v)
+}
+
+func (r *redisStore) Write(namespace string, revision int64, v interface{}) error {
+	data, err := marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return r.update(namespace, revision, func(conn redis.Conn) {
+		conn.Send("SET", r.documentKey(namespace), data)
+		conn.Send("SADD", r.namespacesKey(), namespace)
+	})
+}
+
+func (r *redisStore) Delete(namespace string, revision int64) error {
+	return r.update(namespace, revision, func(conn redis.Conn) {
+		conn.Send("DEL", r.documentKey(namespace))
+		conn.Send("SREM", r.namespacesKey(), namespace)
+	})
+}
+
+// update sends the commands changing the document in a transaction that moves the namespace to the next revision.
+// Unless the revision is rules.AnyRevision, the transaction watches the revision of the namespace, so that it fails
+// if another change of the document is made since the revision is checked.
+func (r *redisStore) update(namespace string, revision int64, send func(redis.Conn)) error {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	if revision != rules.AnyRevision {
+		if _, err := conn.Do("WATCH", r.revisionKey(namespace)); err != nil {
+			return err
+		}
+
+		if err := r.checkRevision(conn, namespace, revision); err != nil {
+			conn.Do("UNWATCH")
+			return err
+		}
+	}
+
+	conn.Send("MULTI")
+	send(conn)
+	conn.Send("INCR", r.revisionKey(namespace))
+
+	// Nil return indicates that the transaction failed
+	if _, err := redis.Values(conn.Do("EXEC")); err != nil {
+		if err == redis.ErrNil {
+			actual, err := redis.Int64(conn.Do("GET", r.revisionKey(namespace)))
+			if err != nil && err != redis.ErrNil {
+				return err
+			}
+			return &rules.RevisionMismatchError{Expected: revision, Actual: actual}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// checkRevision returns an error unless the namespace is at the revision.
+func (r *redisStore) checkRevision(conn redis.Conn, namespace string, revision int64) error {
+	actual, err := redis.Int64(conn.Do("GET", r.revisionKey(namespace)))
+	if err != nil && err != redis.ErrNil {
+		return err
+	}
+
+	return checkRevision(revision, actual)
+}
+
+// namespacesKey is the Redis set of the namespaces that have a document.
+func (r *redisStore) namespacesKey() string {
+	return fmt.Sprintf("controller:%v", r.name)
+}
+
+func (r *redisStore) documentKey(namespace string) string {
+	return fmt.Sprintf("controller:%v:%v", namespace, r.name)
+}
+
+// revisionKey is kept when the document is deleted, so that the revisions of the namespace are never reused.
+func (r *redisStore) revisionKey(namespace string) string {
+	return fmt.Sprintf("controller:%v:%v:revision", namespace, r.name)
+}
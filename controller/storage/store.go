@@ -0,0 +1,144 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+// Package storage persists a JSON document for each namespace, such as the rollouts or templates of the namespace.
+package storage
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/amalgam8/amalgam8/controller/rules"
+)
+
+// Store persists a JSON document for each namespace. Each change of the document of a namespace moves it to a new
+// revision, so that concurrent changes of the document, by one or more controllers, can be detected.
+type Store interface {
+	// Namespaces returns the namespaces that have a document.
+	Namespaces() ([]string, error)
+
+	// Read decodes the document of the namespace into the value, which is left unchanged when the namespace has no
+	// document, and returns the revision of the document.
+	Read(namespace string, v interface{}) (int64, error)
+
+	// Write replaces the document of the namespace with the JSON encoding of the value. Unless the revision is
+	// rules.AnyRevision, the document is only replaced if it is still at the revision, and a
+	// rules.RevisionMismatchError is returned otherwise.
+	Write(namespace string, revision int64, v interface{}) error
+
+	// Delete removes the document of the namespace, on the same condition as Write.
+	Delete(namespace string, revision int64) error
+}
+
+// NewMemoryStore creates a store that keeps documents in memory.
+func NewMemoryStore() Store {
+	return &memoryStore{
+		documents: make(map[string]document),
+	}
+}
+
+// document is the JSON encoding of the document of a namespace at a revision. Deleted documents have no data, and
+// are kept so that the revisions of a namespace are never reused.
+type document struct {
+	Revision int64           `json:"revision"`
+	Data     json.RawMessage `json:"document"`
+}
+
+// memoryStore keeps the JSON encoding of each document, so that values read from the store never share memory with
+// the values written to it.
+type memoryStore struct {
+	documents map[string]document
+	mutex     sync.RWMutex
+}
+
+func (m *memoryStore) Namespaces() ([]string, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	namespaces := make([]string, 0, len(m.documents))
+	for namespace, doc := range m.documents {
+		if doc.Data != nil {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+
+	return namespaces, nil
+}
+
+func (m *memoryStore) Read(namespace string, v interface{}) (int64, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	doc := m.documents[namespace]
+	if doc.Data == nil {
+		return doc.Revision, nil
+	}
+
+	return doc.Revision, unmarshal(doc.Data, v)
+}
+
+func (m *memoryStore) Write(namespace string, revision int64, v interface{}) error {
+	data, err := marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return m.update(namespace, revision, data)
+}
+
+func (m *memoryStore) Delete(namespace string, revision int64) error {
+	return m.update(namespace, revision, nil)
+}
+
+// update replaces the data of the document if it is at the revision, moving it to the next revision.
+func (m *memoryStore) update(namespace string, revision int64, data []byte) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	doc := m.documents[namespace]
+	if err := checkRevision(revision, doc.Revision); err != nil {
+		return err
+	}
+
+	m.documents[namespace] = document{
+		Revision: doc.Revision + 1,
+		Data:     data,
+	}
+
+	return nil
+}
+
+// checkRevision returns an error unless a change on the condition of the revision applies to a document at the
+// actual revision.
+func checkRevision(revision, actual int64) error {
+	if revision != rules.AnyRevision && revision != actual {
+		return &rules.RevisionMismatchError{Expected: revision, Actual: actual}
+	}
+	return nil
+}
+
+func marshal(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, &rules.JSONMarshalError{Message: err.Error()}
+	}
+	return data, nil
+}
+
+func unmarshal(data []byte, v interface{}) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return &rules.JSONMarshalError{Message: err.Error()}
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/amalgam8/amalgam8/controller/rules"
+	"github.com/amalgam8/amalgam8/controller/util/encryption"
+)
+
+type testDocument struct {
+	Names []string `json:"names"`
+}
+
+func TestStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	enc, err := encryption.NewAES([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fileStore, err := NewFileStore(dir, enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"file":   fileStore,
+	}
+
+	for name, store := range stores {
+		written := testDocument{Names: []string{"reviews", "ratings"}}
+		if err := store.Write("test/namespace", 0, &written); err != nil {
+			t.Fatalf("%v: Write: %v", name, err)
+		}
+
+		// Changes to the written value are not seen by readers
+		written.Names[0] = "details"
+
+		read := testDocument{}
+		revision, err := store.Read("test/namespace", &read)
+		if err != nil {
+			t.Fatalf("%v: Read: %v", name, err)
+		}
+		if revision != 1 || !reflect.DeepEqual(read.Names, []string{"reviews", "ratings"}) {
+			t.Errorf("%v: Read: expected [reviews ratings] at revision 1, got %v at revision %v", name, read.Names,
+				revision)
+		}
+
+		// Changes on the condition of a past revision fail
+		err = store.Write("test/namespace", 0, &written)
+		if _, ok := err.(*rules.RevisionMismatchError); !ok {
+			t.Errorf("%v: Write at a past revision: expected a revision mismatch, got %v", name, err)
+		}
+		err = store.Delete("test/namespace", 0)
+		if _, ok := err.(*rules.RevisionMismatchError); !ok {
+			t.Errorf("%v: Delete at a past revision: expected a revision mismatch, got %v", name, err)
+		}
+
+		namespaces, err := store.Namespaces()
+		if err != nil || !reflect.DeepEqual(namespaces, []string{"test/namespace"}) {
+			t.Errorf("%v: Namespaces: expected [test/namespace], got %v, %v", name, namespaces, err)
+		}
+
+		if err := store.Delete("test/namespace", revision); err != nil {
+			t.Fatalf("%v: Delete: %v", name, err)
+		}
+
+		// Revisions are not reused after the document is deleted
+		read = testDocument{}
+		revision, err = store.Read("test/namespace", &read)
+		if err != nil || read.Names != nil || revision != 2 {
+			t.Errorf("%v: Read after Delete: expected no document at revision 2, got %v at revision %v, %v", name, read,
+				revision, err)
+		}
+
+		err = store.Write("test/namespace", 1, &written)
+		if _, ok := err.(*rules.RevisionMismatchError); !ok {
+			t.Errorf("%v: Write after Delete at a past revision: expected a revision mismatch, got %v", name, err)
+		}
+
+		if namespaces, err := store.Namespaces(); err != nil || len(namespaces) != 0 {
+			t.Errorf("%v: Namespaces after Delete: expected none, got %v, %v", name, namespaces, err)
+		}
+
+		// Changes apply whatever the revision is with AnyRevision
+		if err := store.Write("test/namespace", rules.AnyRevision, &written); err != nil {
+			t.Errorf("%v: Write at any revision: %v", name, err)
+		}
+	}
+}
+
+func TestFileStoreEncryption(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	enc, err := encryption.NewAES([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewFileStore(dir, enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Write("namespace", 0, &testDocument{Names: []string{"reviews"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "namespace"+fileExtension))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("reviews")) {
+		t.Errorf("expected the file to be encrypted, got %s", data)
+	}
+
+	// The file cannot be read without the key
+	plain, err := NewFileStore(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := testDocument{}
+	if _, err := plain.Read("namespace", &read); err == nil && len(read.Names) > 0 {
+		t.Errorf("expected no document without the key, got %v", read)
+	}
+}
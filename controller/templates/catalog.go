@@ -0,0 +1,51 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package templates
+
+import (
+	"github.com/amalgam8/amalgam8/registry/api"
+)
+
+// Catalog lists the services registered in the registry.
+type Catalog interface {
+	// Services returns the names of the registered services mapped to the tags of their instances.
+	Services() (map[string][]string, error)
+}
+
+// NewRegistryCatalog creates a catalog of the services discovered through the registry.
+func NewRegistryCatalog(discovery api.ServiceDiscovery) Catalog {
+	return &registryCatalog{
+		discovery: discovery,
+	}
+}
+
+type registryCatalog struct {
+	discovery api.ServiceDiscovery
+}
+
+func (r *registryCatalog) Services() (map[string][]string, error) {
+	instances, err := r.discovery.ListInstances()
+	if err != nil {
+		return nil, err
+	}
+
+	// A service has the tags of any of its instances, since instances of different versions are tagged differently
+	services := make(map[string][]string)
+	for _, instance := range instances {
+		services[instance.ServiceName] = append(services[instance.ServiceName], instance.Tags...)
+	}
+
+	return services, nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package templates
+
+import "fmt"
+
+// InvalidTemplateError occurs when a template is invalid.
+type InvalidTemplateError struct {
+	// Errors describe each of the problems found with the template.
+	Errors []FieldError
+}
+
+// Error description
+func (e *InvalidTemplateError) Error() string {
+	if len(e.Errors) == 0 {
+		return "Invalid Template Error"
+	}
+	return fmt.Sprintf("Invalid Template Error: %v", e.Errors[0])
+}
+
+// InvalidBindingError occurs when a binding is invalid.
+type InvalidBindingError struct {
+	// Errors describe each of the problems found with the binding.
+	Errors []FieldError
+}
+
+// Error description
+func (e *InvalidBindingError) Error() string {
+	if len(e.Errors) == 0 {
+		return "Invalid Binding Error"
+	}
+	return fmt.Sprintf("Invalid Binding Error: %v", e.Errors[0])
+}
+
+// FieldError describes a problem with a field of a template or binding.
+type FieldError struct {
+	// Field the problem was found in, such as "selector.tags".
+	Field string `json:"field"`
+
+	// Description of the problem.
+	Description string `json:"description"`
+}
+
+// String representation of the field error
+func (e FieldError) String() string {
+	return fmt.Sprintf("%v: %v", e.Field, e.Description)
+}
+
+// UnknownParameterError occurs when a template references a parameter that has no value.
+type UnknownParameterError struct {
+	Name string
+}
+
+// Error description
+func (e *UnknownParameterError) Error() string {
+	return fmt.Sprintf("Parameter %v is not set", e.Name)
+}
+
+// TemplateNotFoundError occurs when a template does not exist.
+type TemplateNotFoundError struct {
+	ID string
+}
+
+// Error description
+func (e *TemplateNotFoundError) Error() string {
+	return fmt.Sprintf("Template %v not found", e.ID)
+}
+
+// BindingNotFoundError occurs when a binding does not exist.
+type BindingNotFoundError struct {
+	ID string
+}
+
+// Error description
+func (e *BindingNotFoundError) Error() string {
+	return fmt.Sprintf("Binding %v not found", e.ID)
+}
+
+// ConflictError occurs when a change conflicts with the templates or bindings of the namespace.
+type ConflictError struct {
+	Message string
+}
+
+// Error description
+func (e *ConflictError) Error() string {
+	return e.Message
+}
@@ -0,0 +1,574 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package templates
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/amalgam8/amalgam8/controller/rules"
+	"github.com/amalgam8/amalgam8/controller/storage"
+	"github.com/pborman/uuid"
+)
+
+// defaultInterval is how often bindings are materialized again when no interval is configured.
+const defaultInterval = 30 * time.Second
+
+// Manager manages the templates and bindings of each namespace, and materializes the rules of bindings through the
+// rules manager. Each binding owns the rules tagged with its tag, which are replaced whenever the binding, its
+// template, or the destinations it selects change.
+type Manager interface {
+	// CreateTemplate validates and adds a template to the namespace.
+	CreateTemplate(namespace string, template Template) (Template, error)
+
+	// GetTemplate returns a template of the namespace.
+	GetTemplate(namespace, id string) (Template, error)
+
+	// ListTemplates returns the templates of the namespace.
+	ListTemplates(namespace string) ([]Template, error)
+
+	// UpdateTemplate replaces a template of the namespace, and materializes the bindings of the template again.
+	UpdateTemplate(namespace string, template Template) (Template, error)
+
+	// DeleteTemplate removes a template from the namespace. Templates cannot be removed while they are bound.
+	DeleteTemplate(namespace, id string) error
+
+	// CreateBinding validates a binding, materializes its rules, and adds it to the namespace.
+	CreateBinding(namespace string, binding Binding) (Binding, error)
+
+	// GetBinding returns a binding of the namespace.
+	GetBinding(namespace, id string) (Binding, error)
+
+	// ListBindings returns the bindings of the namespace.
+	ListBindings(namespace string) ([]Binding, error)
+
+	// UpdateBinding replaces a binding of the namespace, and materializes its rules again.
+	UpdateBinding(namespace string, binding Binding) (Binding, error)
+
+	// DeleteBinding removes a binding and its rules from the namespace.
+	DeleteBinding(namespace, id string) error
+
+	// Start materializes bindings again in the background, so that they apply to the destinations that their
+	// selectors select as services are registered, until the manager is stopped.
+	Start()
+
+	// Stop the manager.
+	Stop()
+}
+
+// Config for the template manager.
+type Config struct {
+	// Store in which the templates and bindings of each namespace are persisted.
+	Store storage.Store
+
+	// Rules manager through which the rules of bindings are set.
+	Rules rules.Manager
+
+	// Catalog of registered services, through which selectors select destinations by tags. If nil, destinations can
+	// only be selected by name.
+	Catalog Catalog
+
+	// Interval at which bindings are materialized again.
+	Interval time.Duration
+}
+
+// NewManager creates a template manager.
+func NewManager(conf Config) Manager {
+	interval := conf.Interval
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+
+	return &manager{
+		store:    conf.Store,
+		rules:    conf.Rules,
+		catalog:  conf.Catalog,
+		interval: interval,
+		stop:     make(chan struct{}),
+	}
+}
+
+type manager struct {
+	store    storage.Store
+	rules    rules.Manager
+	catalog  Catalog
+	interval time.Duration
+	stop     chan struct{}
+	mutex    sync.Mutex
+}
+
+func (m *manager) CreateTemplate(namespace string, template Template) (Template, error) {
+	if errs := template.validate(); len(errs) > 0 {
+		return Template{}, &InvalidTemplateError{Errors: errs}
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	collection, err := m.read(namespace)
+	if err != nil {
+		return Template{}, err
+	}
+
+	if template.ID == "" {
+		template.ID = uuid.New()
+	} else if collection.template(template.ID) >= 0 {
+		return Template{}, &ConflictError{
+			Message: fmt.Sprintf("Template %v already exists", template.ID),
+		}
+	}
+
+	collection.Templates = append(collection.Templates, template)
+	if err := m.write(namespace, collection); err != nil {
+		return Template{}, err
+	}
+
+	return template, nil
+}
+
+func (m *manager) GetTemplate(namespace, id string) (Template, error) {
+	collection, err := m.read(namespace)
+	if err != nil {
+		return Template{}, err
+	}
+
+	i := collection.template(id)
+	if i < 0 {
+		return Template{}, &TemplateNotFoundError{ID: id}
+	}
+
+	return collection.Templates[i], nil
+}
+
+func (m *manager) ListTemplates(namespace string) ([]Template, error) {
+	collection, err := m.read(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	return collection.Templates, nil
+}
+
+func (m *manager) UpdateTemplate(namespace string, template Template) (Template, error) {
+	if errs := template.validate(); len(errs) > 0 {
+		return Template{}, &InvalidTemplateError{Errors: errs}
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	collection, err := m.read(namespace)
+	if err != nil {
+		return Template{}, err
+	}
+
+	i := collection.template(template.ID)
+	if i < 0 {
+		return Template{}, &TemplateNotFoundError{ID: template.ID}
+	}
+
+	// Every binding of the template must still set all of its parameters
+	bound := []int{}
+	for j, binding := range collection.Bindings {
+		if binding.Template != template.ID {
+			continue
+		}
+
+		if errs := binding.validate(template, m.catalog != nil); len(errs) > 0 {
+			return Template{}, &InvalidTemplateError{Errors: errs}
+		}
+		bound = append(bound, j)
+	}
+
+	services, err := m.services(collection.Bindings...)
+	if err != nil {
+		return Template{}, err
+	}
+
+	// If materializing a binding fails, the bindings materialized before it keep the rules of the new template
+	// until the bindings are next materialized with the stored template
+	for _, j := range bound {
+		collection.Bindings[j], err = m.materialize(namespace, collection.Bindings[j], template, services)
+		if err != nil {
+			return Template{}, err
+		}
+	}
+
+	collection.Templates[i] = template
+	if err := m.write(namespace, collection); err != nil {
+		return Template{}, err
+	}
+
+	return template, nil
+}
+
+func (m *manager) DeleteTemplate(namespace, id string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	collection, err := m.read(namespace)
+	if err != nil {
+		return err
+	}
+
+	i := collection.template(id)
+	if i < 0 {
+		return &TemplateNotFoundError{ID: id}
+	}
+
+	for _, binding := range collection.Bindings {
+		if binding.Template == id {
+			return &ConflictError{
+				Message: fmt.Sprintf("Template %v is bound by binding %v", id, binding.ID),
+			}
+		}
+	}
+
+	collection.Templates = append(collection.Templates[:i], collection.Templates[i+1:]...)
+	return m.write(namespace, collection)
+}
+
+func (m *manager) CreateBinding(namespace string, binding Binding) (Binding, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	collection, err := m.read(namespace)
+	if err != nil {
+		return Binding{}, err
+	}
+
+	if binding.ID == "" {
+		binding.ID = uuid.New()
+	} else if collection.binding(binding.ID) >= 0 {
+		return Binding{}, &ConflictError{
+			Message: fmt.Sprintf("Binding %v already exists", binding.ID),
+		}
+	}
+
+	binding, err = m.bind(namespace, collection, binding)
+	if err != nil {
+		return Binding{}, err
+	}
+
+	collection.Bindings = append(collection.Bindings, binding)
+	if err := m.write(namespace, collection); err != nil {
+		return Binding{}, err
+	}
+
+	return binding, nil
+}
+
+func (m *manager) GetBinding(namespace, id string) (Binding, error) {
+	collection, err := m.read(namespace)
+	if err != nil {
+		return Binding{}, err
+	}
+
+	i := collection.binding(id)
+	if i < 0 {
+		return Binding{}, &BindingNotFoundError{ID: id}
+	}
+
+	return collection.Bindings[i], nil
+}
+
+func (m *manager) ListBindings(namespace string) ([]Binding, error) {
+	collection, err := m.read(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	return collection.Bindings, nil
+}
+
+func (m *manager) UpdateBinding(namespace string, binding Binding) (Binding, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	collection, err := m.read(namespace)
+	if err != nil {
+		return Binding{}, err
+	}
+
+	i := collection.binding(binding.ID)
+	if i < 0 {
+		return Binding{}, &BindingNotFoundError{ID: binding.ID}
+	}
+
+	binding, err = m.bind(namespace, collection, binding)
+	if err != nil {
+		return Binding{}, err
+	}
+
+	collection.Bindings[i] = binding
+	if err := m.write(namespace, collection); err != nil {
+		return Binding{}, err
+	}
+
+	return binding, nil
+}
+
+func (m *manager) DeleteBinding(namespace, id string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	collection, err := m.read(namespace)
+	if err != nil {
+		return err
+	}
+
+	i := collection.binding(id)
+	if i < 0 {
+		return &BindingNotFoundError{ID: id}
+	}
+
+	if err := m.rules.DeleteRules(namespace, collection.Bindings[i].filter(), rules.AnyRevision); err != nil {
+		return err
+	}
+
+	collection.Bindings = append(collection.Bindings[:i], collection.Bindings[i+1:]...)
+	return m.write(namespace, collection)
+}
+
+func (m *manager) Start() {
+	go func() {
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				m.refresh()
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (m *manager) Stop() {
+	close(m.stop)
+}
+
+// bind validates the binding against its template and materializes it.
+func (m *manager) bind(namespace string, collection Collection, binding Binding) (Binding, error) {
+	i := collection.template(binding.Template)
+	if i < 0 {
+		return Binding{}, &InvalidBindingError{
+			Errors: []FieldError{{
+				Field:       "template",
+				Description: fmt.Sprintf("Template %v not found", binding.Template),
+			}},
+		}
+	}
+	template := collection.Templates[i]
+
+	if errs := binding.validate(template, m.catalog != nil); len(errs) > 0 {
+		return Binding{}, &InvalidBindingError{Errors: errs}
+	}
+
+	services, err := m.services(binding)
+	if err != nil {
+		return Binding{}, err
+	}
+
+	return m.materialize(namespace, binding, template, services)
+}
+
+// refresh materializes the bindings of each namespace again, so that the destinations selected by their selectors
+// follow the services in the catalog.
+func (m *manager) refresh() {
+	namespaces, err := m.store.Namespaces()
+	if err != nil {
+		logrus.WithError(err).Error("Could not read the namespaces of templates")
+		return
+	}
+
+	for _, namespace := range namespaces {
+		if err := m.refreshNamespace(namespace); err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{
+				"namespace": namespace,
+			}).Error("Could not materialize bindings")
+		}
+	}
+}
+
+func (m *manager) refreshNamespace(namespace string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	collection, err := m.read(namespace)
+	if err != nil {
+		return err
+	}
+
+	services, err := m.services(collection.Bindings...)
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	for i, binding := range collection.Bindings {
+		j := collection.template(binding.Template)
+		if j < 0 {
+			continue
+		}
+
+		materialized, err := m.materialize(namespace, binding, collection.Templates[j], services)
+		if err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{
+				"namespace": namespace,
+				"id":        binding.ID,
+			}).Error("Could not materialize binding")
+			continue
+		}
+
+		if !reflect.DeepEqual(materialized.Destinations, binding.Destinations) {
+			collection.Bindings[i] = materialized
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return m.write(namespace, collection)
+}
+
+// read returns the templates and bindings of the namespace.
+func (m *manager) read(namespace string) (Collection, error) {
+	collection := Collection{}
+	revision, err := m.store.Read(namespace, &collection)
+	if err != nil {
+		return Collection{}, err
+	}
+
+	if collection.Templates == nil {
+		collection.Templates = []Template{}
+	}
+	if collection.Bindings == nil {
+		collection.Bindings = []Binding{}
+	}
+	collection.revision = revision
+
+	return collection, nil
+}
+
+// write replaces the templates and bindings of the namespace, removing the namespace from the store when it has
+// neither. The collection is only written if it was not changed by another controller since it was read.
+func (m *manager) write(namespace string, collection Collection) error {
+	var err error
+	if len(collection.Templates) == 0 && len(collection.Bindings) == 0 {
+		err = m.store.Delete(namespace, collection.revision)
+	} else {
+		err = m.store.Write(namespace, collection.revision, &collection)
+	}
+
+	if _, ok := err.(*rules.RevisionMismatchError); ok {
+		return &ConflictError{
+			Message: fmt.Sprintf("Templates of namespace %v were changed concurrently", namespace),
+		}
+	}
+	return err
+}
+
+// services returns the services in the catalog if any of the bindings select destinations by tags, and nil
+// otherwise.
+func (m *manager) services(bindings ...Binding) (map[string][]string, error) {
+	for _, binding := range bindings {
+		if len(binding.Selector.Tags) > 0 && m.catalog != nil {
+			return m.catalog.Services()
+		}
+	}
+
+	return nil, nil
+}
+
+// materialize replaces the rules of the binding with the rules of the template for the destinations it selects,
+// unless the rules are unchanged. Returns the binding with the destinations it was materialized for.
+func (m *manager) materialize(namespace string, binding Binding, template Template,
+	services map[string][]string) (Binding, error) {
+	destinations := binding.Selector.selects(services)
+
+	rs, err := binding.rules(template, destinations)
+	if err != nil {
+		return Binding{}, err
+	}
+
+	existing, err := m.rules.GetRules(namespace, binding.filter())
+	if err != nil {
+		return Binding{}, err
+	}
+
+	same, err := sameRules(existing.Rules, rs)
+	if err != nil {
+		return Binding{}, err
+	}
+
+	if !same {
+		if _, err := m.rules.SetRules(namespace, binding.filter(), rs, rules.AnyRevision); err != nil {
+			return Binding{}, err
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"namespace":    namespace,
+			"id":           binding.ID,
+			"template":     template.ID,
+			"destinations": destinations,
+		}).Info("Binding materialized")
+	}
+
+	binding.Destinations = destinations
+	return binding, nil
+}
+
+// sameRules returns whether the rules are the same regardless of their IDs and order.
+func sameRules(a, b []rules.Rule) (bool, error) {
+	if len(a) != len(b) {
+		return false, nil
+	}
+
+	encode := func(rs []rules.Rule) ([]string, error) {
+		encoded := make([]string, len(rs))
+		for i, rule := range rs {
+			rule.ID = ""
+			data, err := json.Marshal(&rule)
+			if err != nil {
+				return nil, &rules.JSONMarshalError{Message: err.Error()}
+			}
+			encoded[i] = string(data)
+		}
+		sort.Strings(encoded)
+		return encoded, nil
+	}
+
+	encodedA, err := encode(a)
+	if err != nil {
+		return false, err
+	}
+
+	encodedB, err := encode(b)
+	if err != nil {
+		return false, err
+	}
+
+	return reflect.DeepEqual(encodedA, encodedB), nil
+}
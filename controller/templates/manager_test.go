@@ -0,0 +1,256 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package templates
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+
+	"github.com/amalgam8/amalgam8/controller/rules"
+	"github.com/amalgam8/amalgam8/controller/storage"
+	"github.com/amalgam8/amalgam8/controller/util/encryption"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type MockValidator struct{}
+
+func (m *MockValidator) Validate(r rules.Rule) error {
+	return nil
+}
+
+type MockCatalog struct {
+	services map[string][]string
+	err      error
+}
+
+func (m *MockCatalog) Services() (map[string][]string, error) {
+	return m.services, m.err
+}
+
+var _ = Describe("Template", func() {
+
+	It("renders parameters in strings", func() {
+		body, err := render(json.RawMessage(`{"backends":[{"tags":["${version}"],"weight":"${weight}",`+
+			`"rewrite":{"prefix":"/${destination}/v${major}"}}]}`),
+			map[string]interface{}{
+				"version":     "v2",
+				"weight":      0.25,
+				"destination": "reviews",
+				"major":       2.0,
+			})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(body).To(MatchJSON(`{"backends":[{"tags":["v2"],"weight":0.25,` +
+			`"rewrite":{"prefix":"/reviews/v2"}}]}`))
+	})
+
+	It("fails to render unknown parameters", func() {
+		_, err := render(json.RawMessage(`{"header":"x-${name}"}`), map[string]interface{}{})
+		Expect(err).To(Equal(&UnknownParameterError{Name: "name"}))
+	})
+
+	It("selects destinations by name and tags", func() {
+		selector := Selector{
+			Destinations: []string{"details"},
+			Tags:         []string{"tier=backend"},
+		}
+		Expect(selector.selects(map[string][]string{
+			"reviews": {"v1", "tier=backend"},
+			"ratings": {"tier=backend"},
+			"web":     {"tier=frontend"},
+		})).To(Equal([]string{"details", "ratings", "reviews"}))
+	})
+})
+
+var _ = Describe("Manager", func() {
+
+	Describe("with a memory store", func() {
+		testManager(func() storage.Store {
+			return storage.NewMemoryStore()
+		})
+	})
+
+	Describe("with a file store", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "templates")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		testManager(func() storage.Store {
+			enc, err := encryption.NewAES([]byte("0123456789abcdef0123456789abcdef"))
+			Expect(err).ToNot(HaveOccurred())
+
+			store, err := storage.NewFileStore(dir, enc)
+			Expect(err).ToNot(HaveOccurred())
+			return store
+		})
+	})
+})
+
+func testManager(newStore func() storage.Store) {
+	var (
+		ruleManager rules.Manager
+		catalog     *MockCatalog
+		m           *manager
+		template    Template
+		binding     Binding
+	)
+
+	// timeouts returns the timeout of the route of each destination that has a rule.
+	timeouts := func() map[string]float64 {
+		res, err := ruleManager.GetRules("namespace", rules.Filter{})
+		Expect(err).ToNot(HaveOccurred())
+
+		timeouts := make(map[string]float64)
+		for _, rule := range res.Rules {
+			route := rules.Route{}
+			Expect(json.Unmarshal(rule.Route, &route)).To(Succeed())
+			timeouts[rule.Destination] = route.Backends[0].Timeout
+		}
+		return timeouts
+	}
+
+	BeforeEach(func() {
+		ruleManager = rules.NewMemoryManager(&MockValidator{})
+		catalog = &MockCatalog{
+			services: map[string][]string{
+				"reviews": {"v1", "tier=backend"},
+				"ratings": {"tier=backend"},
+				"web":     {"tier=frontend"},
+			},
+		}
+		m = NewManager(Config{
+			Store:   newStore(),
+			Rules:   ruleManager,
+			Catalog: catalog,
+		}).(*manager)
+
+		var err error
+		template, err = m.CreateTemplate("namespace", Template{
+			ID:         "timeout",
+			Priority:   5,
+			Parameters: map[string]interface{}{"timeout": 1},
+			Route:      json.RawMessage(`{"backends":[{"tags":["${version}"],"timeout":"${timeout}"}]}`),
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		binding, err = m.CreateBinding("namespace", Binding{
+			Template:   "timeout",
+			Selector:   Selector{Tags: []string{"tier=backend"}},
+			Parameters: map[string]interface{}{"version": "v1"},
+		})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("materializes rules for the selected destinations", func() {
+		Expect(binding.ID).ToNot(BeEmpty())
+		Expect(binding.Destinations).To(Equal([]string{"ratings", "reviews"}))
+		Expect(timeouts()).To(Equal(map[string]float64{"ratings": 1, "reviews": 1}))
+
+		res, err := ruleManager.GetRules("namespace", rules.Filter{})
+		Expect(err).ToNot(HaveOccurred())
+		for _, rule := range res.Rules {
+			Expect(rule.Priority).To(Equal(5))
+			Expect(rule.Tags).To(Equal([]string{binding.tag()}))
+		}
+
+		list, err := m.ListBindings("namespace")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(list).To(HaveLen(1))
+	})
+
+	It("rejects invalid templates and bindings", func() {
+		_, err := m.CreateTemplate("namespace", Template{
+			Route:   json.RawMessage(`{"backends":[]}`),
+			Actions: json.RawMessage(`[]`),
+		})
+		Expect(err).To(BeAssignableToTypeOf(&InvalidTemplateError{}))
+
+		_, err = m.CreateBinding("namespace", Binding{
+			Template: "timeout",
+			Selector: Selector{Destinations: []string{"details"}},
+		})
+		Expect(err).To(BeAssignableToTypeOf(&InvalidBindingError{}))
+		Expect(err.(*InvalidBindingError).Errors).To(ConsistOf(
+			FieldError{Field: "parameters.version", Description: "Parameter version of template timeout is not set"},
+		))
+
+		_, err = m.CreateBinding("namespace", Binding{
+			Template: "missing",
+			Selector: Selector{Destinations: []string{"details"}},
+		})
+		Expect(err).To(BeAssignableToTypeOf(&InvalidBindingError{}))
+	})
+
+	It("materializes the bindings again when the template changes", func() {
+		template.Parameters = map[string]interface{}{"timeout": 3}
+		_, err := m.UpdateTemplate("namespace", template)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(timeouts()).To(Equal(map[string]float64{"ratings": 3, "reviews": 3}))
+
+		// The binding no longer sets every parameter of the template
+		template.Route = json.RawMessage(`{"backends":[{"tags":["${version}","${zone}"]}]}`)
+		_, err = m.UpdateTemplate("namespace", template)
+		Expect(err).To(BeAssignableToTypeOf(&InvalidTemplateError{}))
+	})
+
+	It("follows the services in the catalog", func() {
+		res, err := ruleManager.GetRules("namespace", rules.Filter{})
+		Expect(err).ToNot(HaveOccurred())
+
+		// Unchanged rules are not replaced
+		m.refresh()
+		unchanged, err := ruleManager.GetRules("namespace", rules.Filter{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(unchanged.Revision).To(Equal(res.Revision))
+
+		catalog.services["details"] = []string{"tier=backend"}
+		delete(catalog.services, "ratings")
+		m.refresh()
+		Expect(timeouts()).To(Equal(map[string]float64{"details": 1, "reviews": 1}))
+
+		b, err := m.GetBinding("namespace", binding.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(b.Destinations).To(Equal([]string{"details", "reviews"}))
+
+		// Rules are kept while the catalog is unavailable
+		catalog.err = errors.New("registry unavailable")
+		m.refresh()
+		Expect(timeouts()).To(HaveLen(2))
+	})
+
+	It("deletes the rules of deleted bindings", func() {
+		Expect(m.DeleteTemplate("namespace", "timeout")).To(BeAssignableToTypeOf(&ConflictError{}))
+
+		Expect(m.DeleteBinding("namespace", binding.ID)).To(Succeed())
+		Expect(timeouts()).To(BeEmpty())
+
+		_, err := m.GetBinding("namespace", binding.ID)
+		Expect(err).To(BeAssignableToTypeOf(&BindingNotFoundError{}))
+
+		Expect(m.DeleteTemplate("namespace", "timeout")).To(Succeed())
+		_, err = m.GetTemplate("namespace", "timeout")
+		Expect(err).To(BeAssignableToTypeOf(&TemplateNotFoundError{}))
+	})
+}
@@ -0,0 +1,343 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package templates
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/amalgam8/amalgam8/controller/rules"
+)
+
+// DestinationParameter is the parameter set to the destination of each rule materialized from a template.
+const DestinationParameter = "destination"
+
+const tagPrefix = "a8_binding="
+
+// placeholder matches references to parameters, such as "${version}", in the strings of a template.
+var placeholder = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Template is a parameterized rule body. Strings in the match, route and actions of a template may reference
+// parameters as "${name}". A string that is only a reference is replaced by the value of the parameter, which may be
+// any JSON value, and references within longer strings are replaced by the parameter value as text.
+type Template struct {
+	ID          string `json:"id"`
+	Description string `json:"description,omitempty"`
+	Priority    int    `json:"priority,omitempty"`
+
+	// Parameters are the default values of the parameters of the template.
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+
+	Match   json.RawMessage `json:"match,omitempty"`
+	Route   json.RawMessage `json:"route,omitempty"`
+	Actions json.RawMessage `json:"actions,omitempty"`
+}
+
+// Binding applies a template to each destination selected by its selector.
+type Binding struct {
+	ID       string   `json:"id"`
+	Template string   `json:"template"`
+	Selector Selector `json:"selector"`
+
+	// Parameters override the default parameter values of the template.
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+
+	// Destinations the template was last materialized for.
+	Destinations []string `json:"destinations"`
+}
+
+// Selector selects destinations by name, or by the tags of the service instances registered for them.
+type Selector struct {
+	// Destinations are selected by name.
+	Destinations []string `json:"destinations,omitempty"`
+
+	// Tags select the services that have instances registered with all of the tags.
+	Tags []string `json:"tags,omitempty"`
+}
+
+// Collection is the templates and bindings of a namespace.
+type Collection struct {
+	Templates []Template `json:"templates"`
+	Bindings  []Binding  `json:"bindings"`
+
+	// revision of the collection in the store when it was read.
+	revision int64
+}
+
+// template returns the index of the template with the ID, or -1 if there is none.
+func (c Collection) template(id string) int {
+	for i, template := range c.Templates {
+		if template.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// binding returns the index of the binding with the ID, or -1 if there is none.
+func (c Collection) binding(id string) int {
+	for i, binding := range c.Bindings {
+		if binding.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (b Binding) tag() string {
+	return tagPrefix + b.ID
+}
+
+func (b Binding) filter() rules.Filter {
+	return rules.Filter{
+		Tags:            []string{b.tag()},
+		IncludeInactive: true,
+	}
+}
+
+// selects returns the destinations selected from the services, which map the names of registered services to the
+// tags of their instances.
+func (s Selector) selects(services map[string][]string) []string {
+	selected := tagSet(s.Destinations)
+
+	if len(s.Tags) > 0 {
+		for service, tags := range services {
+			serviceTags := tagSet(tags)
+
+			all := true
+			for _, tag := range s.Tags {
+				if !serviceTags[tag] {
+					all = false
+					break
+				}
+			}
+
+			if all {
+				selected[service] = true
+			}
+		}
+	}
+
+	destinations := make([]string, 0, len(selected))
+	for destination := range selected {
+		destinations = append(destinations, destination)
+	}
+	sort.Strings(destinations)
+
+	return destinations
+}
+
+// rules materializes the template for each of the destinations with the parameters of the binding.
+func (b Binding) rules(template Template, destinations []string) ([]rules.Rule, error) {
+	rs := make([]rules.Rule, 0, len(destinations))
+	for _, destination := range destinations {
+		params := make(map[string]interface{}, len(template.Parameters)+len(b.Parameters)+1)
+		for name, value := range template.Parameters {
+			params[name] = value
+		}
+		for name, value := range b.Parameters {
+			params[name] = value
+		}
+		params[DestinationParameter] = destination
+
+		rule := rules.Rule{
+			Priority:    template.Priority,
+			Tags:        []string{b.tag()},
+			Destination: destination,
+		}
+
+		var err error
+		if rule.Match, err = render(template.Match, params); err != nil {
+			return nil, err
+		}
+		if rule.Route, err = render(template.Route, params); err != nil {
+			return nil, err
+		}
+		if rule.Actions, err = render(template.Actions, params); err != nil {
+			return nil, err
+		}
+
+		rs = append(rs, rule)
+	}
+
+	return rs, nil
+}
+
+// render replaces the references to parameters in the JSON body.
+func render(body json.RawMessage, params map[string]interface{}) (json.RawMessage, error) {
+	if len(body) == 0 {
+		return body, nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return nil, &rules.JSONMarshalError{Message: err.Error()}
+	}
+
+	value, err := substitute(value, params)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, &rules.JSONMarshalError{Message: err.Error()}
+	}
+
+	return data, nil
+}
+
+// substitute replaces the references to parameters in the strings of the decoded JSON value.
+func substitute(value interface{}, params map[string]interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		if match := placeholder.FindStringSubmatch(v); match != nil && match[0] == v {
+			param, ok := params[match[1]]
+			if !ok {
+				return nil, &UnknownParameterError{Name: match[1]}
+			}
+			return param, nil
+		}
+
+		var err error
+		s := placeholder.ReplaceAllStringFunc(v, func(ref string) string {
+			name := placeholder.FindStringSubmatch(ref)[1]
+			param, ok := params[name]
+			if !ok {
+				err = &UnknownParameterError{Name: name}
+				return ref
+			}
+			if s, ok := param.(string); ok {
+				return s
+			}
+			data, _ := json.Marshal(param)
+			return string(data)
+		})
+		return s, err
+	case []interface{}:
+		for i := range v {
+			var err error
+			if v[i], err = substitute(v[i], params); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	case map[string]interface{}:
+		for key := range v {
+			var err error
+			if v[key], err = substitute(v[key], params); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
+
+// references returns the names of the parameters referenced by the template.
+func (t Template) references() map[string]bool {
+	refs := make(map[string]bool)
+	for _, body := range []json.RawMessage{t.Match, t.Route, t.Actions} {
+		for _, match := range placeholder.FindAllSubmatch(body, -1) {
+			refs[string(match[1])] = true
+		}
+	}
+	return refs
+}
+
+func (t Template) validate() []FieldError {
+	errs := []FieldError{}
+	addError := func(field, format string, args ...interface{}) {
+		errs = append(errs, FieldError{
+			Field:       field,
+			Description: fmt.Sprintf(format, args...),
+		})
+	}
+
+	if len(t.Route) == 0 && len(t.Actions) == 0 {
+		addError("route", "Either a route or actions are required")
+	} else if len(t.Route) > 0 && len(t.Actions) > 0 {
+		addError("actions", "Templates cannot have both a route and actions")
+	}
+
+	bodies := map[string]json.RawMessage{
+		"match":   t.Match,
+		"route":   t.Route,
+		"actions": t.Actions,
+	}
+	for _, field := range []string{"match", "route", "actions"} {
+		if len(bodies[field]) > 0 && !json.Valid(bodies[field]) {
+			addError(field, "Invalid JSON")
+		}
+	}
+
+	if _, ok := t.Parameters[DestinationParameter]; ok {
+		addError("parameters."+DestinationParameter, "The %v parameter is set to the destination of each rule",
+			DestinationParameter)
+	}
+
+	return errs
+}
+
+// validate checks the binding against the template it applies. Selecting services by tags requires a catalog.
+func (b Binding) validate(template Template, catalog bool) []FieldError {
+	errs := []FieldError{}
+	addError := func(field, format string, args ...interface{}) {
+		errs = append(errs, FieldError{
+			Field:       field,
+			Description: fmt.Sprintf(format, args...),
+		})
+	}
+
+	if len(b.Selector.Destinations) == 0 && len(b.Selector.Tags) == 0 {
+		addError("selector", "Destinations or tags are required")
+	}
+	if len(b.Selector.Tags) > 0 && !catalog {
+		addError("selector.tags", "Selecting destinations by tags requires a registry")
+	}
+
+	if _, ok := b.Parameters[DestinationParameter]; ok {
+		addError("parameters."+DestinationParameter, "The %v parameter is set to the destination of each rule",
+			DestinationParameter)
+	}
+
+	refs := template.references()
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		_, inTemplate := template.Parameters[name]
+		_, inBinding := b.Parameters[name]
+		if !inTemplate && !inBinding && name != DestinationParameter {
+			addError("parameters."+name, "Parameter %v of template %v is not set", name, template.ID)
+		}
+	}
+
+	return errs
+}
+
+func tagSet(tags []string) map[string]bool {
+	set := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		set[tag] = true
+	}
+	return set
+}
@@ -0,0 +1,72 @@
+// Copyright 2016 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+)
+
+// aesGCM provides authenticated AES encryption in Galois/Counter Mode.
+type aesGCM struct {
+	aead cipher.AEAD
+}
+
+// NewAES returns an AES encryption using the key, which must be 16, 24 or 32 bytes long.
+func NewAES(key []byte) (Encryption, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &aesGCM{
+		aead: aead,
+	}, nil
+}
+
+func (a *aesGCM) Encrypt(iv, data []byte) ([]byte, error) {
+	if len(iv) != a.aead.NonceSize() {
+		return nil, fmt.Errorf("encryption: invalid IV length %v", len(iv))
+	}
+
+	return a.aead.Seal(nil, iv, data, nil), nil
+}
+
+func (a *aesGCM) Decrypt(iv, data []byte) ([]byte, error) {
+	if len(iv) != a.aead.NonceSize() {
+		return nil, fmt.Errorf("encryption: invalid IV length %v", len(iv))
+	}
+
+	return a.aead.Open(nil, iv, data, nil)
+}
+
+func (a *aesGCM) NewIV() []byte {
+	iv := make([]byte, a.aead.NonceSize())
+
+	// Reusing an IV would compromise the encryption, so a failure to generate one cannot be ignored
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		panic(fmt.Sprintf("encryption: could not generate IV: %v", err))
+	}
+
+	return iv
+}
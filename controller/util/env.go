@@ -18,4 +18,6 @@ package util
 const (
 	Namespace = "NAMESPACE"
 	Context   = "CONTEXT"
+	Principal = "PRINCIPAL"
+	Roles     = "ROLES"
 )
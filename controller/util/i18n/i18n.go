@@ -16,6 +16,7 @@ package i18n
 
 import (
 	"path/filepath"
+	"reflect"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/ant0ine/go-json-rest/rest"
@@ -24,13 +25,20 @@ import (
 
 // Error JSON
 type Error struct {
-	Error       string `json:"error"`
-	Description string `json:"description"`
+	Error       string      `json:"error"`
+	Description string      `json:"description"`
+	Details     interface{} `json:"details,omitempty"`
 }
 
 // RestError writes a basic error response with a translated error message and an untranslated error ID
 // TODO: request ID?
 func RestError(w rest.ResponseWriter, r *rest.Request, code int, id string, args ...interface{}) {
+	RestErrorDetails(w, r, code, id, nil, args...)
+}
+
+// RestErrorDetails writes an error response with a translated error message, an untranslated error ID, and
+// untranslated details of the error
+func RestErrorDetails(w rest.ResponseWriter, r *rest.Request, code int, id string, details interface{}, args ...interface{}) {
 	locale := r.Header.Get("Accept-language")
 	T, err := i18n.Tfunc(locale, "en-US")
 	if err != nil {
@@ -41,9 +49,15 @@ func RestError(w rest.ResponseWriter, r *rest.Request, code int, id string, args
 
 	translated := T(id, args...)
 
+	// Empty slices and maps carry no details, but are not omitted when wrapped in the interface
+	if v := reflect.ValueOf(details); v.IsValid() && (v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.Len() == 0 {
+		details = nil
+	}
+
 	errorResp := Error{
 		Error:       id,
 		Description: translated,
+		Details:     details,
 	}
 
 	w.WriteHeader(code)
@@ -0,0 +1,67 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package i18n
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/stretchr/testify/assert"
+)
+
+func restErrorBody(t *testing.T, details interface{}) map[string]interface{} {
+	a := rest.NewApi()
+	a.SetApp(rest.AppSimple(func(w rest.ResponseWriter, r *rest.Request) {
+		RestErrorDetails(w, r, http.StatusBadRequest, "invalid", details)
+	}))
+
+	req, err := http.NewRequest("GET", "http://localhost/", nil)
+	assert.NoError(t, err)
+	w := httptest.NewRecorder()
+	a.MakeHandler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	body := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return body
+}
+
+func TestRestErrorDetailsOmitsEmptyDetails(t *testing.T) {
+	type detail struct {
+		Field string `json:"field"`
+	}
+
+	cases := []interface{}{
+		nil,
+		[]detail(nil),
+		[]detail{},
+		map[string]string(nil),
+	}
+
+	for _, details := range cases {
+		body := restErrorBody(t, details)
+		assert.Equal(t, "invalid", body["error"])
+		assert.NotContains(t, body, "details", "details: %#v", details)
+	}
+
+	body := restErrorBody(t, []detail{{Field: "route"}})
+	assert.Equal(t, []interface{}{map[string]interface{}{"field": "route"}}, body["details"])
+
+	body = restErrorBody(t, "revision 3 is current")
+	assert.Equal(t, "revision 3 is current", body["details"])
+}
@@ -16,14 +16,38 @@ package i18n
 
 // Message IDs for translation.
 const (
-	ErrorInvalidJSON     = "error_invalid_json"
-	ErrorInvalidRule     = "error_invalid_rule"
-	ErrorNoRulesProvided = "error_no_rules_provided"
+	ErrorInvalidJSON           = "error_invalid_json"
+	ErrorInvalidRule           = "error_invalid_rule"
+	ErrorNoRulesProvided       = "error_no_rules_provided"
+	ErrorNoDestinationProvided = "error_no_destination_provided"
+	ErrorInvalidRevision       = "error_invalid_revision"
+	ErrorInvalidTimeout        = "error_invalid_timeout"
+	ErrorInvalidTimeRange      = "error_invalid_time_range"
+	ErrorInvalidYAML           = "error_invalid_yaml"
+	ErrorInvalidImportMode     = "error_invalid_import_mode"
+	ErrorUnsupportedVersion    = "error_unsupported_version"
+	ErrorInvalidPage           = "error_invalid_page"
+	ErrorInvalidFields         = "error_invalid_fields"
+
+	ErrorRevisionNotFound = "error_revision_not_found"
+	ErrorImportConflict   = "error_import_conflict"
+	ErrorRevisionMismatch = "error_revision_mismatch"
+
+	ErrorInvalidRollout  = "error_invalid_rollout"
+	ErrorRolloutNotFound = "error_rollout_not_found"
+	ErrorRolloutConflict = "error_rollout_conflict"
+
+	ErrorInvalidTemplate  = "error_invalid_template"
+	ErrorInvalidBinding   = "error_invalid_binding"
+	ErrorTemplateNotFound = "error_template_not_found"
+	ErrorBindingNotFound  = "error_binding_not_found"
+	ErrorTemplateConflict = "error_template_conflict"
 
 	ErrorAuthorizationMissingHeader         = "error_auth_header_missing"
 	ErrorAuthorizationMalformedHeader       = "error_auth_header_malformed"
 	ErrorAuthorizationTokenValidationFailed = "error_auth_failed_validation"
 	ErrorAuthorizationNotAuthorized         = "error_auth_not_authorized"
+	ErrorAuthorizationForbidden             = "error_auth_forbidden"
 
 	ErrorInternalServer = "error_internal"
 )
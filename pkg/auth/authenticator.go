@@ -30,3 +30,27 @@ type Authenticator interface {
 	// Authenticate resolves an arbitrary string token into a namespace.
 	Authenticate(ctx context.Context, token string) (*Namespace, error)
 }
+
+// RoleAuthenticator is implemented by authenticators whose tokens grant roles within the namespace.
+type RoleAuthenticator interface {
+	Authenticator
+
+	// AuthenticateRoles resolves an arbitrary string token into a namespace and the roles granted by the token.
+	AuthenticateRoles(ctx context.Context, token string) (*Namespace, Roles, error)
+}
+
+// AuthenticateRoles resolves a token into a namespace and the roles granted by the token. Tokens of authenticators
+// that do not support roles are granted the admin role, so that they keep the permissions they had before roles
+// were introduced.
+func AuthenticateRoles(ctx context.Context, authenticator Authenticator, token string) (*Namespace, Roles, error) {
+	if ra, ok := authenticator.(RoleAuthenticator); ok {
+		return ra.AuthenticateRoles(ctx, token)
+	}
+
+	namespace, err := authenticator.Authenticate(ctx, token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return namespace, Roles{RoleAdmin}, nil
+}
@@ -45,14 +45,19 @@ type chainAuthenticator struct {
 // Authenticate verifies the specified token with the registered authenticators.
 // The function returns the Namespace of this token or an error if the token is not valid
 func (r *chainAuthenticator) Authenticate(ctx context.Context, token string) (*Namespace, error) {
+	namespace, _, err := r.AuthenticateRoles(ctx, token)
+	return namespace, err
+}
+
+func (r *chainAuthenticator) AuthenticateRoles(ctx context.Context, token string) (*Namespace, Roles, error) {
 	// Scan the list of authenticators in order
 	for _, a := range r.authenticators {
-		namespace, err := a.Authenticate(ctx, token)
+		namespace, roles, err := AuthenticateRoles(ctx, a, token)
 		if err == ErrUnrecognizedToken {
 			continue
 		}
-		return namespace, err
+		return namespace, roles, err
 	}
 
-	return nil, ErrUnauthorized
+	return nil, nil, ErrUnauthorized
 }
@@ -25,6 +25,7 @@ import (
 const (
 	SigningAlgorithm = "HS256"
 	NamespaceClaim   = "namespace"
+	RolesClaim       = "roles"
 )
 
 type jwtAuthenticator struct {
@@ -41,27 +42,51 @@ func NewJWTAuthenticator(key []byte) (Authenticator, error) {
 }
 
 func (aut *jwtAuthenticator) Authenticate(ctx context.Context, token string) (*Namespace, error) {
+	namespace, _, err := aut.AuthenticateRoles(ctx, token)
+	return namespace, err
+}
+
+// AuthenticateRoles resolves the token into a namespace and the roles in the roles claim of the token. Tokens without
+// a roles claim are granted the admin role.
+func (aut *jwtAuthenticator) AuthenticateRoles(ctx context.Context, token string) (*Namespace, Roles, error) {
 	if token == "" {
-		return nil, ErrEmptyToken
+		return nil, nil, ErrEmptyToken
 	}
 
 	t, err := aut.parseToken(token)
 	if err != nil {
 		if ve, ok := err.(*jwt.ValidationError); ok {
 			if ve.Errors&jwt.ValidationErrorMalformed != 0 {
-				return nil, ErrUnrecognizedToken
+				return nil, nil, ErrUnrecognizedToken
 			}
 		}
-		return nil, ErrUnauthorized
+		return nil, nil, ErrUnauthorized
 	}
 
 	claim, exists := t.Claims[NamespaceClaim]
 	if !exists || claim.(string) == "" {
-		return nil, ErrUnauthorized
+		return nil, nil, ErrUnauthorized
+	}
+
+	roles := Roles{RoleAdmin}
+	if rolesClaim, exists := t.Claims[RolesClaim]; exists {
+		values, ok := rolesClaim.([]interface{})
+		if !ok {
+			return nil, nil, ErrUnauthorized
+		}
+
+		roles = make(Roles, len(values))
+		for i, value := range values {
+			role, ok := value.(string)
+			if !ok {
+				return nil, nil, ErrUnauthorized
+			}
+			roles[i] = Role(role)
+		}
 	}
 
 	namespace := Namespace(claim.(string))
-	return &namespace, nil
+	return &namespace, roles, nil
 }
 
 func (aut *jwtAuthenticator) parseToken(token string) (*jwt.Token, error) {
@@ -0,0 +1,80 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+var jwtKey = []byte("secret")
+
+func signToken(t *testing.T, claims map[string]interface{}) string {
+	token := jwt.New(jwt.GetSigningMethod(SigningAlgorithm))
+	for key, value := range claims {
+		token.Claims[key] = value
+	}
+
+	signed, err := token.SignedString(jwtKey)
+	assert.NoError(t, err)
+	return signed
+}
+
+func TestJWTRoles(t *testing.T) {
+	aut, err := NewJWTAuthenticator(jwtKey)
+	assert.NoError(t, err)
+
+	cases := []struct {
+		claims map[string]interface{}
+		roles  Roles
+		err    error
+	}{
+		{ // Tokens without roles keep all permissions
+			claims: map[string]interface{}{NamespaceClaim: "namespace1"},
+			roles:  Roles{RoleAdmin},
+		},
+		{
+			claims: map[string]interface{}{NamespaceClaim: "namespace1", RolesClaim: []string{"reader", "registrar"}},
+			roles:  Roles{RoleReader, RoleRegistrar},
+		},
+		{
+			claims: map[string]interface{}{NamespaceClaim: "namespace1", RolesClaim: []string{}},
+			roles:  Roles{},
+		},
+		{
+			claims: map[string]interface{}{NamespaceClaim: "namespace1", RolesClaim: "admin"},
+			err:    ErrUnauthorized,
+		},
+	}
+
+	for _, c := range cases {
+		namespace, roles, err := aut.(RoleAuthenticator).AuthenticateRoles(context.TODO(), signToken(t, c.claims))
+		assert.Equal(t, c.err, err)
+		assert.Equal(t, c.roles, roles)
+		if c.err == nil {
+			assert.Equal(t, namespace1, *namespace)
+		}
+	}
+}
+
+func TestAuthenticateRolesWithoutRoleSupport(t *testing.T) {
+	namespace, roles, err := AuthenticateRoles(context.TODO(), NewTrustedAuthenticator(), "namespace1")
+	assert.NoError(t, err)
+	assert.Equal(t, namespace1, *namespace)
+	assert.Equal(t, Roles{RoleAdmin}, roles)
+}
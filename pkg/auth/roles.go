@@ -0,0 +1,93 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package auth
+
+import "strings"
+
+// Role grants a set of permissions within a namespace.
+type Role string
+
+// Roles granted by tokens.
+const (
+	// RoleReader can read rules and service instances.
+	RoleReader Role = "reader"
+
+	// RoleRuleEditor can read and change rules.
+	RoleRuleEditor Role = "rule-editor"
+
+	// RoleRegistrar can read service instances and register them.
+	RoleRegistrar Role = "registrar"
+
+	// RoleAdmin can make any request.
+	RoleAdmin Role = "admin"
+)
+
+// Roles is a set of roles.
+type Roles []Role
+
+// Grants returns whether the roles grant the permissions of the role. The admin role grants the permissions of all
+// roles, and the rule-editor and registrar roles grant the permissions of the reader role.
+func (r Roles) Grants(role Role) bool {
+	for _, granted := range r {
+		switch {
+		case granted == role, granted == RoleAdmin:
+			return true
+		case role == RoleReader && (granted == RoleRuleEditor || granted == RoleRegistrar):
+			return true
+		}
+	}
+
+	return false
+}
+
+// Permission specifies the role required to make requests with a method to a path.
+type Permission struct {
+	// Method of the requests, or empty for any method.
+	Method string
+
+	// Path of the requests. A path ending with "*" matches any path with the preceding prefix.
+	Path string
+
+	// Role required to make the requests.
+	Role Role
+}
+
+// matches returns whether the permission applies to requests with the method to the path.
+func (p Permission) matches(method, path string) bool {
+	if p.Method != "" && p.Method != method {
+		return false
+	}
+
+	if strings.HasSuffix(p.Path, "*") {
+		return strings.HasPrefix(path, strings.TrimSuffix(p.Path, "*"))
+	}
+
+	return p.Path == path
+}
+
+// Policy is an ordered list of permissions. The first permission that matches a request applies to it.
+type Policy []Permission
+
+// Role returns the role required to make requests with the method to the path. Requests that match no permission
+// require the admin role.
+func (p Policy) Role(method, path string) Role {
+	for _, permission := range p {
+		if permission.matches(method, path) {
+			return permission.Role
+		}
+	}
+
+	return RoleAdmin
+}
@@ -0,0 +1,66 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRolesGrant(t *testing.T) {
+	cases := []struct {
+		roles   Roles
+		role    Role
+		granted bool
+	}{
+		{roles: Roles{RoleReader}, role: RoleReader, granted: true},
+		{roles: Roles{RoleReader}, role: RoleRuleEditor, granted: false},
+		{roles: Roles{RoleRuleEditor}, role: RoleReader, granted: true},
+		{roles: Roles{RoleRuleEditor}, role: RoleRegistrar, granted: false},
+		{roles: Roles{RoleRegistrar}, role: RoleReader, granted: true},
+		{roles: Roles{RoleReader, RoleRegistrar}, role: RoleRegistrar, granted: true},
+		{roles: Roles{RoleAdmin}, role: RoleRuleEditor, granted: true},
+		{roles: Roles{}, role: RoleReader, granted: false},
+		{roles: Roles{"unknown"}, role: RoleReader, granted: false},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.granted, c.roles.Grants(c.role), "%v grants %v", c.roles, c.role)
+	}
+}
+
+func TestPolicyRole(t *testing.T) {
+	policy := Policy{
+		{Method: "GET", Path: "*", Role: RoleReader},
+		{Method: "DELETE", Path: "/v1/rules", Role: RoleAdmin},
+		{Path: "/v1/rules*", Role: RoleRuleEditor},
+	}
+
+	cases := []struct {
+		method, path string
+		role         Role
+	}{
+		{method: "GET", path: "/v1/rules", role: RoleReader},
+		{method: "DELETE", path: "/v1/rules", role: RoleAdmin},
+		{method: "DELETE", path: "/v1/rules/routes/reviews", role: RoleRuleEditor},
+		{method: "POST", path: "/v1/rules", role: RoleRuleEditor},
+		{method: "POST", path: "/v1/other", role: RoleAdmin},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.role, policy.Role(c.method, c.path), "%v %v", c.method, c.path)
+	}
+}
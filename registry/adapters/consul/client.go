@@ -0,0 +1,122 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package consul
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	urlpkg "net/url"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/amalgam8/amalgam8/registry/utils/logging"
+)
+
+const (
+	requestTimeout = time.Duration(10) * time.Second
+	tokenHeader    = "X-Consul-Token"
+)
+
+type client struct {
+	httpClient *http.Client
+	url        string
+	token      string
+	datacenter string
+
+	logger *log.Entry
+}
+
+func newClient(url, token, datacenter string) (*client, error) {
+	// Normalize url to not end with a slash
+	for strings.HasSuffix(url, "/") {
+		url = strings.TrimSuffix(url, "/")
+	}
+
+	u, err := urlpkg.Parse(url)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported consul URL scheme '%s'", u.Scheme)
+	}
+
+	return &client{
+		httpClient: &http.Client{
+			Timeout: requestTimeout,
+		},
+		url:        url,
+		token:      token,
+		datacenter: datacenter,
+		logger:     logging.GetLogger(module),
+	}, nil
+}
+
+// getServices returns the names of the services in the catalog, mapped to the union of the tags of their instances.
+func (c *client) getServices() (map[string][]string, error) {
+	services := map[string][]string{}
+	if err := c.get("/v1/catalog/services", &services); err != nil {
+		return nil, err
+	}
+	return services, nil
+}
+
+// getServiceHealth returns the instances of the named service, along with their health checks.
+func (c *client) getServiceHealth(name string) ([]*ServiceEntry, error) {
+	var entries []*ServiceEntry
+	if err := c.get("/v1/health/service/"+urlpkg.QueryEscape(name), &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (c *client) get(path string, result interface{}) error {
+	url := c.url + path
+	if c.datacenter != "" {
+		url = fmt.Sprintf("%s?dc=%s", url, urlpkg.QueryEscape(c.datacenter))
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	if c.token != "" {
+		req.Header.Set(tokenHeader, c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Failed to get %s [%s]", path, err)
+	}
+
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("Failed to read response [%s]", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Failed to get %s [%s: %s]", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	if err = json.Unmarshal(body, result); err != nil {
+		return fmt.Errorf("Failed to decode response [%s]", err)
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package consul
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/amalgam8/amalgam8/registry/api"
+	"github.com/amalgam8/amalgam8/registry/utils/logging"
+)
+
+const (
+	module                 = "CONSULADAPTER"
+	defaultPollInterval    = 10 * time.Second
+	defaultEndpointType    = "tcp"
+	endpointTypeMetaKey    = "endpoint_type"
+	statusUp               = "UP"
+	statusOutOfService     = "OUT_OF_SERVICE"
+	instanceIDDelimiter    = "-"
+	maintenanceIDDelimiter = ":"
+)
+
+// Make sure we implement the ServiceDiscovery interface
+var _ api.ServiceDiscovery = (*Adapter)(nil)
+
+// Config stores configurable attributes of the Consul adapter
+type Config struct {
+	// URL of the Consul HTTP API, e.g. "http://localhost:8500"
+	URL string
+
+	// Token is the ACL token sent with each request. If left empty, no token is sent.
+	Token string
+
+	// Datacenter to discover services in. If left empty, the datacenter of the queried agent is used.
+	Datacenter string
+
+	// PollInterval is the interval in which the adapter refreshes its copy of the Consul catalog
+	PollInterval time.Duration
+}
+
+// Adapter for Consul Service Discovery.
+//
+// Each Consul service instance is mapped to a service instance with the same service name and tags. Its metadata is
+// the JSON encoding of the Consul service metadata, and its endpoint type is the value of the "endpoint_type"
+// metadata key, or "tcp" if unset. Instances in maintenance mode or failing a health check are excluded, like
+// endpoints that are not ready in Kubernetes, so only UP instances are listed.
+type Adapter struct {
+	client *client
+
+	services map[string][]*api.ServiceInstance
+
+	logger *log.Entry
+	sync.RWMutex
+}
+
+// New creates and initializes a new Consul Service Discovery adapter
+func New(config Config) (*Adapter, error) {
+	client, err := newClient(config.URL, config.Token, config.Datacenter)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.PollInterval == 0 {
+		config.PollInterval = defaultPollInterval
+	}
+
+	adapter := &Adapter{
+		services: map[string][]*api.ServiceInstance{},
+		client:   client,
+		logger:   logging.GetLogger(module),
+	}
+
+	adapter.refresh()
+
+	ticker := time.NewTicker(config.PollInterval)
+	go func() {
+		for range ticker.C {
+			adapter.refresh()
+		}
+	}()
+
+	return adapter, nil
+}
+
+// ListServices queries for the list of services for which instances are currently registered.
+func (a *Adapter) ListServices() ([]string, error) {
+	a.RLock()
+	defer a.RUnlock()
+
+	services := make([]string, 0, len(a.services))
+	for service := range a.services {
+		services = append(services, service)
+	}
+
+	return services, nil
+}
+
+// ListInstances queries for the list of service instances currently registered.
+func (a *Adapter) ListInstances() ([]*api.ServiceInstance, error) {
+	a.RLock()
+	defer a.RUnlock()
+
+	instances := make([]*api.ServiceInstance, 0, len(a.services)*3)
+	for _, service := range a.services {
+		instances = append(instances, service...)
+	}
+
+	return instances, nil
+}
+
+// ListServiceInstances queries for the list of service instances currently registered for the given service.
+func (a *Adapter) ListServiceInstances(serviceName string) ([]*api.ServiceInstance, error) {
+	a.RLock()
+	defer a.RUnlock()
+
+	service := a.services[serviceName]
+	instances := make([]*api.ServiceInstance, 0, len(service))
+	instances = append(instances, service...)
+
+	return instances, nil
+}
+
+func (a *Adapter) refresh() {
+	services, err := a.getServices()
+	if err != nil {
+		return
+	}
+
+	a.Lock()
+	defer a.Unlock()
+	a.services = services
+}
+
+func (a *Adapter) getServices() (map[string][]*api.ServiceInstance, error) {
+	names, err := a.client.getServices()
+	if err != nil {
+		a.logger.Warnf("Unable to get services: %s", err)
+		return nil, err
+	}
+
+	services := make(map[string][]*api.ServiceInstance, len(names))
+	for name := range names {
+		entries, err := a.client.getServiceHealth(name)
+		if err != nil {
+			a.logger.Warnf("Unable to get instances of service %s: %s", name, err)
+			return nil, err
+		}
+
+		insts := make([]*api.ServiceInstance, 0, len(entries))
+		for _, entry := range entries {
+			inst, err := translateEntry(entry)
+			if err != nil {
+				a.logger.WithError(err).Warnf("Skipping instance of service %s", name)
+				continue
+			}
+			// Instances failing a critical check or in maintenance must not receive traffic
+			if inst.Status != statusUp {
+				continue
+			}
+			insts = append(insts, inst)
+		}
+
+		if len(insts) > 0 {
+			services[name] = insts
+		}
+	}
+
+	return services, nil
+}
+
+// translateEntry translates a Consul service entry to a service instance
+func translateEntry(entry *ServiceEntry) (*api.ServiceInstance, error) {
+	if entry.Node == nil || entry.Service == nil {
+		return nil, fmt.Errorf("service entry is missing its node or service")
+	}
+
+	// Services registered without an address are reachable on the address of their node
+	host := entry.Service.Address
+	if host == "" {
+		host = entry.Node.Address
+	}
+	if host == "" || entry.Service.Port == 0 {
+		return nil, fmt.Errorf("service %s on node %s has no address or port", entry.Service.ID, entry.Node.Node)
+	}
+
+	endpointType := defaultEndpointType
+	if value, ok := entry.Service.Meta[endpointTypeMetaKey]; ok && value != "" {
+		endpointType = strings.ToLower(value)
+	}
+
+	var metadata json.RawMessage
+	if len(entry.Service.Meta) > 0 {
+		var err error
+		metadata, err = json.Marshal(entry.Service.Meta)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &api.ServiceInstance{
+		// Service IDs are only unique per node
+		ID:          strings.Join([]string{entry.Node.Node, entry.Service.ID}, instanceIDDelimiter),
+		ServiceName: entry.Service.Service,
+		Endpoint: api.ServiceEndpoint{
+			Type:  endpointType,
+			Value: net.JoinHostPort(host, strconv.Itoa(entry.Service.Port)),
+		},
+		Status:   translateStatus(entry.Service.ID, entry.Checks),
+		Tags:     entry.Service.Tags,
+		Metadata: metadata,
+		TTL:      0,
+	}, nil
+}
+
+// translateStatus aggregates the node and service health checks of a service instance to an instance status
+func translateStatus(serviceID string, checks []*HealthCheck) string {
+	for _, check := range checks {
+		if check.CheckID == NodeMaintenanceCheckID ||
+			check.CheckID == ServiceMaintenanceCheckID+maintenanceIDDelimiter+serviceID {
+			return statusOutOfService
+		}
+		if check.Status == HealthCritical {
+			return statusOutOfService
+		}
+	}
+	return statusUp
+}
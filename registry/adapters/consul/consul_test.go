@@ -0,0 +1,195 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package consul
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/amalgam8/amalgam8/pkg/auth"
+	"github.com/amalgam8/amalgam8/registry/api"
+)
+
+const testToken = "secret"
+
+var testEntries = map[string][]*ServiceEntry{
+	"reviews": {
+		{
+			Node: &Node{Node: "node1", Address: "10.0.0.1"},
+			Service: &AgentService{ID: "reviews-1", Service: "reviews", Tags: []string{"v1"}, Port: 9080,
+				Meta: map[string]string{"endpoint_type": "http", "owner": "books"}},
+			Checks: []*HealthCheck{{CheckID: "serfHealth", Status: HealthPassing}},
+		},
+		{
+			Node:    &Node{Node: "node2", Address: "10.0.0.2"},
+			Service: &AgentService{ID: "reviews-2", Service: "reviews", Tags: []string{"v2"}, Address: "10.0.1.2", Port: 9080},
+			Checks: []*HealthCheck{
+				{CheckID: "serfHealth", Status: HealthPassing},
+				{CheckID: "service:reviews-2", Status: HealthCritical, ServiceID: "reviews-2"},
+			},
+		},
+	},
+	"ratings": {
+		{
+			Node:    &Node{Node: "node1", Address: "10.0.0.1"},
+			Service: &AgentService{ID: "ratings", Service: "ratings", Port: 9080},
+			Checks: []*HealthCheck{
+				{CheckID: "serfHealth", Status: HealthPassing},
+				{CheckID: "_service_maintenance:ratings", Status: HealthCritical, ServiceID: "ratings"},
+			},
+		},
+	},
+	"details": {
+		// No port to reach this instance on
+		{
+			Node:    &Node{Node: "node1", Address: "10.0.0.1"},
+			Service: &AgentService{ID: "details", Service: "details"},
+		},
+	},
+}
+
+// newConsulServer starts a stand-in for the Consul HTTP API serving the given service entries
+func newConsulServer(t *testing.T, entries map[string][]*ServiceEntry, failing *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(failing) != 0 {
+			http.Error(w, "unavailable", http.StatusInternalServerError)
+			return
+		}
+		if r.Header.Get(tokenHeader) != testToken {
+			http.Error(w, "ACL not found", http.StatusForbidden)
+			return
+		}
+		assert.Equal(t, "dc1", r.URL.Query().Get("dc"))
+
+		switch {
+		case r.URL.Path == "/v1/catalog/services":
+			services := map[string][]string{}
+			for name := range entries {
+				services[name] = []string{}
+			}
+			json.NewEncoder(w).Encode(services)
+		case strings.HasPrefix(r.URL.Path, "/v1/health/service/"):
+			name := strings.TrimPrefix(r.URL.Path, "/v1/health/service/")
+			json.NewEncoder(w).Encode(entries[name])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestAdapterListsConsulCatalog(t *testing.T) {
+	var failing int32
+	server := newConsulServer(t, testEntries, &failing)
+	defer server.Close()
+
+	adapter, err := New(Config{URL: server.URL + "/", Token: testToken, Datacenter: "dc1"})
+	assert.NoError(t, err)
+
+	services, err := adapter.ListServices()
+	assert.NoError(t, err)
+	sort.Strings(services)
+	assert.Equal(t, []string{"reviews"}, services)
+
+	instances, err := adapter.ListServiceInstances("reviews")
+	assert.NoError(t, err)
+	sort.Sort(byID(instances))
+	assert.Equal(t, []*api.ServiceInstance{
+		{
+			ID:          "node1-reviews-1",
+			ServiceName: "reviews",
+			Endpoint:    api.ServiceEndpoint{Type: "http", Value: "10.0.0.1:9080"},
+			Status:      "UP",
+			Tags:        []string{"v1"},
+			Metadata:    json.RawMessage(`{"endpoint_type":"http","owner":"books"}`),
+		},
+	}, instances)
+
+	// Instances failing a critical check or in maintenance are excluded
+	instances, err = adapter.ListServiceInstances("ratings")
+	assert.NoError(t, err)
+	assert.Empty(t, instances)
+
+	instances, err = adapter.ListInstances()
+	assert.NoError(t, err)
+	assert.Len(t, instances, 1)
+
+	// A failed refresh keeps the last known catalog
+	atomic.StoreInt32(&failing, 1)
+	adapter.refresh()
+	instances, err = adapter.ListInstances()
+	assert.NoError(t, err)
+	assert.Len(t, instances, 1)
+}
+
+func TestAdapterRejectsInvalidURL(t *testing.T) {
+	_, err := New(Config{URL: "consul:8500"})
+	assert.Error(t, err)
+}
+
+func TestTranslateStatus(t *testing.T) {
+	cases := []struct {
+		checks   []*HealthCheck
+		expected string
+	}{
+		{nil, "UP"},
+		{[]*HealthCheck{{CheckID: "serfHealth", Status: HealthPassing}}, "UP"},
+		{[]*HealthCheck{{CheckID: "service:web", Status: HealthWarning}}, "UP"},
+		{[]*HealthCheck{{CheckID: "service:web", Status: HealthCritical}}, "OUT_OF_SERVICE"},
+		{[]*HealthCheck{{CheckID: "serfHealth", Status: HealthCritical}}, "OUT_OF_SERVICE"},
+		{[]*HealthCheck{{CheckID: "_node_maintenance", Status: HealthCritical}}, "OUT_OF_SERVICE"},
+		{[]*HealthCheck{{CheckID: "_service_maintenance:web", Status: HealthCritical}}, "OUT_OF_SERVICE"},
+	}
+
+	for i, c := range cases {
+		assert.Equal(t, c.expected, translateStatus("web", c.checks), "Wrong status for test-case %d", i)
+	}
+}
+
+func TestCatalogFactoryMapsDefaultNamespace(t *testing.T) {
+	var failing int32
+	server := newConsulServer(t, testEntries, &failing)
+	defer server.Close()
+
+	factory, err := NewCatalogFactory(Config{URL: server.URL, Token: testToken, Datacenter: "dc1"})
+	assert.NoError(t, err)
+
+	catalog, err := factory.CreateCatalog(auth.NamespaceFrom("default"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, catalog) {
+		instances, err := catalog.List("reviews", nil)
+		assert.NoError(t, err)
+		assert.Len(t, instances, 1)
+
+		_, err = catalog.Register(instances[0])
+		assert.Error(t, err)
+	}
+
+	catalog, err = factory.CreateCatalog(auth.NamespaceFrom("tenant"))
+	assert.NoError(t, err)
+	assert.Nil(t, catalog)
+}
+
+type byID []*api.ServiceInstance
+
+func (a byID) Len() int           { return len(a) }
+func (a byID) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byID) Less(i, j int) bool { return a[i].ID < a[j].ID }
@@ -0,0 +1,50 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package consul
+
+import (
+	"github.com/amalgam8/amalgam8/pkg/auth"
+	"github.com/amalgam8/amalgam8/registry/api"
+	"github.com/amalgam8/amalgam8/registry/store"
+)
+
+type catalogFactory struct {
+	adapter store.CatalogFactory
+}
+
+// NewCatalogFactory creates a factory of read-only catalogs backed by the Consul catalog,
+// to be used as an extension of the registry's catalogs.
+func NewCatalogFactory(config Config) (store.CatalogFactory, error) {
+	adapter, err := New(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &catalogFactory{
+		adapter: store.NewDiscoveryAdapter(func(namespace auth.Namespace) (api.ServiceDiscovery, error) {
+			return adapter, nil
+		}),
+	}, nil
+}
+
+func (f *catalogFactory) CreateCatalog(namespace auth.Namespace) (store.Catalog, error) {
+	// Consul is not designed for multi-tenancy.
+	// Therefore, we map the consul catalog ONLY to the default namespace
+	if namespace.String() == "" || namespace.String() == "default" {
+		return f.adapter.CreateCatalog(namespace)
+	}
+	// nil catalog means that we don't map consul catalog to this namespace
+	return nil, nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package consul
+
+// ServiceEntry is an instance of a service, along with the node it runs on and its health checks,
+// as returned by the Consul health API.
+type ServiceEntry struct {
+	Node    *Node          `json:"Node"`
+	Service *AgentService  `json:"Service"`
+	Checks  []*HealthCheck `json:"Checks"`
+}
+
+// Node is a Consul agent node.
+type Node struct {
+	ID         string `json:"ID"`
+	Node       string `json:"Node"`
+	Address    string `json:"Address"`
+	Datacenter string `json:"Datacenter"`
+}
+
+// AgentService is a service instance registered with a Consul agent.
+type AgentService struct {
+	ID      string            `json:"ID"`
+	Service string            `json:"Service"`
+	Tags    []string          `json:"Tags"`
+	Address string            `json:"Address"`
+	Port    int               `json:"Port"`
+	Meta    map[string]string `json:"Meta"`
+}
+
+// HealthCheck is a node or service health check.
+type HealthCheck struct {
+	Node      string `json:"Node"`
+	CheckID   string `json:"CheckID"`
+	Name      string `json:"Name"`
+	Status    string `json:"Status"`
+	ServiceID string `json:"ServiceID"`
+}
+
+// Health check status values
+const (
+	HealthPassing  = "passing"
+	HealthWarning  = "warning"
+	HealthCritical = "critical"
+)
+
+// Health check IDs of Consul's node and service maintenance modes. The service maintenance check ID is suffixed
+// by ':' and the service ID.
+const (
+	NodeMaintenanceCheckID    = "_node_maintenance"
+	ServiceMaintenanceCheckID = "_service_maintenance"
+)
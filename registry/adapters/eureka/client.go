@@ -15,6 +15,7 @@
 package eureka
 
 import (
+	"bytes"
 	"crypto/tls"
 	"fmt"
 	"strings"
@@ -131,3 +132,58 @@ func (c *client) getApplicationsDelta() (*eurekaapi.Applications, error) {
 
 	return apps, nil
 }
+
+func (c *client) register(inst *eurekaapi.Instance) (int, error) {
+	body, err := json.Marshal(&eurekaapi.InstanceWrapper{Inst: inst})
+	if err != nil {
+		return 0, err
+	}
+
+	return c.do("POST", fmt.Sprintf("apps/%s", url.PathEscape(inst.Application)), body)
+}
+
+func (c *client) renew(appid, iid string) (int, error) {
+	return c.do("PUT", fmt.Sprintf("apps/%s/%s", url.PathEscape(appid), url.PathEscape(iid)), nil)
+}
+
+func (c *client) deregister(appid, iid string) (int, error) {
+	return c.do("DELETE", fmt.Sprintf("apps/%s/%s", url.PathEscape(appid), url.PathEscape(iid)), nil)
+}
+
+// do sends the request to each of the eureka servers in turn, until one of them handles it.
+// The status code of the response is returned along with an error if it is not successful.
+func (c *client) do(method, path string, body []byte) (int, error) {
+	var code int
+	var err error
+
+	for _, eurl := range c.eurekaURLs {
+		req, _ := http.NewRequest(method, fmt.Sprintf("%s/%s", eurl, path), bytes.NewReader(body))
+		req.Header.Set("Accept", "application/json")
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		resp, err2 := c.httpClient.Do(req)
+		if err2 != nil {
+			err = err2
+			continue
+		}
+
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		code = resp.StatusCode
+		if code >= 500 {
+			// The next server might be able to handle the request
+			err = fmt.Errorf("%s %s failed with status %d", method, path, code)
+			continue
+		}
+		if code < 200 || code >= 300 {
+			return code, fmt.Errorf("%s %s failed with status %d", method, path, code)
+		}
+
+		return code, nil
+	}
+
+	return code, err
+}
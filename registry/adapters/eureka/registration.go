@@ -0,0 +1,205 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package eureka
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amalgam8/amalgam8/registry/api"
+	registryclient "github.com/amalgam8/amalgam8/registry/client"
+	eurekaapi "github.com/amalgam8/amalgam8/registry/server/protocol/eureka"
+)
+
+const (
+	defaultTTL       = 90
+	heartbeatsPerTTL = 3
+	statusUp         = "UP"
+	metadataTags     = "amalgam8.tags"
+	idDelimiter      = ":"
+	datacenterClass  = "com.netflix.appinfo.InstanceInfo$DefaultDataCenterInfo"
+	datacenterName   = "MyOwn"
+)
+
+// Make sure we implement the ServiceRegistry interface
+var _ api.ServiceRegistry = (*Adapter)(nil)
+
+// Register adds a service instance, described by the given ServiceInstance structure, to the registry.
+// The ID of the registered instance is made of the eureka application name and instance ID,
+// the same as the IDs of the instances listed by the adapter.
+func (a *Adapter) Register(instance *api.ServiceInstance) (*api.ServiceInstance, error) {
+	inst, err := buildInstance(instance)
+	if err != nil {
+		return nil, registryclient.Error{Code: registryclient.ErrorCodeInternalClientError, Message: "invalid service instance", Cause: err}
+	}
+
+	code, err := a.client.register(inst)
+	if err != nil {
+		return nil, translateError(code, "registration failed", err)
+	}
+
+	registered := *instance
+	registered.ID = buildInstanceID(inst.Application, inst.ID)
+	registered.Status = inst.Status
+	registered.TTL = int(inst.Lease.DurationInt)
+	registered.LastHeartbeat = time.Now()
+
+	a.logger.Infof("Instance %s registered", registered.ID)
+	return &registered, nil
+}
+
+// Deregister removes a registered service instance, identified by the given ID, from the registry.
+func (a *Adapter) Deregister(id string) error {
+	appid, iid, err := parseInstanceID(id)
+	if err != nil {
+		return err
+	}
+
+	code, err := a.client.deregister(appid, iid)
+	if err != nil {
+		return translateError(code, "deregistration failed", err)
+	}
+
+	a.logger.Infof("Instance %s deregistered", id)
+	return nil
+}
+
+// Renew sends a heartbeat for the service instance identified by the given ID.
+func (a *Adapter) Renew(id string) error {
+	appid, iid, err := parseInstanceID(id)
+	if err != nil {
+		return err
+	}
+
+	code, err := a.client.renew(appid, iid)
+	if err != nil {
+		return translateError(code, "renewal failed", err)
+	}
+
+	return nil
+}
+
+// buildInstance translates a service instance into a eureka instance.
+// The tags are stored in the eureka metadata, along with the fields of the instance metadata if it is a JSON object.
+func buildInstance(instance *api.ServiceInstance) (*eurekaapi.Instance, error) {
+	if instance.ServiceName == "" {
+		return nil, fmt.Errorf("service name is required")
+	}
+
+	host, port, err := net.SplitHostPort(instance.Endpoint.Value)
+	if err != nil {
+		return nil, err
+	}
+	portNum, err := strconv.Atoi(port)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %s", port)
+	}
+
+	metadata := map[string]string{}
+	var fields map[string]interface{}
+	if json.Unmarshal(instance.Metadata, &fields) == nil {
+		for key, value := range fields {
+			if s, ok := value.(string); ok {
+				metadata[key] = s
+			} else {
+				b, _ := json.Marshal(value)
+				metadata[key] = string(b)
+			}
+		}
+	}
+	if len(instance.Tags) > 0 {
+		metadata[metadataTags] = strings.Join(instance.Tags, ",")
+	}
+	md, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := instance.TTL
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+
+	status := instance.Status
+	if status == "" {
+		status = statusUp
+	}
+
+	inst := &eurekaapi.Instance{
+		ID:          net.JoinHostPort(host, port),
+		HostName:    host,
+		Application: instance.ServiceName,
+		IPAddr:      host,
+		VIPAddr:     instance.ServiceName,
+		Status:      status,
+		Datacenter: &eurekaapi.DatacenterInfo{
+			Class: datacenterClass,
+			Name:  datacenterName,
+		},
+		Lease: &eurekaapi.LeaseInfo{
+			RenewalInt:  uint32(ttl / heartbeatsPerTTL),
+			DurationInt: uint32(ttl),
+		},
+		Metadata: md,
+	}
+
+	if instance.Endpoint.Type == "https" {
+		inst.SecVIPAddr = instance.ServiceName
+		inst.SecPort = &eurekaapi.Port{Enabled: "true", Value: portNum}
+	} else {
+		inst.Port = &eurekaapi.Port{Enabled: "true", Value: portNum}
+	}
+
+	return inst, nil
+}
+
+func buildInstanceID(appid, iid string) string {
+	return appid + idDelimiter + iid
+}
+
+func parseInstanceID(id string) (string, string, error) {
+	parts := strings.SplitN(id, idDelimiter, 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", registryclient.Error{Code: registryclient.ErrorCodeUnknownInstance, Message: fmt.Sprintf("invalid instance ID %s", id)}
+	}
+	return parts[0], parts[1], nil
+}
+
+// translateError translates a failed eureka request into a client error,
+// so that the registration lifecycle handles it the same as a failed Amalgam8 registry request.
+func translateError(code int, message string, err error) error {
+	var errCode registryclient.ErrorCode
+	switch {
+	case code == 0:
+		errCode = registryclient.ErrorCodeConnectionFailure
+	case code == http.StatusNotFound || code == http.StatusGone:
+		errCode = registryclient.ErrorCodeUnknownInstance
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		errCode = registryclient.ErrorCodeUnauthorized
+	case code == http.StatusServiceUnavailable:
+		errCode = registryclient.ErrorCodeServiceUnavailable
+	case code >= 500:
+		errCode = registryclient.ErrorCodeInternalServerError
+	default:
+		errCode = registryclient.ErrorCodeUndefined
+	}
+
+	return registryclient.Error{Code: errCode, Message: message, Cause: err}
+}
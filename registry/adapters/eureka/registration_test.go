@@ -0,0 +1,147 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package eureka
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/amalgam8/amalgam8/registry/api"
+	registryclient "github.com/amalgam8/amalgam8/registry/client"
+	eurekaapi "github.com/amalgam8/amalgam8/registry/server/protocol/eureka"
+)
+
+// eurekaServer is a stand-in for the eureka REST API that keeps track of registered instances
+type eurekaServer struct {
+	sync.Mutex
+	instances map[string]*eurekaapi.Instance // app/id -> instance
+	renewals  int
+}
+
+func (s *eurekaServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/apps"), "/")
+	switch {
+	case r.Method == "GET":
+		json.NewEncoder(w).Encode(&eurekaapi.ApplicationsList{Applications: &eurekaapi.Applications{}})
+	case r.Method == "POST" && len(parts) == 2:
+		var reg eurekaapi.InstanceWrapper
+		if err := json.NewDecoder(r.Body).Decode(&reg); err != nil || reg.Inst == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.instances[parts[1]+"/"+reg.Inst.ID] = reg.Inst
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "PUT" && len(parts) == 3:
+		if _, ok := s.instances[parts[1]+"/"+parts[2]]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		s.renewals++
+	case r.Method == "DELETE" && len(parts) == 3:
+		if _, ok := s.instances[parts[1]+"/"+parts[2]]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(s.instances, parts[1]+"/"+parts[2])
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestRegistrationLifecycle(t *testing.T) {
+	eureka := &eurekaServer{instances: map[string]*eurekaapi.Instance{}}
+	server := httptest.NewServer(eureka)
+	defer server.Close()
+
+	// The first server is unavailable, so requests fail over to the second one
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+
+	adapter, err := New(Config{URLs: []string{unavailable.URL, server.URL + "/"}})
+	assert.NoError(t, err)
+
+	instance := &api.ServiceInstance{
+		ServiceName: "reviews",
+		Endpoint:    api.ServiceEndpoint{Type: "http", Value: "10.0.0.1:9080"},
+		Tags:        []string{"v1", "blue"},
+		TTL:         60,
+	}
+	registered, err := adapter.Register(instance)
+	assert.NoError(t, err)
+	assert.Equal(t, "reviews:10.0.0.1:9080", registered.ID)
+	assert.Equal(t, "UP", registered.Status)
+	assert.Equal(t, 60, registered.TTL)
+
+	inst := eureka.instances["reviews/10.0.0.1:9080"]
+	if assert.NotNil(t, inst) {
+		assert.Equal(t, "reviews", inst.Application)
+		assert.Equal(t, "10.0.0.1", inst.HostName)
+		assert.Equal(t, uint32(60), inst.Lease.DurationInt)
+		assert.JSONEq(t, `{"amalgam8.tags":"v1,blue"}`, string(inst.Metadata))
+	}
+
+	assert.NoError(t, adapter.Renew(registered.ID))
+	assert.Equal(t, 1, eureka.renewals)
+
+	assert.NoError(t, adapter.Deregister(registered.ID))
+	assert.Empty(t, eureka.instances)
+
+	// Renewing an unknown instance should trigger re-registration by the sidecar
+	err = adapter.Renew(registered.ID)
+	if assert.Error(t, err) {
+		assert.Equal(t, registryclient.ErrorCodeUnknownInstance, err.(registryclient.Error).Code)
+	}
+}
+
+func TestBuildInstanceMatchesDiscovery(t *testing.T) {
+	instance := &api.ServiceInstance{
+		ServiceName: "details",
+		Endpoint:    api.ServiceEndpoint{Type: "https", Value: "details.local:443"},
+		Tags:        []string{"v2"},
+		Metadata:    json.RawMessage(`{"owner":"books","replicas":3}`),
+	}
+
+	inst, err := buildInstance(instance)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"amalgam8.tags":"v2","owner":"books","replicas":"3"}`, string(inst.Metadata))
+
+	// An instance registered by the adapter should be listed under the same ID, endpoint and tags
+	listed, err := translateInstance(inst)
+	assert.NoError(t, err)
+	assert.Equal(t, buildInstanceID(inst.Application, inst.ID), listed.ID)
+	assert.Equal(t, "details", listed.ServiceName)
+	assert.Equal(t, api.ServiceEndpoint{Type: "https", Value: "details.local:443"}, listed.Endpoint)
+	assert.Equal(t, []string{"v2"}, listed.Tags)
+	assert.Equal(t, defaultTTL, listed.TTL)
+}
+
+func TestBuildInstanceRejectsInvalidEndpoint(t *testing.T) {
+	_, err := buildInstance(&api.ServiceInstance{ServiceName: "details", Endpoint: api.ServiceEndpoint{Value: "details.local"}})
+	assert.Error(t, err)
+
+	_, err = buildInstance(&api.ServiceInstance{Endpoint: api.ServiceEndpoint{Value: "details.local:443"}})
+	assert.Error(t, err)
+}
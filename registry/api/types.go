@@ -46,6 +46,47 @@ type ServiceRegistry interface {
 	Renew(id string) error
 }
 
+// ServiceWatcher defines the interface used for watching changes to the registered service instances.
+type ServiceWatcher interface {
+
+	// Watch starts watching for changes to the registered service instances, until the stop channel is closed.
+	// Events for changes made after Watch returns are delivered on the returned channel, which is closed once
+	// watching stops. If the channel is closed before the stop channel, some events may have been missed,
+	// and the instances should be listed again before watching anew.
+	Watch(stop <-chan struct{}) (<-chan *Event, error)
+}
+
+// EventType identifies the kind of change to a service instance an Event describes.
+type EventType string
+
+// Enumerate valid EventType values.
+const (
+	// EventRegister is a registration of a new service instance, or re-registration of an existing one.
+	EventRegister EventType = "register"
+
+	// EventDeregister is an explicit deregistration of a service instance.
+	EventDeregister EventType = "deregister"
+
+	// EventStatus is a change of the status of a service instance.
+	EventStatus EventType = "status"
+
+	// EventExpire is a removal of a service instance whose TTL expired without a heartbeat.
+	EventExpire EventType = "expire"
+)
+
+// Event describes a change to a registered service instance.
+type Event struct {
+
+	// Index is the position of the event in the registry's sequence of changes.
+	Index uint64 `json:"index"`
+
+	// Type is the kind of change.
+	Type EventType `json:"type"`
+
+	// Instance is the service instance after the change, or its last known state if it was removed.
+	Instance *ServiceInstance `json:"instance"`
+}
+
 // ServiceInstance describes an instance of a service.
 type ServiceInstance struct {
 
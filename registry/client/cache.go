@@ -22,9 +22,10 @@ import (
 	"github.com/amalgam8/amalgam8/registry/api"
 )
 
-// Make sure we implement the ServiceDiscovery and ServiceRegistry interface.
+// Make sure we implement the ServiceDiscovery, ServiceRegistry and ServiceWatcher interfaces.
 var _ api.ServiceDiscovery = (*Cache)(nil)
 var _ api.ServiceRegistry = (*Cache)(nil)
+var _ api.ServiceWatcher = (*Cache)(nil)
 
 // CacheConfig stores the configurable attributes of the caching client.
 type CacheConfig struct {
@@ -97,6 +98,24 @@ func (c *Cache) ListServiceInstances(serviceName string) ([]*api.ServiceInstance
 	return instances, nil
 }
 
+// Watch starts watching for changes to the registered service instances, until the stop channel is closed.
+// The local cache is refreshed once watching starts, so that applying the watched events to the instances
+// listed from the cache yields the current state of the registry.
+func (c *Cache) Watch(stop <-chan struct{}) (<-chan *api.Event, error) {
+	list, err := c.Client.watchEvents(0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = c.refresh(); err != nil {
+		return nil, err
+	}
+
+	events := make(chan *api.Event)
+	go c.Client.watch(list.Index, events, stop)
+	return events, nil
+}
+
 func (c *Cache) maintain(pollInterval time.Duration) {
 	go c.refresh()
 	for range time.Tick(pollInterval) {
@@ -104,10 +123,10 @@ func (c *Cache) maintain(pollInterval time.Duration) {
 	}
 }
 
-func (c *Cache) refresh() {
+func (c *Cache) refresh() error {
 	instanceList, err := c.Client.ListInstances()
 	if err != nil {
-		return
+		return err
 	}
 
 	instanceMap := make(map[string][]*api.ServiceInstance)
@@ -118,4 +137,5 @@ func (c *Cache) refresh() {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 	c.cache = instanceMap
+	return nil
 }
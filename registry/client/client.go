@@ -22,6 +22,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -29,11 +30,20 @@ import (
 	"github.com/amalgam8/amalgam8/registry/server/protocol/amalgam8"
 )
 
-const defaultTimeout = time.Second * 30
+const (
+	defaultTimeout = time.Second * 30
 
-// Make sure we implement both the ServiceDiscovery and ServiceRegistry interfaces.
+	// defaultWatchTimeout is the time each watch request waits for events. It must be shorter than the HTTP timeout.
+	defaultWatchTimeout = time.Second * 20
+
+	// watchRetryInterval is the time to wait before retrying a failed watch request.
+	watchRetryInterval = time.Second
+)
+
+// Make sure we implement the ServiceDiscovery, ServiceRegistry and ServiceWatcher interfaces.
 var _ api.ServiceDiscovery = (*Client)(nil)
 var _ api.ServiceRegistry = (*Client)(nil)
+var _ api.ServiceWatcher = (*Client)(nil)
 
 // Config stores the configurable attributes of the client.
 type Config struct {
@@ -168,6 +178,94 @@ func (client *Client) ListInstancesWithFilter(filter InstanceFilter) ([]*api.Ser
 	return s.Instances, nil
 }
 
+// Watch starts watching for changes to the registered service instances, until the stop channel is closed.
+// The current index of the registry is retrieved before Watch returns, so an error is returned if the registry
+// does not support watching. Failed watch requests are retried from the last received index; the returned channel
+// is closed before the stop channel only if the registry no longer retains the events since that index.
+func (client *Client) Watch(stop <-chan struct{}) (<-chan *api.Event, error) {
+	list, err := client.watchEvents(0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan *api.Event)
+	go client.watch(list.Index, events, stop)
+	return events, nil
+}
+
+func (client *Client) watch(index uint64, events chan<- *api.Event, stop <-chan struct{}) {
+	defer close(events)
+
+	timeout := defaultWatchTimeout
+	if client.httpClient.Timeout > 0 && timeout >= client.httpClient.Timeout {
+		timeout = client.httpClient.Timeout / 2
+	}
+
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		list, err := client.watchEvents(index, timeout)
+		if err != nil {
+			if e, ok := err.(Error); ok && e.Code == ErrorCodeIndexExpired {
+				return
+			}
+
+			select {
+			case <-stop:
+				return
+			case <-time.After(watchRetryInterval):
+			}
+			continue
+		}
+
+		for _, event := range list.Events {
+			select {
+			case events <- event:
+			case <-stop:
+				return
+			}
+		}
+		index = list.Index
+	}
+}
+
+// watchEvents requests the events that occurred after the given index, waiting up to the timeout for one to occur.
+// An index of 0 requests the current index of the registry.
+func (client *Client) watchEvents(index uint64, timeout time.Duration) (*eventsList, error) {
+	path := amalgam8.EventsURL()
+	if index > 0 {
+		queryParams := url.Values{}
+		queryParams.Set(amalgam8.QueryParamIndex, strconv.FormatUint(index, 10))
+		queryParams.Set(amalgam8.QueryParamTimeout, strconv.Itoa(int(timeout/time.Second)))
+		path = fmt.Sprintf("%s?%s", path, queryParams.Encode())
+	}
+
+	body, err := client.doRequest("GET", path, nil, http.StatusOK)
+	if err != nil {
+		if e, ok := err.(Error); ok && e.Code == ErrorCodeUnknownInstance {
+			e.Code = ErrorCodeIndexExpired
+			return nil, e
+		}
+		return nil, err
+	}
+
+	list := &eventsList{}
+	err = json.Unmarshal(body, list)
+	if err != nil {
+		return nil, newError(ErrorCodeInternalClientError, "error unmarshaling HTTP response body", err, "")
+	}
+	return list, nil
+}
+
+type eventsList struct {
+	Index  uint64       `json:"index"`
+	Events []*api.Event `json:"events"`
+}
+
 func (client *Client) doRequest(method string, path string, body interface{}, status int) ([]byte, error) {
 	var reader io.Reader
 	if body != nil {
@@ -237,6 +335,8 @@ func (client *Client) doRequest(method string, path string, body interface{}, st
 		return nil, newError(ErrorCodeUnauthorized, message, nil, requestID)
 	case http.StatusInternalServerError:
 		return nil, newError(ErrorCodeInternalServerError, message, nil, requestID)
+	case http.StatusNotImplemented:
+		return nil, newError(ErrorCodeWatchNotSupported, message, nil, requestID)
 	default:
 		return nil, newError(ErrorCodeInternalClientError, message, nil, requestID)
 	}
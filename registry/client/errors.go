@@ -33,6 +33,9 @@ const (
 	ErrorCodeUnauthorized
 	ErrorCodeInvalidConfiguration
 	ErrorCodeInternalClientError
+
+	ErrorCodeWatchNotSupported
+	ErrorCodeIndexExpired
 )
 
 func (code ErrorCode) String() string {
@@ -52,6 +55,10 @@ func (code ErrorCode) String() string {
 		return "ErrorCodeInvalidConfiguration"
 	case ErrorCodeInternalClientError:
 		return "ErrorCodeInternalClientError"
+	case ErrorCodeWatchNotSupported:
+		return "ErrorCodeWatchNotSupported"
+	case ErrorCodeIndexExpired:
+		return "ErrorCodeIndexExpired"
 
 	default:
 		return "ErrorCodeUndefined"
@@ -14,7 +14,10 @@
 
 package cluster
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 // BackendType defines the type of backend used for the cluster
 type BackendType int
@@ -24,6 +27,7 @@ const (
 	UnspecifiedBackend BackendType = iota // Zero-value
 	FilesystemBackend
 	MemoryBackend
+	GossipBackend
 )
 
 type backend interface {
@@ -41,6 +45,12 @@ func newBackend(conf *Config) (backend, error) {
 		return newFilesystemBackend(conf.Directory, conf.TTL*3)
 	case MemoryBackend:
 		return newMemoryBackend(), nil
+	case GossipBackend:
+		return newGossipBackend(&gossipConfig{
+			bindAddress:   fmt.Sprintf(":%d", conf.GossipPort),
+			seeds:         conf.Seeds,
+			probeInterval: conf.ProbeInterval,
+		})
 	default:
 		return nil, errors.New("No backend type configured")
 	}
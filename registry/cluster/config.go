@@ -24,6 +24,8 @@ const (
 	DefaultRenewInterval = time.Duration(7) * time.Second
 	DefaultScanInterval  = time.Duration(5) * time.Second
 	DefaultSize          = 0
+	DefaultGossipPort    = 6200
+	DefaultProbeInterval = time.Duration(1) * time.Second
 )
 
 // Config encapsulates cluster configuration parameters
@@ -34,6 +36,11 @@ type Config struct {
 	RenewInterval time.Duration
 	ScanInterval  time.Duration
 	Size          int
+
+	// Gossip backend parameters
+	GossipPort    uint16
+	Seeds         []string
+	ProbeInterval time.Duration
 }
 
 // defaultize creates an output configuration based on the input configuration,
@@ -68,6 +75,12 @@ func defaultize(conf *Config) *Config {
 	if out.Size == 0 {
 		out.Size = DefaultSize
 	}
+	if out.GossipPort == 0 {
+		out.GossipPort = DefaultGossipPort
+	}
+	if out.ProbeInterval == time.Duration(0) {
+		out.ProbeInterval = DefaultProbeInterval
+	}
 
 	return out
 
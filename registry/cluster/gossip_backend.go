@@ -0,0 +1,748 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/amalgam8/amalgam8/registry/utils/logging"
+	"github.com/rcrowley/go-metrics"
+)
+
+const (
+	gossipIndirectChecks = 3  // Number of members asked to probe an unresponsive member on our behalf
+	gossipRetransmitMult = 3  // Multiplier of the number of times an update is piggybacked
+	gossipMaxPiggyback   = 16 // Maximal number of updates piggybacked on a single message
+	gossipSuspicionMult  = 5  // Suspicion timeout, in probe intervals
+	gossipReclaimMult    = 60 // Retention of dead and left members, in probe intervals
+	gossipMaxPacketSize  = 65507
+)
+
+const (
+	gossipSendErrorsMetricName    = "cluster.gossip.errors.send"
+	gossipReceiveErrorsMetricName = "cluster.gossip.errors.receive"
+)
+
+// gossipState is the state of a member, as perceived by the gossip protocol.
+// At the same incarnation, a greater state overrides a lesser one.
+type gossipState int
+
+const (
+	gossipAlive gossipState = iota
+	gossipSuspect
+	gossipDead
+	gossipLeft
+)
+
+func (s gossipState) String() string {
+	switch s {
+	case gossipAlive:
+		return "alive"
+	case gossipSuspect:
+		return "suspect"
+	case gossipDead:
+		return "dead"
+	case gossipLeft:
+		return "left"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+}
+
+type gossipMessageType string
+
+const (
+	gossipPing    gossipMessageType = "ping"
+	gossipAck     gossipMessageType = "ack"
+	gossipPingReq gossipMessageType = "ping-req"
+	gossipJoin    gossipMessageType = "join"
+	gossipPush    gossipMessageType = "push"
+)
+
+// gossipUpdate is the disseminated state of a single member
+type gossipUpdate struct {
+	IP          net.IP      `json:"ip"`
+	Port        uint16      `json:"port"`
+	Address     string      `json:"address"`
+	Incarnation uint64      `json:"incarnation"`
+	State       gossipState `json:"state"`
+}
+
+func (u *gossipUpdate) id() MemberID {
+	return (&member{MemberIP: u.IP, MemberPort: u.Port}).ID()
+}
+
+// gossipMessage is the datagram exchanged between members.
+// Every message may carry updates, either piggybacked or as its payload.
+type gossipMessage struct {
+	Type    gossipMessageType `json:"type"`
+	Seq     uint64            `json:"seq,omitempty"`
+	Target  string            `json:"target,omitempty"`
+	Updates []*gossipUpdate   `json:"updates,omitempty"`
+}
+
+type gossipPeer struct {
+	member      *member
+	address     *net.UDPAddr
+	incarnation uint64
+	state       gossipState
+	changed     time.Time
+}
+
+func (p *gossipPeer) live() bool {
+	return p.state == gossipAlive || p.state == gossipSuspect
+}
+
+func (p *gossipPeer) update() *gossipUpdate {
+	return &gossipUpdate{
+		IP:          p.member.MemberIP,
+		Port:        p.member.MemberPort,
+		Address:     p.address.String(),
+		Incarnation: p.incarnation,
+		State:       p.state,
+	}
+}
+
+type gossipBroadcast struct {
+	update    *gossipUpdate
+	transmits int
+}
+
+type gossipConfig struct {
+	bindAddress   string
+	seeds         []string
+	probeInterval time.Duration
+}
+
+// newGossipBackend creates a backend which discovers members through a SWIM-style gossip protocol.
+// Members are probed periodically over UDP, either directly or through other members, and unresponsive members
+// are suspected and eventually declared dead. Membership changes are piggybacked on the probe messages.
+func newGossipBackend(conf *gossipConfig) (*gossipBackend, error) {
+	addr, err := net.ResolveUDPAddr("udp", conf.bindAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := conf.probeInterval
+	if interval == 0 {
+		interval = DefaultProbeInterval
+	}
+
+	b := &gossipBackend{
+		conn:             conn,
+		port:             conn.LocalAddr().(*net.UDPAddr).Port,
+		seeds:            conf.seeds,
+		probeInterval:    interval,
+		probeTimeout:     interval / 2,
+		suspicionTimeout: interval * gossipSuspicionMult,
+		reclaimTimeout:   interval * gossipReclaimMult,
+		peers:            make(map[MemberID]*gossipPeer),
+		acks:             make(map[uint64]func()),
+		done:             make(chan struct{}),
+		sendErrors:       metrics.NewRegisteredMeter(gossipSendErrorsMetricName, metrics.DefaultRegistry),
+		receiveErrors:    metrics.NewRegisteredMeter(gossipReceiveErrorsMetricName, metrics.DefaultRegistry),
+		logger:           logging.GetLogger(module),
+	}
+
+	go b.receive()
+	go b.probePeriodically()
+
+	b.logger.Infof("Gossip membership listening on %v, seeds: %v", conn.LocalAddr(), conf.seeds)
+
+	return b, nil
+}
+
+type gossipBackend struct {
+	conn  *net.UDPConn
+	port  int
+	seeds []string
+
+	probeInterval    time.Duration
+	probeTimeout     time.Duration
+	suspicionTimeout time.Duration
+	reclaimTimeout   time.Duration
+
+	// The local member is nil until written, and leaves rather than being deleted
+	self       *gossipPeer
+	peers      map[MemberID]*gossipPeer
+	broadcasts []*gossipBroadcast
+	probeOrder []MemberID
+	probeIndex int
+	seq        uint64
+	acks       map[uint64]func()
+	mutex      sync.Mutex
+
+	done      chan struct{}
+	closeOnce sync.Once
+
+	sendErrors    metrics.Meter
+	receiveErrors metrics.Meter
+	logger        *logrus.Entry
+}
+
+// WriteMember announces the local member to the cluster.
+// The gossip backend represents a single local member, which is the first member written.
+func (b *gossipBackend) WriteMember(m *member) error {
+
+	b.mutex.Lock()
+
+	if b.self == nil {
+		b.self = &gossipPeer{
+			member:  &member{MemberIP: m.MemberIP, MemberPort: m.MemberPort},
+			address: &net.UDPAddr{IP: m.MemberIP, Port: b.port},
+			state:   gossipAlive,
+			changed: time.Now(),
+		}
+	} else if b.self.member.ID() != m.ID() {
+		b.mutex.Unlock()
+		return fmt.Errorf("Member %v is not the local member %v", m.ID(), b.self.member.ID())
+	} else if b.self.state == gossipLeft {
+		// Rejoin with a newer incarnation, overriding our departure
+		b.self.incarnation++
+		b.self.state = gossipAlive
+		b.self.changed = time.Now()
+	} else {
+		b.mutex.Unlock()
+		return nil
+	}
+
+	b.enqueue(b.self.update())
+	join := &gossipMessage{Type: gossipJoin, Updates: []*gossipUpdate{b.self.update()}}
+	b.mutex.Unlock()
+
+	b.logger.Infof("Joining gossip cluster as %v", m.ID())
+	b.sendToSeeds(join)
+	return nil
+
+}
+
+// DeleteMember announces the departure of the local member, or declares another member dead.
+func (b *gossipBackend) DeleteMember(id MemberID) error {
+
+	b.mutex.Lock()
+
+	if b.self != nil && b.self.member.ID() == id {
+		if b.self.state == gossipLeft {
+			b.mutex.Unlock()
+			return fmt.Errorf("Member %v does not exist", id)
+		}
+
+		b.self.incarnation++
+		b.self.state = gossipLeft
+		b.self.changed = time.Now()
+
+		// Notify the live members right away, rather than waiting for the next probes
+		leave := &gossipMessage{Type: gossipPush, Updates: []*gossipUpdate{b.self.update()}}
+		addrs := make([]*net.UDPAddr, 0, len(b.peers))
+		for _, p := range b.peers {
+			if p.live() {
+				addrs = append(addrs, p.address)
+			}
+		}
+		b.mutex.Unlock()
+
+		b.logger.Infof("Leaving gossip cluster as %v", id)
+		for _, addr := range addrs {
+			b.send(addr, leave)
+		}
+		return nil
+	}
+
+	defer b.mutex.Unlock()
+
+	p, exists := b.peers[id]
+	if !exists || !p.live() {
+		return fmt.Errorf("Member %v does not exist", id)
+	}
+
+	p.state = gossipDead
+	p.changed = time.Now()
+	b.enqueue(p.update())
+	return nil
+
+}
+
+// ReadMember returns the identified member if it is alive or suspected, or nil otherwise.
+// Failure detection is carried out by the gossip protocol, so live members are always reported as freshly renewed.
+func (b *gossipBackend) ReadMember(id MemberID) (*member, error) {
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.liveMembers(time.Now())[id], nil
+
+}
+
+func (b *gossipBackend) ReadMembers() (map[MemberID]*member, error) {
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.liveMembers(time.Now()), nil
+
+}
+
+func (b *gossipBackend) ReadMemberIDs() (map[MemberID]struct{}, error) {
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	members := b.liveMembers(time.Now())
+	ids := make(map[MemberID]struct{}, len(members))
+	for id := range members {
+		ids[id] = struct{}{}
+	}
+	return ids, nil
+
+}
+
+// address returns the local address on which gossip messages are received
+func (b *gossipBackend) address() *net.UDPAddr {
+	return b.conn.LocalAddr().(*net.UDPAddr)
+}
+
+// close stops participating in the gossip protocol, without notifying other members
+func (b *gossipBackend) close() {
+	b.closeOnce.Do(func() {
+		close(b.done)
+		b.conn.Close()
+	})
+}
+
+// liveMembers must be called with the mutex held
+func (b *gossipBackend) liveMembers(now time.Time) map[MemberID]*member {
+	members := make(map[MemberID]*member, len(b.peers)+1)
+	if b.self != nil && b.self.live() {
+		members[b.self.member.ID()] = &member{MemberIP: b.self.member.MemberIP, MemberPort: b.self.member.MemberPort, Timestamp: now}
+	}
+	for id, p := range b.peers {
+		if p.live() {
+			members[id] = &member{MemberIP: p.member.MemberIP, MemberPort: p.member.MemberPort, Timestamp: now}
+		}
+	}
+	return members
+}
+
+func (b *gossipBackend) receive() {
+	buf := make([]byte, gossipMaxPacketSize)
+	for {
+		n, from, err := b.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-b.done:
+				return
+			default:
+			}
+			b.receiveErrors.Mark(1)
+			b.logger.WithField("error", err).Warning("Error receiving gossip message")
+			continue
+		}
+
+		msg := &gossipMessage{}
+		if err = json.Unmarshal(buf[:n], msg); err != nil {
+			b.receiveErrors.Mark(1)
+			b.logger.WithField("error", err).Warningf("Error decoding gossip message from %v", from)
+			continue
+		}
+
+		b.handle(msg, from)
+	}
+}
+
+func (b *gossipBackend) handle(msg *gossipMessage, from *net.UDPAddr) {
+	b.applyUpdates(msg.Updates)
+
+	switch msg.Type {
+	case gossipPing:
+		b.send(from, &gossipMessage{Type: gossipAck, Seq: msg.Seq})
+	case gossipAck:
+		b.mutex.Lock()
+		callback := b.acks[msg.Seq]
+		delete(b.acks, msg.Seq)
+		b.mutex.Unlock()
+		if callback != nil {
+			callback()
+		}
+	case gossipPingReq:
+		target, err := net.ResolveUDPAddr("udp", msg.Target)
+		if err != nil {
+			b.logger.WithField("error", err).Warningf("Error resolving indirect probe target %v", msg.Target)
+			return
+		}
+		seq := b.expectAck(func() {
+			b.send(from, &gossipMessage{Type: gossipAck, Seq: msg.Seq})
+		})
+		time.AfterFunc(b.probeInterval, func() { b.cancelAck(seq) })
+		b.send(target, &gossipMessage{Type: gossipPing, Seq: seq})
+	case gossipJoin:
+		b.mutex.Lock()
+		updates := make([]*gossipUpdate, 0, len(b.peers)+1)
+		if b.self != nil {
+			updates = append(updates, b.self.update())
+		}
+		for _, p := range b.peers {
+			updates = append(updates, p.update())
+		}
+		b.mutex.Unlock()
+		b.send(from, &gossipMessage{Type: gossipPush, Updates: updates})
+	case gossipPush:
+		// Updates already applied
+	default:
+		b.logger.Warningf("Unknown gossip message type %q from %v", msg.Type, from)
+	}
+}
+
+func (b *gossipBackend) applyUpdates(updates []*gossipUpdate) {
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := time.Now()
+	for _, u := range updates {
+		b.applyUpdate(u, now)
+	}
+
+}
+
+// applyUpdate must be called with the mutex held
+func (b *gossipBackend) applyUpdate(u *gossipUpdate, now time.Time) {
+	id := u.id()
+
+	if b.self != nil && b.self.member.ID() == id {
+		if b.self.state == gossipLeft {
+			return
+		}
+		// Refute any suspicion about ourselves (or a stale incarnation of ourselves) by reincarnating
+		if u.Incarnation > b.self.incarnation || (u.Incarnation == b.self.incarnation && u.State != gossipAlive) {
+			b.logger.Infof("Refuting %v state of the local member %v at incarnation %d", u.State, id, u.Incarnation)
+			b.self.incarnation = u.Incarnation + 1
+			b.self.changed = now
+			b.enqueue(b.self.update())
+		}
+		return
+	}
+
+	p, exists := b.peers[id]
+	if exists && u.Incarnation < p.incarnation {
+		return
+	}
+	if exists && u.Incarnation == p.incarnation && u.State <= p.state {
+		return
+	}
+
+	addr, err := net.ResolveUDPAddr("udp", u.Address)
+	if err != nil {
+		b.logger.WithField("error", err).Warningf("Error resolving gossip address %v of member %v", u.Address, id)
+		return
+	}
+
+	if !exists {
+		p = &gossipPeer{member: &member{MemberIP: u.IP, MemberPort: u.Port}}
+		b.peers[id] = p
+	}
+
+	b.logger.Debugf("Member %v is %v at incarnation %d", id, u.State, u.Incarnation)
+
+	p.address = addr
+	p.incarnation = u.Incarnation
+	p.state = u.State
+	p.changed = now
+	b.enqueue(p.update())
+}
+
+// enqueue must be called with the mutex held
+func (b *gossipBackend) enqueue(u *gossipUpdate) {
+	id := u.id()
+	for i, bc := range b.broadcasts {
+		if bc.update.id() == id {
+			b.broadcasts = append(b.broadcasts[:i], b.broadcasts[i+1:]...)
+			break
+		}
+	}
+	b.broadcasts = append(b.broadcasts, &gossipBroadcast{update: u})
+}
+
+// piggyback selects the least transmitted updates, and retires those transmitted often enough
+// to have reached every member with high probability.
+// piggyback must be called with the mutex held
+func (b *gossipBackend) piggyback() []*gossipUpdate {
+	if len(b.broadcasts) == 0 {
+		return nil
+	}
+
+	n := 1
+	for _, p := range b.peers {
+		if p.live() {
+			n++
+		}
+	}
+	limit := gossipRetransmitMult * int(math.Ceil(math.Log10(float64(n+1))))
+
+	sort.SliceStable(b.broadcasts, func(i, j int) bool {
+		return b.broadcasts[i].transmits < b.broadcasts[j].transmits
+	})
+
+	count := len(b.broadcasts)
+	if count > gossipMaxPiggyback {
+		count = gossipMaxPiggyback
+	}
+
+	updates := make([]*gossipUpdate, 0, count)
+	remaining := b.broadcasts[:0]
+	for i, bc := range b.broadcasts {
+		if i < count {
+			updates = append(updates, bc.update)
+			bc.transmits++
+		}
+		if bc.transmits < limit {
+			remaining = append(remaining, bc)
+		}
+	}
+	b.broadcasts = remaining
+
+	return updates
+}
+
+func (b *gossipBackend) send(addr *net.UDPAddr, msg *gossipMessage) {
+	if len(msg.Updates) == 0 {
+		b.mutex.Lock()
+		msg = &gossipMessage{Type: msg.Type, Seq: msg.Seq, Target: msg.Target, Updates: b.piggyback()}
+		b.mutex.Unlock()
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		b.sendErrors.Mark(1)
+		b.logger.WithField("error", err).Warningf("Error encoding gossip %s message", msg.Type)
+		return
+	}
+
+	if _, err = b.conn.WriteToUDP(data, addr); err != nil {
+		b.sendErrors.Mark(1)
+		b.logger.WithField("error", err).Debugf("Error sending gossip %s message to %v", msg.Type, addr)
+	}
+}
+
+func (b *gossipBackend) sendToSeeds(msg *gossipMessage) {
+	for _, seed := range b.seeds {
+		addr, err := net.ResolveUDPAddr("udp", seed)
+		if err != nil {
+			b.logger.WithField("error", err).Warningf("Error resolving gossip seed %v", seed)
+			continue
+		}
+		b.send(addr, msg)
+	}
+}
+
+func (b *gossipBackend) expectAck(callback func()) uint64 {
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.seq++
+	b.acks[b.seq] = callback
+	return b.seq
+
+}
+
+func (b *gossipBackend) cancelAck(seq uint64) {
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	delete(b.acks, seq)
+
+}
+
+func (b *gossipBackend) probePeriodically() {
+	ticker := time.NewTicker(b.probeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			b.tick()
+		case <-b.done:
+			return
+		}
+	}
+}
+
+func (b *gossipBackend) tick() {
+	now := time.Now()
+
+	b.mutex.Lock()
+
+	lonely := true
+	for id, p := range b.peers {
+		switch {
+		case p.state == gossipSuspect && now.Sub(p.changed) >= b.suspicionTimeout:
+			b.logger.Infof("Member %v failed to refute suspicion, declaring it dead", id)
+			p.state = gossipDead
+			p.changed = now
+			b.enqueue(p.update())
+		case !p.live() && now.Sub(p.changed) >= b.reclaimTimeout:
+			delete(b.peers, id)
+		case p.live():
+			lonely = false
+		}
+	}
+
+	joined := b.self != nil && b.self.state != gossipLeft
+	var join *gossipMessage
+	if joined && lonely {
+		join = &gossipMessage{Type: gossipJoin, Updates: []*gossipUpdate{b.self.update()}}
+	}
+
+	b.mutex.Unlock()
+
+	if !joined {
+		return
+	}
+
+	// Keep contacting the seeds until some other member is known
+	if join != nil {
+		b.sendToSeeds(join)
+		return
+	}
+
+	if id, addr, incarnation, ok := b.nextProbeTarget(); ok {
+		b.probe(id, addr, incarnation)
+	}
+}
+
+// nextProbeTarget selects live members in a randomized round-robin order
+func (b *gossipBackend) nextProbeTarget() (MemberID, *net.UDPAddr, uint64, bool) {
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for i := 0; i <= len(b.peers); i++ {
+		if b.probeIndex >= len(b.probeOrder) {
+			b.probeOrder = b.probeOrder[:0]
+			for id, p := range b.peers {
+				if p.live() {
+					b.probeOrder = append(b.probeOrder, id)
+				}
+			}
+			for j := range b.probeOrder {
+				k := rand.Intn(j + 1)
+				b.probeOrder[j], b.probeOrder[k] = b.probeOrder[k], b.probeOrder[j]
+			}
+			b.probeIndex = 0
+			if len(b.probeOrder) == 0 {
+				return "", nil, 0, false
+			}
+		}
+
+		id := b.probeOrder[b.probeIndex]
+		b.probeIndex++
+		if p, exists := b.peers[id]; exists && p.live() {
+			return id, p.address, p.incarnation, true
+		}
+	}
+
+	return "", nil, 0, false
+
+}
+
+// probe pings the member directly, and then indirectly through other members,
+// suspecting it if no acknowledgement arrives within the probe interval
+func (b *gossipBackend) probe(id MemberID, addr *net.UDPAddr, incarnation uint64) {
+	acked := make(chan struct{}, 1)
+	seq := b.expectAck(func() {
+		select {
+		case acked <- struct{}{}:
+		default:
+		}
+	})
+	defer b.cancelAck(seq)
+
+	b.send(addr, &gossipMessage{Type: gossipPing, Seq: seq})
+
+	select {
+	case <-acked:
+		return
+	case <-b.done:
+		return
+	case <-time.After(b.probeTimeout):
+	}
+
+	for _, relay := range b.randomLivePeers(gossipIndirectChecks, id) {
+		b.send(relay, &gossipMessage{Type: gossipPingReq, Seq: seq, Target: addr.String()})
+	}
+
+	select {
+	case <-acked:
+		return
+	case <-b.done:
+		return
+	case <-time.After(b.probeInterval - b.probeTimeout):
+	}
+
+	b.suspect(id, incarnation)
+}
+
+func (b *gossipBackend) randomLivePeers(count int, exclude MemberID) []*net.UDPAddr {
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	addrs := make([]*net.UDPAddr, 0, len(b.peers))
+	for id, p := range b.peers {
+		if id != exclude && p.live() {
+			addrs = append(addrs, p.address)
+		}
+	}
+	for i := range addrs {
+		j := rand.Intn(i + 1)
+		addrs[i], addrs[j] = addrs[j], addrs[i]
+	}
+	if len(addrs) > count {
+		addrs = addrs[:count]
+	}
+	return addrs
+
+}
+
+func (b *gossipBackend) suspect(id MemberID, incarnation uint64) {
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	p, exists := b.peers[id]
+	if !exists || p.state != gossipAlive || p.incarnation != incarnation {
+		return
+	}
+
+	b.logger.Infof("Member %v failed to respond to probes, suspecting it", id)
+	p.state = gossipSuspect
+	p.changed = time.Now()
+	b.enqueue(p.update())
+
+}
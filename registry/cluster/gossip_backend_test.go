@@ -0,0 +1,271 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package cluster
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testProbeInterval = time.Duration(20) * time.Millisecond
+	testGossipTimeout = time.Duration(5) * time.Second
+)
+
+var loopback = net.ParseIP("127.0.0.1")
+
+// startGossipBackends starts a gossip backend per member on loopback, seeded by the first backend
+func startGossipBackends(t *testing.T, members ...*member) []*gossipBackend {
+	backends := make([]*gossipBackend, len(members))
+	for i, m := range members {
+		conf := &gossipConfig{bindAddress: "127.0.0.1:0", probeInterval: testProbeInterval}
+		if i > 0 {
+			conf.seeds = []string{backends[0].address().String()}
+		}
+		b, err := newGossipBackend(conf)
+		require.NoError(t, err)
+		require.NoError(t, b.WriteMember(m))
+		backends[i] = b
+	}
+	return backends
+}
+
+func closeGossipBackends(backends []*gossipBackend) {
+	for _, b := range backends {
+		b.close()
+	}
+}
+
+// awaitMembers waits until each backend reflects exactly the expected members
+func awaitMembers(t *testing.T, backends []*gossipBackend, expected ...*member) bool {
+	deadline := time.Now().Add(testGossipTimeout)
+	for {
+		converged := true
+		for _, b := range backends {
+			ids, err := b.ReadMemberIDs()
+			assert.NoError(t, err)
+			if len(ids) != len(expected) {
+				converged = false
+				break
+			}
+			for _, m := range expected {
+				if _, exists := ids[m.ID()]; !exists {
+					converged = false
+				}
+			}
+		}
+		if converged {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return assert.Fail(t, "Gossip members did not converge", "expected %d members", len(expected))
+		}
+		time.Sleep(testProbeInterval)
+	}
+}
+
+func newLoopbackMember(port uint16) *member {
+	return &member{MemberIP: loopback, MemberPort: port, Timestamp: time.Now()}
+}
+
+func TestGossipBackendJoinThroughSeed(t *testing.T) {
+	m1, m2, m3 := newLoopbackMember(7101), newLoopbackMember(7102), newLoopbackMember(7103)
+	backends := startGossipBackends(t, m1, m2, m3)
+	defer closeGossipBackends(backends)
+
+	awaitMembers(t, backends, m1, m2, m3)
+
+	m, err := backends[2].ReadMember(m1.ID())
+	assert.NoError(t, err)
+	if assert.NotNil(t, m) {
+		assert.Equal(t, m1.ID(), m.ID())
+		assert.True(t, time.Since(m.Timestamp) < time.Second)
+	}
+}
+
+func TestGossipBackendDetectsFailedMember(t *testing.T) {
+	m1, m2, m3 := newLoopbackMember(7111), newLoopbackMember(7112), newLoopbackMember(7113)
+	backends := startGossipBackends(t, m1, m2, m3)
+	defer closeGossipBackends(backends)
+
+	require.True(t, awaitMembers(t, backends, m1, m2, m3))
+
+	backends[2].close()
+	awaitMembers(t, backends[:2], m1, m2)
+}
+
+func TestGossipBackendLeaveAndRejoin(t *testing.T) {
+	m1, m2, m3 := newLoopbackMember(7121), newLoopbackMember(7122), newLoopbackMember(7123)
+	backends := startGossipBackends(t, m1, m2, m3)
+	defer closeGossipBackends(backends)
+
+	require.True(t, awaitMembers(t, backends, m1, m2, m3))
+
+	assert.NoError(t, backends[2].DeleteMember(m3.ID()))
+	assert.Error(t, backends[2].DeleteMember(m3.ID()))
+	awaitMembers(t, backends[:2], m1, m2)
+
+	ids, err := backends[2].ReadMemberIDs()
+	assert.NoError(t, err)
+	assert.NotContains(t, ids, m3.ID())
+
+	assert.NoError(t, backends[2].WriteMember(m3))
+	awaitMembers(t, backends, m1, m2, m3)
+}
+
+func TestGossipBackendRefutesSuspicion(t *testing.T) {
+	m1, m2 := newLoopbackMember(7131), newLoopbackMember(7132)
+	backends := startGossipBackends(t, m1, m2)
+	defer closeGossipBackends(backends)
+
+	require.True(t, awaitMembers(t, backends, m1, m2))
+
+	// Member 2 wrongly suspects member 1, which should reincarnate and remain a member
+	backends[1].applyUpdates([]*gossipUpdate{{
+		IP:          m1.MemberIP,
+		Port:        m1.MemberPort,
+		Address:     backends[0].address().String(),
+		Incarnation: 0,
+		State:       gossipSuspect,
+	}})
+
+	deadline := time.Now().Add(testGossipTimeout)
+	for {
+		backends[1].mutex.Lock()
+		state, incarnation := backends[1].peers[m1.ID()].state, backends[1].peers[m1.ID()].incarnation
+		backends[1].mutex.Unlock()
+		if state == gossipAlive && incarnation > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			assert.Fail(t, "Suspicion was not refuted", "member 1 is %v at incarnation %d", state, incarnation)
+			break
+		}
+		time.Sleep(testProbeInterval)
+	}
+
+	awaitMembers(t, backends, m1, m2)
+}
+
+func TestGossipBackendSingleLocalMember(t *testing.T) {
+	b, err := newGossipBackend(&gossipConfig{bindAddress: "127.0.0.1:0", probeInterval: testProbeInterval})
+	require.NoError(t, err)
+	defer b.close()
+
+	ids, err := b.ReadMemberIDs()
+	assert.NoError(t, err)
+	assert.Empty(t, ids)
+
+	assert.NoError(t, b.WriteMember(member1))
+	assert.NoError(t, b.WriteMember(member1))
+	assert.Error(t, b.WriteMember(member2))
+	assert.Error(t, b.DeleteMember(member2.ID()))
+
+	ids, err = b.ReadMemberIDs()
+	assert.NoError(t, err)
+	assert.Len(t, ids, 1)
+	assert.Contains(t, ids, member1.ID())
+}
+
+type recordingListener struct {
+	joined map[MemberID]struct{}
+	left   map[MemberID]struct{}
+	mutex  sync.Mutex
+}
+
+func newRecordingListener() *recordingListener {
+	return &recordingListener{joined: make(map[MemberID]struct{}), left: make(map[MemberID]struct{})}
+}
+
+func (l *recordingListener) OnJoin(m Member) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.joined[m.ID()] = struct{}{}
+}
+
+func (l *recordingListener) OnLeave(m Member) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.left[m.ID()] = struct{}{}
+}
+
+func (l *recordingListener) await(t *testing.T, joined bool, id MemberID) {
+	deadline := time.Now().Add(testGossipTimeout)
+	for {
+		l.mutex.Lock()
+		_, exists := l.left[id]
+		if joined {
+			_, exists = l.joined[id]
+		}
+		l.mutex.Unlock()
+		if exists {
+			return
+		}
+		if time.Now().After(deadline) {
+			assert.Fail(t, "Missing membership notification", "member %v (joined: %v)", id, joined)
+			return
+		}
+		time.Sleep(testProbeInterval)
+	}
+}
+
+func TestGossipClusterNotifiesListeners(t *testing.T) {
+	port1, port2 := freeUDPPort(t), freeUDPPort(t)
+
+	c1, err := New(&Config{
+		BackendType:   GossipBackend,
+		GossipPort:    port1,
+		ProbeInterval: testProbeInterval,
+		ScanInterval:  testInterval,
+	})
+	require.NoError(t, err)
+	defer c1.(*cluster).backend.(*gossipBackend).close()
+
+	c2, err := New(&Config{
+		BackendType:   GossipBackend,
+		GossipPort:    port2,
+		Seeds:         []string{(&net.UDPAddr{IP: loopback, Port: int(port1)}).String()},
+		ProbeInterval: testProbeInterval,
+		ScanInterval:  testInterval,
+	})
+	require.NoError(t, err)
+	backend2 := c2.(*cluster).backend.(*gossipBackend)
+	defer backend2.close()
+
+	listener := newRecordingListener()
+	c1.Membership().RegisterListener(listener)
+
+	self1, self2 := NewMember(loopback, 7141), NewMember(loopback, 7142)
+	require.NoError(t, c1.Registrator(self1).Join())
+	require.NoError(t, c2.Registrator(self2).Join())
+
+	listener.await(t, true, self2.ID())
+
+	// Fail the second registry without leaving
+	backend2.close()
+	listener.await(t, false, self2.ID())
+}
+
+func freeUDPPort(t *testing.T) uint16 {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: loopback})
+	require.NoError(t, err)
+	defer conn.Close()
+	return uint16(conn.LocalAddr().(*net.UDPAddr).Port)
+}
@@ -35,8 +35,11 @@ type Values struct {
 	Replication bool
 	SyncTimeout time.Duration
 
-	ClusterDirectory string
-	ClusterSize      int
+	ClusterBackend    string
+	ClusterDirectory  string
+	ClusterSize       int
+	ClusterGossipPort uint16
+	ClusterSeeds      []string
 
 	NamespaceCapacity int
 	DefaultTTL        time.Duration
@@ -48,11 +51,18 @@ type Values struct {
 
 	EurekaURLs []string
 
+	ConsulURL        string
+	ConsulToken      string
+	ConsulDatacenter string
+
 	FSCatalog string
 
 	Store         string
 	StoreAddr     string
 	StorePassword string
+
+	StoreDir              string
+	StoreSnapshotInterval time.Duration
 }
 
 // NewValuesFromContext creates a Config instance from the given CLI context
@@ -71,8 +81,11 @@ func NewValuesFromContext(context *cli.Context) *Values {
 		Replication: context.Bool(ReplicationFlag),
 		SyncTimeout: context.Duration(SyncTimeoutFlag),
 
-		ClusterDirectory: context.String(ClusterDirectoryFlag),
-		ClusterSize:      context.Int(ClusterSizeFlag),
+		ClusterBackend:    context.String(ClusterBackendFlag),
+		ClusterDirectory:  context.String(ClusterDirectoryFlag),
+		ClusterSize:       context.Int(ClusterSizeFlag),
+		ClusterGossipPort: uint16(context.Int(ClusterGossipPortFlag)),
+		ClusterSeeds:      context.StringSlice(ClusterSeedsFlag),
 
 		NamespaceCapacity: context.Int(NamespaceCapacityFlag),
 		DefaultTTL:        context.Duration(DefaultTTLFlag),
@@ -84,10 +97,17 @@ func NewValuesFromContext(context *cli.Context) *Values {
 
 		EurekaURLs: context.StringSlice(EurekaURLsFlag),
 
+		ConsulURL:        context.String(ConsulURLFlag),
+		ConsulToken:      context.String(ConsulTokenFlag),
+		ConsulDatacenter: context.String(ConsulDatacenterFlag),
+
 		FSCatalog: context.String(FSCatalogFlag),
 
 		Store:         context.String(StoreFlag),
 		StoreAddr:     context.String(StoreAddrFlag),
 		StorePassword: context.String(StorePasswordFlag),
+
+		StoreDir:              context.String(StoreDirFlag),
+		StoreSnapshotInterval: context.Duration(StoreSnapshotIntervalFlag),
 	}
 }
@@ -37,8 +37,11 @@ const (
 	ReplicationFlag = "replication"
 	SyncTimeoutFlag = "sync_timeout"
 
-	ClusterDirectoryFlag = "cluster_dir"
-	ClusterSizeFlag      = "cluster_size"
+	ClusterBackendFlag    = "cluster_backend"
+	ClusterDirectoryFlag  = "cluster_dir"
+	ClusterSizeFlag       = "cluster_size"
+	ClusterGossipPortFlag = "cluster_gossip_port"
+	ClusterSeedsFlag      = "cluster_seeds"
 
 	NamespaceCapacityFlag = "namespace_capacity"
 	DefaultTTLFlag        = "default_ttl"
@@ -50,11 +53,18 @@ const (
 
 	EurekaURLsFlag = "eureka_url"
 
+	ConsulURLFlag        = "consul_url"
+	ConsulTokenFlag      = "consul_token"
+	ConsulDatacenterFlag = "consul_datacenter"
+
 	FSCatalogFlag = "fs_catalog"
 
 	StoreFlag         = "store"
 	StoreAddrFlag     = "store_address"
 	StorePasswordFlag = "store_password"
+
+	StoreDirFlag              = "store_dir"
+	StoreSnapshotIntervalFlag = "store_snapshot_interval"
 )
 
 // Flags represents the set of supported flags
@@ -119,6 +129,13 @@ var Flags = []cli.Flag{
 		Usage:  "Registry timeout for establishing peer synchronization connection",
 	},
 
+	cli.StringFlag{
+		Name:   ClusterBackendFlag,
+		EnvVar: envVarFromFlag(ClusterBackendFlag),
+		Value:  "filesystem",
+		Usage:  "Cluster membership backend. Supported values are: 'filesystem', 'gossip'",
+	},
+
 	cli.StringFlag{
 		Name:   ClusterDirectoryFlag,
 		EnvVar: envVarFromFlag(ClusterDirectoryFlag),
@@ -133,6 +150,19 @@ var Flags = []cli.Flag{
 		Usage:  "Cluster minimal healthy size",
 	},
 
+	cli.IntFlag{
+		Name:   ClusterGossipPortFlag,
+		EnvVar: envVarFromFlag(ClusterGossipPortFlag),
+		Value:  cluster.DefaultGossipPort,
+		Usage:  "UDP port number for gossip cluster membership",
+	},
+
+	cli.StringSliceFlag{
+		Name:   ClusterSeedsFlag,
+		EnvVar: envVarFromFlag(ClusterSeedsFlag),
+		Usage:  "Gossip addresses (host:port) of cluster members to join through",
+	},
+
 	cli.DurationFlag{
 		Name:   DefaultTTLFlag,
 		EnvVar: envVarFromFlag(DefaultTTLFlag),
@@ -179,6 +209,24 @@ var Flags = []cli.Flag{
 		Usage:  "Enable eureka catalog and specify the eureka API server URLs",
 	},
 
+	cli.StringFlag{
+		Name:   ConsulURLFlag,
+		EnvVar: envVarFromFlag(ConsulURLFlag),
+		Usage:  "Enable consul catalog and specify the consul HTTP API URL",
+	},
+
+	cli.StringFlag{
+		Name:   ConsulTokenFlag,
+		EnvVar: envVarFromFlag(ConsulTokenFlag),
+		Usage:  "Consul ACL token",
+	},
+
+	cli.StringFlag{
+		Name:   ConsulDatacenterFlag,
+		EnvVar: envVarFromFlag(ConsulDatacenterFlag),
+		Usage:  "Consul datacenter to query (defaults to the datacenter of the consul agent)",
+	},
+
 	cli.StringFlag{
 		Name:   FSCatalogFlag,
 		EnvVar: envVarFromFlag(FSCatalogFlag),
@@ -189,7 +237,7 @@ var Flags = []cli.Flag{
 		Name:   StoreFlag,
 		EnvVar: envVarFromFlag(StoreFlag),
 		Value:  "inmem",
-		Usage:  "Backing store. Supported values are: 'inmem', 'redis'",
+		Usage:  "Backing store. Supported values are: 'inmem', 'redis', 'persistent'",
 	},
 
 	cli.StringFlag{
@@ -204,6 +252,19 @@ var Flags = []cli.Flag{
 		Value:  "",
 		Usage:  "Store password",
 	},
+
+	cli.StringFlag{
+		Name:   StoreDirFlag,
+		EnvVar: envVarFromFlag(StoreDirFlag),
+		Usage:  "Directory of the persistent store",
+	},
+
+	cli.DurationFlag{
+		Name:   StoreSnapshotIntervalFlag,
+		EnvVar: envVarFromFlag(StoreSnapshotIntervalFlag),
+		Value:  time.Duration(5) * time.Minute,
+		Usage:  "Interval between snapshots of the persistent store",
+	},
 }
 
 // envVarFromFlag returns the environment variable bound to the given flag
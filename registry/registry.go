@@ -23,6 +23,7 @@ import (
 
 	"github.com/amalgam8/amalgam8/pkg/auth"
 	"github.com/amalgam8/amalgam8/pkg/version"
+	"github.com/amalgam8/amalgam8/registry/adapters/consul"
 	"github.com/amalgam8/amalgam8/registry/adapters/filesystem"
 	"github.com/amalgam8/amalgam8/registry/adapters/kubernetes"
 	"github.com/amalgam8/amalgam8/registry/api"
@@ -85,6 +86,13 @@ func Run(conf *config.Values) error {
 		}
 	}
 
+	// Persistent store requires a directory
+	if conf.Store == "persistent" {
+		if conf.StoreDir == "" {
+			return fmt.Errorf("Directory required for persistent store")
+		}
+	}
+
 	var rep replication.Replication
 
 	// Don't need replication if using a store that's not in memory
@@ -100,9 +108,18 @@ func Run(conf *config.Values) error {
 
 			// Configure and create the cluster module
 			clConfig := &cluster.Config{
-				BackendType: cluster.FilesystemBackend,
-				Directory:   conf.ClusterDirectory,
-				Size:        conf.ClusterSize,
+				Directory:  conf.ClusterDirectory,
+				Size:       conf.ClusterSize,
+				GossipPort: conf.ClusterGossipPort,
+				Seeds:      conf.ClusterSeeds,
+			}
+			switch conf.ClusterBackend {
+			case "filesystem":
+				clConfig.BackendType = cluster.FilesystemBackend
+			case "gossip":
+				clConfig.BackendType = cluster.GossipBackend
+			default:
+				return fmt.Errorf("Unsupported cluster backend %q", conf.ClusterBackend)
 			}
 			cl, err := cluster.New(clConfig)
 			if err != nil {
@@ -169,6 +186,19 @@ func Run(conf *config.Values) error {
 		catalogsExt = append(catalogsExt, eurekaFactory)
 	}
 
+	// See whether consul adapter is enabled
+	if conf.ConsulURL != "" {
+		consulFactory, err := consul.NewCatalogFactory(consul.Config{
+			URL:        conf.ConsulURL,
+			Token:      conf.ConsulToken,
+			Datacenter: conf.ConsulDatacenter,
+		})
+		if err != nil {
+			return err
+		}
+		catalogsExt = append(catalogsExt, consulFactory)
+	}
+
 	// See whether Filesystem adapter is enabled
 	if conf.FSCatalog != "" {
 		fsFactory := store.NewDiscoveryAdapter(func(namespace auth.Namespace) (api.ServiceDiscovery, error) {
@@ -191,8 +221,13 @@ func Run(conf *config.Values) error {
 		Store:             conf.Store,
 		StoreAddr:         conf.StoreAddr,
 		StorePassword:     conf.StorePassword,
+		StoreDir:          conf.StoreDir,
+		SnapshotInterval:  conf.StoreSnapshotInterval,
+	}
+	cm, err := store.Open(cmConfig)
+	if err != nil {
+		return err
 	}
-	cm := store.New(cmConfig)
 
 	serverConfig := &server.Config{
 		HTTPAddressSpec: fmt.Sprintf(":%d", conf.APIPort),
@@ -311,6 +311,64 @@ func TestSynchronization(t *testing.T) {
 	}
 }
 
+func TestGossipReplication(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	port1 := nextPort()
+	port2 := nextPort()
+
+	// Each registry runs its own gossip membership, joining through the first one
+	cl1, err := cluster.New(&cluster.Config{
+		BackendType:   cluster.GossipBackend,
+		GossipPort:    port1 + 2000,
+		ProbeInterval: time.Duration(100) * time.Millisecond,
+		ScanInterval:  time.Duration(100) * time.Millisecond,
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, cl1)
+
+	cl2, err := cluster.New(&cluster.Config{
+		BackendType:   cluster.GossipBackend,
+		GossipPort:    port2 + 2000,
+		Seeds:         []string{fmt.Sprintf("127.0.0.1:%d", port1+2000)},
+		ProbeInterval: time.Duration(100) * time.Millisecond,
+		ScanInterval:  time.Duration(100) * time.Millisecond,
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, cl2)
+
+	rep1, server1 := setupServer(t, port1, port1+1000, cl1)
+	defer func() {
+		server1.Stop()
+		rep1.Stop()
+	}()
+
+	rep2, server2 := setupServer(t, port2, port2+1000, cl2)
+	defer func() {
+		server2.Stop()
+		rep2.Stop()
+	}()
+
+	// Let the members discover each other
+	time.Sleep(time.Duration(1) * time.Second)
+
+	ids := register(t, port1)
+
+	// Let a few milliseconds for the replication
+	time.Sleep(time.Duration(1) * time.Second)
+
+	assert.EqualValues(t, len(instances), list(t, port2))
+	for _, inst := range instances {
+		assert.EqualValues(t, 1, lookup(t, port2, inst.ServiceName))
+	}
+
+	for _, id := range ids {
+		renew(t, port2, id)
+	}
+}
+
 func nextPort() uint16 {
 	// Note: must use this to get new port for each server created
 	// otherwise there is race conditions causing unknown state of the system
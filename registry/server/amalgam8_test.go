@@ -19,8 +19,10 @@ package server
 import (
 	"bytes"
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"reflect"
 	"testing"
 	"time"
@@ -590,6 +592,121 @@ func TestServicesList(t *testing.T) {
 	}
 }
 
+//--------
+// events
+//--------
+// /events: watch instances
+func TestEventsWatch(t *testing.T) {
+	cases := []struct {
+		query    string // input query string
+		expected int    // expected result
+		index    uint64 // expected index
+		events   int    // expected number of events
+	}{
+		{"", http.StatusOK, 2, 0},
+		{"?index=1&timeout=0", http.StatusOK, 2, 1},
+		{"?index=2", http.StatusOK, 2, 0},
+		{"?index=3", http.StatusGone, 0, 0},
+		{"?index=-1", http.StatusBadRequest, 0, 0},
+		{"?index=1&timeout=1s", http.StatusBadRequest, 0, 0},
+		{"?index=1&timeout=3600", http.StatusBadRequest, 0, 0},
+	}
+
+	c := defaultServerConfig()
+	c.CatalogMap.(*mockCatalog).events = []*store.Event{
+		{Index: 1, Type: store.EventRegister, Instance: &instances[0].data},
+		{Index: 2, Type: store.EventDeregister, Instance: &instances[0].data},
+	}
+	handler, err := setupServer(c)
+	assert.Nil(t, err)
+
+	for _, tc := range cases {
+		recorder := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", serverURL+amalgam8.EventsURL()+tc.query, nil)
+		assert.Nil(t, err)
+		handler.ServeHTTP(recorder, req)
+		assert.Equal(t, tc.expected, recorder.Code, tc.query)
+		if tc.expected == http.StatusOK {
+			list := amalgam8.EventsList{}
+
+			err = json.Unmarshal(recorder.Body.Bytes(), &list)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.index, list.Index, tc.query)
+			assert.NotNil(t, list.Events, tc.query)
+			assert.Len(t, list.Events, tc.events, tc.query)
+			for _, event := range list.Events {
+				assert.Equal(t, string(store.EventDeregister), event.Type)
+				assert.Equal(t, instances[0].data.ID, event.Instance.ID)
+				assert.Equal(t, instances[0].data.Endpoint.Value, event.Instance.Endpoint.Value)
+			}
+		}
+	}
+}
+
+func TestSnapshotsNotSupported(t *testing.T) {
+	c := defaultServerConfig()
+	handler, err := setupServer(c)
+	assert.Nil(t, err)
+
+	for _, method := range []string{"GET", "POST"} {
+		recorder := httptest.NewRecorder()
+		req, err := http.NewRequest(method, serverURL+amalgam8.SnapshotsURL(), nil)
+		assert.Nil(t, err)
+		handler.ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusNotImplemented, recorder.Code, method)
+	}
+}
+
+func TestSnapshotsCreateAndRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshots")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	conf := store.NewConfig(time.Minute, time.Second, time.Hour, -1, nil, nil, "persistent", "", "", nil)
+	conf.StoreDir = dir
+	conf.SnapshotInterval = 0
+	c := defaultServerConfig()
+	c.CatalogMap = store.New(conf)
+	handler, err := setupServer(c)
+	assert.Nil(t, err)
+
+	recorder := httptest.NewRecorder()
+	req, err := http.NewRequest("POST", serverURL+amalgam8.SnapshotsURL(), nil)
+	assert.Nil(t, err)
+	handler.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+	snapshot := amalgam8.Snapshot{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &snapshot))
+	assert.NotEmpty(t, snapshot.ID)
+
+	recorder = httptest.NewRecorder()
+	req, err = http.NewRequest("GET", serverURL+amalgam8.SnapshotsURL(), nil)
+	assert.Nil(t, err)
+	handler.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	list := amalgam8.SnapshotsList{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &list))
+	if assert.Len(t, list.Snapshots, 1) {
+		assert.Equal(t, snapshot.ID, list.Snapshots[0].ID)
+	}
+
+	cases := []struct {
+		id       string
+		expected int
+	}{
+		{snapshot.ID, http.StatusOK},
+		{"12345", http.StatusNotFound},
+		{"latest", http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		recorder = httptest.NewRecorder()
+		req, err = http.NewRequest("POST", serverURL+amalgam8.SnapshotRestoreURL(tc.id), nil)
+		assert.Nil(t, err)
+		handler.ServeHTTP(recorder, req)
+		assert.Equal(t, tc.expected, recorder.Code, tc.id)
+	}
+}
+
 //---------------
 // secure access
 //---------------
@@ -26,11 +26,23 @@ import (
 	"github.com/amalgam8/amalgam8/registry/utils/i18n"
 )
 
+// DefaultPolicy specifies the roles required for the registry APIs. Any role can read service instances, and only
+// registrars can register, renew and deregister them. Only admins can manage snapshots.
+var DefaultPolicy = auth.Policy{
+	{Path: "/api/v1/admin/*", Role: auth.RoleAdmin},
+	{Method: "GET", Path: "*", Role: auth.RoleReader},
+	{Path: "*", Role: auth.RoleRegistrar},
+}
+
 // AuthMiddleware provides a generic authentication middleware
-// On failure, a 401 HTTP response is returned. On success, the wrapped middleware is called.
+// On failure, a 401 HTTP response is returned. If the token does not grant the role required by the policy, a 403
+// HTTP response is returned. On success, the wrapped middleware is called.
 type AuthMiddleware struct {
 	TokenRouteParam string
 	Authenticator   auth.Authenticator
+
+	// Policy enforced on authenticated requests. No roles are required when the policy is nil.
+	Policy auth.Policy
 }
 
 // MiddlewareFunc returns a go-json-rest HTTP Handler function, wrapping calls to the provided HandlerFunc
@@ -66,7 +78,7 @@ func (mw *AuthMiddleware) handler(writer rest.ResponseWriter, request *rest.Requ
 		ctx = context.WithValue(ctxFromEnv.(context.Context), auth.ContextHeadersKey, request.Header)
 	}
 
-	nsPtr, err := mw.Authenticator.Authenticate(ctx, token)
+	nsPtr, roles, err := auth.AuthenticateRoles(ctx, mw.Authenticator, token)
 	if err != nil {
 		switch err {
 		case auth.ErrEmptyToken:
@@ -81,6 +93,17 @@ func (mw *AuthMiddleware) handler(writer rest.ResponseWriter, request *rest.Requ
 		return
 	}
 
+	if mw.Policy != nil {
+		if role := mw.Policy.Role(request.Method, request.URL.Path); !roles.Grants(role) {
+			i18n.Error(request, writer, http.StatusForbidden, i18n.ErrorAuthorizationForbidden, map[string]interface{}{
+				"Role":   role,
+				"Method": request.Method,
+				"Path":   request.URL.Path,
+			})
+			return
+		}
+	}
+
 	request.Env[env.Namespace] = *nsPtr
 	h(writer, request)
 }
@@ -159,3 +159,40 @@ func TestCommunicationError(t *testing.T) {
 
 	assert.Equal(t, http.StatusServiceUnavailable, res.Code)
 }
+
+type mockRoleAuthenticator struct {
+	mockAuthenticator
+	roles auth.Roles
+}
+
+func (ma *mockRoleAuthenticator) AuthenticateRoles(ctx context.Context, token string) (*auth.Namespace, auth.Roles, error) {
+	namespace := auth.NamespaceFrom(token)
+	return &namespace, ma.roles, nil
+}
+
+func TestPolicyEnforced(t *testing.T) {
+	cases := []struct {
+		roles auth.Roles
+		code  int
+	}{
+		{roles: auth.Roles{auth.RoleRegistrar}, code: http.StatusOK},
+		{roles: auth.Roles{auth.RoleAdmin}, code: http.StatusOK},
+		{roles: auth.Roles{auth.RoleReader}, code: http.StatusForbidden},
+		{roles: auth.Roles{}, code: http.StatusForbidden},
+	}
+
+	for _, c := range cases {
+		authMw := &AuthMiddleware{
+			Authenticator: &mockRoleAuthenticator{roles: c.roles},
+			Policy:        auth.Policy{{Path: "*", Role: auth.RoleRegistrar}},
+		}
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://example.com/", nil)
+		req.Header.Set("Authorization", "Bearer "+validToken)
+
+		jrestServer(authMw, "/").ServeHTTP(res, req)
+
+		assert.Equal(t, c.code, res.Code, "roles %v", c.roles)
+	}
+}
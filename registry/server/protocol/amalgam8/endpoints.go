@@ -63,19 +63,50 @@ func serviceInstancesTemplateURL() string {
 	return serviceInstanceTemplate
 }
 
+// EventsURL returns URL path used for watching changes to the instances
+func EventsURL() string {
+	return eventsPath
+}
+
+// SnapshotsURL returns URL path used for creating and listing snapshots of the catalogs
+func SnapshotsURL() string {
+	return snapshotsPath
+}
+
+// SnapshotRestoreURL returns (client side) URL path used for restoring the identified snapshot
+func SnapshotRestoreURL(id string) string {
+	return strings.Join([]string{snapshotsPath, "/", id, restore}, "")
+}
+
+// snapshotRestoreTemplateURL returns router (server side) URL template for restoring a snapshot
+func snapshotRestoreTemplateURL() string {
+	return snapshotRestoreTemplate
+}
+
 // API parameter names
 const (
 	RouteParamServiceName = "sname"
 	RouteParamInstanceID  = "iid"
+	RouteParamSnapshotID  = "snid"
+)
+
+// API query parameter names
+const (
+	QueryParamIndex   = "index"
+	QueryParamTimeout = "timeout"
 )
 
 const ( // API related constants
 	apiPath                   = "/api"
 	apiVer                    = "/v1"
 	heartbeat                 = "/heartbeat"
+	restore                   = "/restore"
 	instancesPath             = apiPath + apiVer + "/instances"
 	servicesPath              = apiPath + apiVer + "/services"
+	eventsPath                = apiPath + apiVer + "/events"
 	instanceTemplate          = instancesPath + "/#" + RouteParamInstanceID
 	instanceHeartbeatTemplate = instanceTemplate + heartbeat
 	serviceInstanceTemplate   = servicesPath + "/#" + RouteParamServiceName
+	snapshotsPath             = apiPath + apiVer + "/admin/snapshots"
+	snapshotRestoreTemplate   = snapshotsPath + "/#" + RouteParamSnapshotID + restore
 )
@@ -0,0 +1,132 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package amalgam8
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/ant0ine/go-json-rest/rest"
+
+	"github.com/amalgam8/amalgam8/registry/server/env"
+	"github.com/amalgam8/amalgam8/registry/store"
+	"github.com/amalgam8/amalgam8/registry/utils/i18n"
+)
+
+const (
+	// DefaultWatchTimeout is the time a watch request waits for events, if no timeout is specified
+	DefaultWatchTimeout = 30 * time.Second
+
+	// MaxWatchTimeout is the longest time a watch request may wait for events
+	MaxWatchTimeout = 5 * time.Minute
+)
+
+// watchInstances returns the events that occurred after the requested index, waiting for one to occur if necessary.
+// Without an index, the current index is returned immediately, so clients can list the instances and then watch
+// for changes from that point on.
+func (routes *Routes) watchInstances(w rest.ResponseWriter, r *rest.Request) {
+	query := r.URL.Query()
+
+	var index uint64
+	if value := query.Get(QueryParamIndex); value != "" {
+		var err error
+		index, err = strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			routes.logger.WithFields(log.Fields{
+				"namespace": r.Env[env.Namespace],
+				"error":     err,
+			}).Warn("Failed to watch instances")
+
+			i18n.Error(r, w, http.StatusBadRequest, i18n.ErrorEventsIndexInvalid)
+			return
+		}
+	}
+
+	timeout := DefaultWatchTimeout
+	if value := query.Get(QueryParamTimeout); value != "" {
+		seconds, err := strconv.ParseUint(value, 10, 32)
+		if err != nil || time.Duration(seconds)*time.Second > MaxWatchTimeout {
+			routes.logger.WithFields(log.Fields{
+				"namespace": r.Env[env.Namespace],
+				"error":     err,
+			}).Warnf("Failed to watch instances with timeout %s", value)
+
+			i18n.Error(r, w, http.StatusBadRequest, i18n.ErrorEventsTimeoutInvalid, int(MaxWatchTimeout/time.Second))
+			return
+		}
+		timeout = time.Duration(seconds) * time.Second
+	}
+
+	catalog := routes.catalog(w, r)
+	if catalog == nil {
+		routes.logger.WithFields(log.Fields{
+			"namespace": r.Env[env.Namespace],
+			"error":     "catalog is nil",
+		}).Error("Failed to watch instances")
+		// error response set by routes.catalog()
+		return
+	}
+
+	events, last, err := catalog.Watch(index, timeout)
+	if err != nil {
+		routes.logger.WithFields(log.Fields{
+			"namespace": r.Env[env.Namespace],
+			"error":     err,
+		}).Warnf("Failed to watch instances from index %d", index)
+
+		id := i18n.ErrorEventsEnumeration
+		if regerr, ok := err.(*store.Error); ok {
+			switch regerr.Code {
+			case store.ErrorWatchNotSupported:
+				id = i18n.ErrorEventsNotSupported
+			case store.ErrorIndexExpired:
+				id = i18n.ErrorEventsIndexExpired
+			}
+		}
+		i18n.Error(r, w, statusCodeFromError(err), id)
+		return
+	}
+
+	list := &EventsList{Index: last, Events: make([]*Event, 0, len(events))}
+	for _, event := range events {
+		inst, err := copyInstanceWithFilter(event.Instance.ServiceName, event.Instance, nil)
+		if err != nil {
+			routes.logger.WithFields(log.Fields{
+				"namespace": r.Env[env.Namespace],
+				"error":     err,
+			}).Warn("Failed to watch instances")
+
+			i18n.Error(r, w, http.StatusInternalServerError, i18n.ErrorEventsEnumeration)
+			return
+		}
+		list.Events = append(list.Events, &Event{Index: event.Index, Type: string(event.Type), Instance: inst})
+	}
+
+	if err = w.WriteJson(list); err != nil {
+		routes.logger.WithFields(log.Fields{
+			"namespace": r.Env[env.Namespace],
+			"error":     err,
+		}).Warn("Failed to encode events list response")
+
+		i18n.Error(r, w, http.StatusInternalServerError, i18n.ErrorEncoding)
+		return
+	}
+
+	routes.logger.WithFields(log.Fields{
+		"namespace": r.Env[env.Namespace],
+	}).Debugf("Watch instances from index %d (%d)", index, len(list.Events))
+}
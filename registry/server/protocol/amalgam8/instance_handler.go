@@ -441,6 +441,14 @@ func statusCodeFromError(err error) int {
 			return http.StatusBadRequest
 		case store.ErrorInstanceMetaDataTooLong:
 			return http.StatusBadRequest
+		case store.ErrorWatchNotSupported:
+			return http.StatusNotImplemented
+		case store.ErrorIndexExpired:
+			return http.StatusGone
+		case store.ErrorSnapshotNotSupported:
+			return http.StatusNotImplemented
+		case store.ErrorNoSuchSnapshot:
+			return http.StatusNotFound
 		default:
 			return http.StatusInternalServerError
 		}
@@ -88,6 +88,34 @@ func (routes *Routes) RouteHandlers(middlewares ...rest.Middleware) []*rest.Rout
 			Operation: protocol.RenewInstance,
 			Handler:   routes.renewInstance,
 		},
+		{
+			Path:      EventsURL(),
+			Method:    "GET",
+			Protocol:  protocol.Amalgam8,
+			Operation: protocol.WatchInstances,
+			Handler:   routes.watchInstances,
+		},
+		{
+			Path:      SnapshotsURL(),
+			Method:    "POST",
+			Protocol:  protocol.Amalgam8,
+			Operation: protocol.CreateSnapshot,
+			Handler:   routes.createSnapshot,
+		},
+		{
+			Path:      SnapshotsURL(),
+			Method:    "GET",
+			Protocol:  protocol.Amalgam8,
+			Operation: protocol.ListSnapshots,
+			Handler:   routes.listSnapshots,
+		},
+		{
+			Path:      snapshotRestoreTemplateURL(),
+			Method:    "POST",
+			Protocol:  protocol.Amalgam8,
+			Operation: protocol.RestoreSnapshot,
+			Handler:   routes.restoreSnapshot,
+		},
 	}
 
 	rts := make([]*rest.Route, 0, len(descriptors))
@@ -0,0 +1,150 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package amalgam8
+
+import (
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/ant0ine/go-json-rest/rest"
+
+	"github.com/amalgam8/amalgam8/pkg/auth"
+	"github.com/amalgam8/amalgam8/registry/server/env"
+	"github.com/amalgam8/amalgam8/registry/store"
+	"github.com/amalgam8/amalgam8/registry/utils/i18n"
+)
+
+// createSnapshot writes the state of the catalogs of all namespaces to a new snapshot.
+func (routes *Routes) createSnapshot(w rest.ResponseWriter, r *rest.Request) {
+	snapshotter := routes.snapshotter(w, r)
+	if snapshotter == nil {
+		// error response set by routes.snapshotter()
+		return
+	}
+
+	info, err := snapshotter.Snapshot()
+	if err != nil {
+		routes.logger.WithFields(log.Fields{
+			"namespace": r.Env[env.Namespace],
+			"error":     err,
+		}).Warn("Failed to create snapshot")
+
+		i18n.Error(r, w, statusCodeFromError(err), snapshotErrorID(err, i18n.ErrorSnapshotFailed))
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err = w.WriteJson(&Snapshot{ID: info.ID, Time: info.Time}); err != nil {
+		routes.logger.WithFields(log.Fields{
+			"namespace": r.Env[env.Namespace],
+			"error":     err,
+		}).Warn("Failed to encode snapshot response")
+		return
+	}
+
+	routes.logger.WithFields(log.Fields{
+		"namespace": r.Env[env.Namespace],
+	}).Infof("Snapshot %s created", info.ID)
+}
+
+// listSnapshots lists the retained snapshots, oldest first.
+func (routes *Routes) listSnapshots(w rest.ResponseWriter, r *rest.Request) {
+	snapshotter := routes.snapshotter(w, r)
+	if snapshotter == nil {
+		// error response set by routes.snapshotter()
+		return
+	}
+
+	infos, err := snapshotter.ListSnapshots()
+	if err != nil {
+		routes.logger.WithFields(log.Fields{
+			"namespace": r.Env[env.Namespace],
+			"error":     err,
+		}).Warn("Failed to list snapshots")
+
+		i18n.Error(r, w, statusCodeFromError(err), snapshotErrorID(err, i18n.ErrorSnapshotEnumeration))
+		return
+	}
+
+	list := &SnapshotsList{Snapshots: make([]*Snapshot, 0, len(infos))}
+	for _, info := range infos {
+		list.Snapshots = append(list.Snapshots, &Snapshot{ID: info.ID, Time: info.Time})
+	}
+
+	if err = w.WriteJson(list); err != nil {
+		routes.logger.WithFields(log.Fields{
+			"namespace": r.Env[env.Namespace],
+			"error":     err,
+		}).Warn("Failed to encode snapshots list response")
+
+		i18n.Error(r, w, http.StatusInternalServerError, i18n.ErrorEncoding)
+		return
+	}
+}
+
+// restoreSnapshot replaces the instances of the requester's namespace with the instances in the snapshot.
+// Instances of other namespaces are not affected.
+func (routes *Routes) restoreSnapshot(w rest.ResponseWriter, r *rest.Request) {
+	id := r.PathParam(RouteParamSnapshotID)
+
+	snapshotter := routes.snapshotter(w, r)
+	if snapshotter == nil {
+		// error response set by routes.snapshotter()
+		return
+	}
+	namespace := r.Env[env.Namespace].(auth.Namespace)
+
+	if err := snapshotter.Restore(namespace, id); err != nil {
+		routes.logger.WithFields(log.Fields{
+			"namespace": namespace,
+			"error":     err,
+		}).Warnf("Failed to restore snapshot %s", id)
+
+		i18n.Error(r, w, statusCodeFromError(err), snapshotErrorID(err, i18n.ErrorSnapshotRestoreFailed))
+		return
+	}
+
+	routes.logger.WithFields(log.Fields{
+		"namespace": namespace,
+	}).Infof("Snapshot %s restored", id)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (routes *Routes) snapshotter(w rest.ResponseWriter, r *rest.Request) store.Snapshotter {
+	if r.Env[env.Namespace] == nil {
+		i18n.Error(r, w, http.StatusUnauthorized, i18n.ErrorNamespaceNotFound)
+		return nil
+	}
+
+	snapshotter, ok := routes.catalogMap.(store.Snapshotter)
+	if !ok {
+		i18n.Error(r, w, http.StatusNotImplemented, i18n.ErrorSnapshotNotSupported)
+		return nil
+	}
+	return snapshotter
+}
+
+func snapshotErrorID(err error, id string) string {
+	if regerr, ok := err.(*store.Error); ok {
+		switch regerr.Code {
+		case store.ErrorSnapshotNotSupported:
+			return i18n.ErrorSnapshotNotSupported
+		case store.ErrorNoSuchSnapshot:
+			return i18n.ErrorSnapshotNotFound
+		}
+	}
+	return id
+}
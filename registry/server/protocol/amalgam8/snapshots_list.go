@@ -0,0 +1,28 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package amalgam8
+
+import "time"
+
+// Snapshot describes a snapshot of the catalogs
+type Snapshot struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+}
+
+// SnapshotsList type is returned in response to a request to list the snapshots
+type SnapshotsList struct {
+	Snapshots []*Snapshot `json:"snapshots"`
+}
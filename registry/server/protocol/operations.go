@@ -39,6 +39,10 @@ const (
 	ListInstances                  = "ListInstances"
 	SetInstanceStatus              = "SetStatus"
 	GetInstance                    = "GetInfo"
+	WatchInstances                 = "WatchInstances"
+	CreateSnapshot                 = "CreateSnapshot"
+	ListSnapshots                  = "ListSnapshots"
+	RestoreSnapshot                = "RestoreSnapshot"
 )
 
 // String returns a string representation of this Operation value.
@@ -124,7 +124,11 @@ func (s *server) setup() (http.Handler, error) {
 
 	amalgam8Routes := amalgam8.New(s.config.CatalogMap)
 	eurekaRoutes := eureka.New(s.config.CatalogMap)
-	authMw := &middleware.AuthMiddleware{TokenRouteParam: eureka.RouteParamToken, Authenticator: s.config.Authenticator}
+	authMw := &middleware.AuthMiddleware{
+		TokenRouteParam: eureka.RouteParamToken,
+		Authenticator:   s.config.Authenticator,
+		Policy:          middleware.DefaultPolicy,
+	}
 
 	routes = append(routes, amalgam8Routes.RouteHandlers(secureMw, authMw)...)
 	routes = append(routes, eurekaRoutes.RouteHandlers(secureMw, authMw)...)
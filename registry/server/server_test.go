@@ -60,6 +60,7 @@ type mockInstance struct {
 type mockCatalog struct {
 	instances map[string]*store.ServiceInstance
 	services  []*store.Service
+	events    []*store.Event
 }
 
 func createCatalogMap() store.CatalogMap {
@@ -170,6 +171,27 @@ func (mc *mockCatalog) ListServices(predicate store.Predicate) []*store.Service
 	return collection[:len(collection)]
 }
 
+func (mc *mockCatalog) Watch(index uint64, timeout time.Duration) ([]*store.Event, uint64, error) {
+	var last uint64
+	if len(mc.events) > 0 {
+		last = mc.events[len(mc.events)-1].Index
+	}
+	if index == 0 {
+		return nil, last, nil
+	}
+	if index > last {
+		return nil, last, store.NewError(store.ErrorIndexExpired, "index expired", index)
+	}
+
+	events := []*store.Event{}
+	for _, event := range mc.events {
+		if event.Index > index {
+			events = append(events, event)
+		}
+	}
+	return events, last, nil
+}
+
 func defaultServerConfig() *Config {
 	return &Config{
 		HTTPAddressSpec: ":" + port,
@@ -127,6 +127,10 @@ func (c *discoveryAdapterCatalog) Instance(instanceID string) (*ServiceInstance,
 	return nil, NewError(ErrorNoSuchServiceInstance, "no such service instance", instanceID)
 }
 
+func (c *discoveryAdapterCatalog) Watch(index uint64, timeout time.Duration) ([]*Event, uint64, error) {
+	return nil, 0, NewError(ErrorWatchNotSupported, "Discovery Adapter Catalog: API Not Supported", "Watch")
+}
+
 // convert the instances structs to the store package own representation
 func convertServiceInstances(instances []*api.ServiceInstance) []*ServiceInstance {
 	converted := make([]*ServiceInstance, len(instances))
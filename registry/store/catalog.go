@@ -18,6 +18,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"strings"
+	"time"
 )
 
 // Predicate for filtering returned instances
@@ -33,6 +34,12 @@ type Catalog interface {
 	Instance(instanceID string) (*ServiceInstance, error)
 	List(serviceName string, predicate Predicate) ([]*ServiceInstance, error)
 	ListServices(predicate Predicate) []*Service
+
+	// Watch returns the events that occurred after the given index, along with the index of the latest event.
+	// If no such events occurred, it blocks until one does or until the timeout expires.
+	// An index of 0 returns the index of the latest event immediately, with no events.
+	// The returned events are shared between watchers and must not be modified.
+	Watch(index uint64, timeout time.Duration) ([]*Event, uint64, error)
 }
 
 const (
@@ -16,6 +16,7 @@
 package store
 
 import (
+	"fmt"
 	"sync"
 
 	log "github.com/Sirupsen/logrus"
@@ -33,17 +34,44 @@ type CatalogMap interface {
 	GetCatalog(auth.Namespace) (Catalog, error)
 }
 
+// Snapshotter represents the interface for taking snapshots of the catalogs and restoring them
+type Snapshotter interface {
+	// Snapshot writes the state of the catalogs of all namespaces to a new snapshot.
+	Snapshot() (*SnapshotInfo, error)
+
+	// ListSnapshots lists the retained snapshots, oldest first.
+	ListSnapshots() ([]*SnapshotInfo, error)
+
+	// Restore replaces the instances in the catalog of the namespace with the instances in the identified snapshot.
+	Restore(namespace auth.Namespace, id string) error
+}
+
+// Make sure the catalog map implements the Snapshotter interface
+var _ Snapshotter = (*catalogMap)(nil)
+
 type catalogMap struct {
 	conf           *Config
 	catalogs       map[auth.Namespace]Catalog
 	catalogFactory CatalogFactory
+	snapshotter    Snapshotter
 
 	logger *log.Entry
 	sync.Mutex
 }
 
-// New creates a new CatalogMap instance, bounded with the specified configuration
+// New creates a new CatalogMap instance, bounded with the specified configuration.
+// New panics if the configured store cannot be opened, see Open.
 func New(conf *Config) CatalogMap {
+	cm, err := Open(conf)
+	if err != nil {
+		panic(err)
+	}
+	return cm
+}
+
+// Open creates a new CatalogMap instance, bounded with the specified configuration.
+// Returns an error if the configured store cannot be opened.
+func Open(conf *Config) (CatalogMap, error) {
 	var lentry = logging.GetLogger(module)
 	var factory CatalogFactory
 
@@ -71,6 +99,24 @@ func New(conf *Config) CatalogMap {
 		externalFactory := newExternalFactory(externalConfig)
 		factory = externalFactory
 		conf.Replication = nil
+	} else if conf.Store == "persistent" {
+		persistentConfig := &persistentConfig{
+			inmem: &inMemoryConfig{
+				defaultTTL:        conf.DefaultTTL,
+				minimumTTL:        conf.MinimumTTL,
+				maximumTTL:        conf.MaximumTTL,
+				namespaceCapacity: conf.NamespaceCapacity,
+			},
+			dir:              conf.StoreDir,
+			snapshotInterval: conf.SnapshotInterval,
+		}
+		persistentFactory, err := newPersistentFactory(persistentConfig)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to open the persistent store in %s: %s", conf.StoreDir, err)
+		}
+		factory = persistentFactory
+		cmap.snapshotter = persistentFactory
+		conf.Replication = nil
 	} else {
 		// The InMemory catalog is the Read-Write catalog.
 		inmemConfig := &inMemoryConfig{
@@ -105,7 +151,7 @@ func New(conf *Config) CatalogMap {
 	}
 
 	cmap.catalogFactory = factory
-	return cmap
+	return cmap, nil
 }
 
 func (cm *catalogMap) GetCatalog(namespace auth.Namespace) (Catalog, error) {
@@ -130,3 +176,24 @@ func (cm *catalogMap) GetCatalog(namespace auth.Namespace) (Catalog, error) {
 
 	return catalog, nil
 }
+
+func (cm *catalogMap) Snapshot() (*SnapshotInfo, error) {
+	if cm.snapshotter == nil {
+		return nil, NewError(ErrorSnapshotNotSupported, "Snapshots are only supported by the persistent store", cm.conf.Store)
+	}
+	return cm.snapshotter.Snapshot()
+}
+
+func (cm *catalogMap) ListSnapshots() ([]*SnapshotInfo, error) {
+	if cm.snapshotter == nil {
+		return nil, NewError(ErrorSnapshotNotSupported, "Snapshots are only supported by the persistent store", cm.conf.Store)
+	}
+	return cm.snapshotter.ListSnapshots()
+}
+
+func (cm *catalogMap) Restore(namespace auth.Namespace, id string) error {
+	if cm.snapshotter == nil {
+		return NewError(ErrorSnapshotNotSupported, "Snapshots are only supported by the persistent store", cm.conf.Store)
+	}
+	return cm.snapshotter.Restore(namespace, id)
+}
@@ -30,6 +30,7 @@ const (
 	defaultStore             = "inmem"
 	defaultStoreAddr         = ""
 	defaultStorePassword     = ""
+	defaultSnapshotInterval  = time.Duration(5) * time.Minute
 )
 
 // DefaultConfig is the default configuration parameters for the registry
@@ -52,6 +53,10 @@ type Config struct {
 	StoreAddr     string
 	StorePassword string
 	StoreDatabase database.Database
+
+	// StoreDir is the directory of the persistent store, and SnapshotInterval is how often it takes snapshots.
+	StoreDir         string
+	SnapshotInterval time.Duration
 }
 
 // NewConfig creates a new registry configuration according to the specified TTL values
@@ -69,6 +74,7 @@ func NewConfig(defaultTTL, minimumTTL, maximumTTL time.Duration, namespaceCapaci
 		StoreAddr:         storeAddr,
 		StorePassword:     storePassword,
 		StoreDatabase:     storeDatabase,
+		SnapshotInterval:  defaultSnapshotInterval,
 	}
 }
 
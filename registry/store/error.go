@@ -33,6 +33,10 @@ const (
 	ErrorInstanceEndpointValueTooLong
 	ErrorInstanceStatusLengthTooLong
 	ErrorInstanceMetaDataTooLong
+	ErrorWatchNotSupported
+	ErrorIndexExpired
+	ErrorSnapshotNotSupported
+	ErrorNoSuchSnapshot
 )
 
 // Error is an error implementation that is associated with an ErrorCode
@@ -137,6 +137,11 @@ func (ec *eurekaCatalog) SetStatus(instanceID, status string) (*store.ServiceIns
 	return nil, store.NewError(store.ErrorBadRequest, "Read-only Catalog: API Not Supported", "SetStatus")
 }
 
+func (ec *eurekaCatalog) Watch(index uint64, timeout time.Duration) ([]*store.Event, uint64, error) {
+	ec.logger.Infof("Unsupported API (Watch) called")
+	return nil, 0, store.NewError(store.ErrorWatchNotSupported, "Read-only Catalog: API Not Supported", "Watch")
+}
+
 func (ec *eurekaCatalog) refresh() {
 	var services serviceMap
 	var instances instanceMap
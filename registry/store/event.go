@@ -0,0 +1,132 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package store
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType identifies the kind of change to the catalog an Event describes
+type EventType string
+
+// EventType predefined values
+const (
+	EventRegister   EventType = "register"
+	EventDeregister EventType = "deregister"
+	EventStatus     EventType = "status"
+	EventExpire     EventType = "expire"
+)
+
+// Event describes a change to a service instance in the catalog.
+// Instance holds the state of the instance after the change, or its last known state if it was removed.
+type Event struct {
+	Index    uint64
+	Type     EventType
+	Instance *ServiceInstance
+}
+
+// DefaultEventLogCapacity is the number of most recent events retained by a catalog for watchers to catch up with
+const DefaultEventLogCapacity = 1024
+
+// eventLog is a bounded, in-memory log of catalog events, indexed by a monotonically increasing index.
+// Watchers block on the log until events newer than their last seen index are appended.
+// The index of an empty log is 1, since index 0 is reserved for querying the current index.
+type eventLog struct {
+	events   []*Event
+	index    uint64
+	capacity int
+
+	// changed is closed (and replaced) whenever an event is appended, waking up blocked watchers
+	changed chan struct{}
+
+	sync.Mutex
+}
+
+func newEventLog(capacity int) *eventLog {
+	if capacity <= 0 {
+		capacity = DefaultEventLogCapacity
+	}
+
+	return &eventLog{
+		events:   make([]*Event, 0, capacity),
+		index:    1,
+		capacity: capacity,
+		changed:  make(chan struct{}),
+	}
+}
+
+// append adds an event for the given instance to the log, evicting the oldest event if the log is at capacity.
+// The instance is cloned, so the caller may go on modifying it.
+func (l *eventLog) append(eventType EventType, si *ServiceInstance) {
+	l.Lock()
+	defer l.Unlock()
+
+	l.index++
+	event := &Event{Index: l.index, Type: eventType, Instance: si.DeepClone()}
+	if len(l.events) == l.capacity {
+		copy(l.events, l.events[1:])
+		l.events[len(l.events)-1] = event
+	} else {
+		l.events = append(l.events, event)
+	}
+
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// since returns the events appended after the given index, along with the index of the latest event.
+// If there are no such events, it blocks until one is appended or the timeout expires.
+// An index of 0 returns the index of the latest event immediately, with no events.
+func (l *eventLog) since(index uint64, timeout time.Duration) ([]*Event, uint64, error) {
+	l.Lock()
+	if index == 0 {
+		defer l.Unlock()
+		return nil, l.index, nil
+	}
+	if index > l.index || (len(l.events) > 0 && index < l.events[0].Index-1) {
+		defer l.Unlock()
+		return nil, l.index, NewError(ErrorIndexExpired, "events since the index are no longer available", index)
+	}
+
+	if index == l.index && timeout > 0 {
+		changed := l.changed
+		l.Unlock()
+
+		timer := time.NewTimer(timeout)
+		select {
+		case <-changed:
+		case <-timer.C:
+		}
+		timer.Stop()
+
+		l.Lock()
+		// Events may have been evicted while waiting
+		if len(l.events) > 0 && index < l.events[0].Index-1 {
+			defer l.Unlock()
+			return nil, l.index, NewError(ErrorIndexExpired, "events since the index are no longer available", index)
+		}
+	}
+	defer l.Unlock()
+
+	if index == l.index {
+		return []*Event{}, l.index, nil
+	}
+
+	first := len(l.events) - int(l.index-index)
+	events := make([]*Event, len(l.events)-first)
+	copy(events, l.events[first:])
+	return events, l.index, nil
+}
@@ -0,0 +1,167 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchCurrentIndex(t *testing.T) {
+
+	catalog := newInMemoryCatalog(nil)
+
+	events, index, err := catalog.Watch(0, time.Minute)
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+	assert.EqualValues(t, 1, index)
+
+	_, err = doRegister(catalog, newServiceInstance("Calc", "192.168.0.1", 9080))
+	assert.NoError(t, err)
+
+	events, index, err = catalog.Watch(0, time.Minute)
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+	assert.EqualValues(t, 2, index)
+
+}
+
+func TestWatchInstanceEvents(t *testing.T) {
+
+	catalog := newInMemoryCatalog(nil)
+
+	_, start, _ := catalog.Watch(0, 0)
+
+	instance := newServiceInstance("Calc", "192.168.0.1", 9080)
+	id, err := doRegister(catalog, instance)
+	assert.NoError(t, err)
+
+	_, err = catalog.SetStatus(id, OutOfService)
+	assert.NoError(t, err)
+
+	// Setting the same status again is not a change
+	_, err = catalog.SetStatus(id, OutOfService)
+	assert.NoError(t, err)
+
+	// Neither is a heartbeat
+	_, err = catalog.Renew(id)
+	assert.NoError(t, err)
+
+	_, err = catalog.Deregister(id)
+	assert.NoError(t, err)
+
+	events, index, err := catalog.Watch(start, time.Minute)
+	assert.NoError(t, err)
+	assert.EqualValues(t, start+3, index)
+	if assert.Len(t, events, 3) {
+		assert.Equal(t, EventRegister, events[0].Type)
+		assert.Equal(t, EventStatus, events[1].Type)
+		assert.Equal(t, OutOfService, events[1].Instance.Status)
+		assert.Equal(t, EventDeregister, events[2].Type)
+		for i, event := range events {
+			assert.EqualValues(t, start+uint64(i)+1, event.Index)
+			assert.Equal(t, id, event.Instance.ID)
+		}
+	}
+
+	events, _, err = catalog.Watch(events[0].Index, time.Minute)
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+
+}
+
+func TestWatchExpireEvent(t *testing.T) {
+
+	conf := createNewConfig(testShortTTL)
+	catalog := newInMemoryCatalog(conf)
+
+	id, err := doRegister(catalog, newServiceInstance("Calc", "192.168.0.1", 9080))
+	assert.NoError(t, err)
+
+	events, index, err := catalog.Watch(2, 10*testShortTTL)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, index)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, EventExpire, events[0].Type)
+		assert.Equal(t, id, events[0].Instance.ID)
+	}
+
+}
+
+func TestWatchBlocksUntilEvent(t *testing.T) {
+
+	catalog := newInMemoryCatalog(nil)
+
+	_, err := doRegister(catalog, newServiceInstance("Calc", "192.168.0.1", 9080))
+	assert.NoError(t, err)
+
+	go func() {
+		time.Sleep(testShortTTL)
+		doRegister(catalog, newServiceInstance("Calc", "192.168.0.2", 9080))
+	}()
+
+	start := time.Now()
+	events, index, err := catalog.Watch(2, time.Minute)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, index)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "192.168.0.2:9080", events[0].Instance.Endpoint.Value)
+	}
+	assert.True(t, time.Since(start) >= testShortTTL)
+	assert.True(t, time.Since(start) < time.Minute)
+
+}
+
+func TestWatchTimeout(t *testing.T) {
+
+	catalog := newInMemoryCatalog(nil)
+
+	_, err := doRegister(catalog, newServiceInstance("Calc", "192.168.0.1", 9080))
+	assert.NoError(t, err)
+
+	start := time.Now()
+	events, index, err := catalog.Watch(2, testShortTTL)
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+	assert.EqualValues(t, 2, index)
+	assert.True(t, time.Since(start) >= testShortTTL)
+
+}
+
+func TestWatchIndexExpired(t *testing.T) {
+
+	log := newEventLog(2)
+	for i := 0; i < 4; i++ {
+		log.append(EventRegister, newServiceInstance("Calc", "192.168.0.1", 9080))
+	}
+
+	// Events 2 and 3 were evicted
+	_, _, err := log.since(2, 0)
+	assert.Error(t, err)
+	assert.EqualValues(t, ErrorIndexExpired, extractErrorCode(err))
+
+	events, index, err := log.since(3, 0)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, index)
+	assert.Len(t, events, 2)
+
+	// Indices beyond the latest event were never issued by this log
+	_, _, err = log.since(6, 0)
+	assert.Error(t, err)
+	assert.EqualValues(t, ErrorIndexExpired, extractErrorCode(err))
+
+}
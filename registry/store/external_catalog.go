@@ -383,6 +383,10 @@ func (ec *externalCatalog) ListServices(predicate Predicate) []*Service {
 	return services
 }
 
+func (ec *externalCatalog) Watch(index uint64, timeout time.Duration) ([]*Event, uint64, error) {
+	return nil, 0, NewError(ErrorWatchNotSupported, "External Catalog: API Not Supported", "Watch")
+}
+
 // delete deletes the specified instanceID from the catalog internal datastructures.
 // It assumes the catalog's write-lock is acquired by the calling goroutine.
 func (ec *externalCatalog) delete(instanceID string) *ServiceInstance {
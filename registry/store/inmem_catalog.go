@@ -52,6 +52,7 @@ type inMemoryService map[string]*ServiceInstance
 type inMemoryCatalog struct {
 	services  map[string]inMemoryService
 	instances map[string]*ServiceInstance
+	events    *eventLog
 	conf      *inMemoryConfig
 	logger    *log.Entry
 
@@ -79,6 +80,7 @@ func newInMemoryCatalog(conf *inMemoryConfig) *inMemoryCatalog {
 	catalog := &inMemoryCatalog{
 		services:  make(map[string]inMemoryService),
 		instances: make(map[string]*ServiceInstance),
+		events:    newEventLog(DefaultEventLogCapacity),
 		conf:      conf,
 		logger:    logging.GetLogger(module),
 
@@ -166,6 +168,7 @@ func (imc *inMemoryCatalog) Register(si *ServiceInstance) (*ServiceInstance, err
 	imc.instances[instanceID] = newSI
 
 	imc.renew(newSI)
+	imc.events.append(EventRegister, newSI)
 
 	metadataLength := len(newSI.Metadata)
 	tagsLength := len(newSI.Tags)
@@ -219,6 +222,7 @@ func (imc *inMemoryCatalog) Deregister(instanceID string) (*ServiceInstance, err
 	if instance == nil {
 		return nil, NewError(ErrorNoSuchServiceInstance, "no such service instance", instanceID)
 	}
+	imc.events.append(EventDeregister, instance)
 
 	return instance, nil
 }
@@ -245,8 +249,12 @@ func (imc *inMemoryCatalog) SetStatus(instanceID, status string) (*ServiceInstan
 		return nil, NewError(ErrorNoSuchServiceInstance, "no such service instance", instanceID)
 	}
 
+	changed := instance.Status != status
 	instance.Status = status
 	imc.renew(instance)
+	if changed {
+		imc.events.append(EventStatus, instance)
+	}
 	return instance.DeepClone(), nil
 }
 
@@ -299,6 +307,10 @@ func (imc *inMemoryCatalog) ListServices(predicate Predicate) []*Service {
 	return services
 }
 
+func (imc *inMemoryCatalog) Watch(index uint64, timeout time.Duration) ([]*Event, uint64, error) {
+	return imc.events.since(index, timeout)
+}
+
 func (imc *inMemoryCatalog) checkIfExpired(instanceID string) {
 	var instance *ServiceInstance
 	func() {
@@ -334,6 +346,7 @@ func (imc *inMemoryCatalog) checkIfExpired(instanceID string) {
 
 		imc.logger.Debugf("Instance ID %s is expired", instance.ID)
 		imc.delete(instanceID)
+		imc.events.append(EventExpire, instance)
 		imc.expirationMetric.Mark(1)
 	}
 }
@@ -371,6 +384,44 @@ func (imc *inMemoryCatalog) delete(instanceID string) *ServiceInstance {
 	return instance
 }
 
+// restore replaces the instances of the catalog with the given instances. The registration and renewal times of the
+// instances are kept, so that they expire when they would have if they had never left the catalog.
+func (imc *inMemoryCatalog) restore(instances []*ServiceInstance) {
+	imc.Lock()
+	defer imc.Unlock()
+
+	for instanceID := range imc.instances {
+		instance := imc.delete(instanceID)
+		imc.events.append(EventDeregister, instance)
+	}
+
+	now := time.Now()
+	for _, si := range instances {
+		instance := si.DeepClone()
+
+		service, exists := imc.services[instance.ServiceName]
+		if !exists {
+			service = make(map[string]*ServiceInstance)
+			imc.services[instance.ServiceName] = service
+		}
+		service[instance.ID] = instance
+		imc.instances[instance.ID] = instance
+		imc.events.append(EventRegister, instance)
+
+		imc.instancesMetric.Inc(1)
+		if len(instance.Metadata) > 0 {
+			imc.metadataInstancesMetric.Inc(1)
+		}
+		if len(instance.Tags) > 0 {
+			imc.tagsInstancesMetric.Inc(1)
+		}
+
+		time.AfterFunc(instance.LastRenewal.Add(instance.TTL).Sub(now), func() {
+			imc.checkIfExpired(instance.ID)
+		})
+	}
+}
+
 func (imc *inMemoryCatalog) renew(instance *ServiceInstance) {
 	instance.LastRenewal = time.Now()
 
@@ -15,6 +15,8 @@
 package store
 
 import (
+	"time"
+
 	"github.com/amalgam8/amalgam8/pkg/auth"
 )
 
@@ -128,3 +130,11 @@ func (mc *multiCatalog) ListServices(predicate Predicate) []*Service {
 
 	return services
 }
+
+func (mc *multiCatalog) Watch(index uint64, timeout time.Duration) ([]*Event, uint64, error) {
+	// Events of several catalogs cannot be ordered by a single index
+	if len(mc.catalogs) != 1 {
+		return nil, 0, NewError(ErrorWatchNotSupported, "Multi Catalog: API Not Supported", "Watch")
+	}
+	return mc.catalogs[rwCatalogIndex].Watch(index, timeout)
+}
@@ -17,6 +17,7 @@ package store
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -78,6 +79,10 @@ func (mc *mockCatalog) ListServices(predicate Predicate) []*Service {
 	return list
 }
 
+func (mc *mockCatalog) Watch(index uint64, timeout time.Duration) ([]*Event, uint64, error) {
+	return nil, 0, NewError(ErrorWatchNotSupported, "not supported", "Watch")
+}
+
 func TestNewMultiCatalog(t *testing.T) {
 
 	inmemF := newInMemoryFactory(nil)
@@ -0,0 +1,215 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package store
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/amalgam8/amalgam8/pkg/auth"
+	"github.com/amalgam8/amalgam8/registry/utils/logging"
+)
+
+type persistentConfig struct {
+	inmem            *inMemoryConfig
+	dir              string
+	snapshotInterval time.Duration
+}
+
+type persistentFactory struct {
+	conf     *persistentConfig
+	store    *persistentStore
+	catalogs map[auth.Namespace]*persistentCatalog
+	logger   *log.Entry
+
+	sync.Mutex
+}
+
+func newPersistentFactory(conf *persistentConfig) (*persistentFactory, error) {
+	ps, err := openPersistentStore(conf.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &persistentFactory{
+		conf:     conf,
+		store:    ps,
+		catalogs: make(map[auth.Namespace]*persistentCatalog),
+		logger:   logging.GetLogger(module),
+	}
+
+	if conf.snapshotInterval > 0 {
+		go f.snapshotPeriodically()
+	}
+
+	return f, nil
+}
+
+func (f *persistentFactory) CreateCatalog(namespace auth.Namespace) (Catalog, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	local := newInMemoryCatalog(f.conf.inmem)
+	local.restore(f.store.instances(namespace))
+
+	catalog := &persistentCatalog{
+		local:     local,
+		store:     f.store,
+		namespace: namespace,
+		logger:    logging.GetLogger(module).WithFields(log.Fields{"namespace": namespace}),
+	}
+	f.catalogs[namespace] = catalog
+
+	return catalog, nil
+}
+
+// Snapshot writes the state of all the catalogs to a new snapshot.
+func (f *persistentFactory) Snapshot() (*SnapshotInfo, error) {
+	return f.store.snapshot()
+}
+
+// ListSnapshots lists the retained snapshots, oldest first.
+func (f *persistentFactory) ListSnapshots() ([]*SnapshotInfo, error) {
+	return f.store.snapshots()
+}
+
+// Restore replaces the instances in the catalog of the namespace with the instances in the snapshot.
+func (f *persistentFactory) Restore(namespace auth.Namespace, id string) error {
+	f.Lock()
+	defer f.Unlock()
+
+	catalog := f.catalogs[namespace]
+	if catalog == nil {
+		// The catalog will be restored from the store once it is created
+		_, err := f.store.restore(namespace, id)
+		return err
+	}
+
+	catalog.Lock()
+	defer catalog.Unlock()
+
+	instances, err := f.store.restore(namespace, id)
+	if err != nil {
+		return err
+	}
+	catalog.local.restore(instances)
+
+	catalog.logger.Infof("Restored %d instances from snapshot %s", len(instances), id)
+	return nil
+}
+
+func (f *persistentFactory) snapshotPeriodically() {
+	for range time.Tick(f.conf.snapshotInterval) {
+		if _, err := f.Snapshot(); err != nil {
+			f.logger.WithFields(log.Fields{
+				"error": err,
+			}).Error("Failed to create a periodic snapshot")
+		}
+	}
+}
+
+// persistentCatalog is an in-memory catalog whose changes are written to a persistent store.
+// Changes are serialized, so that they are written to the store in the order they are applied to the catalog.
+// Expirations are not written, since the store expires instances by the same rule as the catalog does.
+type persistentCatalog struct {
+	local     *inMemoryCatalog
+	store     *persistentStore
+	namespace auth.Namespace
+
+	logger *log.Entry
+
+	sync.Mutex
+}
+
+func (pc *persistentCatalog) Register(si *ServiceInstance) (*ServiceInstance, error) {
+	pc.Lock()
+	defer pc.Unlock()
+
+	result, err := pc.local.Register(si)
+	if err != nil {
+		return nil, err
+	}
+
+	pc.write(&walRecord{Op: walRegister, Namespace: pc.namespace, Instance: result, Time: result.LastRenewal})
+	return result, nil
+}
+
+func (pc *persistentCatalog) Deregister(instanceID string) (*ServiceInstance, error) {
+	pc.Lock()
+	defer pc.Unlock()
+
+	instance, err := pc.local.Deregister(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	pc.write(&walRecord{Op: walDeregister, Namespace: pc.namespace, InstanceID: instanceID, Time: time.Now()})
+	return instance, nil
+}
+
+func (pc *persistentCatalog) Renew(instanceID string) (*ServiceInstance, error) {
+	pc.Lock()
+	defer pc.Unlock()
+
+	instance, err := pc.local.Renew(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	pc.write(&walRecord{Op: walRenew, Namespace: pc.namespace, InstanceID: instanceID, Time: instance.LastRenewal})
+	return instance, nil
+}
+
+func (pc *persistentCatalog) SetStatus(instanceID, status string) (*ServiceInstance, error) {
+	pc.Lock()
+	defer pc.Unlock()
+
+	instance, err := pc.local.SetStatus(instanceID, status)
+	if err != nil {
+		return nil, err
+	}
+
+	pc.write(&walRecord{Op: walSetStatus, Namespace: pc.namespace, InstanceID: instanceID, Status: status,
+		Time: instance.LastRenewal})
+	return instance, nil
+}
+
+func (pc *persistentCatalog) Instance(instanceID string) (*ServiceInstance, error) {
+	return pc.local.Instance(instanceID)
+}
+
+func (pc *persistentCatalog) List(serviceName string, predicate Predicate) ([]*ServiceInstance, error) {
+	return pc.local.List(serviceName, predicate)
+}
+
+func (pc *persistentCatalog) ListServices(predicate Predicate) []*Service {
+	return pc.local.ListServices(predicate)
+}
+
+func (pc *persistentCatalog) Watch(index uint64, timeout time.Duration) ([]*Event, uint64, error) {
+	return pc.local.Watch(index, timeout)
+}
+
+// write writes the change to the persistent store. A failure to write does not fail the change, which has already
+// been applied to the catalog, but it is lost if the registry restarts before the next snapshot.
+func (pc *persistentCatalog) write(record *walRecord) {
+	if err := pc.store.append(record); err != nil {
+		pc.logger.WithFields(log.Fields{
+			"error": err,
+		}).Errorf("Failed to write %s record to the persistent store", record.Op)
+	}
+}
@@ -0,0 +1,293 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/amalgam8/amalgam8/pkg/auth"
+)
+
+var testNamespace = auth.NamespaceFrom("ns1")
+
+func openTestPersistentFactory(t *testing.T, dir string, defaultTTL time.Duration) *persistentFactory {
+	factory, err := newPersistentFactory(&persistentConfig{inmem: createNewConfig(defaultTTL), dir: dir})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return factory
+}
+
+func createTestPersistentCatalog(t *testing.T, factory *persistentFactory) Catalog {
+	catalog, err := factory.CreateCatalog(testNamespace)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return catalog
+}
+
+func TestPersistentCatalogRecoversAfterRestart(t *testing.T) {
+
+	dir, _ := ioutil.TempDir("", "persistent")
+	defer os.RemoveAll(dir)
+
+	factory := openTestPersistentFactory(t, dir, testMediumTTL)
+	catalog := createTestPersistentCatalog(t, factory)
+
+	instance1 := newServiceInstance("Calc", "192.168.0.1", 9080)
+	instance1.Tags = []string{"v1"}
+	id1, err := doRegister(catalog, instance1)
+	assert.NoError(t, err)
+	id2, err := doRegister(catalog, newServiceInstance("Calc", "192.168.0.2", 9080))
+	assert.NoError(t, err)
+	id3, err := doRegister(catalog, newServiceInstance("Calc", "192.168.0.3", 9080))
+	assert.NoError(t, err)
+
+	_, err = catalog.SetStatus(id1, OutOfService)
+	assert.NoError(t, err)
+	renewed, err := catalog.Renew(id2)
+	assert.NoError(t, err)
+	_, err = catalog.Deregister(id3)
+	assert.NoError(t, err)
+	factory.store.close()
+
+	// Restart
+	factory = openTestPersistentFactory(t, dir, testMediumTTL)
+	defer factory.store.close()
+	catalog = createTestPersistentCatalog(t, factory)
+
+	instances, err := catalog.List("Calc", nil)
+	assert.NoError(t, err)
+	assert.Len(t, instances, 2)
+
+	instance, err := catalog.Instance(id1)
+	if assert.NoError(t, err) {
+		assert.Equal(t, OutOfService, instance.Status)
+		assert.Equal(t, []string{"v1"}, instance.Tags)
+		assert.Equal(t, testMediumTTL, instance.TTL)
+	}
+	instance, err = catalog.Instance(id2)
+	if assert.NoError(t, err) {
+		assert.True(t, renewed.LastRenewal.Equal(instance.LastRenewal))
+	}
+	_, err = catalog.Instance(id3)
+	assert.Error(t, err)
+
+	// Other namespaces are not affected
+	other, err := factory.CreateCatalog(auth.NamespaceFrom("ns2"))
+	assert.NoError(t, err)
+	assert.Empty(t, other.ListServices(nil))
+
+}
+
+func TestPersistentCatalogPreservesExpiry(t *testing.T) {
+
+	dir, _ := ioutil.TempDir("", "persistent")
+	defer os.RemoveAll(dir)
+
+	factory := openTestPersistentFactory(t, dir, testShortTTL)
+	catalog := createTestPersistentCatalog(t, factory)
+
+	id1, err := doRegister(catalog, newServiceInstance("Calc", "192.168.0.1", 9080))
+	assert.NoError(t, err)
+	id2, err := doRegister(catalog, newServiceInstance("Calc", "192.168.0.2", 9080))
+	assert.NoError(t, err)
+	_, err = catalog.SetStatus(id2, OutOfService)
+	assert.NoError(t, err)
+	factory.store.close()
+
+	// Restart before the TTL elapses, the instances are recovered and then expire on time
+	factory = openTestPersistentFactory(t, dir, testShortTTL)
+	catalog = createTestPersistentCatalog(t, factory)
+
+	_, err = catalog.Instance(id1)
+	assert.NoError(t, err)
+
+	time.Sleep(testShortTTL + testShortTTL/2)
+	_, err = catalog.Instance(id1)
+	assert.Error(t, err)
+	_, err = catalog.Instance(id2)
+	assert.NoError(t, err, "Out of service instances should not expire")
+	factory.store.close()
+
+	// Restart after the TTL elapsed, the expired instance is not recovered
+	factory = openTestPersistentFactory(t, dir, testShortTTL)
+	defer factory.store.close()
+	catalog = createTestPersistentCatalog(t, factory)
+
+	instances, err := catalog.List("Calc", nil)
+	assert.NoError(t, err)
+	if assert.Len(t, instances, 1) {
+		assert.Equal(t, id2, instances[0].ID)
+	}
+
+}
+
+func TestPersistentCatalogSnapshotAndRestore(t *testing.T) {
+
+	dir, _ := ioutil.TempDir("", "persistent")
+	defer os.RemoveAll(dir)
+
+	factory := openTestPersistentFactory(t, dir, testMediumTTL)
+	catalog := createTestPersistentCatalog(t, factory)
+
+	id1, err := doRegister(catalog, newServiceInstance("Calc", "192.168.0.1", 9080))
+	assert.NoError(t, err)
+
+	snap, err := factory.Snapshot()
+	assert.NoError(t, err)
+
+	id2, err := doRegister(catalog, newServiceInstance("Calc", "192.168.0.2", 9080))
+	assert.NoError(t, err)
+	_, err = catalog.Deregister(id1)
+	assert.NoError(t, err)
+
+	snapshots, err := factory.ListSnapshots()
+	assert.NoError(t, err)
+	if assert.Len(t, snapshots, 1) {
+		assert.Equal(t, snap.ID, snapshots[0].ID)
+		assert.True(t, snap.Time.Equal(snapshots[0].Time))
+	}
+
+	err = factory.Restore(testNamespace, "12345")
+	assert.Error(t, err)
+	assert.EqualValues(t, ErrorNoSuchSnapshot, extractErrorCode(err))
+	err = factory.Restore(testNamespace, "../wal")
+	assert.Error(t, err)
+	assert.EqualValues(t, ErrorNoSuchSnapshot, extractErrorCode(err))
+
+	assert.NoError(t, factory.Restore(testNamespace, snap.ID))
+
+	_, err = catalog.Instance(id1)
+	assert.NoError(t, err)
+	_, err = catalog.Instance(id2)
+	assert.Error(t, err)
+	factory.store.close()
+
+	// The restore is persisted as well
+	factory = openTestPersistentFactory(t, dir, testMediumTTL)
+	defer factory.store.close()
+	catalog = createTestPersistentCatalog(t, factory)
+
+	instances, err := catalog.List("Calc", nil)
+	assert.NoError(t, err)
+	if assert.Len(t, instances, 1) {
+		assert.Equal(t, id1, instances[0].ID)
+	}
+
+}
+
+func TestPersistentStoreSnapshotTruncatesLog(t *testing.T) {
+
+	dir, _ := ioutil.TempDir("", "persistent")
+	defer os.RemoveAll(dir)
+
+	factory := openTestPersistentFactory(t, dir, testMediumTTL)
+	catalog := createTestPersistentCatalog(t, factory)
+
+	id, err := doRegister(catalog, newServiceInstance("Calc", "192.168.0.1", 9080))
+	assert.NoError(t, err)
+
+	_, err = factory.Snapshot()
+	assert.NoError(t, err)
+	info, err := os.Stat(filepath.Join(dir, walFileName))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, info.Size())
+
+	for i := 0; i < snapshotRetention+2; i++ {
+		_, err = factory.Snapshot()
+		assert.NoError(t, err)
+	}
+	snapshots, err := factory.ListSnapshots()
+	assert.NoError(t, err)
+	assert.Len(t, snapshots, snapshotRetention)
+	factory.store.close()
+
+	// A partially written record at the end of the log is ignored
+	wal, err := os.OpenFile(filepath.Join(dir, walFileName), os.O_WRONLY|os.O_APPEND, 0644)
+	assert.NoError(t, err)
+	wal.WriteString(`{"Op":"DEREGISTER","Namespace":"ns1","Instan`)
+	wal.Close()
+
+	factory = openTestPersistentFactory(t, dir, testMediumTTL)
+	defer factory.store.close()
+	catalog = createTestPersistentCatalog(t, factory)
+
+	_, err = catalog.Instance(id)
+	assert.NoError(t, err)
+
+}
+
+func TestPersistentStoreFallsBackToPreviousSnapshot(t *testing.T) {
+
+	dir, _ := ioutil.TempDir("", "persistent")
+	defer os.RemoveAll(dir)
+
+	factory := openTestPersistentFactory(t, dir, testMediumTTL)
+	catalog := createTestPersistentCatalog(t, factory)
+
+	id, err := doRegister(catalog, newServiceInstance("Calc", "192.168.0.1", 9080))
+	assert.NoError(t, err)
+	_, err = factory.Snapshot()
+	assert.NoError(t, err)
+	latest, err := factory.Snapshot()
+	assert.NoError(t, err)
+	factory.store.close()
+
+	// Corrupt the latest snapshot
+	err = ioutil.WriteFile(factory.store.snapshotPath(latest.ID), []byte(`{"namespaces":{"ns1":`), 0644)
+	assert.NoError(t, err)
+
+	factory = openTestPersistentFactory(t, dir, testMediumTTL)
+	defer factory.store.close()
+	catalog = createTestPersistentCatalog(t, factory)
+
+	_, err = catalog.Instance(id)
+	assert.NoError(t, err)
+
+}
+
+func TestPersistentStoreOpenFails(t *testing.T) {
+
+	dir, _ := ioutil.TempDir("", "persistent")
+	defer os.RemoveAll(dir)
+
+	// The store directory is a regular file
+	path := filepath.Join(dir, "file")
+	assert.NoError(t, ioutil.WriteFile(path, nil, 0644))
+
+	conf := *DefaultConfig
+	conf.Store = "persistent"
+	conf.StoreDir = path
+	cm, err := Open(&conf)
+	assert.Error(t, err)
+	assert.Nil(t, cm)
+
+	// Neither can a store whose only snapshot is unreadable be opened
+	snapshots := filepath.Join(dir, "store", snapshotsDirName)
+	assert.NoError(t, os.MkdirAll(snapshots, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(snapshots, snapshotPrefix+"1"+snapshotSuffix), []byte("{"), 0644))
+	conf.StoreDir = filepath.Join(dir, "store")
+	cm, err = Open(&conf)
+	assert.Error(t, err)
+	assert.Nil(t, cm)
+
+}
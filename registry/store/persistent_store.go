@@ -0,0 +1,410 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/amalgam8/amalgam8/pkg/auth"
+	"github.com/amalgam8/amalgam8/registry/utils/logging"
+)
+
+const (
+	walFileName       = "wal.log"
+	snapshotsDirName  = "snapshots"
+	snapshotPrefix    = "snapshot-"
+	snapshotSuffix    = ".json"
+	snapshotRetention = 10
+	maxWALRecordSize  = 1024 * 1024
+)
+
+// SnapshotInfo describes a snapshot of the persistent catalogs.
+type SnapshotInfo struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+}
+
+type walOperation string
+
+// Enumeration of the write-ahead log record operations
+const (
+	walRegister   walOperation = "REGISTER"
+	walDeregister walOperation = "DEREGISTER"
+	walRenew      walOperation = "RENEW"
+	walSetStatus  walOperation = "SETSTATUS"
+	walRestore    walOperation = "RESTORE"
+)
+
+// walRecord is a change to a catalog, as written to the write-ahead log
+type walRecord struct {
+	Op         walOperation
+	Namespace  auth.Namespace
+	InstanceID string             `json:",omitempty"`
+	Instance   *ServiceInstance   `json:",omitempty"`
+	Instances  []*ServiceInstance `json:",omitempty"`
+	Status     string             `json:",omitempty"`
+	Time       time.Time
+}
+
+// snapshot is the state of all the catalogs at a point in time, as written to a snapshot file
+type snapshot struct {
+	Time       time.Time
+	Namespaces map[auth.Namespace][]*ServiceInstance
+}
+
+type persistentInstances map[string]*ServiceInstance // instance ID -> instance
+
+// persistentStore keeps the state of the catalogs of all namespaces on disk, as the latest snapshot followed by a
+// write-ahead log of the changes made since. The state is mirrored in memory, so that snapshots can be taken
+// without reading back the log.
+type persistentStore struct {
+	dir   string
+	wal   *os.File
+	state map[auth.Namespace]persistentInstances
+
+	logger *log.Entry
+
+	sync.Mutex
+}
+
+// openPersistentStore opens the persistent store in the given directory, recovering its state from the latest
+// snapshot and the write-ahead log.
+func openPersistentStore(dir string) (*persistentStore, error) {
+	ps := &persistentStore{
+		dir:    dir,
+		state:  make(map[auth.Namespace]persistentInstances),
+		logger: logging.GetLogger(module),
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, snapshotsDirName), 0755); err != nil {
+		return nil, err
+	}
+
+	snapshots, err := ps.snapshots()
+	if err != nil {
+		return nil, err
+	}
+	// A snapshot that cannot be read is skipped in favor of the previous one. Changes between the two snapshots
+	// are lost, and are restored as instances renew or register again.
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		snap, err := ps.readSnapshot(snapshots[i].ID)
+		if err != nil {
+			ps.logger.WithFields(log.Fields{
+				"error": err,
+			}).Warnf("Skipping unreadable snapshot %s", snapshots[i].ID)
+			if i == 0 {
+				return nil, fmt.Errorf("No readable snapshot in %s", dir)
+			}
+			continue
+		}
+		for namespace, instances := range snap.Namespaces {
+			ps.state[namespace] = toPersistentInstances(instances)
+		}
+		ps.logger.Infof("Recovered persistent store from snapshot %s", snapshots[i].ID)
+		break
+	}
+
+	count, err := ps.replay()
+	if err != nil {
+		return nil, err
+	}
+	ps.logger.Infof("Replayed %d write-ahead log records", count)
+
+	ps.wal, err = os.OpenFile(ps.walPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return ps, nil
+}
+
+// instances returns the instances of the namespace that have not expired.
+func (ps *persistentStore) instances(namespace auth.Namespace) []*ServiceInstance {
+	ps.Lock()
+	defer ps.Unlock()
+
+	now := time.Now()
+	instances := make([]*ServiceInstance, 0, len(ps.state[namespace]))
+	for _, instance := range ps.state[namespace] {
+		if !isExpired(instance, now) {
+			instances = append(instances, instance.DeepClone())
+		}
+	}
+	return instances
+}
+
+// append applies the change to the state and writes it to the write-ahead log.
+func (ps *persistentStore) append(record *walRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	ps.Lock()
+	defer ps.Unlock()
+
+	ps.apply(record)
+
+	if _, err = ps.wal.Write(append(data, '\n')); err != nil {
+		return err
+	}
+
+	// Renewals only affect the time instances expire, so they are not worth waiting for the disk.
+	// Losing the latest renewals in a crash at worst makes instances expire earlier after recovery.
+	if record.Op != walRenew {
+		return ps.wal.Sync()
+	}
+	return nil
+}
+
+// snapshot writes the state to a new snapshot file and truncates the write-ahead log.
+// Expired instances are left out, and the oldest snapshots beyond the retention limit are removed.
+func (ps *persistentStore) snapshot() (*SnapshotInfo, error) {
+	ps.Lock()
+	defer ps.Unlock()
+
+	now := time.Now()
+	snap := &snapshot{Time: now, Namespaces: make(map[auth.Namespace][]*ServiceInstance, len(ps.state))}
+	for namespace, instances := range ps.state {
+		for id, instance := range instances {
+			if isExpired(instance, now) {
+				delete(instances, id)
+				continue
+			}
+			snap.Namespaces[namespace] = append(snap.Namespaces[namespace], instance)
+		}
+		if len(instances) == 0 {
+			delete(ps.state, namespace)
+		}
+	}
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &SnapshotInfo{ID: strconv.FormatInt(now.UnixNano(), 10), Time: now}
+	tmpPath := ps.snapshotPath(info.ID) + ".tmp"
+	if err = writeFileSync(tmpPath, data); err != nil {
+		return nil, err
+	}
+	if err = os.Rename(tmpPath, ps.snapshotPath(info.ID)); err != nil {
+		return nil, err
+	}
+
+	// Replaying the log on top of the snapshot is harmless, so a crash before truncating it loses nothing
+	if err = ps.wal.Truncate(0); err != nil {
+		return nil, err
+	}
+
+	snapshots, err := ps.snapshots()
+	if err == nil && len(snapshots) > snapshotRetention {
+		for _, old := range snapshots[:len(snapshots)-snapshotRetention] {
+			os.Remove(ps.snapshotPath(old.ID))
+		}
+	}
+
+	ps.logger.Infof("Snapshot %s has been created", info.ID)
+	return info, nil
+}
+
+// snapshots lists the snapshots in the store, oldest first.
+func (ps *persistentStore) snapshots() ([]*SnapshotInfo, error) {
+	files, err := ioutil.ReadDir(filepath.Join(ps.dir, snapshotsDirName))
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []*SnapshotInfo
+	for _, file := range files {
+		name := file.Name()
+		if !strings.HasPrefix(name, snapshotPrefix) || !strings.HasSuffix(name, snapshotSuffix) {
+			continue
+		}
+		id := strings.TrimSuffix(strings.TrimPrefix(name, snapshotPrefix), snapshotSuffix)
+		nanos, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, &SnapshotInfo{ID: id, Time: time.Unix(0, nanos)})
+	}
+
+	sort.Sort(snapshotsByTime(snapshots))
+	return snapshots, nil
+}
+
+// restore replaces the instances of the namespace with those in the snapshot, and returns them.
+// The restored instances are renewed, giving them a full TTL to be renewed again by their owners.
+func (ps *persistentStore) restore(namespace auth.Namespace, id string) ([]*ServiceInstance, error) {
+	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+		return nil, NewError(ErrorNoSuchSnapshot, "no such snapshot", id)
+	}
+
+	snap, err := ps.readSnapshot(id)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, NewError(ErrorNoSuchSnapshot, "no such snapshot", id)
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	instances := snap.Namespaces[namespace]
+	for _, instance := range instances {
+		instance.LastRenewal = now
+	}
+
+	if err = ps.append(&walRecord{Op: walRestore, Namespace: namespace, Instances: instances, Time: now}); err != nil {
+		return nil, err
+	}
+
+	return instances, nil
+}
+
+func (ps *persistentStore) close() error {
+	return ps.wal.Close()
+}
+
+// apply applies the change to the state.
+// It assumes the store's lock is acquired by the calling goroutine.
+func (ps *persistentStore) apply(record *walRecord) {
+	instances := ps.state[record.Namespace]
+	if instances == nil {
+		instances = make(persistentInstances)
+		ps.state[record.Namespace] = instances
+	}
+
+	switch record.Op {
+	case walRegister:
+		instances[record.Instance.ID] = record.Instance.DeepClone()
+	case walDeregister:
+		delete(instances, record.InstanceID)
+	case walRenew:
+		if instance, exists := instances[record.InstanceID]; exists {
+			instance.LastRenewal = record.Time
+		}
+	case walSetStatus:
+		if instance, exists := instances[record.InstanceID]; exists {
+			instance.Status = record.Status
+			instance.LastRenewal = record.Time
+		}
+	case walRestore:
+		ps.state[record.Namespace] = toPersistentInstances(record.Instances)
+	default:
+		ps.logger.Warnf("Unknown write-ahead log operation %s", record.Op)
+	}
+}
+
+// replay applies the records of the write-ahead log to the state, and returns the number of records applied.
+// A partially written record at the end of the log, as left by a crash, is ignored.
+func (ps *persistentStore) replay() (int, error) {
+	file, err := os.Open(ps.walPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer file.Close()
+
+	var count int
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxWALRecordSize)
+	for scanner.Scan() {
+		var record walRecord
+		if err = json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			ps.logger.WithFields(log.Fields{
+				"error": err,
+			}).Warnf("Ignoring malformed write-ahead log record %d", count+1)
+			break
+		}
+		if record.Op == walRegister && record.Instance == nil {
+			continue
+		}
+		ps.apply(&record)
+		count++
+	}
+
+	return count, scanner.Err()
+}
+
+func (ps *persistentStore) readSnapshot(id string) (*snapshot, error) {
+	data, err := ioutil.ReadFile(ps.snapshotPath(id))
+	if err != nil {
+		return nil, err
+	}
+
+	var snap snapshot
+	if err = json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("Malformed snapshot %s: %s", id, err)
+	}
+	return &snap, nil
+}
+
+func (ps *persistentStore) walPath() string {
+	return filepath.Join(ps.dir, walFileName)
+}
+
+func (ps *persistentStore) snapshotPath(id string) string {
+	return filepath.Join(ps.dir, snapshotsDirName, snapshotPrefix+id+snapshotSuffix)
+}
+
+// isExpired returns whether the instance has expired at the given time, by the same rule the in-memory catalog
+// expires instances by.
+func isExpired(instance *ServiceInstance, now time.Time) bool {
+	return instance.Status != OutOfService && now.Sub(instance.LastRenewal) > instance.TTL
+}
+
+func toPersistentInstances(instances []*ServiceInstance) persistentInstances {
+	result := make(persistentInstances, len(instances))
+	for _, instance := range instances {
+		result[instance.ID] = instance.DeepClone()
+	}
+	return result
+}
+
+func writeFileSync(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+type snapshotsByTime []*SnapshotInfo
+
+func (s snapshotsByTime) Len() int           { return len(s) }
+func (s snapshotsByTime) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s snapshotsByTime) Less(i, j int) bool { return s[i].Time.Before(s[j].Time) }
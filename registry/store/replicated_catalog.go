@@ -226,6 +226,11 @@ func (rpc *replicatedCatalog) ListServices(predicate Predicate) []*Service {
 	return rpc.local.ListServices(predicate)
 }
 
+func (rpc *replicatedCatalog) Watch(index uint64, timeout time.Duration) ([]*Event, uint64, error) {
+	// Both local and replicated changes are applied to the local catalog, so its events cover the whole namespace
+	return rpc.local.Watch(index, timeout)
+}
+
 func (rpc *replicatedCatalog) handleIncomingMsgs() {
 	var data replicatedMsg
 
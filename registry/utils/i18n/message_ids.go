@@ -24,6 +24,7 @@ const (
 	ErrorAuthorizationMalformedHeader       = "error_auth_header_malformed"
 	ErrorAuthorizationTokenValidationFailed = "error_auth_failed_validation"
 	ErrorAuthorizationNotAuthorized         = "error_auth_not_authorized"
+	ErrorAuthorizationForbidden             = "error_auth_forbidden"
 	ErrorEncoding                           = "error_encoding_generic"
 	ErrorFilterBadFields                    = "error_filter_bad_fields"
 	ErrorFilterSelectionCriteria            = "error_filter_selection_criteria"
@@ -51,6 +52,16 @@ const (
 	ErrorEndpointValueTooLong               = "error_instance_endpoint_too_long"
 	ErrorStatusLengthTooLong                = "error_status_too_long"
 	ErrorMetaDataTooLong                    = "error_meta_data_too_long"
+	ErrorEventsIndexInvalid                 = "error_events_index_invalid"
+	ErrorEventsTimeoutInvalid               = "error_events_timeout_invalid"
+	ErrorEventsIndexExpired                 = "error_events_index_expired"
+	ErrorEventsNotSupported                 = "error_events_not_supported"
+	ErrorEventsEnumeration                  = "error_events_enumeration"
+	ErrorSnapshotNotSupported               = "error_snapshot_not_supported"
+	ErrorSnapshotNotFound                   = "error_snapshot_not_found"
+	ErrorSnapshotFailed                     = "error_snapshot_failed"
+	ErrorSnapshotEnumeration                = "error_snapshot_enumeration"
+	ErrorSnapshotRestoreFailed              = "error_snapshot_restore_failed"
 )
 
 // EurekaErrorApplicationEnumeration and other constants denote Eureka specific errors. In addition, Eureka API may
@@ -16,22 +16,27 @@ package api
 
 import (
 	"net/http"
+	"time"
 
+	"github.com/Sirupsen/logrus"
 	"github.com/amalgam8/amalgam8/controller/rules"
 	"github.com/amalgam8/amalgam8/registry/api"
 	"github.com/amalgam8/amalgam8/sidecar/proxy"
+	"github.com/amalgam8/amalgam8/sidecar/proxy/nginx"
 	"github.com/ant0ine/go-json-rest/rest"
 )
 
 // DebugAPI handles debugging API calls to sidecar for checking state
 type DebugAPI struct {
-	nginxProxy proxy.NGINXProxy
+	nginxProxy  proxy.NGINXProxy
+	nginxClient nginx.Client
 }
 
 // NewDebugAPI creates struct
-func NewDebugAPI(nginxProxy proxy.NGINXProxy) *DebugAPI {
+func NewDebugAPI(nginxProxy proxy.NGINXProxy, nginxClient nginx.Client) *DebugAPI {
 	return &DebugAPI{
-		nginxProxy: nginxProxy,
+		nginxProxy:  nginxProxy,
+		nginxClient: nginxClient,
 	}
 }
 
@@ -48,17 +53,25 @@ func (d *DebugAPI) Routes(middlewares ...rest.Middleware) []*rest.Route {
 }
 
 // checkState returns the cached rules from controller and cached instances
-// from registry stored in sidecar memory
+// from registry stored in sidecar memory, and the instances ejected by circuit breakers
 func (d *DebugAPI) checkState(w rest.ResponseWriter, req *rest.Request) {
 
 	cachedInstances, cachedRules := d.nginxProxy.GetState()
 
+	// The cached state is still useful when NGINX cannot be queried
+	ejected, err := d.nginxClient.EjectedInstances()
+	if err != nil {
+		logrus.WithError(err).Warn("Could not get ejected instances from NGINX")
+	}
+
 	state := struct {
 		Instances []api.ServiceInstance `json:"instances"`
 		Rules     []rules.Rule          `json:"rules"`
+		Ejected   map[string]time.Time  `json:"ejected,omitempty"`
 	}{
 		Instances: cachedInstances,
 		Rules:     cachedRules,
+		Ejected:   ejected,
 	}
 
 	w.WriteHeader(http.StatusOK)
@@ -43,6 +43,7 @@ const (
 	Amalgam8Backend   = "amalgam8"
 	KubernetesBackend = "kubernetes"
 	EurekaBackend     = "eureka"
+	ConsulBackend     = "consul"
 )
 
 // Command to be managed by sidecar app supervisor
@@ -73,6 +74,7 @@ type Registry struct {
 	Amalgam8   Amalgam8Registry   `yaml:"amalgam8"`
 	Kubernetes KubernetesRegistry `yaml:"kubernetes"`
 	Eureka     EurekaRegistry     `yaml:"eureka"`
+	Consul     ConsulRegistry     `yaml:"consul"`
 
 	// for backward compatibility, support amalgam8 registry
 	// configuration directly under the `registry:` clause
@@ -111,6 +113,13 @@ type EurekaRegistry struct {
 	URLs []string `yaml:"urls"`
 }
 
+// ConsulRegistry configuration
+type ConsulRegistry struct {
+	URL        string `yaml:"url"`
+	Token      string `yaml:"token"`
+	Datacenter string `yaml:"datacenter"`
+}
+
 // Health check types.
 const (
 	HTTPHealthCheck    = "http"
@@ -248,6 +257,9 @@ func (c *Config) loadFromContext(context *cli.Context) error {
 	loadFromContextIfSet(&c.Registry.Kubernetes.Token, kubernetesTokenFlag)
 	loadFromContextIfSet(&c.Registry.Kubernetes.Namespace, kubernetesNamespaceFlag)
 	loadFromContextIfSet(&c.Registry.Eureka.URLs, eurekaURLFlag)
+	loadFromContextIfSet(&c.Registry.Consul.URL, consulURLFlag)
+	loadFromContextIfSet(&c.Registry.Consul.Token, consulTokenFlag)
+	loadFromContextIfSet(&c.Registry.Consul.Datacenter, consulDatacenterFlag)
 	loadFromContextIfSet(&c.Controller.URL, controllerURLFlag)
 	loadFromContextIfSet(&c.Controller.Token, controllerTokenFlag)
 	loadFromContextIfSet(&c.Controller.Poll, controllerPollFlag)
@@ -347,9 +359,11 @@ func (c *Config) Validate() error {
 	)
 
 	validators = append(validators,
-		IsInSet("Registry backend", c.Registry.Backend, []string{Amalgam8Backend, KubernetesBackend, EurekaBackend}),
+		IsInSet("Registry backend", c.Registry.Backend, []string{Amalgam8Backend, KubernetesBackend, EurekaBackend,
+			ConsulBackend}),
 		IsEmptyOrValidURL("Amalgam8 Registry URL", c.Registry.Amalgam8.URL),
-		IsEmptyOrValidURL("Kubernetes URL", c.Registry.Kubernetes.URL))
+		IsEmptyOrValidURL("Kubernetes URL", c.Registry.Kubernetes.URL),
+		IsEmptyOrValidURL("Consul URL", c.Registry.Consul.URL))
 	for _, url := range c.Registry.Eureka.URLs {
 		validators = append(validators, IsEmptyOrValidURL("Eureka URL", url))
 	}
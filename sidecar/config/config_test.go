@@ -101,6 +101,9 @@ var _ = Describe("Config", func() {
 				"--kubernetes_namespace=default",
 				"--eureka_url=http://eureka1:9001",
 				"--eureka_url=http://eureka2:9002",
+				"--consul_url=http://consul:8500",
+				"--consul_token=67890",
+				"--consul_datacenter=dc1",
 				"--controller_url=http://controller:8080",
 				"--controller_token=local",
 				"--controller_poll=5s",
@@ -132,6 +135,9 @@ var _ = Describe("Config", func() {
 			Expect(c.Registry.Kubernetes.Token).To(Equal("12345"))
 			Expect(c.Registry.Kubernetes.Namespace).To(Equal("default"))
 			Expect(c.Registry.Eureka.URLs).To(And(ContainElement("http://eureka1:9001"), ContainElement("http://eureka2:9002")))
+			Expect(c.Registry.Consul.URL).To(Equal("http://consul:8500"))
+			Expect(c.Registry.Consul.Token).To(Equal("67890"))
+			Expect(c.Registry.Consul.Datacenter).To(Equal("dc1"))
 			Expect(c.Controller.URL).To(Equal("http://controller:8080"))
 			Expect(c.Controller.Token).To(Equal("local"))
 			Expect(c.Controller.Poll).To(Equal(time.Duration(5) * time.Second))
@@ -174,6 +180,9 @@ var _ = Describe("Config", func() {
 			os.Setenv("A8_KUBERNETES_TOKEN", "12345")
 			os.Setenv("A8_KUBERNETES_NAMESPACE", "default")
 			os.Setenv("A8_EUREKA_URL", "http://eureka1:9001,http://eureka2:9002")
+			os.Setenv("A8_CONSUL_URL", "http://consul:8500")
+			os.Setenv("A8_CONSUL_TOKEN", "67890")
+			os.Setenv("A8_CONSUL_DATACENTER", "dc1")
 			os.Setenv("A8_CONTROLLER_URL", "http://controller:8080")
 			os.Setenv("A8_CONTROLLER_TOKEN", "local")
 			os.Setenv("A8_CONTROLLER_POLL", "5s")
@@ -203,6 +212,9 @@ var _ = Describe("Config", func() {
 			os.Unsetenv("A8_KUBERNETES_TOKEN")
 			os.Unsetenv("A8_KUBERNETES_NAMESPACE")
 			os.Unsetenv("A8_EUREKA_URL")
+			os.Unsetenv("A8_CONSUL_URL")
+			os.Unsetenv("A8_CONSUL_TOKEN")
+			os.Unsetenv("A8_CONSUL_DATACENTER")
 			os.Unsetenv("A8_CONTROLLER_URL")
 			os.Unsetenv("A8_CONTROLLER_TOKEN")
 			os.Unsetenv("A8_CONTROLLER_POLL")
@@ -229,6 +241,9 @@ var _ = Describe("Config", func() {
 			Expect(c.Registry.Kubernetes.Token).To(Equal("12345"))
 			Expect(c.Registry.Kubernetes.Namespace).To(Equal("default"))
 			Expect(c.Registry.Eureka.URLs).To(And(ContainElement("http://eureka1:9001"), ContainElement("http://eureka2:9002")))
+			Expect(c.Registry.Consul.URL).To(Equal("http://consul:8500"))
+			Expect(c.Registry.Consul.Token).To(Equal("67890"))
+			Expect(c.Registry.Consul.Datacenter).To(Equal("dc1"))
 			Expect(c.Controller.URL).To(Equal("http://controller:8080"))
 			Expect(c.Controller.Token).To(Equal("local"))
 			Expect(c.Controller.Poll).To(Equal(time.Duration(5) * time.Second))
@@ -284,6 +299,10 @@ registry:
     urls:
       - http://eureka1:9001
       - http://eureka2:9002
+  consul:
+    url:   http://consul:8500
+    token: 67890
+    datacenter: dc1
 
 controller:
   url:   http://controller:8080
@@ -349,6 +368,9 @@ log_level: debug
 			Expect(c.Registry.Kubernetes.Token).To(Equal("12345"))
 			Expect(c.Registry.Kubernetes.Namespace).To(Equal("default"))
 			Expect(c.Registry.Eureka.URLs).To(And(ContainElement("http://eureka1:9001"), ContainElement("http://eureka2:9002")))
+			Expect(c.Registry.Consul.URL).To(Equal("http://consul:8500"))
+			Expect(c.Registry.Consul.Token).To(Equal("67890"))
+			Expect(c.Registry.Consul.Datacenter).To(Equal("dc1"))
 			Expect(c.Controller.URL).To(Equal("http://controller:8080"))
 			Expect(c.Controller.Token).To(Equal("local"))
 			Expect(c.Dnsconfig.Port).To(Equal(4056))
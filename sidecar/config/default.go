@@ -47,6 +47,11 @@ var DefaultConfig = Config{
 		Eureka: EurekaRegistry{
 			URLs: []string{},
 		},
+		Consul: ConsulRegistry{
+			URL:        "",
+			Token:      "",
+			Datacenter: "",
+		},
 
 		Amalgam8Registry: Amalgam8Registry{
 			URL:   "",
@@ -37,6 +37,9 @@ const (
 	kubernetesTokenFlag     = "kubernetes_token"
 	kubernetesNamespaceFlag = "kubernetes_namespace"
 	eurekaURLFlag           = "eureka_url"
+	consulURLFlag           = "consul_url"
+	consulTokenFlag         = "consul_token"
+	consulDatacenterFlag    = "consul_datacenter"
 	controllerURLFlag       = "controller_url"
 	controllerTokenFlag     = "controller_token"
 	controllerPollFlag      = "controller_poll"
@@ -98,7 +101,7 @@ var Flags = []cli.Flag{
 	cli.StringFlag{
 		Name:   registryBackendFlag,
 		EnvVar: envVar(registryBackendFlag),
-		Usage:  "Registry backend type (amalgam8, kubernetes, eureka)",
+		Usage:  "Registry backend type (amalgam8, kubernetes, eureka, consul)",
 	},
 	cli.StringFlag{
 		Name:   registryURLFlag,
@@ -135,6 +138,21 @@ var Flags = []cli.Flag{
 		EnvVar: envVar(eurekaURLFlag),
 		Usage:  "List of Eureka server URLs",
 	},
+	cli.StringFlag{
+		Name:   consulURLFlag,
+		EnvVar: envVar(consulURLFlag),
+		Usage:  "URL for Consul HTTP API",
+	},
+	cli.StringFlag{
+		Name:   consulTokenFlag,
+		EnvVar: envVar(consulTokenFlag),
+		Usage:  "ACL token for Consul HTTP API",
+	},
+	cli.StringFlag{
+		Name:   consulDatacenterFlag,
+		EnvVar: envVar(consulDatacenterFlag),
+		Usage:  "Consul datacenter",
+	},
 	cli.StringFlag{
 		Name:   controllerURLFlag,
 		EnvVar: envVar(controllerURLFlag),
@@ -148,7 +166,7 @@ var Flags = []cli.Flag{
 	cli.DurationFlag{
 		Name:   controllerPollFlag,
 		EnvVar: envVar(controllerPollFlag),
-		Usage:  "Interval for polling Controller",
+		Usage:  "Longest interval between checks of Controller for rule changes",
 	},
 
 	cli.StringFlag{
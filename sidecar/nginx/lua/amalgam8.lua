@@ -25,7 +25,10 @@ local io = require("io");
 -----to be evaluated at high load.
 local Amalgam8 = { _VERSION = '0.4.3' }
 
-local delay_action, abort_action, trace_action = 1, 2, 3
+local delay_action, abort_action, trace_action, mirror_action, rate_limit_action = 1, 2, 3, 4, 5
+
+-- how long a request waits for an instance below its circuit breaker's max_connections, in seconds
+local circuit_breaker_wait, circuit_breaker_wait_step = 1.0, 0.01
 
 local function is_valid_string(input)
    if input and type(input) == 'string' and input ~= '' then
@@ -192,41 +195,89 @@ end
 -- end
 
 
-local function match_header_value(req_headers, header_name, header_val_pattern)
-   local header_value = req_headers[header_name]
-   if header_value then
-      -- ngx_log(ngx_DEBUG, "match_header_value: header_name "..header_name.." value "..header_value.." pattern "..header_val_pattern)
-      local m, err = ngx.re.match(header_value, header_val_pattern, "jo")
-      if m then
-         -- ngx_log(ngx_DEBUG, "header matched for "..header_name.." returning true")
-         return true
+-- path of the request, relative to the destination service
+local function get_request_path()
+   local path = ngx.var.reqpath
+   if not is_valid_string(path) then
+      path = "/"
+   end
+   return path
+end
+
+
+-- a condition is a {kind, name, pattern} triple, created by add_conditions
+local function match_condition(req_headers, condition)
+   local kind, name, pattern = condition[1], condition[2], condition[3]
+   local value
+
+   if kind == "header" then
+      value = req_headers[name]
+   elseif kind == "path_prefix" then
+      return string.sub(get_request_path(), 1, #pattern) == pattern
+   elseif kind == "path_regex" then
+      value = get_request_path()
+   elseif kind == "method" then
+      local method = ngx.req.get_method()
+      for _, m in ipairs(pattern) do
+         if m == method then return true end
       end
+      return false
+   elseif kind == "query" then
+      value = ngx.req.get_uri_args()[name]
+   elseif kind == "cookie" then
+      value = ngx_var["cookie_"..name]
+   end
+
+   -- repeated headers and query parameters have multiple values, any of which may match
+   if type(value) == "table" then
+      for _, v in ipairs(value) do
+         if type(v) == "string" and ngx.re.match(v, pattern, "jo") then return true end
+      end
+      return false
+   end
+
+   -- query parameters without a value are true
+   if type(value) ~= "string" then
+      return false
+   end
+
+   -- ngx_log(ngx_DEBUG, "match_condition: "..kind.." "..name.." value "..value.." pattern "..pattern)
+   local m, err = ngx.re.match(value, pattern, "jo")
+   if m then
+      return true
    end
    return false
 end
 
 
-local function match_headers(req_headers, rule)
+-- all conditions in the list should hold true (AND)
+local function match_all_conditions(req_headers, conditions)
+   for _, c in ipairs(conditions) do
+      if not match_condition(req_headers, c) then return false end
+   end
+   return true
+end
+
+
+local function match_request(req_headers, rule)
    -- r1 = cjson.encode(rule)
    if not rule.match then
-      -- ngx_log(ngx_DEBUG, "match_headers: No rule.match block for rule "..r1)
+      -- ngx_log(ngx_DEBUG, "match_request: No rule.match block for rule "..r1)
       return true
    end --source matched (earlier), destination matched.
    if rule.match.all then
-      -- ngx_log(ngx_DEBUG, "match_headers: rule has match.all block "..r1)
-      for _, kv in ipairs(rule.match.all) do
-         -- ngx_log(ngx_DEBUG, "match_headers: matching header "..k.." value "..v.." for rule.match.all "..r1)
-         if not match_header_value(req_headers, kv[1], kv[2]) then return false end
-      end
+      -- ngx_log(ngx_DEBUG, "match_request: rule has match.all block "..r1)
+      if not match_all_conditions(req_headers, rule.match.all) then return false end
    end
 
    if rule.match.any then
-      -- all conditions should hold true (AND)
-      -- atleast one should pass. (OR).
+      -- the any block is a list of condition groups, one per entry.
+      -- all conditions of a group should hold true (AND)
+      -- atleast one group should pass. (OR).
       local any_match = false
 
-      for _, kv in ipairs(rule.match.any) do
-         if match_header_value(req_headers, kv[1], kv[2]) then
+      for _, group in ipairs(rule.match.any) do
+         if match_all_conditions(req_headers, group) then
             any_match = true
             break
          end
@@ -235,9 +286,9 @@ local function match_headers(req_headers, rule)
    end
 
    if rule.match.none then
-      -- none of the conditions should match
-      for _, kv in ipairs(rule.match.none) do
-         if match_header_value(req_headers, kv[1], kv[2]) then return false end
+      -- no group of conditions should hold true as a whole
+      for _, group in ipairs(rule.match.none) do
+         if match_all_conditions(req_headers, group) then return false end
       end
    end
 
@@ -245,6 +296,41 @@ local function match_headers(req_headers, rule)
 end
 
 
+-- add the request conditions of a match sub block to the list of conditions.
+-- The name is never nil, so that cjson encodes conditions as arrays without holes.
+local function add_conditions(conditions, m)
+   if m.headers then
+      for k,v in pairs(m.headers) do
+         table.insert(conditions, {"header", k, v})
+      end
+   end
+
+   if m.path then
+      if m.path.prefix then
+         table.insert(conditions, {"path_prefix", "", m.path.prefix})
+      elseif m.path.regex then
+         table.insert(conditions, {"path_regex", "", m.path.regex})
+      end
+   end
+
+   if m.method then
+      table.insert(conditions, {"method", "", m.method})
+   end
+
+   if m.query then
+      for k,v in pairs(m.query) do
+         table.insert(conditions, {"query", k, v})
+      end
+   end
+
+   if m.cookies then
+      for k,v in pairs(m.cookies) do
+         table.insert(conditions, {"cookie", k, v})
+      end
+   end
+end
+
+
 local function match_tags(src_tag_string, dst_tag_set) --assumes both are not nil
    -- local empty_src_tags = (string.len(src_tag_string) == 0)
    -- local empty_dst_tags = (not dst_tag_set)
@@ -264,7 +350,8 @@ local function check_and_preprocess_match(myname, mytags, match_type, match_sub_
 
    local is_my_tag_empty = (string.len(mytags) == 0)
    local match_all = false
-   local match_headers = nil
+   local match_conditions = nil
+   local unconditional = false
 
    for _, m in ipairs(match_sub_block) do
       local check1 = false
@@ -294,18 +381,35 @@ local function check_and_preprocess_match(myname, mytags, match_type, match_sub_
          match_all = match_all or match_found
       end
 
-      if match_found and m.headers then
-         if not match_headers then match_headers = {} end
-         for k,v in pairs(m.headers) do
-            table.insert(match_headers, {k, v})
+      if match_found then
+         local conditions = {}
+         add_conditions(conditions, m)
+         if match_type == "all" then
+            -- every condition of every entry should hold, so they are kept in a single list
+            if #conditions > 0 then
+               match_conditions = match_conditions or {}
+               for _, c in ipairs(conditions) do
+                  table.insert(match_conditions, c)
+               end
+            end
+         elseif #conditions > 0 then
+            -- the conditions of an ANY or NONE entry hold together, so each entry is kept as a group
+            match_conditions = match_conditions or {}
+            table.insert(match_conditions, conditions)
+         elseif match_type == "any" then
+            -- an ANY entry without request conditions matches every request
+            unconditional = true
          end
       end
    end
 
    if match_type == "all" then
-      return true, match_headers
+      return true, match_conditions
+   end
+   if unconditional then
+      return match_all, nil
    end
-   return match_all, match_headers
+   return match_all, match_conditions
 end
 
 
@@ -324,33 +428,37 @@ local function is_rule_for_me(myname, mytags, rule)
    if not rule.match then return true end
 
    local match = {}
-   local all_headers, any_headers, none_headers
+   local all_conditions, any_conditions, none_conditions
    local all_res, any_res, none_res
  
-   if rule.match.source or rule.match.headers then
+   if rule.match.source or rule.match.headers or rule.match.path or rule.match.method or rule.match.query or rule.match.cookies then
       match["source"] = rule.match.source
       match["headers"] = rule.match.headers
+      match["path"] = rule.match.path
+      match["method"] = rule.match.method
+      match["query"] = rule.match.query
+      match["cookies"] = rule.match.cookies
       if not rule.match.all then
          rule.match.all = {}
       end
       table.insert(rule.match.all, match)
    end
 
-   all_res, all_headers = check_and_preprocess_match(myname, mytags, "all", rule.match.all)
-   any_res, any_headers = check_and_preprocess_match(myname, mytags, "any", rule.match.any)
-   none_res, none_headers = check_and_preprocess_match(myname, mytags, "none", rule.match.none)
+   all_res, all_conditions = check_and_preprocess_match(myname, mytags, "all", rule.match.all)
+   any_res, any_conditions = check_and_preprocess_match(myname, mytags, "any", rule.match.any)
+   none_res, none_conditions = check_and_preprocess_match(myname, mytags, "none", rule.match.none)
 
    -- either all block or any block must match and nothing in none block should match.
    -- empty blocks amount to nil. We have already taken care of all blocks being empty by checking for empty rule.match
    local res = ((all_res and rule.match.all) or (any_res and rule.match.any)) and not (none_res and rule.match.none)
    if res then
-      if not all_headers and not any_headers and not none_headers then
+      if not all_conditions and not any_conditions and not none_conditions then
          rule.match = nil
       else
          rule.match = {
-            all = all_headers,
-            any = any_headers,
-            none = none_headers
+            all = all_conditions,
+            any = any_conditions,
+            none = none_conditions
          }
       end
    end
@@ -419,13 +527,28 @@ local function create_rule(rule, myname, mytags)
    end
 
    if rule.actions then
-      for _, a in ipairs(rule.actions) do
+      for i, a in ipairs(rule.actions) do
          if a.action == "delay" then
             a.action= delay_action
          elseif a.action == "abort" then
             a.action= abort_action
          elseif a.action == "trace" then
             a.action= trace_action
+         elseif a.action == "mirror" then
+            a.action= mirror_action
+            if not a.probability then
+               a.probability = 1.0
+            end
+            if not a.backend.name then
+               a.backend.name = rule.destination
+            end
+         elseif a.action == "rate_limit" then
+            a.action= rate_limit_action
+            if not a.burst then
+               a.burst = 0
+            end
+            -- identifies the limit in the a8_rate_limits shared dict
+            a.limit_id = tostring(rule.id)..":"..tostring(i)
          else
             ngx_log(ngx_ERR, "Unknown action provided in rule "..a.action)
             return nil
@@ -455,6 +578,276 @@ local function get_unpacked_val(shared_dict, key)
 end
 
 
+-- circuit breaker state is kept in the a8_circuit_breakers shared dict, under keys of the form
+-- <kind>:<ip>:<port> for each instance, where kind is one of "active", "failures" or "ejected"
+local function circuit_breaker_key(kind, instance)
+   return kind..":"..instance.ip..":"..tostring(instance.port)
+end
+
+
+local function is_ejected(instance)
+   return ngx_shared.a8_circuit_breakers:get(circuit_breaker_key("ejected", instance)) ~= nil
+end
+
+
+local function has_capacity(instances, max_connections)
+   for _, i in ipairs(instances) do
+      local active = ngx_shared.a8_circuit_breakers:get(circuit_breaker_key("active", i)) or 0
+      if active < max_connections then return true end
+   end
+   return false
+end
+
+
+-- record the result of a request to an instance, ejecting the instance after too many consecutive failures
+local function record_circuit_breaker_result(cb, instance, failed)
+   local dict = ngx_shared.a8_circuit_breakers
+   dict:incr(circuit_breaker_key("active", instance), -1, 1)
+
+   if not cb.consecutive_5xx then return end
+   if not failed then
+      dict:set(circuit_breaker_key("failures", instance), 0)
+      return
+   end
+
+   local failures = dict:incr(circuit_breaker_key("failures", instance), 1, 0)
+   if failures and failures >= cb.consecutive_5xx then
+      ngx_log(ngx_WARN, "ejecting instance "..instance.ip..":"..tostring(instance.port).." for "..tostring(cb.ejection_duration).."s after "..tostring(failures).." consecutive failures")
+      dict:set(circuit_breaker_key("ejected", instance), ngx.now() + cb.ejection_duration, cb.ejection_duration)
+      dict:set(circuit_breaker_key("failures", instance), 0)
+   end
+end
+
+
+-- remove ejected instances, and wait for an instance with capacity if all instances have
+-- max_connections requests. Exits with HTTP 503 if no instance is available.
+local function apply_circuit_breaker(cb, backend, instances)
+   local available = {}
+   for _, i in ipairs(instances) do
+      if not is_ejected(i) then
+         table.insert(available, i)
+      end
+   end
+
+   if #available == 0 then
+      ngx.status = ngx.HTTP_SERVICE_UNAVAILABLE
+      ngx.exit(ngx.status)
+   end
+
+   if cb.max_connections and not has_capacity(available, cb.max_connections) then
+      local dict = ngx_shared.a8_circuit_breakers
+      local pending_key = "pending:"..create_cookie_version(backend)
+      local pending = dict:incr(pending_key, 1, 0)
+      local ok = false
+
+      if pending and pending <= (cb.max_pending_requests or 0) then
+         local waited = 0
+         while waited < circuit_breaker_wait do
+            ngx.sleep(circuit_breaker_wait_step)
+            waited = waited + circuit_breaker_wait_step
+            if has_capacity(available, cb.max_connections) then
+               ok = true
+               break
+            end
+         end
+      end
+      dict:incr(pending_key, -1, 1)
+
+      if not ok then
+         ngx.status = ngx.HTTP_SERVICE_UNAVAILABLE
+         ngx.exit(ngx.status)
+      end
+   end
+
+   return available
+end
+
+
+-- replace the prefix of the path, if the prefix matches whole path segments
+local function rewrite_uri(path, rewrite)
+   path = path or ""
+   local prefix = rewrite.prefix
+   if string.sub(path, 1, #prefix) ~= prefix then
+      return path
+   end
+
+   local rest = string.sub(path, #prefix + 1)
+   if string.sub(prefix, -1) ~= "/" and rest ~= "" and string.sub(rest, 1, 1) ~= "/" then
+      return path
+   end
+
+   local replacement = rewrite.replacement or ""
+   if string.sub(replacement, -1) == "/" and string.sub(rest, 1, 1) == "/" then
+      rest = string.sub(rest, 2)
+   end
+
+   local uri = replacement..rest
+   if string.sub(uri, 1, 1) ~= "/" then
+      uri = "/"..uri
+   end
+   return uri
+end
+
+
+-- remove, then set, then add request headers
+local function apply_header_operations(ops)
+   if ops.remove then
+      for _, name in ipairs(ops.remove) do
+         ngx.req.clear_header(name)
+      end
+   end
+
+   if ops.set then
+      for name, value in pairs(ops.set) do
+         ngx.req.set_header(name, value)
+      end
+   end
+
+   if ops.add then
+      local current = ngx.req.get_headers()
+      for name, value in pairs(ops.add) do
+         local existing = current[name]
+         if not existing then
+            ngx.req.set_header(name, value)
+         elseif type(existing) == "table" then
+            table.insert(existing, value)
+            ngx.req.set_header(name, existing)
+         else
+            ngx.req.set_header(name, {existing, value})
+         end
+      end
+   end
+end
+
+
+-- headers that are set by send_mirror_request rather than copied from the original request
+local mirror_skip_headers = {
+   ["host"] = true,
+   ["connection"] = true,
+   ["content-length"] = true,
+   ["transfer-encoding"] = true,
+}
+
+
+-- runs in a timer, so the mirrored request does not delay the original request.
+-- The response is read and discarded.
+local function send_mirror_request(premature, instance, method, uri, headers, body)
+   if premature then return end
+
+   local sock = ngx.socket.tcp()
+   sock:settimeout(10000) -- 10 sec
+
+   local ok, err = sock:connect(instance.ip, instance.port)
+   if not ok then
+      ngx_log(ngx_WARN, "failed to connect to mirror "..instance.ip..":"..tostring(instance.port).." : "..err)
+      return
+   end
+
+   if instance.type == 'https' then
+      ok, err = sock:sslhandshake(nil, instance.host, false)
+      if not ok then
+         ngx_log(ngx_WARN, "failed SSL handshake with mirror "..instance.ip..":"..tostring(instance.port).." : "..err)
+         sock:close()
+         return
+      end
+   end
+
+   local req = { method, " ", uri, " HTTP/1.1\r\n",
+                 "Host: ", instance.host or instance.ip, "\r\n",
+                 "Connection: close\r\n",
+                 "Content-Length: ", tostring(#body), "\r\n" }
+   for k, v in pairs(headers) do
+      if not mirror_skip_headers[string.lower(k)] then
+         if type(v) == "table" then
+            v = table.concat(v, ", ")
+         end
+         table.insert(req, k..": "..v.."\r\n")
+      end
+   end
+   table.insert(req, "\r\n")
+   table.insert(req, body)
+
+   _, err = sock:send(req)
+   if err then
+      ngx_log(ngx_WARN, "failed to send request to mirror "..instance.ip..":"..tostring(instance.port).." : "..err)
+      sock:close()
+      return
+   end
+
+   local status_line
+   status_line, err = sock:receive("*l")
+   if err then
+      ngx_log(ngx_WARN, "failed to read response from mirror "..instance.ip..":"..tostring(instance.port).." : "..err)
+   end
+   -- ngx_log(ngx_DEBUG, "mirror responded with "..tostring(status_line))
+   sock:close()
+end
+
+
+-- send a copy of the current request to a random instance of the backend
+local function mirror_request(backend, headers)
+   local instances = get_unpacked_val(ngx_shared.a8_instances, backend.name)
+   if not instances then return end
+
+   local mirror_instances = {}
+   for _, i in ipairs(instances) do
+      if i.tags and match_tags(i.tags, backend.tags) then
+         table.insert(mirror_instances, i)
+      end
+   end
+   if #mirror_instances == 0 then return end
+   local instance = mirror_instances[math.random(#mirror_instances)]
+
+   local uri = ngx.var.reqpath
+   if not is_valid_string(uri) then uri = "/" end
+   if ngx.var.args then uri = uri.."?"..ngx.var.args end
+
+   ngx.req.read_body()
+   local body = ngx.req.get_body_data() or ""
+
+   local ok, err = ngx.timer.at(0, send_mirror_request, instance, ngx.req.get_method(), uri, headers, body)
+   if not ok then
+      ngx_log(ngx_ERR, "failed to create timer for mirror request: "..err)
+   end
+end
+
+
+-- rate limit state is kept in the a8_rate_limits shared dict, as "<excess>:<timestamp>" under keys of the form
+-- <rule id>:<action index>|<key value>. The excess is the number of recent requests that have not yet drained at
+-- the rate of the limit (a leaky bucket). Like the rest of the shared state, updates are not atomic across workers.
+local function is_rate_limited(limit, source, headers)
+   local key = limit.limit_id
+   if limit.key == "source" then
+      key = key.."|"..source
+   elseif limit.key == "header" then
+      local value = headers[limit.header]
+      if type(value) == "table" then value = value[1] end
+      key = key.."|"..(value or "")
+   end
+
+   local dict = ngx_shared.a8_rate_limits
+   local now = ngx.now()
+   local excess = 0
+   local state = dict:get(key)
+   if state then
+      local prev_excess, last = string.match(state, "^([^:]+):(.+)$")
+      excess = math.max(tonumber(prev_excess) - (now - tonumber(last)) * limit.rate, 0)
+   end
+
+   excess = excess + 1
+   if excess > limit.burst + 1 then
+      return true
+   end
+
+   -- the bucket is empty again once the excess has drained
+   local ok, err = dict:set(key, tostring(excess)..":"..tostring(now), excess / limit.rate + 1)
+   if not ok then
+      ngx_log(ngx_ERR, "failed to update rate limit "..key.." : "..err)
+   end
+   return false
+end
+
+
 local function reset_state()
    ngx_shared.a8_instances:flush_all()
    ngx_shared.a8_instances:flush_expired()
@@ -634,7 +1027,8 @@ function Amalgam8:proxy_admin()
       local state = {
          instances = {},
          routes = {},
-         actions = {}
+         actions = {},
+         ejected = {}
       }
 
       -- TODO: This fetches utmost 1024 keys only
@@ -651,6 +1045,13 @@ function Amalgam8:proxy_admin()
       for _,key in ipairs(action_keys) do
          state.actions[key] = get_unpacked_val(ngx_shared.a8_actions, key)
       end
+      -- ejected instances, with the time at which they are restored
+      for _,key in ipairs(ngx_shared.a8_circuit_breakers:get_keys()) do
+         local endpoint = string.match(key, "^ejected:(.+)$")
+         if endpoint then
+            state.ejected[endpoint] = ngx_shared.a8_circuit_breakers:get(key)
+         end
+      end
 
       local output, err = cjson.encode(state)
       if err then
@@ -704,7 +1105,7 @@ function Amalgam8:apply_rules()
       for _, r in ipairs(routes) do --rules are ordered by decreasing priority
          -- r1 = cjson.encode(r)
          -- ngx_log(ngx_DEBUG, "Matching route "..r1)
-         if match_headers(headers, r) then
+         if match_request(headers, r) then
             selected_route = r.route
             break
          end
@@ -786,11 +1187,23 @@ function Amalgam8:apply_rules()
       ngx.var.a8_upstream_name = destination
    end
 
+   if selected_backend and selected_backend.circuit_breaker then
+      selected_instances = apply_circuit_breaker(selected_backend.circuit_breaker, selected_backend, selected_instances)
+      ngx.ctx.a8_circuit_breaker = selected_backend.circuit_breaker
+   end
+
    ngx.ctx.a8_upstreams = selected_instances
    ngx.var.a8_upstream_tags = ""
    if selected_backend then
       ngx.ctx.a8_timeout = selected_backend.timeout
       ngx.ctx.a8_retries = selected_backend.retries
+
+      if selected_backend.rewrite then
+         ngx.var.a8_upstream_path = rewrite_uri(ngx.var.reqpath, selected_backend.rewrite)
+      end
+      if selected_backend.headers then
+         apply_header_operations(selected_backend.headers)
+      end
    end
 
    -- FIXME: By doing the LB in balancer_by_lua and supporting retries,
@@ -812,7 +1225,7 @@ function Amalgam8:apply_rules()
    if actions then
       -- ngx_log(ngx_DEBUG, destination.." has actions "..tostring(#actions))
       for _, a in ipairs(actions) do --rules are ordered by decreasing priority
-         if match_headers(headers, a) then
+         if match_request(headers, a) then
             selected_actions = a.actions
             break
          end
@@ -836,6 +1249,15 @@ function Amalgam8:apply_rules()
             elseif sa.action == trace_action then
                ngx.var.a8_trace_key = sa.log_key
                ngx.var.a8_trace_value = sa.log_value
+            elseif sa.action == mirror_action then
+               if math.random() < sa.probability then
+                  mirror_request(sa.backend, headers)
+               end
+            elseif sa.action == rate_limit_action then
+               if is_rate_limited(sa, self.myname, headers) then
+                  ngx.status = 429 -- Too many requests
+                  ngx.exit(ngx.status)
+               end
             end
          end
       end
@@ -860,9 +1282,14 @@ function Amalgam8:load_balance()
    local selected_instances = ngx.ctx.a8_upstreams
    local timeout = ngx.ctx.a8_timeout
    local retries = ngx.ctx.a8_retries
+   local cb = ngx.ctx.a8_circuit_breaker
 
    -- only retry if last attempt was a failed connection (error/connect timeout/bad headers).
    local state, status = balancer.get_last_failure()
+   if cb and state and ngx.ctx.a8_peer then
+      record_circuit_breaker_result(cb, ngx.ctx.a8_peer, true)
+      ngx.ctx.a8_peer = nil
+   end
    if state and state ~= "failed" then
       return ngx.exit(0)
    end
@@ -882,6 +1309,12 @@ function Amalgam8:load_balance()
    while not upstream and count > 0 do
       pick = math.random(#selected_instances)
       upstream = selected_instances[pick]
+      -- skip instances that are ejected or already have max_connections requests
+      if upstream and cb then
+         if is_ejected(upstream) or (cb.max_connections and not has_capacity({upstream}, cb.max_connections)) then
+            upstream = nil
+         end
+      end
       if upstream then break end
       count = count -1
    end
@@ -903,6 +1336,11 @@ function Amalgam8:load_balance()
    end
    ngx.var.a8_upstream_tags = upstream.tags
 
+   if cb then
+      ngx_shared.a8_circuit_breakers:incr(circuit_breaker_key("active", upstream), 1, 0)
+      ngx.ctx.a8_peer = upstream
+   end
+
    if not retries then
       retries = #selected_instances
    end
@@ -927,6 +1365,16 @@ function Amalgam8:load_balance()
 end
 
 
+-- record the response of the last instance the request was sent to with the circuit breaker
+function Amalgam8:update_circuit_breaker()
+   local cb = ngx.ctx.a8_circuit_breaker
+   local peer = ngx.ctx.a8_peer
+   if not cb or not peer then return end
+
+   record_circuit_breaker_result(cb, peer, ngx.status >= 500)
+end
+
+
 function Amalgam8:get_myname()
    return self.myname
 end
@@ -15,8 +15,6 @@
 package monitor
 
 import (
-	"time"
-
 	"github.com/Sirupsen/logrus"
 	"github.com/amalgam8/amalgam8/controller/client"
 	"github.com/amalgam8/amalgam8/controller/rules"
@@ -29,16 +27,14 @@ type ControllerListener interface {
 
 // ControllerConfig holds configuration options for the controller monitor.
 type ControllerConfig struct {
-	Client       client.Client
-	Listeners    []ControllerListener
-	PollInterval time.Duration
+	Client    client.Client
+	Listeners []ControllerListener
 }
 
 type controllerMonitor struct {
 	controller client.Client
 
-	ticker       *time.Ticker
-	pollInterval time.Duration
+	stop chan struct{}
 
 	revision  int64
 	listeners []ControllerListener
@@ -47,54 +43,36 @@ type controllerMonitor struct {
 // NewControllerMonitor instantiates a new controller monitor
 func NewControllerMonitor(conf ControllerConfig) Monitor {
 	return &controllerMonitor{
-		controller:   conf.Client,
-		listeners:    conf.Listeners,
-		pollInterval: conf.PollInterval,
-		revision:     -1,
+		controller: conf.Client,
+		listeners:  conf.Listeners,
+		revision:   -1,
 	}
 }
 
 // Start monitoring the A8 controller. This is a blocking operation.
 func (c *controllerMonitor) Start() error {
-	// Stop existing ticker if necessary
-	if c.ticker != nil {
+	// Stop existing watch if necessary
+	if c.stop != nil {
 		if err := c.Stop(); err != nil {
-			logrus.WithError(err).Error("Could not stop existing periodic poll")
+			logrus.WithError(err).Error("Could not stop existing watch")
 			return err
 		}
 	}
 
-	// Create new ticker
-	c.ticker = time.NewTicker(c.pollInterval)
-
-	// Do initial poll
-	if err := c.poll(); err != nil {
-		logrus.WithError(err).Error("Poll failed")
-	}
-
-	// Start periodic poll
-	for range c.ticker.C {
-		if err := c.poll(); err != nil {
-			logrus.WithError(err).Error("Poll failed")
-		}
+	// Watch the rules until stopped
+	c.stop = make(chan struct{})
+	for resp := range c.controller.Watch(rules.Filter{}, c.stop) {
+		c.notify(resp)
 	}
 
 	return nil
 }
 
-// poll the A8 controller for changes and notify listeners
-func (c *controllerMonitor) poll() error {
-
-	// Get the latest rules from the A8 controller.
-	resp, err := c.controller.GetRules(rules.Filter{})
-	if err != nil {
-		logrus.WithError(err).Error("Call to controller failed")
-		return err
-	}
-
+// notify listeners of rules from the A8 controller
+func (c *controllerMonitor) notify(resp client.RuleResponse) {
 	// Short-circuit if the controller's revision is not newer than our revision
 	if c.revision >= resp.Revision {
-		return nil
+		return
 	}
 
 	// Update our revision
@@ -106,16 +84,14 @@ func (c *controllerMonitor) poll() error {
 			logrus.WithError(err).Warn("Controller listener failed")
 		}
 	}
-
-	return nil
 }
 
 // Stop monitoring the A8 controller
 func (c *controllerMonitor) Stop() error {
-	// Stop ticker if necessary
-	if c.ticker != nil {
-		c.ticker.Stop()
-		c.ticker = nil
+	// Stop watch if necessary
+	if c.stop != nil {
+		close(c.stop)
+		c.stop = nil
 	}
 
 	return nil
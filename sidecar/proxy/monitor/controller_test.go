@@ -0,0 +1,69 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package monitor
+
+import (
+	"testing"
+
+	"github.com/amalgam8/amalgam8/controller/client"
+	"github.com/amalgam8/amalgam8/controller/rules"
+)
+
+type mockControllerClient struct {
+	client.Client
+	responses []client.RuleResponse
+}
+
+func (m *mockControllerClient) Watch(f rules.Filter, stop <-chan struct{}) <-chan client.RuleResponse {
+	changes := make(chan client.RuleResponse, len(m.responses))
+	for _, resp := range m.responses {
+		changes <- resp
+	}
+	close(changes)
+	return changes
+}
+
+type mockControllerListener struct {
+	changes [][]rules.Rule
+}
+
+func (m *mockControllerListener) RuleChange(rs []rules.Rule) error {
+	m.changes = append(m.changes, rs)
+	return nil
+}
+
+func TestControllerMonitorNotifiesNewRevisions(t *testing.T) {
+	listener := &mockControllerListener{}
+	m := NewControllerMonitor(ControllerConfig{
+		Client: &mockControllerClient{
+			responses: []client.RuleResponse{
+				{Revision: 0, Rules: []rules.Rule{}},
+				{Revision: 2, Rules: []rules.Rule{{ID: "a"}}},
+				{Revision: 2, Rules: []rules.Rule{{ID: "a"}}},
+				{Revision: 1, Rules: []rules.Rule{}},
+				{Revision: 3, Rules: []rules.Rule{{ID: "a"}, {ID: "b"}}},
+			},
+		},
+		Listeners: []ControllerListener{listener},
+	})
+
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(listener.changes) != 3 || len(listener.changes[1]) != 1 || len(listener.changes[2]) != 2 {
+		t.Errorf("unexpected changes %v", listener.changes)
+	}
+}
@@ -38,7 +38,7 @@ type RegistryConfig struct {
 type registryMonitor struct {
 	discovery api.ServiceDiscovery
 
-	ticker       *time.Ticker
+	stop         chan struct{}
 	pollInterval time.Duration
 
 	cache     map[string][]*api.ServiceInstance
@@ -48,9 +48,12 @@ type registryMonitor struct {
 // DefaultRegistryPollInterval is the default used for the registry monitor's poll interval,
 // if no other value is specified. Currently, all existing ServiceDiscovery adapters use caching
 // with background polling, so the 1 second polling here is basically polling a local cache only.
-// Note: this will be removed once the ServiceDiscovery interface exposes a Watch() mechanism.
+// Polling is only used when the ServiceDiscovery does not implement ServiceWatcher, or while watching fails.
 const DefaultRegistryPollInterval = 1 * time.Second
 
+// maxRegistryWatchBackoff is the longest time the registry monitor polls before trying to watch the registry again.
+const maxRegistryWatchBackoff = 1 * time.Minute
+
 // NewRegistryMonitor instantiates a new registry monitor
 func NewRegistryMonitor(conf RegistryConfig) Monitor {
 	if conf.PollInterval == 0 {
@@ -66,16 +69,97 @@ func NewRegistryMonitor(conf RegistryConfig) Monitor {
 
 // Start monitoring registry
 func (m *registryMonitor) Start() error {
-	// Stop existing ticker if necessary
-	if m.ticker != nil {
+	// Stop existing monitoring if necessary
+	if m.stop != nil {
 		if err := m.Stop(); err != nil {
-			logrus.WithError(err).Error("Could not stop existing periodic poll")
+			logrus.WithError(err).Error("Could not stop existing monitoring")
 			return err
 		}
 	}
 
-	// Create new ticker
-	m.ticker = time.NewTicker(m.pollInterval)
+	stop := make(chan struct{})
+	m.stop = stop
+
+	if watcher, ok := m.discovery.(api.ServiceWatcher); ok {
+		m.watch(watcher, stop)
+	} else {
+		m.pollFor(0, stop)
+	}
+
+	return nil
+}
+
+// watch applies changes to the catalog as the registry reports them, until the monitor is stopped.
+// While watching fails, the registry is polled instead, backing off exponentially between attempts to watch.
+func (m *registryMonitor) watch(watcher api.ServiceWatcher, stop <-chan struct{}) {
+	backoff := m.pollInterval
+	for {
+		events, err := watcher.Watch(stop)
+		if err != nil {
+			logrus.WithError(err).Warnf("Could not watch registry, polling for %v", backoff)
+			if !m.pollFor(backoff, stop) {
+				return
+			}
+
+			backoff *= 2
+			if backoff > maxRegistryWatchBackoff {
+				backoff = maxRegistryWatchBackoff
+			}
+			continue
+		}
+		backoff = m.pollInterval
+
+		// Events apply to the catalog as of when watching started, so it is listed only now.
+		// Should that fail, list it again on later events until it succeeds.
+		synced := m.poll() == nil
+		for event := range events {
+			if !synced {
+				synced = m.poll() == nil
+				continue
+			}
+
+			changed := m.apply(event)
+
+			// Apply any further events already delivered, to notify the listeners only once
+		drain:
+			for {
+				select {
+				case event, ok := <-events:
+					if !ok {
+						break drain
+					}
+					changed = m.apply(event) || changed
+				default:
+					break drain
+				}
+			}
+
+			if changed {
+				m.notify()
+			}
+		}
+
+		select {
+		case <-stop:
+			return
+		default:
+			logrus.Info("Registry watch interrupted, resynchronizing catalog")
+		}
+	}
+}
+
+// pollFor polls the registry every poll interval, until the duration elapses or the monitor is stopped.
+// A zero duration polls until the monitor is stopped. Returns false if the monitor was stopped.
+func (m *registryMonitor) pollFor(d time.Duration, stop <-chan struct{}) bool {
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+
+	var expired <-chan time.Time
+	if d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		expired = timer.C
+	}
 
 	// Do initial poll
 	if err := m.poll(); err != nil {
@@ -83,13 +167,18 @@ func (m *registryMonitor) Start() error {
 	}
 
 	// Start periodic poll
-	for range m.ticker.C {
-		if err := m.poll(); err != nil {
-			logrus.WithError(err).Error("Catalog check failed")
+	for {
+		select {
+		case <-stop:
+			return false
+		case <-expired:
+			return true
+		case <-ticker.C:
+			if err := m.poll(); err != nil {
+				logrus.WithError(err).Error("Catalog check failed")
+			}
 		}
 	}
-
-	return nil
 }
 
 // poll registry for changes in the catalog
@@ -112,14 +201,96 @@ func (m *registryMonitor) poll() error {
 	m.cache = catalog
 
 	// Notify the listeners
-	instancesByValue := instanceListAsValues(instances)
+	m.notify()
+
+	return nil
+}
+
+// apply applies the change described by the event to the cached catalog.
+// Return 'true' if the cached catalog changed, and 'false' otherwise.
+func (m *registryMonitor) apply(event *api.Event) bool {
+	instance := event.Instance
+	if instance == nil {
+		return false
+	}
+
+	if m.cache == nil {
+		m.cache = make(map[string][]*api.ServiceInstance)
+	}
+	instances := m.cache[instance.ServiceName]
+	i := sort.Search(len(instances), func(i int) bool { return instances[i].ID >= instance.ID })
+	exists := i < len(instances) && instances[i].ID == instance.ID
+
+	switch event.Type {
+	case api.EventRegister, api.EventStatus:
+		// Polling lists only the instances which are UP, so instances which are not are removed
+		if !isUp(instance.Status) {
+			return m.remove(instances, i, exists)
+		}
+
+		if exists {
+			if instancesEqual(instances[i], instance) {
+				return false
+			}
+			instances[i] = instance
+			return true
+		}
+
+		instances = append(instances, nil)
+		copy(instances[i+1:], instances[i:])
+		instances[i] = instance
+		m.cache[instance.ServiceName] = instances
+		return true
+
+	case api.EventDeregister, api.EventExpire:
+		return m.remove(instances, i, exists)
+
+	default:
+		logrus.Warnf("Ignoring registry event of unknown type %v", event.Type)
+		return false
+	}
+}
+
+// remove removes the i'th instance of a service from the cached catalog, if it exists.
+// Return 'true' if the cached catalog changed, and 'false' otherwise.
+func (m *registryMonitor) remove(instances []*api.ServiceInstance, i int, exists bool) bool {
+	if !exists {
+		return false
+	}
+
+	name := instances[i].ServiceName
+	instances = append(instances[:i], instances[i+1:]...)
+	if len(instances) == 0 {
+		delete(m.cache, name)
+	} else {
+		m.cache[name] = instances
+	}
+	return true
+}
+
+// isUp checks whether an instance with the given status is listed by the registry by default.
+// As in the registry, user-defined statuses are treated as UP.
+func isUp(status string) bool {
+	switch status {
+	case "STARTING", "OUT_OF_SERVICE":
+		return false
+	default:
+		return true
+	}
+}
+
+// notify the listeners of the cached catalog
+func (m *registryMonitor) notify() {
+	instances := make([]api.ServiceInstance, 0, len(m.cache)*3)
+	for _, service := range m.cache {
+		instances = append(instances, instanceListAsValues(service)...)
+	}
+
 	for _, listener := range m.listeners {
-		if err = listener.CatalogChange(instancesByValue); err != nil {
+		if err := listener.CatalogChange(instances); err != nil {
 			logrus.WithError(err).Warn("Registry listener failed")
 		}
 	}
-
-	return nil
 }
 
 // compareToCache compares the given catalog to the cached one, by comparing all instance attributes
@@ -141,14 +312,7 @@ func (m *registryMonitor) compareToCache(catalog map[string][]*api.ServiceInstan
 		}
 
 		for i, instance := range instances {
-			cachedInstance := cachedInstances[i]
-			if instance.ID != cachedInstance.ID ||
-				instance.ServiceName != cachedInstance.ServiceName ||
-				instance.Status != cachedInstance.Status ||
-				instance.Endpoint.Type != cachedInstance.Endpoint.Type ||
-				instance.Endpoint.Value != cachedInstance.Endpoint.Value ||
-				!reflect.DeepEqual(instance.Tags, cachedInstance.Tags) ||
-				!reflect.DeepEqual(instance.Metadata, cachedInstance.Metadata) {
+			if !instancesEqual(instance, cachedInstances[i]) {
 				return false
 			}
 		}
@@ -157,12 +321,23 @@ func (m *registryMonitor) compareToCache(catalog map[string][]*api.ServiceInstan
 	return true
 }
 
+// instancesEqual compares all instance attributes except for heartbeat and TTL.
+func instancesEqual(a, b *api.ServiceInstance) bool {
+	return a.ID == b.ID &&
+		a.ServiceName == b.ServiceName &&
+		a.Status == b.Status &&
+		a.Endpoint.Type == b.Endpoint.Type &&
+		a.Endpoint.Value == b.Endpoint.Value &&
+		reflect.DeepEqual(a.Tags, b.Tags) &&
+		reflect.DeepEqual(a.Metadata, b.Metadata)
+}
+
 // Stop monitoring registry
 func (m *registryMonitor) Stop() error {
-	// Stop ticker if necessary
-	if m.ticker != nil {
-		m.ticker.Stop()
-		m.ticker = nil
+	// Stop polling or watching if necessary
+	if m.stop != nil {
+		close(m.stop)
+		m.stop = nil
 	}
 
 	return nil
@@ -89,3 +89,136 @@ func TestCatalogComparison(t *testing.T) {
 		}
 	}
 }
+
+type mockServiceWatcher struct {
+	instances []*api.ServiceInstance
+	events    []*api.Event
+}
+
+func (m *mockServiceWatcher) ListServices() ([]string, error) {
+	return nil, nil
+}
+
+func (m *mockServiceWatcher) ListInstances() ([]*api.ServiceInstance, error) {
+	return m.instances, nil
+}
+
+func (m *mockServiceWatcher) ListServiceInstances(serviceName string) ([]*api.ServiceInstance, error) {
+	return nil, nil
+}
+
+func (m *mockServiceWatcher) Watch(stop <-chan struct{}) (<-chan *api.Event, error) {
+	events := make(chan *api.Event, len(m.events))
+	for _, event := range m.events {
+		events <- event
+	}
+	go func() {
+		<-stop
+		close(events)
+	}()
+	return events, nil
+}
+
+type mockRegistryListener struct {
+	changes chan []api.ServiceInstance
+}
+
+func (m *mockRegistryListener) CatalogChange(instances []api.ServiceInstance) error {
+	m.changes <- instances
+	return nil
+}
+
+func TestRegistryMonitorAppliesEvents(t *testing.T) {
+	a := &api.ServiceInstance{ID: "a", ServiceName: "ServiceA", Status: "UP"}
+	b := &api.ServiceInstance{ID: "b", ServiceName: "ServiceA", Status: "UP"}
+	c := &api.ServiceInstance{ID: "c", ServiceName: "ServiceB", Status: "UP"}
+	bOut := &api.ServiceInstance{ID: "b", ServiceName: "ServiceA", Status: "OUT_OF_SERVICE"}
+
+	listener := &mockRegistryListener{changes: make(chan []api.ServiceInstance, 10)}
+	r := registryMonitor{
+		discovery: &mockServiceWatcher{
+			instances: []*api.ServiceInstance{a},
+			events: []*api.Event{
+				{Index: 1, Type: api.EventRegister, Instance: b},
+				{Index: 2, Type: api.EventRegister, Instance: a},
+				{Index: 3, Type: api.EventRegister, Instance: c},
+				{Index: 4, Type: api.EventStatus, Instance: bOut},
+				{Index: 5, Type: api.EventDeregister, Instance: a},
+				{Index: 6, Type: api.EventExpire, Instance: c},
+			},
+		},
+		listeners:    []RegistryListener{listener},
+		pollInterval: DefaultRegistryPollInterval,
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		r.watch(r.discovery.(api.ServiceWatcher), stop)
+		close(done)
+	}()
+
+	// The initial listing is followed by a single change for the batch of buffered events
+	initial := <-listener.changes
+	if len(initial) != 1 || initial[0].ID != "a" {
+		t.Errorf("unexpected initial catalog %v", initial)
+	}
+
+	// Instance b is dropped once it is out of service, as it would be by polling
+	final := <-listener.changes
+	if len(final) != 0 {
+		t.Errorf("unexpected final catalog %v", final)
+	}
+
+	close(stop)
+	<-done
+
+	if len(listener.changes) != 0 {
+		t.Errorf("unexpected catalog changes %v", <-listener.changes)
+	}
+}
+
+func TestRegistryEventApplication(t *testing.T) {
+	a := &api.ServiceInstance{ID: "a", ServiceName: "Service"}
+	b := &api.ServiceInstance{ID: "b", ServiceName: "Service"}
+
+	r := registryMonitor{}
+
+	cases := []struct {
+		Event   api.Event
+		Changed bool
+		IDs     []string
+	}{
+		{api.Event{Type: api.EventRegister, Instance: b}, true, []string{"b"}},
+		{api.Event{Type: api.EventRegister, Instance: a}, true, []string{"a", "b"}},
+		{api.Event{Type: api.EventRegister, Instance: a}, false, []string{"a", "b"}},
+		{api.Event{Type: api.EventStatus, Instance: &api.ServiceInstance{ID: "a", ServiceName: "Service", Status: "UP"}},
+			true, []string{"a", "b"}},
+		{api.Event{Type: api.EventStatus, Instance: &api.ServiceInstance{ID: "b", ServiceName: "Service", Status: "OUT_OF_SERVICE"}},
+			true, []string{"a"}},
+		{api.Event{Type: api.EventStatus, Instance: &api.ServiceInstance{ID: "b", ServiceName: "Service", Status: "STARTING"}},
+			false, []string{"a"}},
+		{api.Event{Type: api.EventStatus, Instance: &api.ServiceInstance{ID: "b", ServiceName: "Service", Status: "user-defined"}},
+			true, []string{"a", "b"}},
+		{api.Event{Type: api.EventDeregister, Instance: b}, true, []string{"a"}},
+		{api.Event{Type: api.EventExpire, Instance: b}, false, []string{"a"}},
+		{api.Event{Type: api.EventExpire, Instance: a}, true, nil},
+	}
+	for i, c := range cases {
+		changed := r.apply(&c.Event)
+		if changed != c.Changed {
+			t.Errorf("apply(%v): expected %v, got %v %d", c.Event, c.Changed, changed, i)
+		}
+
+		instances := r.cache["Service"]
+		if len(instances) != len(c.IDs) {
+			t.Errorf("apply(%v): expected %v, got %v %d", c.Event, c.IDs, instances, i)
+			continue
+		}
+		for j, id := range c.IDs {
+			if instances[j].ID != id {
+				t.Errorf("apply(%v): expected %v, got %v %d", c.Event, c.IDs, instances, i)
+			}
+		}
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"io/ioutil"
+	"math"
 	"net/http"
 
 	"errors"
@@ -34,6 +35,10 @@ const clientTimeout = 30 * time.Second
 // Client for NGINX
 type Client interface {
 	Update([]api.ServiceInstance, []rules.Rule) error
+
+	// EjectedInstances returns the endpoints of the instances that circuit breakers have ejected from load
+	// balancing, and the time at which each of them is restored.
+	EjectedInstances() (map[string]time.Time, error)
 }
 
 type client struct {
@@ -97,3 +102,45 @@ func (c *client) Update(newInstances []api.ServiceInstance, newRules []rules.Rul
 
 	return nil
 }
+
+// EjectedInstances queries the NGINX server for the instances ejected by circuit breakers
+func (c *client) EjectedInstances() (map[string]time.Time, error) {
+	req, err := http.NewRequest("GET", c.url+"/a8-admin", nil)
+	if err != nil {
+		logrus.WithError(err).Error("Building request for NGINX server failed")
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to send request to NGINX server")
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := ioutil.ReadAll(resp.Body)
+		logrus.WithFields(logrus.Fields{
+			"body":        string(data),
+			"status_code": resp.StatusCode,
+		}).Error("GET from NGINX server failed")
+		return nil, errors.New("nginx: GET from NGINX server failed")
+	}
+
+	state := struct {
+		Ejected map[string]float64 `json:"ejected"`
+	}{}
+	if err = json.NewDecoder(resp.Body).Decode(&state); err != nil {
+		logrus.WithError(err).Error("Could not unmarshal response body")
+		return nil, err
+	}
+
+	// The NGINX server reports times in seconds since the epoch
+	ejected := make(map[string]time.Time, len(state.Ejected))
+	for endpoint, until := range state.Ejected {
+		sec, frac := math.Modf(until)
+		ejected[endpoint] = time.Unix(int64(sec), int64(frac*float64(time.Second)))
+	}
+
+	return ejected, nil
+}
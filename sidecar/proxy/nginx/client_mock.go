@@ -12,6 +12,8 @@
 package nginx
 
 import (
+	"time"
+
 	"github.com/amalgam8/amalgam8/controller/rules"
 	"github.com/amalgam8/amalgam8/registry/api"
 )
@@ -20,6 +22,9 @@ import (
 type MockClient struct {
 	UpdateError error
 	UpdateCount int
+
+	Ejected      map[string]time.Time
+	EjectedError error
 }
 
 // Update mocks interface
@@ -27,3 +32,8 @@ func (m *MockClient) Update([]api.ServiceInstance, []rules.Rule) error {
 	m.UpdateCount++
 	return m.UpdateError
 }
+
+// EjectedInstances mocks interface
+func (m *MockClient) EjectedInstances() (map[string]time.Time, error) {
+	return m.Ejected, m.EjectedError
+}
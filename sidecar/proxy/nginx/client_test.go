@@ -0,0 +1,89 @@
+// Copyright 2016 IBM Corporation
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package nginx
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/amalgam8/amalgam8/controller/rules"
+	"github.com/amalgam8/amalgam8/registry/api"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client", func() {
+
+	var (
+		server   *httptest.Server
+		body     []byte
+		status   int
+		response string
+	)
+
+	BeforeEach(func() {
+		body = nil
+		status = http.StatusOK
+		response = ""
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.URL.Path).To(Equal("/a8-admin"))
+			body, _ = ioutil.ReadAll(r.Body)
+			w.WriteHeader(status)
+			w.Write([]byte(response))
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("passes rule actions through to NGINX", func() {
+		actions := `[{"action":"mirror","probability":0.1,"tags":["v1"],"backend":{"name":"reviews","tags":["v2"]}},` +
+			`{"action":"rate_limit","rate":10,"burst":5,"key":"header","header":"X-User"}]`
+		a8Rules := []rules.Rule{
+			{
+				ID:          "actions",
+				Destination: "reviews",
+				Actions:     []byte(actions),
+			},
+		}
+
+		Expect(NewClient(server.URL).Update([]api.ServiceInstance{}, a8Rules)).ToNot(HaveOccurred())
+
+		conf := struct {
+			Rules []rules.Rule `json:"rules"`
+		}{}
+		Expect(json.Unmarshal(body, &conf)).ToNot(HaveOccurred())
+		Expect(conf.Rules).To(HaveLen(1))
+		Expect(conf.Rules[0].Actions).To(MatchJSON(actions))
+	})
+
+	It("reports an error when NGINX rejects the update", func() {
+		status = http.StatusBadRequest
+		Expect(NewClient(server.URL).Update([]api.ServiceInstance{}, []rules.Rule{})).To(HaveOccurred())
+	})
+
+	It("returns the instances ejected by circuit breakers", func() {
+		response = `{"instances":{},"routes":{},"actions":{},"ejected":{"10.0.0.1:8080":1476700000.5}}`
+
+		ejected, err := NewClient(server.URL).EjectedInstances()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ejected).To(HaveLen(1))
+		Expect(ejected["10.0.0.1:8080"].Equal(time.Unix(1476700000, 500000000))).To(BeTrue())
+	})
+})
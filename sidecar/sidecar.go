@@ -28,6 +28,7 @@ import (
 	"github.com/amalgam8/amalgam8/controller/rules"
 	"github.com/amalgam8/amalgam8/pkg/auth"
 	"github.com/amalgam8/amalgam8/pkg/version"
+	"github.com/amalgam8/amalgam8/registry/adapters/consul"
 	"github.com/amalgam8/amalgam8/registry/adapters/eureka"
 	"github.com/amalgam8/amalgam8/registry/adapters/kubernetes"
 	registryapi "github.com/amalgam8/amalgam8/registry/api"
@@ -127,7 +128,7 @@ func Run(conf config.Config) error {
 
 	var lifecycle register.Lifecycle
 	if conf.Register {
-		registry, err := buildServiceRegistry(&conf)
+		registry, err := buildServiceRegistry(&conf, discovery)
 		if err != nil {
 			return err
 		}
@@ -177,7 +178,7 @@ func Run(conf config.Config) error {
 	return nil
 }
 
-func buildServiceRegistry(conf *config.Config) (registryapi.ServiceRegistry, error) {
+func buildServiceRegistry(conf *config.Config, discovery registryapi.ServiceDiscovery) (registryapi.ServiceRegistry, error) {
 	switch strings.ToLower(conf.Registry.Backend) {
 	case config.Amalgam8Backend:
 		regConf := registryclient.Config{
@@ -185,6 +186,15 @@ func buildServiceRegistry(conf *config.Config) (registryapi.ServiceRegistry, err
 			AuthToken: conf.Registry.Amalgam8.Token,
 		}
 		return registryclient.New(regConf)
+	case config.EurekaBackend:
+		// Share the discovery adapter, if any, rather than polling eureka twice
+		if adapter, ok := discovery.(*eureka.Adapter); ok {
+			return adapter, nil
+		}
+		eurConf := eureka.Config{
+			URLs: conf.Registry.Eureka.URLs,
+		}
+		return eureka.New(eurConf)
 	case "":
 		return nil, fmt.Errorf("no service registry backend specified")
 	default:
@@ -215,6 +225,13 @@ func buildServiceDiscovery(conf *config.Config) (registryapi.ServiceDiscovery, e
 			URLs: conf.Registry.Eureka.URLs,
 		}
 		return eureka.New(eurConf)
+	case config.ConsulBackend:
+		consulConf := consul.Config{
+			URL:        conf.Registry.Consul.URL,
+			Token:      conf.Registry.Consul.Token,
+			Datacenter: conf.Registry.Consul.Datacenter,
+		}
+		return consul.New(consulConf)
 	case "":
 		return nil, fmt.Errorf("no service discovery backend specified")
 	default:
@@ -235,8 +252,9 @@ func startProxy(conf *config.Config, discovery registryapi.ServiceDiscovery) err
 	nginxProxy := proxy.NewNGINXProxy(nginxManager)
 
 	controllerClient, err := controllerclient.New(controllerclient.Config{
-		URL:       conf.Controller.URL,
-		AuthToken: conf.Controller.Token,
+		URL:          conf.Controller.URL,
+		AuthToken:    conf.Controller.Token,
+		WatchTimeout: conf.Controller.Poll,
 	})
 	if err != nil {
 		logrus.WithError(err).Error("Could not create controller client")
@@ -248,7 +266,6 @@ func startProxy(conf *config.Config, discovery registryapi.ServiceDiscovery) err
 		Listeners: []monitor.ControllerListener{
 			nginxProxy,
 		},
-		PollInterval: conf.Controller.Poll,
 	})
 
 	registryMonitor := monitor.NewRegistryMonitor(monitor.RegistryConfig{
@@ -269,7 +286,7 @@ func startProxy(conf *config.Config, discovery registryapi.ServiceDiscovery) err
 		}
 	}()
 
-	debugger := api.NewDebugAPI(nginxProxy)
+	debugger := api.NewDebugAPI(nginxProxy, nginxClient)
 
 	a := rest.NewApi()
 	a.Use(
@@ -309,6 +326,7 @@ type NGINXState struct {
 	Routes    map[string][]rules.Rule `json:"routes"`
 	Instances map[string][]Instance   `json:"instances"`
 	Actions   map[string][]rules.Rule `json:"actions"`
+	Ejected   map[string]float64      `json:"ejected"`
 }
 
 func cliCommand(command string) {